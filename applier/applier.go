@@ -8,6 +8,7 @@ import (
 
 	log "github.com/sirupsen/logrus"
 	"github.com/skeema/skeema/fs"
+	"github.com/skeema/skeema/util"
 	"github.com/skeema/tengo"
 )
 
@@ -60,7 +61,7 @@ func Worker(ctx context.Context, targetGroups <-chan TargetGroup, results chan<-
 			if configFlavor := tengo.NewFlavor(t.Dir.Config.Get("flavor")); configFlavor != tengo.FlavorUnknown {
 				mods.Flavor = configFlavor
 			} else {
-				mods.Flavor = t.Instance.Flavor()
+				mods.Flavor = util.InstanceFlavor(t.Instance)
 			}
 
 			// Build DDLStatements for each ObjectDiff, handling pre-execution errors
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/skeema/skeema/fs"
+	"github.com/skeema/skeema/util"
 	"github.com/skeema/skeema/workspace"
 	"github.com/skeema/tengo"
 )
@@ -30,7 +31,7 @@ func VerifyDiff(diff *tengo.SchemaDiff, t *Target) error {
 		StrictIndexOrder:       true, // needed since we must get the SHOW CREATE TABLEs to match
 		StrictForeignKeyNaming: true, // ditto
 		AllowUnsafe:            true, // needed since we're just running against the temp schema
-		Flavor:                 t.Instance.Flavor(),
+		Flavor:                 util.InstanceFlavor(t.Instance),
 	}
 	if major, minor, _ := t.Instance.Version(); major != 5 || minor != 5 {
 		// avoid having MySQL ignore index changes that are simply reordered, but only
@@ -8,6 +8,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/skeema/mybase"
 	"github.com/skeema/skeema/fs"
+	"github.com/skeema/skeema/util"
 	"github.com/skeema/tengo"
 )
 
@@ -82,7 +83,7 @@ func AddEnvHandler(cfg *mybase.Config) error {
 	} else {
 		dir.OptionFile.SetOptionValue(environment, "port", strconv.Itoa(inst.Port))
 	}
-	if flavor := inst.Flavor(); flavor != tengo.FlavorUnknown {
+	if flavor := util.InstanceFlavor(inst); flavor != tengo.FlavorUnknown {
 		dir.OptionFile.SetOptionValue(environment, "flavor", flavor.String())
 	}
 	for _, persistOpt := range []string{"user", "ignore-schema", "ignore-table", "connect-options"} {
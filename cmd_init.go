@@ -11,6 +11,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/skeema/mybase"
 	"github.com/skeema/skeema/fs"
+	"github.com/skeema/skeema/util"
 	"github.com/skeema/tengo"
 )
 
@@ -108,7 +109,7 @@ func InitHandler(cfg *mybase.Config) error {
 	} else {
 		hostOptionFile.SetOptionValue(environment, "port", strconv.Itoa(inst.Port))
 	}
-	if flavor := inst.Flavor(); flavor != tengo.FlavorUnknown {
+	if flavor := util.InstanceFlavor(inst); flavor != tengo.FlavorUnknown {
 		hostOptionFile.SetOptionValue(environment, "flavor", flavor.String())
 	}
 	for _, persistOpt := range []string{"user", "ignore-schema", "ignore-table", "connect-options"} {
@@ -227,7 +228,11 @@ func PopulateSchemaDir(s *tengo.Schema, parentDir *fs.Dir, makeSubdir bool) erro
 			continue
 		}
 		createStmt = fs.AddDelimiter(createStmt)
-		filePath := path.Join(subPath, fmt.Sprintf("%s.sql", key.Name))
+		fileName, err := fs.FileNameForObject(parentDir.Config.Get("schema-file-pattern"), key.Name)
+		if err != nil {
+			return NewExitValue(CodeBadConfig, "%s", err)
+		}
+		filePath := path.Join(subPath, fileName)
 		var bytesWritten int
 		if bytesWritten, _, err = fs.AppendToFile(filePath, createStmt); err != nil {
 			return NewExitValue(CodeCantCreate, "Unable to write to %s: %s", filePath, err)
@@ -45,8 +45,7 @@ func LintHandler(cfg *mybase.Config) error {
 	}
 
 	result := lintWalker(dir, 5)
-	switch {
-	case len(result.Exceptions) > 0:
+	if len(result.Exceptions) > 0 {
 		exitCode := CodeFatalError
 		for _, err := range result.Exceptions {
 			if _, ok := err.(linter.ConfigError); ok {
@@ -54,11 +53,18 @@ func LintHandler(cfg *mybase.Config) error {
 			}
 		}
 		return NewExitValue(exitCode, "Skipped %d operations due to fatal errors", len(result.Exceptions))
-	case len(result.Errors) > 0:
+	}
+
+	annotations := make([]*linter.Annotation, 0, len(result.Errors)+len(result.Warnings))
+	annotations = append(annotations, result.Errors...)
+	annotations = append(annotations, result.Warnings...)
+	switch linter.ResultExitCode(annotations) {
+	case linter.ExitCodeErrors:
 		return NewExitValue(CodeFatalError, "Found %d errors", len(result.Errors))
-	case len(result.Warnings) > 0:
+	case linter.ExitCodeWarnings:
 		return NewExitValue(CodeDifferencesFound, "Found %d warnings", len(result.Warnings))
-	case len(result.FormatNotices) > 0:
+	}
+	if len(result.FormatNotices) > 0 {
 		return NewExitValue(CodeDifferencesFound, "")
 	}
 	return nil
@@ -69,7 +75,7 @@ func lintWalker(dir *fs.Dir, maxDepth int) (result *linter.Result) {
 
 	// Connect to first defined instance, unless configured to use local Docker
 	var inst *tengo.Instance
-	if wsType, _ := dir.Config.GetEnum("workspace", "temp-schema", "docker"); wsType != "docker" || !dir.Config.Changed("flavor") {
+	if wsType, _ := dir.Config.GetEnum("workspace", "temp-schema", "docker", "read-only"); wsType != "docker" || !dir.Config.Changed("flavor") {
 		var err error
 		if inst, err = dir.FirstInstance(); err != nil {
 			result = linter.BadConfigResult(err)
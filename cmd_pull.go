@@ -10,6 +10,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/skeema/mybase"
 	"github.com/skeema/skeema/fs"
+	"github.com/skeema/skeema/util"
 	"github.com/skeema/skeema/workspace"
 	"github.com/skeema/tengo"
 )
@@ -170,6 +171,11 @@ func pullSchemaDir(dir *fs.Dir, instance *tengo.Instance, instSchema *tengo.Sche
 		log.Infof("Wrote %s -- updated schema-level default-character-set and default-collation", dir.OptionFile.Path())
 	}
 
+	// If the dir keeps all of its definitions in one file, new objects will be
+	// appended to that file, instead of being written to one file per object.
+	// This is cleared below if the rewrites delete the file.
+	singleFilePath := logicalSchema.SingleFilePath()
+
 	// Iterate through the objects that have create statements in the filesystem,
 	// and compare to instSchema. Track which files need rewrites.
 	filesToRewrite := make(map[*fs.TokenizedSQLFile]bool)
@@ -207,6 +213,9 @@ func pullSchemaDir(dir *fs.Dir, instance *tengo.Instance, instSchema *tengo.Sche
 			return err
 		} else if bytesWritten == 0 {
 			log.Infof("Deleted %s -- no longer exists", file)
+			if file.Path() == singleFilePath {
+				singleFilePath = ""
+			}
 		} else {
 			log.Infof("Wrote %s (%d bytes) -- updated definition", file, bytesWritten)
 		}
@@ -221,7 +230,14 @@ func pullSchemaDir(dir *fs.Dir, instance *tengo.Instance, instSchema *tengo.Sche
 		if key.Type == tengo.ObjectTypeTable && ignoreTable != nil && ignoreTable.MatchString(key.Name) {
 			continue
 		}
-		filePath := path.Join(dir.Path, fmt.Sprintf("%s.sql", key.Name))
+		filePath := singleFilePath
+		if filePath == "" {
+			fileName, err := fs.FileNameForObject(dir.Config.Get("schema-file-pattern"), key.Name)
+			if err != nil {
+				return NewExitValue(CodeBadConfig, "%s", err)
+			}
+			filePath = path.Join(dir.Path, fileName)
+		}
 		contents := instCreate
 		if key.Type == tengo.ObjectTypeTable && !dir.Config.GetBool("include-auto-inc") {
 			contents, _ = tengo.ParseCreateAutoInc(contents)
@@ -263,7 +279,7 @@ func statementModifiersForPull(config *mybase.Config, instance *tengo.Instance,
 	if configFlavor := tengo.NewFlavor(config.Get("flavor")); configFlavor != tengo.FlavorUnknown {
 		mods.Flavor = configFlavor
 	} else {
-		mods.Flavor = instance.Flavor()
+		mods.Flavor = util.InstanceFlavor(instance)
 	}
 	return mods
 }
@@ -310,11 +326,16 @@ func objectsInDiff(instSchema *tengo.Schema, logicalSchema *fs.LogicalSchema, op
 // updateFlavor updates the dir's .skeema option file if the instance's current
 // flavor does not match what's in the file. However, it leaves the value in the
 // file alone if it's specified and we're unable to detect the instance's
-// vendor, as this gives operators the ability to manually override an
-// undetectable flavor.
+// vendor or version, as this gives operators the ability to manually override
+// an undetectable flavor. Similarly, if the instance is a fork such as Aurora
+// or TiDB, an existing value in the file is left alone, since the detected
+// flavor only approximates the fork's behavior.
 func updateFlavor(dir *fs.Dir, instance *tengo.Instance) {
-	instFlavor := instance.Flavor()
-	if instFlavor.Vendor == tengo.VendorUnknown || instFlavor.String() == dir.Config.Get("flavor") {
+	instFlavor := util.InstanceFlavor(instance)
+	if instFlavor.Vendor == tengo.VendorUnknown || instFlavor.Major == 0 || instFlavor.String() == dir.Config.Get("flavor") {
+		return
+	}
+	if util.InstanceFork(instance) != util.ForkNone && dir.Config.Changed("flavor") {
 		return
 	}
 	dir.OptionFile.SetOptionValue(dir.Config.Get("environment"), "flavor", instFlavor.String())
@@ -2,7 +2,6 @@ package fs
 
 import (
 	"fmt"
-	"io/ioutil"
 	"net/url"
 	"os"
 	"path"
@@ -23,7 +22,7 @@ type Dir struct {
 	Path              string
 	Config            *mybase.Config
 	OptionFile        *mybase.File
-	SQLFiles          []SQLFile
+	SQLFiles          []SQLFile        // sorted by file name
 	LogicalSchemas    []*LogicalSchema // for now, always 0 or 1 elements; 2+ in same dir to be supported in future
 	IgnoredStatements []*Statement     // statements with unknown type / not supported by this package
 }
@@ -59,6 +58,26 @@ func (logicalSchema *LogicalSchema) AddStatement(stmt *Statement) error {
 	return fmt.Errorf("AddStatement: unsupported statement type %d in %+v", stmt.Type, stmt)
 }
 
+// SingleFilePath returns the path of the file containing all of the logical
+// schema's CREATE statements, if there are at least two CREATEs and they all
+// come from the same file. This indicates the user prefers keeping all
+// definitions in one file, rather than the usual layout of one file per object.
+// Otherwise, an empty string is returned.
+func (logicalSchema *LogicalSchema) SingleFilePath() string {
+	var filePath string
+	for _, stmt := range logicalSchema.Creates {
+		if filePath == "" {
+			filePath = stmt.File
+		} else if stmt.File != filePath {
+			return ""
+		}
+	}
+	if len(logicalSchema.Creates) < 2 {
+		return ""
+	}
+	return filePath
+}
+
 // ParseDir parses the specified directory, including all *.sql files in it,
 // its .skeema config file, and all .skeema config files of its parent
 // directory hierarchy. Evaluation of parent dirs stops once we hit either a
@@ -121,6 +140,34 @@ func (dir *Dir) Delete() error {
 	return os.RemoveAll(dir.Path)
 }
 
+// CreateSubdirWithCleanup creates a new subdirectory of dir with the supplied
+// name, and then calls populate on it, typically to write out a .skeema option
+// file and *.sql files. If populate returns an error, or the populated subdir
+// cannot be parsed, the subdir and anything written to it are deleted, so that
+// a failure does not leave behind a partially-populated directory. An error is
+// returned if the subdir already exists. The returned subdir's Config cascades
+// from dir's Config, in the same manner as Subdirs.
+func (dir *Dir) CreateSubdirWithCleanup(name string, populate func(*Dir) error) (*Dir, error) {
+	sub := &Dir{
+		Path:   path.Join(dir.Path, name),
+		Config: dir.Config.Clone(),
+	}
+	if err := os.Mkdir(sub.Path, 0777); err != nil {
+		return nil, err
+	}
+	err := populate(sub)
+	if err == nil {
+		err = sub.parseContents()
+	}
+	if err != nil {
+		if delErr := sub.Delete(); delErr != nil {
+			log.Warnf("Unable to clean up directory %s: %s", sub.Path, delErr)
+		}
+		return nil, err
+	}
+	return sub, nil
+}
+
 // HasFile returns true if the specified filename exists in dir.
 func (dir *Dir) HasFile(name string) (bool, error) {
 	_, err := os.Stat(path.Join(dir.Path, name))
@@ -132,27 +179,67 @@ func (dir *Dir) HasFile(name string) (bool, error) {
 	return false, err
 }
 
+// IsEmpty returns true if dir is effectively empty from Skeema's perspective:
+// it contains no *.sql files (or other files matching the schema-file-pattern
+// option), and no subdirectories containing a .skeema file. Hidden files and
+// subdirectories, such as .gitignore or .git, are ignored, as is dir's own
+// .skeema file. This method reads the directory's current contents from the
+// filesystem, rather than relying on any previously-parsed state.
+func (dir *Dir) IsEmpty() (bool, error) {
+	pattern := dir.Config.Get("schema-file-pattern")
+	entries, err := os.ReadDir(dir.Path)
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if name[0] == '.' {
+			continue
+		}
+		if entry.IsDir() {
+			if _, err := os.Stat(path.Join(dir.Path, name, ".skeema")); err == nil {
+				return false, nil
+			} else if !os.IsNotExist(err) {
+				return false, err
+			}
+		} else if matched, err := path.Match(pattern, name); err != nil {
+			return false, fmt.Errorf("Invalid file pattern %q: %s", pattern, err)
+		} else if matched {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
 // Subdirs reads the list of direct, non-hidden subdirectories of dir, parses
 // them (*.sql and .skeema files), and returns them. An error will be returned
 // if there are problems reading dir's the directory list. Otherwise, err is
 // nil but the returned int is a count of subdirs that had problems being read
-// or parsed.
+// or parsed. The returned subdirs are always sorted by name.
 func (dir *Dir) Subdirs() ([]*Dir, int, error) {
-	fileInfos, err := ioutil.ReadDir(dir.Path)
+	return dir.subdirs(false)
+}
+
+// subdirs implements Subdirs. If stopOnError is true, the first subdir parsing
+// error is returned as an error, instead of being logged and counted.
+func (dir *Dir) subdirs(stopOnError bool) ([]*Dir, int, error) {
+	entries, err := os.ReadDir(dir.Path)
 	if err != nil {
 		return nil, 0, err
 	}
 
-	result := make([]*Dir, 0, len(fileInfos))
+	result := make([]*Dir, 0, len(entries))
 	var badSubdirCount int
-	for _, fi := range fileInfos {
-		if fi.IsDir() && fi.Name()[0] != '.' {
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name()[0] != '.' {
 			sub := &Dir{
-				Path:   path.Join(dir.Path, fi.Name()),
+				Path:   path.Join(dir.Path, entry.Name()),
 				Config: dir.Config.Clone(),
 			}
 			subErr := sub.parseContents()
-			if subErr != nil {
+			if subErr != nil && stopOnError {
+				return nil, badSubdirCount + 1, fmt.Errorf("%s: %s", sub.Path, subErr)
+			} else if subErr != nil {
 				log.Warnf("%s: %s", sub.Path, subErr)
 				badSubdirCount++
 			} else {
@@ -163,6 +250,56 @@ func (dir *Dir) Subdirs() ([]*Dir, int, error) {
 	return result, badSubdirCount, nil
 }
 
+// Walk traverses dir and all of its non-hidden descendant directories,
+// calling fn on each one, with parent dirs visited before their subdirs. Each
+// subdir's Config is a clone of its parent's Config with the subdir's own
+// option file (if any) layered on top, so option values cascade down the tree.
+// If fn returns an error, the walk stops and that error is returned. If a
+// subdir cannot be parsed, the behavior depends on continueOnError: if true,
+// the problem is logged and the subdir (along with its descendants) is skipped;
+// if false, the walk stops and the parsing error is returned.
+func (dir *Dir) Walk(fn func(*Dir) error, continueOnError bool) error {
+	if err := fn(dir); err != nil {
+		return err
+	}
+	subdirs, _, err := dir.subdirs(!continueOnError)
+	if err != nil {
+		return err
+	}
+	for _, sub := range subdirs {
+		if err := sub.Walk(fn, continueOnError); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LeafDirs recursively descends from dir, returning dir and all of its
+// descendants that map to at least one schema, as determined by HasSchema.
+// Hidden subdirectories are skipped, and symlinks to directories are not
+// followed, consistent with Subdirs. The int return value is the total number
+// of subdirectories that could not be parsed; these are logged and skipped
+// rather than being treated as a fatal error.
+func (dir *Dir) LeafDirs() ([]*Dir, int, error) {
+	var result []*Dir
+	if dir.HasSchema() {
+		result = append(result, dir)
+	}
+	subdirs, badSubdirCount, err := dir.Subdirs()
+	if err != nil {
+		return nil, badSubdirCount, err
+	}
+	for _, sub := range subdirs {
+		subLeaves, subBadCount, err := sub.LeafDirs()
+		badSubdirCount += subBadCount
+		if err != nil {
+			return nil, badSubdirCount, err
+		}
+		result = append(result, subLeaves...)
+	}
+	return result, badSubdirCount, nil
+}
+
 // Instances returns 0 or more tengo.Instance pointers, based on the
 // directory's configuration. The Instances will NOT be checked for
 // connectivity. However, if the configuration is invalid (for example, illegal
@@ -177,17 +314,24 @@ func (dir *Dir) Instances() ([]*tengo.Instance, error) {
 	}
 
 	// Before looping over hostnames, do a single lookup of user, password,
-	// connect-options, port, socket.
-	var userAndPass string
-	if !dir.Config.Changed("password") {
-		userAndPass = dir.Config.Get("user")
-	} else {
-		userAndPass = fmt.Sprintf("%s:%s", dir.Config.Get("user"), dir.Config.Get("password"))
-	}
+	// connect-options, ssl options, port, socket.
 	params, err := dir.InstanceDefaultParams()
 	if err != nil {
 		return nil, fmt.Errorf("Invalid connection options: %s", err)
 	}
+	paramValues, _ := url.ParseQuery(params) // no error possible, since InstanceDefaultParams already encoded it
+	tlsOpts, err := util.TLSOptionsForConfig(dir.Config)
+	if err != nil {
+		return nil, err
+	}
+	if tlsParam, err := tlsOpts.DSNParam(); err != nil {
+		return nil, err
+	} else if tlsParam != "" {
+		if paramValues.Get("tls") != "" {
+			return nil, fmt.Errorf("Invalid connection options: tls cannot be specified in connect-options when also using ssl options")
+		}
+		paramValues.Set("tls", tlsParam)
+	}
 	portValue := dir.Config.GetIntOrDefault("port")
 	portWasSupplied := dir.Config.Supplied("port")
 	portIsntDefault := dir.Config.Changed("port")
@@ -220,13 +364,16 @@ func (dir *Dir) Instances() ([]*tengo.Instance, error) {
 	// For each hostname, construct a DSN and use it to create an Instance
 	var instances []*tengo.Instance
 	for _, host := range hosts {
-		var dsn string
+		builder := util.NewDSNBuilder().User(dir.Config.Get("user")).Params(paramValues)
+		if dir.Config.Changed("password") {
+			builder.Password(dir.Config.Get("password"))
+		}
 		thisPortValue := portValue
 		// TODO also support cloudsql DSNs
 		if host == "localhost" && (socketWasSupplied || !portWasSupplied) {
-			dsn = fmt.Sprintf("%s@unix(%s)/?%s", userAndPass, socketValue, params)
+			builder.Socket(socketValue)
 		} else {
-			splitHost, splitPort, err := tengo.SplitHostOptionalPort(host)
+			splitHost, splitPort, err := util.SplitHostOptionalPort(host)
 			if err != nil {
 				return nil, err
 			}
@@ -237,15 +384,14 @@ func (dir *Dir) Instances() ([]*tengo.Instance, error) {
 				host = splitHost
 				thisPortValue = splitPort
 			}
-			dsn = fmt.Sprintf("%s@tcp(%s:%d)/?%s", userAndPass, host, thisPortValue, params)
+			builder.Host(host).Port(thisPortValue)
 		}
-		instance, err := util.NewInstance("mysql", dsn)
+		instance, err := util.NewInstance("mysql", builder.Build())
 		if err != nil || instance == nil {
 			if dir.Config.Changed("password") {
-				safeUserPass := fmt.Sprintf("%s:*****", dir.Config.Get("user"))
-				dsn = strings.Replace(dsn, userAndPass, safeUserPass, 1)
+				builder.Password("*****")
 			}
-			return nil, fmt.Errorf("Invalid connection information for %s (DSN=%s): %s", dir, dsn, err)
+			return nil, fmt.Errorf("Invalid connection information for %s (DSN=%s): %s", dir, builder.Build(), err)
 		}
 		instances = append(instances, instance)
 	}
@@ -442,12 +588,19 @@ func (dir *Dir) parseContents() error {
 		if dir.OptionFile, err = parseOptionFile(dir.Path, dir.Config); err != nil {
 			return err
 		}
+		included, err := includedOptionFiles(dir.OptionFile, dir.Config, nil)
+		if err != nil {
+			return err
+		}
+		for _, f := range included {
+			dir.Config.AddSource(f)
+		}
 		dir.Config.AddSource(dir.OptionFile)
 	}
 
 	// Tokenize and parse any *.sql files
 	var err error
-	if dir.SQLFiles, err = sqlFiles(dir.Path); err != nil {
+	if dir.SQLFiles, err = dir.SQLFilesMatching(dir.Config.Get("schema-file-pattern")); err != nil {
 		return err
 	}
 	for _, sf := range dir.SQLFiles {
@@ -460,6 +613,7 @@ func (dir *Dir) parseContents() error {
 		for _, stmt := range tokenizedFile.Statements {
 			if _, ok := logicalSchemasByName[stmt.Schema()]; !ok {
 				logicalSchemasByName[stmt.Schema()] = &LogicalSchema{
+					Name:    stmt.Schema(),
 					Creates: make(map[tengo.ObjectKey]*Statement),
 				}
 			}
@@ -508,7 +662,8 @@ func (dir *Dir) parseContents() error {
 // direct parent File last. The return value excludes dirPath's file, as well
 // as the home directory's, as these are presumed to be parsed elsewhere.
 // The files will be read and parsed, using baseConfig to know which options
-// are defined and valid.
+// are defined and valid. Any files pulled in via the "include" option are
+// returned immediately before the file that included them.
 func ParentOptionFiles(dirPath string, baseConfig *mybase.Config) ([]*mybase.File, error) {
 	cleaned, err := filepath.Abs(filepath.Clean(dirPath))
 	if err != nil {
@@ -517,7 +672,7 @@ func ParentOptionFiles(dirPath string, baseConfig *mybase.Config) ([]*mybase.Fil
 	cleaned = strings.TrimRight(cleaned, "/") // Prevent strings.Split from spitting out 2 blank strings for root dir
 
 	components := strings.Split(cleaned, string(os.PathSeparator))
-	files := make([]*mybase.File, 0, len(components)-1)
+	groups := make([][]*mybase.File, 0, len(components)-1)
 
 	// Examine parent dirs, going up one level at a time, stopping early if we
 	// hit either the user's home directory or a directory containing a .git subdir.
@@ -529,15 +684,15 @@ func ParentOptionFiles(dirPath string, baseConfig *mybase.Config) ([]*mybase.Fil
 			// We already read ~/.skeema as a global file
 			break
 		}
-		fileInfos, err := ioutil.ReadDir(curPath)
+		entries, err := os.ReadDir(curPath)
 		// If we hit a dir we cannot read, halt early but don't consider this fatal
 		if err != nil {
 			break
 		}
-		for _, fi := range fileInfos {
-			if fi.Name() == ".git" {
+		for _, entry := range entries {
+			if entry.Name() == ".git" {
 				atRepoRoot = true
-			} else if fi.Name() == ".skeema" && n < len(components)-1 {
+			} else if entry.Name() == ".skeema" && n < len(components)-1 {
 				// The second part of the above conditional ensures we ignore dirPath's own
 				// .skeema file, since that is handled in Dir.parseContents() to save as
 				// dir.OptionFile.
@@ -545,20 +700,111 @@ func ParentOptionFiles(dirPath string, baseConfig *mybase.Config) ([]*mybase.Fil
 				if err != nil {
 					return nil, err
 				}
-				files = append(files, f)
+				included, err := includedOptionFiles(f, baseConfig, nil)
+				if err != nil {
+					return nil, err
+				}
+				groups = append(groups, append(included, f))
 			}
 		}
 	}
 
-	// Reverse the order of the result, so that dir's option file is last. This way
-	// we can easily add the files to the config by applying them in order.
-	for left, right := 0, len(files)-1; left < right; left, right = left+1, right-1 {
-		files[left], files[right] = files[right], files[left]
+	// Flatten the result in reverse order, so that dir's option file is last. This
+	// way we can easily add the files to the config by applying them in order.
+	var files []*mybase.File
+	for n := len(groups) - 1; n >= 0; n-- {
+		files = append(files, groups[n]...)
 	}
 	return files, nil
 }
 
+// includedOptionFiles returns the option files pulled in by f's "include"
+// option, if any, recursively. The result is ordered such that the most deeply
+// included file is first, allowing the files to be applied in order so that
+// each including file's values take precedence over those it includes. Each
+// included file uses the same environment section as f. The chain argument
+// tracks the symlink-resolved paths of files already traversed, in order to
+// detect cycles; it should be nil in the initial call. An error is returned if an included file
+// does not exist, cannot be parsed, or results in a cycle.
+func includedOptionFiles(f *mybase.File, baseConfig *mybase.Config, chain []string) ([]*mybase.File, error) {
+	includePath, _ := f.OptionValue("include")
+	if includePath == "" {
+		return nil, nil
+	}
+	if chain == nil {
+		chain = []string{resolvedPath(f.Path())}
+	}
+	if !filepath.IsAbs(includePath) {
+		includePath = filepath.Join(f.Dir, includePath)
+	}
+	included := mybase.NewFile(includePath)
+	if fi, err := os.Stat(included.Path()); err != nil {
+		return nil, fmt.Errorf("Option file %s: included file %s cannot be read: %s", f.Path(), included.Path(), err)
+	} else if fi.IsDir() {
+		return nil, fmt.Errorf("Option file %s: included file %s is a directory", f.Path(), included.Path())
+	}
+	includedResolved := resolvedPath(included.Path())
+	for _, seen := range chain {
+		if seen == includedResolved {
+			return nil, fmt.Errorf("Option file %s: include cycle detected: %s -> %s", f.Path(), strings.Join(chain, " -> "), includedResolved)
+		}
+	}
+	chain = append(chain, includedResolved)
+	if err := included.Parse(baseConfig); err != nil {
+		return nil, err
+	}
+	_ = included.UseSection(baseConfig.Get("environment")) // as with parseOptionFile, a missing section is not an error
+	deeper, err := includedOptionFiles(included, baseConfig, chain)
+	if err != nil {
+		return nil, err
+	}
+	return append(deeper, included), nil
+}
+
+// resolvedPath returns p with any symlinks evaluated, so that two paths to the
+// same file compare as equal. If evaluation fails, p is returned unchanged.
+func resolvedPath(p string) string {
+	if resolved, err := filepath.EvalSymlinks(p); err == nil {
+		return resolved
+	}
+	return p
+}
+
+// MissingSectionError is returned by OptionFileForEnvironment when the option
+// file does not contain a section for the requested environment.
+type MissingSectionError string
+
+// Error satisfies the builtin error interface.
+func (mse MissingSectionError) Error() string {
+	return string(mse)
+}
+
+// OptionFileForEnvironment reads and parses dir's .skeema file, selecting the
+// section for the supplied environment name rather than the one specified in
+// baseConfig. Neither dir nor baseConfig are modified. If the file exists but
+// lacks a section for env, the file is still returned (with only its
+// sectionless options selected) along with a MissingSectionError.
+func (dir *Dir) OptionFileForEnvironment(baseConfig *mybase.Config, env string) (*mybase.File, error) {
+	f, err := readOptionFile(dir.Path, baseConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.UseSection(env); err != nil {
+		return f, MissingSectionError(err.Error())
+	}
+	return f, nil
+}
+
 func parseOptionFile(dirPath string, baseConfig *mybase.Config) (*mybase.File, error) {
+	f, err := readOptionFile(dirPath, baseConfig)
+	if err != nil {
+		return nil, err
+	}
+	_ = f.UseSection(baseConfig.Get("environment")) // we don't care if the section doesn't exist
+	return f, nil
+}
+
+func readOptionFile(dirPath string, baseConfig *mybase.Config) (*mybase.File, error) {
 	f := mybase.NewFile(dirPath, ".skeema")
 	if err := f.Read(); err != nil {
 		return nil, err
@@ -566,30 +812,47 @@ func parseOptionFile(dirPath string, baseConfig *mybase.Config) (*mybase.File, e
 	if err := f.Parse(baseConfig); err != nil {
 		return nil, err
 	}
-	_ = f.UseSection(baseConfig.Get("environment")) // we don't care if the section doesn't exist
 	return f, nil
 }
 
-// sqlFiles returns a slice of SQLFile for all *.sql files found in the supplied
-// path. This function does not recursively search subdirs, and does not parse
-// or validate the SQLFile contents in any way. An error will only be returned
-// if the directory cannot be read.
-func sqlFiles(dirPath string) ([]SQLFile, error) {
-	fileInfos, err := ioutil.ReadDir(dirPath)
+// SQLFilesMatching returns a slice of SQLFile for all regular files in dir
+// whose names match the supplied glob pattern, using the syntax of path.Match.
+// Symlinks are resolved, but symlinks to missing paths are skipped. This method
+// does not recursively search subdirs, and does not parse or validate the
+// SQLFile contents in any way. The returned files are always sorted by name.
+// An error will be returned if the pattern is malformed or the directory cannot
+// be read.
+func (dir *Dir) SQLFilesMatching(pattern string) ([]SQLFile, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("Invalid file pattern %q: %s", pattern, err)
+	}
+	return sqlFiles(dir.Path, pattern)
+}
+
+// sqlFiles returns a slice of SQLFile for all files matching pattern found in
+// the supplied path. This function does not recursively search subdirs, and
+// does not parse or validate the SQLFile contents in any way. An error will
+// only be returned if the directory cannot be read. The pattern is assumed to
+// have already been validated. The result is sorted by file name, since
+// os.ReadDir sorts its result.
+func sqlFiles(dirPath, pattern string) ([]SQLFile, error) {
+	entries, err := os.ReadDir(dirPath)
 	if err != nil {
 		return nil, err
 	}
-	result := make([]SQLFile, 0, len(fileInfos))
-	for _, fi := range fileInfos {
-		name := fi.Name()
-		if fi.Mode()&os.ModeSymlink == os.ModeSymlink {
-			fi, err = os.Stat(path.Join(dirPath, name))
+	result := make([]SQLFile, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		mode := entry.Type()
+		if mode&os.ModeSymlink == os.ModeSymlink {
+			fi, err := os.Stat(path.Join(dirPath, name))
 			if err != nil {
 				// ignore symlink pointing to a missing path
 				continue
 			}
+			mode = fi.Mode()
 		}
-		if strings.HasSuffix(name, ".sql") && fi.Mode().IsRegular() {
+		if matched, _ := path.Match(pattern, name); matched && mode.IsRegular() {
 			sf := SQLFile{
 				Dir:      dirPath,
 				FileName: name,
@@ -1,8 +1,12 @@
 package fs
 
 import (
+	"errors"
 	"net/url"
+	"os"
+	"path"
 	"reflect"
+	"sort"
 	"strings"
 	"testing"
 
@@ -62,6 +66,71 @@ func TestParseDir(t *testing.T) {
 	}
 }
 
+func TestParseDirNamedSchemas(t *testing.T) {
+	WriteTestFile(t, "../testdata/.scratch/fs/multi/.skeema", "schema=product\ndefault-character-set=latin1\ndefault-collation=latin1_swedish_ci\n")
+	WriteTestFile(t, "../testdata/.scratch/fs/multi/one.sql", "CREATE TABLE foo (id int);\nUSE analytics;\nCREATE TABLE bar (id int);\n")
+	WriteTestFile(t, "../testdata/.scratch/fs/multi/two.sql", "CREATE TABLE baz (id int);\nCREATE TABLE analytics.bat (id int);\n")
+	defer RemoveTestDirectory(t, "../testdata/.scratch/fs")
+
+	dir := getDir(t, "../testdata/.scratch/fs/multi")
+	if len(dir.LogicalSchemas) != 2 {
+		t.Fatalf("Expected 2 LogicalSchemas; instead found %d", len(dir.LogicalSchemas))
+	}
+	unnamed, named := dir.LogicalSchemas[0], dir.LogicalSchemas[1]
+	if unnamed.Name != "" || unnamed.CharSet != "latin1" || unnamed.Collation != "latin1_swedish_ci" || len(unnamed.Creates) != 2 {
+		t.Errorf("Unexpected contents of unnamed LogicalSchema: %+v", unnamed)
+	}
+	if named.Name != "analytics" || len(named.Creates) != 2 {
+		t.Errorf("Unexpected contents of named LogicalSchema: %+v", named)
+	}
+	if !dir.HasSchema() {
+		t.Error("Expected HasSchema() to return true, but it did not")
+	}
+}
+
+func TestParseDirDuplicateCreate(t *testing.T) {
+	WriteTestFile(t, "../testdata/.scratch/fs/dupe/foo.sql", "CREATE TABLE foo (id int);\n")
+	WriteTestFile(t, "../testdata/.scratch/fs/dupe/foo_copy.sql", "-- copied from foo.sql\n\nCREATE TABLE `foo` (id int);\n")
+	defer RemoveTestDirectory(t, "../testdata/.scratch/fs")
+	dir, err := ParseDir("../testdata/.scratch/fs/dupe", getValidConfig(t))
+	if dir != nil || err == nil {
+		t.Fatalf("Expected ParseDir to return nil dir and non-nil error, but dir=%v err=%v", dir, err)
+	}
+	for _, expected := range []string{"`foo`", "foo.sql line 1", "foo_copy.sql line 3"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error message to contain %q, but it did not: %s", expected, err)
+		}
+	}
+
+	// Same table name in different schemas is not a duplicate
+	WriteTestFile(t, "../testdata/.scratch/fs/dupe/foo_copy.sql", "USE other;\nCREATE TABLE `foo` (id int);\n")
+	if _, err := ParseDir("../testdata/.scratch/fs/dupe", getValidConfig(t)); err != nil {
+		t.Errorf("Unexpected error from ParseDir(): %s", err)
+	}
+}
+
+func TestLogicalSchemaSingleFilePath(t *testing.T) {
+	dir := getDir(t, "../testdata/golden/init/mydb/product")
+	if fp := dir.LogicalSchemas[0].SingleFilePath(); fp != "" {
+		t.Errorf("Expected no single file path for one-file-per-table layout, instead found %s", fp)
+	}
+
+	WriteTestFile(t, "../testdata/.scratch/fs/onefile/schema.sql", "CREATE TABLE foo (id int);\nCREATE TABLE bar (id int);\n")
+	defer RemoveTestDirectory(t, "../testdata/.scratch/fs")
+	dir = getDir(t, "../testdata/.scratch/fs/onefile")
+	expected := path.Join(dir.Path, "schema.sql")
+	if fp := dir.LogicalSchemas[0].SingleFilePath(); fp != expected {
+		t.Errorf("Expected single file path %s, instead found %q", expected, fp)
+	}
+
+	// A lone CREATE is ambiguous, so does not count as a single-file layout
+	WriteTestFile(t, "../testdata/.scratch/fs/onefile/schema.sql", "CREATE TABLE foo (id int);\n")
+	dir = getDir(t, "../testdata/.scratch/fs/onefile")
+	if fp := dir.LogicalSchemas[0].SingleFilePath(); fp != "" {
+		t.Errorf("Expected no single file path for lone CREATE, instead found %s", fp)
+	}
+}
+
 func TestDirBaseName(t *testing.T) {
 	dir := getDir(t, "../testdata/golden/init/mydb/product")
 	if bn := dir.BaseName(); bn != "product" {
@@ -87,6 +156,77 @@ func TestDirRelPath(t *testing.T) {
 	}
 }
 
+func TestDirCreateSubdirWithCleanup(t *testing.T) {
+	MakeTestDirectory(t, "../testdata/.scratch/fs")
+	defer RemoveTestDirectory(t, "../testdata/.scratch/fs")
+	dir := getDir(t, "../testdata/.scratch/fs")
+
+	sub, err := dir.CreateSubdirWithCleanup("good", func(d *Dir) error {
+		WriteTestFile(t, path.Join(d.Path, ".skeema"), "schema=good\n")
+		WriteTestFile(t, path.Join(d.Path, "foo.sql"), "CREATE TABLE foo (id int);\n")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error from CreateSubdirWithCleanup(): %s", err)
+	}
+	if !sub.HasSchema() || len(sub.LogicalSchemas) != 1 || len(sub.LogicalSchemas[0].Creates) != 1 {
+		t.Errorf("Subdir not parsed as expected: %+v", sub)
+	}
+
+	// Subdir already exists
+	if _, err := dir.CreateSubdirWithCleanup("good", func(*Dir) error { return nil }); err == nil {
+		t.Error("Expected error from CreateSubdirWithCleanup() on existing subdir, but err was nil")
+	} else if has, _ := sub.HasFile("foo.sql"); !has {
+		t.Error("Existing subdir was unexpectedly modified")
+	}
+
+	// Error from populate callback, or from parsing the result, should remove subdir
+	populateErr := errors.New("fail")
+	cases := map[string]func(*Dir) error{
+		"callbackerr": func(d *Dir) error {
+			WriteTestFile(t, path.Join(d.Path, "foo.sql"), "CREATE TABLE foo (id int);\n")
+			return populateErr
+		},
+		"parseerr": func(d *Dir) error {
+			WriteTestFile(t, path.Join(d.Path, ".skeema"), "not-a-real-option=1\n")
+			return nil
+		},
+	}
+	for name, populate := range cases {
+		if sub, err := dir.CreateSubdirWithCleanup(name, populate); sub != nil || err == nil {
+			t.Errorf("Expected CreateSubdirWithCleanup(%q) to return nil subdir and non-nil error, instead found %v, %v", name, sub, err)
+		}
+		if has, _ := dir.HasFile(name); has {
+			t.Errorf("Expected subdir %s to be cleaned up, but it still exists", name)
+		}
+	}
+}
+
+func TestDirIsEmpty(t *testing.T) {
+	WriteTestFile(t, "../testdata/.scratch/fs/.skeema", "schema=foo\n")
+	WriteTestFile(t, "../testdata/.scratch/fs/.gitignore", "*.bak\n")
+	WriteTestFile(t, "../testdata/.scratch/fs/.git/HEAD", "ref: refs/heads/main\n")
+	WriteTestFile(t, "../testdata/.scratch/fs/.hidden/.skeema", "schema=bar\n")
+	WriteTestFile(t, "../testdata/.scratch/fs/README.md", "hello\n")
+	MakeTestDirectory(t, "../testdata/.scratch/fs/nooptions")
+	defer RemoveTestDirectory(t, "../testdata/.scratch/fs")
+	dir := getDir(t, "../testdata/.scratch/fs")
+	if empty, err := dir.IsEmpty(); !empty || err != nil {
+		t.Errorf("Expected dir to be considered empty, instead found %t, %v", empty, err)
+	}
+
+	WriteTestFile(t, "../testdata/.scratch/fs/nooptions/.skeema", "schema=baz\n")
+	if empty, err := dir.IsEmpty(); empty || err != nil {
+		t.Errorf("Expected dir with schema subdir to be considered non-empty, instead found %t, %v", empty, err)
+	}
+	RemoveTestDirectory(t, "../testdata/.scratch/fs/nooptions")
+
+	WriteTestFile(t, "../testdata/.scratch/fs/foo.sql", "CREATE TABLE foo (id int);\n")
+	if empty, err := dir.IsEmpty(); empty || err != nil {
+		t.Errorf("Expected dir with *.sql file to be considered non-empty, instead found %t, %v", empty, err)
+	}
+}
+
 func TestDirSubdirs(t *testing.T) {
 	dir := getDir(t, "../testdata/golden/init/mydb")
 	subs, badCount, err := dir.Subdirs()
@@ -104,6 +244,238 @@ func TestDirSubdirs(t *testing.T) {
 	}
 }
 
+func TestDirSorting(t *testing.T) {
+	names := []string{"zed", "Upper", "alpha", "mid_1", "mid-2", "10", "9"}
+	for _, name := range names {
+		WriteTestFile(t, "../testdata/.scratch/fs/sorting/"+name+".sql", "CREATE TABLE "+name+" (id int);\n")
+		WriteTestFile(t, "../testdata/.scratch/fs/sorting/"+name+"/.skeema", "schema="+name+"\n")
+	}
+	defer RemoveTestDirectory(t, "../testdata/.scratch/fs")
+	sort.Strings(names)
+
+	dir := getDir(t, "../testdata/.scratch/fs/sorting")
+	if len(dir.SQLFiles) != len(names) {
+		t.Fatalf("Expected %d SQLFiles, instead found %d", len(names), len(dir.SQLFiles))
+	}
+	for n, sf := range dir.SQLFiles {
+		if sf.FileName != names[n]+".sql" {
+			t.Errorf("SQLFiles[%d]: expected %s.sql, instead found %s", n, names[n], sf.FileName)
+		}
+	}
+	subs, badCount, err := dir.Subdirs()
+	if err != nil || badCount > 0 || len(subs) != len(names) {
+		t.Fatalf("Unexpected return from Subdirs(): %d subs, badCount=%d, err=%v", len(subs), badCount, err)
+	}
+	for n, sub := range subs {
+		if sub.BaseName() != names[n] {
+			t.Errorf("Subdirs()[%d]: expected %s, instead found %s", n, names[n], sub.BaseName())
+		}
+	}
+}
+
+func TestDirLeafDirs(t *testing.T) {
+	dir := getDir(t, "../testdata/golden/init")
+	leaves, badCount, err := dir.LeafDirs()
+	if err != nil || badCount > 0 {
+		t.Fatalf("Unexpected return from LeafDirs(): badCount=%d err=%s", badCount, err)
+	}
+	var foundProduct, foundAnalytics bool
+	for _, leaf := range leaves {
+		if !leaf.HasSchema() {
+			t.Errorf("LeafDirs() returned %s, which does not have a schema", leaf)
+		}
+		switch leaf.RelPath() {
+		case "../testdata/golden/init/mydb/product":
+			foundProduct = true
+		case "../testdata/golden/init/mydb/analytics":
+			foundAnalytics = true
+		}
+	}
+	if len(leaves) != 2 || !foundProduct || !foundAnalytics {
+		t.Errorf("Expected LeafDirs() to include product and analytics dirs; instead found %v", leaves)
+	}
+
+	// Hidden dirs should be skipped
+	WriteTestFile(t, "../testdata/.scratch/fs/.hidden/.skeema", "schema=hidden\n")
+	WriteTestFile(t, "../testdata/.scratch/fs/visible/.skeema", "schema=visible\n")
+	defer RemoveTestDirectory(t, "../testdata/.scratch/fs")
+	dir = getDir(t, "../testdata/.scratch/fs")
+	if leaves, badCount, err = dir.LeafDirs(); err != nil || badCount > 0 || len(leaves) != 1 || leaves[0].BaseName() != "visible" {
+		t.Errorf("Unexpected return from LeafDirs(): leaves=%v badCount=%d err=%v", leaves, badCount, err)
+	}
+}
+
+func TestDirWalk(t *testing.T) {
+	WriteTestFile(t, "../testdata/.scratch/fs/.skeema", "host=127.0.0.1\n")
+	WriteTestFile(t, "../testdata/.scratch/fs/a/.skeema", "schema=foo\n")
+	WriteTestFile(t, "../testdata/.scratch/fs/a/b/.skeema", "port=3307\n")
+	WriteTestFile(t, "../testdata/.scratch/fs/c/.skeema", "not-a-real-option=1\n")
+	defer RemoveTestDirectory(t, "../testdata/.scratch/fs")
+	dir := getDir(t, "../testdata/.scratch/fs")
+
+	seen := make(map[string]*Dir)
+	err := dir.Walk(func(d *Dir) error {
+		seen[d.RelPath()] = d
+		return nil
+	}, true)
+	if err != nil {
+		t.Fatalf("Unexpected error from Walk(): %s", err)
+	}
+	if len(seen) != 3 {
+		t.Errorf("Expected Walk() to visit 3 dirs, instead visited %d", len(seen))
+	}
+	if b := seen["../testdata/.scratch/fs/a/b"]; b == nil {
+		t.Error("Expected Walk() to visit dir a/b, but it did not")
+	} else if b.Config.Get("host") != "127.0.0.1" || b.Config.Get("schema") != "foo" || b.Config.Get("port") != "3307" {
+		t.Errorf("Options did not cascade as expected: host=%s schema=%s port=%s", b.Config.Get("host"), b.Config.Get("schema"), b.Config.Get("port"))
+	}
+
+	// With continueOnError false, the bad subdir should halt the walk
+	if err := dir.Walk(func(*Dir) error { return nil }, false); err == nil {
+		t.Error("Expected error from Walk() with continueOnError=false, but err was nil")
+	}
+
+	// Errors from the callback should halt the walk
+	var visits int
+	err = dir.Walk(func(*Dir) error {
+		visits++
+		return errors.New("stop")
+	}, true)
+	if err == nil || visits != 1 {
+		t.Errorf("Expected callback error to halt Walk() after 1 visit; instead found err=%v after %d visits", err, visits)
+	}
+}
+
+func TestDirOptionFileForEnvironment(t *testing.T) {
+	WriteTestFile(t, "../testdata/.scratch/fs/envs/.skeema", "schema=foo\nhost=prod.example.com\n\n[staging]\nhost=staging.example.com\n")
+	defer RemoveTestDirectory(t, "../testdata/.scratch/fs")
+	dir := getDir(t, "../testdata/.scratch/fs/envs")
+	cfg := getValidConfig(t)
+
+	f, err := dir.OptionFileForEnvironment(cfg, "staging")
+	if err != nil {
+		t.Fatalf("Unexpected error from OptionFileForEnvironment(): %s", err)
+	}
+	if val, _ := f.OptionValue("host"); val != "staging.example.com" {
+		t.Errorf("Expected host from staging section, instead found %q", val)
+	}
+	if val, _ := f.OptionValue("schema"); val != "foo" {
+		t.Errorf("Expected schema from sectionless options, instead found %q", val)
+	}
+	if dir.Config.Get("host") != "prod.example.com" {
+		t.Errorf("dir.Config unexpectedly modified: host is %s", dir.Config.Get("host"))
+	}
+
+	f, err = dir.OptionFileForEnvironment(cfg, "development")
+	if _, ok := err.(MissingSectionError); !ok {
+		t.Errorf("Expected MissingSectionError, instead found %T %v", err, err)
+	}
+	if val, _ := f.OptionValue("host"); val != "prod.example.com" {
+		t.Errorf("Expected host from sectionless options, instead found %q", val)
+	}
+
+	dir = getDir(t, "../testdata/.scratch/fs")
+	if _, err := dir.OptionFileForEnvironment(cfg, "staging"); err == nil {
+		t.Error("Expected error from OptionFileForEnvironment() on dir without .skeema, but err was nil")
+	} else if _, ok := err.(MissingSectionError); ok {
+		t.Error("Expected non-MissingSectionError error for missing file")
+	}
+}
+
+func TestDirOptionFileInclude(t *testing.T) {
+	WriteTestFile(t, "../testdata/.scratch/fs/shared/base.skeema", "host=base.example.com\nport=3307\nflavor=mysql:8.0\n\n[production]\nport=3308\n")
+	WriteTestFile(t, "../testdata/.scratch/fs/shared/deeper.skeema", "include=base.skeema\nflavor=mysql:5.7\n")
+	WriteTestFile(t, "../testdata/.scratch/fs/app/.skeema", "include=../shared/deeper.skeema\nhost=local.example.com\n")
+	WriteTestFile(t, "../testdata/.scratch/fs/app/sub/.skeema", "schema=foo\n")
+	defer RemoveTestDirectory(t, "../testdata/.scratch/fs")
+
+	// Local values should override included ones, which in turn should override
+	// values from files they include
+	dir := getDir(t, "../testdata/.scratch/fs/app")
+	expected := map[string]string{
+		"host":   "local.example.com",
+		"port":   "3308",
+		"flavor": "mysql:5.7",
+	}
+	for name, value := range expected {
+		if actual := dir.Config.Get(name); actual != value {
+			t.Errorf("Expected %s to be %q, instead found %q", name, value, actual)
+		}
+	}
+	if _, ok := dir.OptionFile.OptionValue("port"); ok {
+		t.Error("Expected dir.OptionFile to exclude included values, but port was present")
+	}
+
+	// Included files should also apply to subdirs via ParentOptionFiles
+	dir = getDir(t, "../testdata/.scratch/fs/app/sub")
+	for name, value := range expected {
+		if actual := dir.Config.Get(name); actual != value {
+			t.Errorf("In subdir, expected %s to be %q, instead found %q", name, value, actual)
+		}
+	}
+
+	// Cycles and missing files should be errors
+	WriteTestFile(t, "../testdata/.scratch/fs/shared/base.skeema", "include=../app/.skeema\n")
+	if _, err := ParseDir("../testdata/.scratch/fs/app", getValidConfig(t)); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("Expected include cycle error, instead found %v", err)
+	}
+	if _, err := ParseDir("../testdata/.scratch/fs/app/sub", getValidConfig(t)); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("Expected include cycle error from subdir, instead found %v", err)
+	}
+
+	// Cycles through a symlink should also be detected, even though the textual
+	// paths differ
+	if err := os.Symlink("../app/.skeema", "../testdata/.scratch/fs/shared/link.skeema"); err != nil {
+		t.Fatalf("Unable to create symlink: %s", err)
+	}
+	WriteTestFile(t, "../testdata/.scratch/fs/shared/base.skeema", "include=link.skeema\n")
+	if _, err := ParseDir("../testdata/.scratch/fs/app", getValidConfig(t)); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("Expected include cycle error via symlink, instead found %v", err)
+	}
+
+	WriteTestFile(t, "../testdata/.scratch/fs/shared/base.skeema", "include=missing.skeema\n")
+	if _, err := ParseDir("../testdata/.scratch/fs/app", getValidConfig(t)); err == nil || !strings.Contains(err.Error(), "missing.skeema") {
+		t.Errorf("Expected error about missing included file, instead found %v", err)
+	}
+}
+
+func TestDirSQLFilesMatching(t *testing.T) {
+	WriteTestFile(t, "../testdata/.scratch/fs/globs/foo.sql", "CREATE TABLE foo (id int);\n")
+	WriteTestFile(t, "../testdata/.scratch/fs/globs/bar.ddl", "CREATE TABLE bar (id int);\n")
+	WriteTestFile(t, "../testdata/.scratch/fs/globs/app_baz.ddl", "CREATE TABLE baz (id int);\n")
+	defer RemoveTestDirectory(t, "../testdata/.scratch/fs")
+	dir := getDir(t, "../testdata/.scratch/fs/globs")
+	if len(dir.SQLFiles) != 1 || dir.SQLFiles[0].FileName != "foo.sql" {
+		t.Errorf("Unexpected SQLFiles with default schema-file-pattern: %v", dir.SQLFiles)
+	}
+
+	cases := map[string]int{
+		"*.sql":   1,
+		"*.ddl":   2,
+		"app_*":   1,
+		"*":       3,
+		"*.txt":   0,
+		"foo.sql": 1,
+	}
+	for pattern, expected := range cases {
+		if sqlFiles, err := dir.SQLFilesMatching(pattern); err != nil {
+			t.Errorf("Unexpected error from SQLFilesMatching(%q): %s", pattern, err)
+		} else if len(sqlFiles) != expected {
+			t.Errorf("Expected SQLFilesMatching(%q) to return %d files, instead found %d", pattern, expected, len(sqlFiles))
+		}
+	}
+	if _, err := dir.SQLFilesMatching("[*.sql"); err == nil {
+		t.Error("Expected error from SQLFilesMatching with malformed pattern, but err was nil")
+	}
+
+	// Option file setting should control which files are parsed
+	WriteTestFile(t, "../testdata/.scratch/fs/globs/.skeema", "schema-file-pattern=*.ddl\n")
+	dir = getDir(t, "../testdata/.scratch/fs/globs")
+	if len(dir.SQLFiles) != 2 || len(dir.LogicalSchemas) != 1 || len(dir.LogicalSchemas[0].Creates) != 2 {
+		t.Errorf("Unexpected result of parsing dir with schema-file-pattern=*.ddl: %d files, %+v", len(dir.SQLFiles), dir.LogicalSchemas)
+	}
+}
+
 func TestDirInstances(t *testing.T) {
 	assertInstances := func(optionValues map[string]string, expectError bool, expectedInstances ...string) []*tengo.Instance {
 		cmd := mybase.NewCommand("test", "1.0", "this is for testing", nil)
@@ -158,6 +530,10 @@ func TestDirInstances(t *testing.T) {
 	assertInstances(map[string]string{"host": "some.db.host:3306", "port": "3307"}, true)
 	assertInstances(map[string]string{"host": "@@@@@"}, true)
 	assertInstances(map[string]string{"host-wrapper": "`echo {INVALID_VAR}`", "host": "irrelevant"}, true)
+	assertInstances(map[string]string{"host": "some.db.host", "ssl-mode": "sometimes"}, true)
+	assertInstances(map[string]string{"host": "some.db.host", "ssl-ca": "/nonexistent/ca.pem"}, true)
+	assertInstances(map[string]string{"host": "some.db.host", "ssl-mode": "required", "connect-options": "tls=true"}, true)
+	assertInstances(map[string]string{"host": "some.db.host", "ssl-mode": "disabled"}, false, "some.db.host:3306")
 
 	// dynamic hosts via host-wrapper command execution
 	assertInstances(map[string]string{"host-wrapper": "/usr/bin/printf '{HOST}:3306'", "host": "some.db.host"}, false, "some.db.host:3306")
@@ -228,9 +604,11 @@ func getValidConfig(t *testing.T) *mybase.Config {
 	cmd.AddOption(mybase.StringOption("schema", 0, "", "Database schema name").Hidden())
 	cmd.AddOption(mybase.StringOption("default-character-set", 0, "", "Schema-level default character set").Hidden())
 	cmd.AddOption(mybase.StringOption("default-collation", 0, "", "Schema-level default collation").Hidden())
+	cmd.AddOption(mybase.StringOption("schema-file-pattern", 0, "*.sql", "Glob pattern for selecting files containing schema definitions").Hidden())
 	cmd.AddOption(mybase.StringOption("host", 0, "", "Database hostname or IP address").Hidden())
 	cmd.AddOption(mybase.StringOption("port", 0, "3306", "Port to use for database host").Hidden())
 	cmd.AddOption(mybase.StringOption("flavor", 0, "", "Database server expressed in format vendor:major.minor, for use in vendor/version specific syntax").Hidden())
+	cmd.AddOption(mybase.StringOption("include", 0, "", "Path of another option file to apply beneath this one, relative to this file's directory").Hidden())
 	cmd.AddArg("environment", "production", false)
 	return mybase.ParseFakeCLI(t, cmd, "fstest")
 }
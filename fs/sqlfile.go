@@ -2,7 +2,6 @@ package fs
 
 import (
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path"
 	"regexp"
@@ -51,7 +50,7 @@ func (sf SQLFile) Create(contents string) error {
 	} else if exists {
 		return fmt.Errorf("Cannot create %s: already exists", sf)
 	}
-	return ioutil.WriteFile(sf.Path(), []byte(contents), 0666)
+	return os.WriteFile(sf.Path(), []byte(contents), 0666)
 }
 
 // Delete unlinks the file.
@@ -107,6 +106,24 @@ func (sf SQLFile) Tokenize() (*TokenizedSQLFile, error) {
 	return NewTokenizedSQLFile(sf, statements), err
 }
 
+// TokenizeStream reads the file and splits it into statements like Tokenize,
+// but rather than returning all statements at once, it calls fn on each
+// statement as soon as it is tokenized. This keeps memory usage bounded
+// regardless of file size, which is useful for very large dump files. If fn
+// returns an error, tokenization stops and that error is returned.
+// Unlike Tokenize, the returned statements have a nil FromFile, and files
+// containing a single routine without a DELIMITER command are not specially
+// re-parsed.
+func (sf SQLFile) TokenizeStream(fn func(*Statement) error) error {
+	file, err := os.Open(sf.Path())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	tokenizer := newStatementTokenizer(sf.Path(), ";")
+	return tokenizer.stream(file, fn)
+}
+
 // WriteStatements writes (or re-writes) the file using the contents of the
 // supplied statements. The number of bytes written is returned.
 func (sf SQLFile) WriteStatements(statements []*Statement) (int, error) {
@@ -115,7 +132,7 @@ func (sf SQLFile) WriteStatements(statements []*Statement) (int, error) {
 		lines[n] = string(statements[n].Text)
 	}
 	value := strings.Join(lines, "")
-	err := ioutil.WriteFile(sf.Path(), []byte(value), 0666)
+	err := os.WriteFile(sf.Path(), []byte(value), 0666)
 	if err != nil {
 		return 0, err
 	}
@@ -154,18 +171,46 @@ func (tsf *TokenizedSQLFile) Rewrite() (int, error) {
 	return 0, tsf.Delete()
 }
 
+// LogicalSchemas groups the file's CREATE and ALTER statements by schema name,
+// based on the most recent USE command preceding each statement (or a schema
+// name qualifier in the statement itself). Statements without any schema
+// association are mapped to the blank-named LogicalSchema. This permits
+// handling of files containing multiple schemas, such as a dump from
+// `mysqldump --databases`. An error is returned if the same object is created
+// more than once in the same schema.
+func (tsf *TokenizedSQLFile) LogicalSchemas() (map[string]*LogicalSchema, error) {
+	result := make(map[string]*LogicalSchema)
+	for _, stmt := range tsf.Statements {
+		if stmt.Type != StatementTypeCreate && stmt.Type != StatementTypeAlter {
+			continue
+		}
+		name := stmt.Schema()
+		if _, ok := result[name]; !ok {
+			result[name] = &LogicalSchema{
+				Name:    name,
+				Creates: make(map[tengo.ObjectKey]*Statement),
+			}
+		}
+		if err := result[name].AddStatement(stmt); err != nil {
+			foundStmt := result[name].Creates[stmt.ObjectKey()]
+			return nil, fmt.Errorf("%s %s found multiple times in %s: line %d and line %d", stmt.ObjectType, tengo.EscapeIdentifier(stmt.ObjectName), tsf.SQLFile, foundStmt.LineNo, stmt.LineNo)
+		}
+	}
+	return result, nil
+}
+
 // AppendToFile appends the supplied string to the file at the given path. If the
 // file already exists and is not newline-terminated, a newline will be added
 // before contents are appended. If the file does not exist, it will be created.
 func AppendToFile(filePath, contents string) (bytesWritten int, created bool, err error) {
 	_, err = os.Stat(filePath)
 	if os.IsNotExist(err) {
-		return len(contents), true, ioutil.WriteFile(filePath, []byte(contents), 0666)
+		return len(contents), true, os.WriteFile(filePath, []byte(contents), 0666)
 	} else if err != nil {
 		return
 	}
 
-	byteContents, err := ioutil.ReadFile(filePath)
+	byteContents, err := os.ReadFile(filePath)
 	if err != nil {
 		return 0, false, fmt.Errorf("%s: Cannot append: %s", filePath, err)
 	}
@@ -174,7 +219,28 @@ func AppendToFile(filePath, contents string) (bytesWritten int, created bool, er
 		whitespace = "\n"
 	}
 	newContents := fmt.Sprintf("%s%s%s", string(byteContents), whitespace, contents)
-	return len(newContents), false, ioutil.WriteFile(filePath, []byte(newContents), 0666)
+	return len(newContents), false, os.WriteFile(filePath, []byte(newContents), 0666)
+}
+
+// FileNameForObject returns the name of a new file for storing the definition
+// of the object with the supplied name, such that the file will be matched by
+// the supplied schema-file-pattern. Normally this is the object name with a .sql
+// extension, but if the pattern does not match that, its single "*" wildcard is
+// replaced with the object name; for example, pattern "*.ddl" yields a .ddl
+// extension instead. An error is returned if the pattern has any other special
+// characters, or if no suitable name can be determined.
+func FileNameForObject(pattern, objectName string) (string, error) {
+	fileName := fmt.Sprintf("%s.sql", objectName)
+	if matched, _ := path.Match(pattern, fileName); matched {
+		return fileName, nil
+	}
+	if strings.Count(pattern, "*") == 1 && !strings.ContainsAny(pattern, "?[\\") {
+		fileName = strings.Replace(pattern, "*", objectName, 1)
+		if matched, _ := path.Match(pattern, fileName); matched {
+			return fileName, nil
+		}
+	}
+	return "", fmt.Errorf("Unable to determine file name for %s matching schema-file-pattern %q: pattern must contain a single * wildcard and no other special characters", objectName, pattern)
 }
 
 var reIsMultiStatement = regexp.MustCompile(`(?is)begin.*;.*end`)
@@ -1,6 +1,7 @@
 package fs
 
 import (
+	"errors"
 	"strings"
 	"testing"
 
@@ -76,7 +77,7 @@ func TestSQLFileTokenize(t *testing.T) {
 				t.Errorf("statement[%d]: Expected default db %s, instead found %s", n, expect.DefaultDatabase, actual.DefaultDatabase)
 			}
 			if actual.Type != expect.Type {
-				t.Errorf("statement[%d]: Expected statement type %d, instead found %d", n, expect.Type, actual.Type)
+				t.Errorf("statement[%d]: Expected statement type %s, instead found %s", n, expect.Type, actual.Type)
 			}
 			if actual.ObjectType != expect.ObjectType {
 				t.Errorf("statement[%d]: Expected object type %s, instead found %s", n, expect.ObjectType, actual.ObjectType)
@@ -93,12 +94,19 @@ func TestSQLFileTokenize(t *testing.T) {
 		}
 	}
 
+	// Confirm each statement's ByteOffset points to its Text in the original file
+	origContents := ReadTestFile(t, sf.Path())
+	for n, stmt := range tokenizedFile.Statements {
+		if end := stmt.ByteOffset + len(stmt.Text); end > len(origContents) || origContents[stmt.ByteOffset:end] != stmt.Text {
+			t.Errorf("statement[%d]: ByteOffset %d does not correspond to location of statement text in file", n, stmt.ByteOffset)
+		}
+	}
+
 	// Test error returns for unterminated quote or unterminated C-style comment
 	sf2 := SQLFile{
 		Dir:      "../testdata",
 		FileName: "statements2.sql",
 	}
-	origContents := ReadTestFile(t, sf.Path())
 	contents := strings.Replace(origContents, "use /*wtf*/`analytics`", "use /*wtf*/`analytics", 1)
 	WriteTestFile(t, sf2.Path(), contents)
 	if _, err := sf2.Tokenize(); err == nil {
@@ -111,6 +119,35 @@ func TestSQLFileTokenize(t *testing.T) {
 		t.Error("Expected to get an error about unterminated comment, but err was nil")
 	}
 
+	// Test error returns for a DELIMITER command inside of an unbalanced quote,
+	// as well as inside of an unterminated C-style comment
+	contents = strings.Replace(origContents, "'foo'@localhost", "'foo@localhost", 1)
+	WriteTestFile(t, sf2.Path(), contents)
+	if _, err := sf2.Tokenize(); err == nil || !strings.Contains(err.Error(), "line 33") {
+		t.Errorf("Expected to get an error about DELIMITER on line 33 inside quote, but instead err was %v", err)
+	}
+	contents = strings.Replace(origContents, "use /*wtf*/`analytics`", "use /*wtf`analytics", 1) + "\nDELIMITER //\n"
+	WriteTestFile(t, sf2.Path(), contents)
+	if _, err := sf2.Tokenize(); err == nil || !strings.Contains(err.Error(), "line 57") {
+		t.Errorf("Expected to get an error about DELIMITER on line 57 inside comment, but instead err was %v", err)
+	}
+
+	// Test handling of a leading UTF-8 byte order mark, which should be skipped,
+	// and invalid UTF-8, which should cause an error
+	WriteTestFile(t, sf2.Path(), "\xEF\xBB\xBF"+origContents)
+	if tokenizedFile, err := sf2.Tokenize(); err != nil {
+		t.Errorf("Unexpected error from Tokenize() on file with BOM: %s", err)
+	} else if len(tokenizedFile.Statements) != len(expected) {
+		t.Errorf("Expected %d statements, instead found %d", len(expected), len(tokenizedFile.Statements))
+	} else if tokenizedFile.Statements[0].Text != expected[0].Text || tokenizedFile.Statements[1].ByteOffset != 3+len(expected[0].Text) {
+		t.Errorf("Byte order mark not handled as expected: %+v", tokenizedFile.Statements[0])
+	}
+	contents = strings.Replace(origContents, "ok 💩💩💩 cool", "ok \xF0\x9F cool", 1)
+	WriteTestFile(t, sf2.Path(), contents)
+	if _, err := sf2.Tokenize(); err == nil || !strings.Contains(err.Error(), "line 34") {
+		t.Errorf("Expected to get an error about invalid UTF-8 on line 34, but instead err was %v", err)
+	}
+
 	// Test error return for nonexistent file
 	sf2.Delete()
 	if _, err := sf2.Tokenize(); err == nil {
@@ -160,6 +197,220 @@ func TestSQLFileTokenize(t *testing.T) {
 	}
 }
 
+func TestSQLFileTokenizeStream(t *testing.T) {
+	sf := SQLFile{
+		Dir:      "../testdata",
+		FileName: "statements.sql",
+	}
+	tokenizedFile, err := sf.Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error from Tokenize(): %s", err)
+	}
+
+	// Streamed statements should match those from Tokenize
+	var n int
+	err = sf.TokenizeStream(func(stmt *Statement) error {
+		if n >= len(tokenizedFile.Statements) {
+			t.Fatalf("TokenizeStream emitted more statements than expected")
+		}
+		expect := tokenizedFile.Statements[n]
+		if stmt.Text != expect.Text || stmt.LineNo != expect.LineNo || stmt.ByteOffset != expect.ByteOffset || stmt.Type != expect.Type || stmt.DefaultDatabase != expect.DefaultDatabase {
+			t.Errorf("statement[%d]: Expected %+v, instead found %+v", n, *expect, *stmt)
+		}
+		n++
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Unexpected error from TokenizeStream(): %s", err)
+	} else if n != len(tokenizedFile.Statements) {
+		t.Errorf("Expected %d statements, instead found %d", len(tokenizedFile.Statements), n)
+	}
+
+	// An error from the callback should halt tokenization and be returned
+	stopErr := errors.New("stop")
+	n = 0
+	err = sf.TokenizeStream(func(stmt *Statement) error {
+		n++
+		if n == 3 {
+			return stopErr
+		}
+		return nil
+	})
+	if err != stopErr || n != 3 {
+		t.Errorf("Expected callback error to halt tokenization after 3 statements; instead found err=%v after %d statements", err, n)
+	}
+
+	// Nonexistent file should be an error
+	sf.FileName = "doesnt-exist.sql"
+	if err := sf.TokenizeStream(func(*Statement) error { return nil }); err == nil {
+		t.Error("Expected error from TokenizeStream() on nonexistent file, but err was nil")
+	}
+}
+
+func TestSQLFileTokenizeTrailingComments(t *testing.T) {
+	contents := "CREATE TABLE foo (id int); -- owned by billing\n" +
+		"CREATE TABLE bar (id int); /* one-liner */\n" +
+		"-- this comment belongs to baz\n" +
+		"CREATE TABLE baz (id int); /* this block comment\nalso belongs to baz */\n" +
+		"CREATE TABLE bat (id int); /* so does\nthis one */ CREATE TABLE qux (id int);\n" +
+		"/* a comment on its own line belongs to the next statement */\n" +
+		"CREATE TABLE quux (id int);\n"
+	WriteTestFile(t, "../testdata/.scratch/fs/comments.sql", contents)
+	defer RemoveTestDirectory(t, "../testdata/.scratch/fs")
+	sf := SQLFile{
+		Dir:      "../testdata/.scratch/fs",
+		FileName: "comments.sql",
+	}
+	tokenizedFile, err := sf.Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error from Tokenize(): %s", err)
+	}
+	expected := []struct {
+		text            string
+		trailingComment string
+	}{
+		{"CREATE TABLE foo (id int); -- owned by billing\n", "-- owned by billing"},
+		{"CREATE TABLE bar (id int); /* one-liner */\n", "/* one-liner */"},
+		{"-- this comment belongs to baz\n", ""},
+		{"CREATE TABLE baz (id int); /* this block comment\nalso belongs to baz */\n", "/* this block comment\nalso belongs to baz */"},
+		{"CREATE TABLE bat (id int); /* so does\nthis one */", "/* so does\nthis one */"},
+		{" ", ""},
+		{"CREATE TABLE qux (id int);\n", ""},
+		{"/* a comment on its own line belongs to the next statement */\n", ""},
+		{"CREATE TABLE quux (id int);\n", ""},
+	}
+	if len(tokenizedFile.Statements) != len(expected) {
+		t.Fatalf("Expected %d statements, instead found %d", len(expected), len(tokenizedFile.Statements))
+	}
+	for n, stmt := range tokenizedFile.Statements {
+		if stmt.Text != expected[n].text || stmt.TrailingComment != expected[n].trailingComment {
+			t.Errorf("statement[%d]: Expected text %#v and trailing comment %#v; instead found %#v and %#v", n, expected[n].text, expected[n].trailingComment, stmt.Text, stmt.TrailingComment)
+		}
+	}
+	if body := tokenizedFile.Statements[0].Body(); body != "CREATE TABLE foo (id int)" {
+		t.Errorf("Unexpected statement body: %s", body)
+	}
+	if body := tokenizedFile.Statements[3].Body(); body != "CREATE TABLE baz (id int)" {
+		t.Errorf("Unexpected statement body: %s", body)
+	}
+}
+
+func TestSQLFileTokenizeVersionGatedComments(t *testing.T) {
+	contents := "/*!40101 SET @OLD_CHARACTER_SET_CLIENT=@@CHARACTER_SET_CLIENT */;\n" +
+		"/* plain comment */\n" +
+		"/*!40101 SET NAMES utf8mb4 */;\n" +
+		"CREATE TABLE foo (id int /*!50601 COMMENT 'hi' */);\n"
+	WriteTestFile(t, "../testdata/.scratch/fs/gated.sql", contents)
+	defer RemoveTestDirectory(t, "../testdata/.scratch/fs")
+	sf := SQLFile{
+		Dir:      "../testdata/.scratch/fs",
+		FileName: "gated.sql",
+	}
+	tokenizedFile, err := sf.Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error from Tokenize(): %s", err)
+	}
+	expected := []struct {
+		text string
+		typ  StatementType
+	}{
+		{"/*!40101 SET @OLD_CHARACTER_SET_CLIENT=@@CHARACTER_SET_CLIENT */;\n", StatementTypeCommand},
+		{"/* plain comment */\n", StatementTypeNoop},
+		{"/*!40101 SET NAMES utf8mb4 */;\n", StatementTypeCommand},
+		{"CREATE TABLE foo (id int /*!50601 COMMENT 'hi' */);\n", StatementTypeCreate},
+	}
+	if len(tokenizedFile.Statements) != len(expected) {
+		t.Fatalf("Expected %d statements, instead found %d", len(expected), len(tokenizedFile.Statements))
+	}
+	for n, stmt := range tokenizedFile.Statements {
+		if stmt.Text != expected[n].text || stmt.Type != expected[n].typ {
+			t.Errorf("statement[%d]: Expected text %#v of type %s; instead found %#v of type %s", n, expected[n].text, expected[n].typ, stmt.Text, stmt.Type)
+		}
+	}
+}
+
+func TestSQLFileTokenizeIdentifierQuote(t *testing.T) {
+	contents := "CREATE TABLE `foo` (id int);\n" +
+		"CREATE TABLE \"bar\" (\"id\" int, name varchar(10) DEFAULT 'x`y');\n" +
+		"CREATE TABLE baz (id int DEFAULT '1');\n" +
+		"CREATE TABLE bat (`id` int DEFAULT \"1\");\n" +
+		"CREATE TABLE baz (id int DEFAULT \"1\");\n" +
+		"CREATE TABLE \"qux\" (`id` int);\n" +
+		"CREATE TABLE e (status enum(\"a\",\"b\") COMMENT \"x\", \"id\" int);\n" +
+		"CREATE TABLE f (status enum(\"a\",\"b\") COMMENT \"x\", KEY (status));\n" +
+		"CREATE TABLE g (id int, KEY idx (\"id\"));\n" +
+		"CREATE TABLE h (status enum(\"a\\\"(b\",\"c\") COMMENT \"x\");\n" +
+		"CREATE TABLE i (id int /* ( */ DEFAULT \"1\", \"name\" int);\n"
+	WriteTestFile(t, "../testdata/.scratch/fs/quotes.sql", contents)
+	defer RemoveTestDirectory(t, "../testdata/.scratch/fs")
+	sf := SQLFile{
+		Dir:      "../testdata/.scratch/fs",
+		FileName: "quotes.sql",
+	}
+	tokenizedFile, err := sf.Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error from Tokenize(): %s", err)
+	}
+	expected := []rune{'`', '"', 0, '`', 0, '`', '"', 0, '"', 0, '"'}
+	if len(tokenizedFile.Statements) != len(expected) {
+		t.Fatalf("Expected %d statements, instead found %d", len(expected), len(tokenizedFile.Statements))
+	}
+	for n, stmt := range tokenizedFile.Statements {
+		if stmt.IdentifierQuote != expected[n] {
+			t.Errorf("statement[%d]: Expected identifier quote %q, instead found %q", n, expected[n], stmt.IdentifierQuote)
+		}
+	}
+}
+
+func TestTokenizedSQLFileLogicalSchemas(t *testing.T) {
+	contents := "CREATE TABLE nodb (id int);\n" +
+		"USE `one`;\n" +
+		"CREATE TABLE foo (id int);\n" +
+		"CREATE TABLE bar (id int);\n" +
+		"USE two;\n" +
+		"CREATE TABLE foo (id int);\n" +
+		"CREATE TABLE one.baz (id int);\n"
+	WriteTestFile(t, "../testdata/.scratch/fs/dump.sql", contents)
+	defer RemoveTestDirectory(t, "../testdata/.scratch/fs")
+	sf := SQLFile{
+		Dir:      "../testdata/.scratch/fs",
+		FileName: "dump.sql",
+	}
+	tokenizedFile, err := sf.Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error from Tokenize(): %s", err)
+	}
+	logicalSchemas, err := tokenizedFile.LogicalSchemas()
+	if err != nil {
+		t.Fatalf("Unexpected error from LogicalSchemas(): %s", err)
+	}
+	expected := map[string]int{
+		"":    1,
+		"one": 3,
+		"two": 1,
+	}
+	if len(logicalSchemas) != len(expected) {
+		t.Fatalf("Expected %d logical schemas, instead found %d", len(expected), len(logicalSchemas))
+	}
+	for name, creates := range expected {
+		ls := logicalSchemas[name]
+		if ls == nil {
+			t.Errorf("Expected logical schema %q to be present, but it was not", name)
+		} else if ls.Name != name || len(ls.Creates) != creates {
+			t.Errorf("Unexpected contents of logical schema %q: %+v", name, ls)
+		}
+	}
+
+	// Duplicate CREATE in the same schema should be an error
+	WriteTestFile(t, "../testdata/.scratch/fs/dump.sql", contents+"USE one;\nCREATE TABLE bar (id int);\n")
+	if tokenizedFile, err = sf.Tokenize(); err != nil {
+		t.Fatalf("Unexpected error from Tokenize(): %s", err)
+	}
+	if _, err := tokenizedFile.LogicalSchemas(); err == nil {
+		t.Error("Expected error from LogicalSchemas() with duplicate CREATE, but err was nil")
+	}
+}
+
 func TestTokenizedSQLFileRewrite(t *testing.T) {
 	// Use Rewrite() to write file statements2.sql with same contents as statements.sql
 	contents := ReadTestFile(t, "../testdata/statements.sql")
@@ -262,6 +513,28 @@ func TestAppendToFile(t *testing.T) {
 	RemoveTestFile(t, "../testdata/.scratch/fs")
 }
 
+func TestFileNameForObject(t *testing.T) {
+	cases := map[string]string{
+		"*.sql":        "foo.sql",
+		"*":            "foo.sql",
+		"f*":           "foo.sql",
+		"*.ddl":        "foo.ddl",
+		"b*.sql":       "bfoo.sql",
+		"schema-*.sql": "schema-foo.sql",
+		"[a-z]*.sql":   "foo.sql",
+	}
+	for pattern, expected := range cases {
+		if actual, err := FileNameForObject(pattern, "foo"); err != nil || actual != expected {
+			t.Errorf("Expected FileNameForObject(%q, \"foo\") to return %q, nil; instead found %q, %v", pattern, expected, actual, err)
+		}
+	}
+	for _, pattern := range []string{"*.ddl.*", "?*.ddl", "table.ddl", "[a-z]*.ddl"} {
+		if actual, err := FileNameForObject(pattern, "foo"); err == nil {
+			t.Errorf("Expected FileNameForObject(%q, \"foo\") to return an error, instead found %q", pattern, actual)
+		}
+	}
+}
+
 func TestAddDelimiter(t *testing.T) {
 	proc := `CREATE PROCEDURE whatever(name varchar(10))
 BEGIN
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"regexp"
 	"strings"
 	"unicode"
 	"unicode/utf8"
@@ -24,12 +25,28 @@ type StatementType int
 const (
 	StatementTypeUnknown StatementType = iota
 	StatementTypeNoop                  // entirely whitespace and/or comments
-	StatementTypeCommand               // currently just USE or DELIMITER
+	StatementTypeCommand               // USE, DELIMITER, or a version-gated comment such as /*!40101 SET ... */
 	StatementTypeCreate
 	StatementTypeAlter
 	// Other types will be added once they are supported by the package
 )
 
+// String returns a lowercase human-readable name for the statement type.
+func (t StatementType) String() string {
+	switch t {
+	case StatementTypeNoop:
+		return "noop"
+	case StatementTypeCommand:
+		return "command"
+	case StatementTypeCreate:
+		return "create"
+	case StatementTypeAlter:
+		return "alter"
+	default:
+		return "unknown"
+	}
+}
+
 // Statement represents a logical instruction in a file, consisting of either
 // an SQL statement, a command (e.g. "USE some_database"), or whitespace and/or
 // comments between two separate statements or commands.
@@ -37,12 +54,15 @@ type Statement struct {
 	File            string
 	LineNo          int
 	CharNo          int
+	ByteOffset      int // offset into the file where Text begins, in bytes
 	Text            string
 	DefaultDatabase string // only populated if an explicit USE command was encountered
 	Type            StatementType
 	ObjectType      tengo.ObjectType
 	ObjectName      string
 	ObjectQualifier string
+	TrailingComment string // comment starting after the delimiter on the same line (C-style ones may span lines); also included in Text
+	IdentifierQuote rune   // backtick if any were used in the statement, else double-quote if used around an identifier, else 0
 	FromFile        *TokenizedSQLFile
 	delimiter       string
 }
@@ -82,10 +102,14 @@ func (stmt *Statement) Body() string {
 	return body
 }
 
-// SplitTextBody returns Text with its trailing delimiter and whitespace (if
-// any) separated out into a separate string.
+// SplitTextBody returns Text with its trailing delimiter, whitespace, and
+// trailing comment (if any) separated out into a separate string.
 func (stmt *Statement) SplitTextBody() (body string, suffix string) {
 	body = strings.TrimRight(stmt.Text, "\n\r\t ")
+	if stmt.TrailingComment != "" {
+		body = strings.TrimSuffix(body, stmt.TrailingComment)
+		body = strings.TrimRight(body, "\n\r\t ")
+	}
 	body = strings.TrimSuffix(body, stmt.delimiter)
 	body = strings.TrimRight(body, "\n\r\t ")
 	return body, stmt.Text[len(body):]
@@ -120,15 +144,30 @@ type statementTokenizer struct {
 	filePath  string
 	delimiter string // statement delimiter, typically ";" or sometimes "//" for routines
 
-	result []*Statement // completed statements
-	stmt   *Statement   // tracking current (not yet completely tokenized) statement
-	buf    bytes.Buffer // tracking text to eventually put into stmt
-
-	lineNo          int    // human-readable line number, starting at 1
-	inRelevant      bool   // true if current statement contains something other than just whitespace and comments
-	inCComment      bool   // true if in a C-style comment
-	inQuote         rune   // nonzero if inside of a quoted string; value indicates which quote rune
-	defaultDatabase string // tracks most recent USE command
+	emit    func(*Statement) error // called on each completed statement
+	emitErr error                  // first error returned by emit, if any
+	stmt    *Statement             // tracking current (not yet completely tokenized) statement
+	buf     bytes.Buffer           // tracking text to eventually put into stmt
+
+	lineNo               int    // human-readable line number, starting at 1
+	lineOffset           int    // byte offset of the start of the current line within the file
+	inRelevant           bool   // true if current statement contains something other than just whitespace and comments
+	inCComment           bool   // true if in a C-style comment
+	inTrailingComment    bool   // true if the C-style comment is a trailing comment of the current statement
+	trailingCommentStart int    // offset in buf where the trailing C-style comment begins
+	inQuote              rune   // nonzero if inside of a quoted string; value indicates which quote rune
+	defaultDatabase      string // tracks most recent USE command
+
+	// State used for classifying double-quotes, tracked incrementally for the
+	// current statement, ignoring quoted strings and comments
+	lastRune   rune     // most recent non-whitespace rune
+	lastWord   string   // lowercased most recent word, if lastRune is part of it
+	inWord     bool     // true if the next identifier rune extends lastWord
+	parenWords []string // lastWord preceding each currently-open parenthesis
+
+	invalidUTF8LineNo    int // line number of first line containing invalid UTF-8
+	delimInQuoteLineNo   int // line number of first DELIMITER command found inside of a quoted string
+	delimInCommentLineNo int // line number of first DELIMITER command found inside of a C-style comment
 }
 
 type lineState struct {
@@ -147,30 +186,63 @@ func newStatementTokenizer(filePath, delimiter string) *statementTokenizer {
 	}
 }
 
+const utf8BOM = "\xEF\xBB\xBF"
+
+// statements tokenizes the entire file, returning a slice of all statements.
+// If an error occurs, the statements tokenized so far are still returned.
 func (st *statementTokenizer) statements() ([]*Statement, error) {
 	file, err := os.Open(st.filePath)
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
-	reader := bufio.NewReader(file)
+	var result []*Statement
+	err = st.stream(file, func(stmt *Statement) error {
+		result = append(result, stmt)
+		return nil
+	})
+	return result, err
+}
+
+// stream tokenizes the contents of r, calling fn on each statement as soon as
+// it has been completely tokenized. Only one line of input, plus the text of
+// the current statement, is held in memory at a time. If fn returns an error,
+// tokenization stops and that error is returned.
+func (st *statementTokenizer) stream(r io.Reader, fn func(*Statement) error) (err error) {
+	st.emit = fn
+	reader := bufio.NewReader(r)
+
+	// Skip over a UTF-8 byte order mark, if present. Some editors on Windows add
+	// these, but they aren't meaningful to the database server.
+	if bom, _ := reader.Peek(len(utf8BOM)); string(bom) == utf8BOM {
+		reader.Discard(len(utf8BOM))
+		st.lineOffset = len(utf8BOM)
+	}
 
 	for err != io.EOF {
 		var line string
 		line, err = reader.ReadString('\n')
 		if err != nil && err != io.EOF {
-			return st.result, err
+			return err
 		}
 		st.processLine(line, err == io.EOF)
+		if st.emitErr != nil {
+			return st.emitErr
+		}
+		st.lineOffset += len(line)
 	}
-	if st.inQuote != 0 {
-		err = fmt.Errorf("File %s has unterminated quote %c", st.filePath, st.inQuote)
+	if st.invalidUTF8LineNo > 0 {
+		return fmt.Errorf("File %s line %d: invalid UTF-8 encoding; file must be converted to UTF-8", st.filePath, st.invalidUTF8LineNo)
+	} else if st.delimInQuoteLineNo > 0 {
+		return fmt.Errorf("File %s line %d: DELIMITER command found inside of a quoted string; check for an unbalanced quote earlier in the file", st.filePath, st.delimInQuoteLineNo)
+	} else if st.inQuote != 0 {
+		return fmt.Errorf("File %s has unterminated quote %c", st.filePath, st.inQuote)
+	} else if st.inCComment && st.delimInCommentLineNo > 0 {
+		return fmt.Errorf("File %s line %d: DELIMITER command found inside of an unterminated C-style comment", st.filePath, st.delimInCommentLineNo)
 	} else if st.inCComment {
-		err = fmt.Errorf("File %s has unterminated C-style comment", st.filePath)
-	} else {
-		err = nil
+		return fmt.Errorf("File %s has unterminated C-style comment", st.filePath)
 	}
-	return st.result, err
+	return nil
 }
 
 func (st *statementTokenizer) processLine(line string, eof bool) {
@@ -180,6 +252,22 @@ func (st *statementTokenizer) processLine(line string, eof bool) {
 		line:               line,
 	}
 
+	if st.invalidUTF8LineNo == 0 && !utf8.ValidString(line) {
+		st.invalidUTF8LineNo = st.lineNo
+	}
+
+	// A DELIMITER command cannot occur inside of a quoted string or comment. If
+	// one is found in a quote, this almost always means an earlier quote was not
+	// properly balanced. In a comment it is only a problem if the comment never
+	// gets terminated, since commenting out a block of DDL is perfectly valid.
+	if reDelimiterLine.MatchString(line) {
+		if st.inQuote != 0 && st.delimInQuoteLineNo == 0 {
+			st.delimInQuoteLineNo = st.lineNo
+		} else if st.inCComment && st.delimInCommentLineNo == 0 {
+			st.delimInCommentLineNo = st.lineNo
+		}
+	}
+
 	for ls.pos < len(ls.line) {
 		c, cLen := ls.nextRune()
 		if ls.stmt == nil {
@@ -189,6 +277,9 @@ func (st *statementTokenizer) processLine(line string, eof bool) {
 			if c == '*' && ls.peekRune() == '/' {
 				ls.nextRune()
 				ls.inCComment = false
+				if ls.inTrailingComment {
+					ls.doneTrailingComment()
+				}
 			}
 			continue
 		} else if ls.inQuote > 0 {
@@ -204,8 +295,14 @@ func (st *statementTokenizer) processLine(line string, eof bool) {
 			continue
 		}
 
-		// C-style comment can be multi-line
+		// C-style comment can be multi-line. A version-gated comment (/*!...*/) at
+		// the start of a statement is executable, rather than ignorable whitespace,
+		// so it begins a relevant statement of its own.
 		if c == '/' && ls.peekRune() == '*' {
+			if !ls.inRelevant && ls.peekRunes(2) == "*!" {
+				ls.doneStatement(cLen)
+				ls.inRelevant = true
+			}
 			ls.inCComment = true
 			ls.nextRune()
 			continue
@@ -234,6 +331,10 @@ func (st *statementTokenizer) processLine(line string, eof bool) {
 			ls.inRelevant = true
 		}
 
+		if c != '"' && c != '`' && c != '\'' {
+			ls.trackRune(c)
+		}
+
 		delimFirstRune, delimFirstRuneLen := utf8.DecodeRuneInString(st.delimiter)
 		delimRuneCount := utf8.RuneCountInString(st.delimiter)
 		switch c {
@@ -246,6 +347,12 @@ func (st *statementTokenizer) processLine(line string, eof bool) {
 			}
 		case '"', '`', '\'':
 			ls.inQuote = c
+			if c == '`' {
+				ls.stmt.IdentifierQuote = c
+			} else if c == '"' && ls.stmt.IdentifierQuote == 0 && ls.doubleQuoteIsIdentifier() {
+				ls.stmt.IdentifierQuote = c
+			}
+			ls.trackRune(c)
 		case delimFirstRune:
 			// Multi-rune delimiter: peek ahead to see if we've matched the full
 			// delimiter. If so, slurp up the rest of the delimiter's runes.
@@ -257,8 +364,26 @@ func (st *statementTokenizer) processLine(line string, eof bool) {
 					ls.nextRune()
 				}
 			}
-			// Slurp up a single trailing newline, if present
-			if ls.peekRune() == '\n' {
+			// If the rest of the line is just a single-line comment, it belongs to
+			// this statement, so slurp it up along with the newline. A C-style
+			// comment which is not terminated on this line also belongs to this
+			// statement, which remains open until the comment ends. Otherwise just
+			// slurp up a single trailing newline, if present.
+			rest := ls.line[ls.pos:]
+			if comment := trailingComment(rest); comment != "" {
+				ls.stmt.TrailingComment = comment
+				ls.buf.WriteString(rest)
+				ls.pos = len(ls.line)
+			} else if trimmed := strings.TrimLeftFunc(rest, unicode.IsSpace); strings.HasPrefix(trimmed, "/*") && !strings.HasPrefix(trimmed, "/*!") && !strings.Contains(trimmed[2:], "*/") {
+				for ls.pos < len(ls.line)-len(trimmed) {
+					ls.nextRune()
+				}
+				ls.trailingCommentStart = ls.buf.Len()
+				ls.nextRune()
+				ls.nextRune()
+				ls.inCComment, ls.inTrailingComment = true, true
+				break
+			} else if ls.peekRune() == '\n' {
 				ls.nextRune()
 			}
 			ls.doneStatement(0)
@@ -271,6 +396,19 @@ func (st *statementTokenizer) processLine(line string, eof bool) {
 	}
 }
 
+// doneTrailingComment finalizes the current statement once its multi-line
+// trailing C-style comment has been terminated. If the rest of the line is
+// only whitespace, it is included in the statement as well.
+func (ls *lineState) doneTrailingComment() {
+	ls.inTrailingComment = false
+	ls.stmt.TrailingComment = string(ls.buf.Bytes()[ls.trailingCommentStart:])
+	if rest := ls.line[ls.pos:]; strings.TrimSpace(rest) == "" {
+		ls.buf.WriteString(rest)
+		ls.pos = len(ls.line)
+	}
+	ls.doneStatement(0)
+}
+
 // nextRune returns the rune at the current position, along with its length
 // in bytes. It also advances to the next position.
 func (ls *lineState) nextRune() (rune, int) {
@@ -306,20 +444,48 @@ func (ls *lineState) peekRunes(n int) string {
 }
 
 // beginStatement records the starting position of the next (not yet fully
-// tokenized) statement.
+// tokenized) statement. At this point, the buffer only contains bytes from the
+// current line which belong to the new statement, so these are subtracted
+// from the current position to obtain the byte offset.
 func (ls *lineState) beginStatement() {
 	ls.stmt = &Statement{
 		File:            ls.filePath,
 		LineNo:          ls.lineNo,
 		CharNo:          ls.charNo,
+		ByteOffset:      ls.lineOffset + ls.pos - ls.buf.Len(),
 		DefaultDatabase: ls.defaultDatabase,
 		delimiter:       ls.delimiter,
 	}
+	ls.lastRune, ls.lastWord, ls.inWord, ls.parenWords = 0, "", false, nil
+}
+
+// trackRune updates the state used by doubleQuoteIsIdentifier, based on c,
+// which must not be inside of a quoted string or comment. Opening quote runes
+// should be supplied only after classifying the quote.
+func (ls *lineState) trackRune(c rune) {
+	if unicode.IsSpace(c) {
+		ls.inWord = false
+		return
+	}
+	if c == '(' {
+		ls.parenWords = append(ls.parenWords, ls.lastWord)
+	} else if c == ')' && len(ls.parenWords) > 0 {
+		ls.parenWords = ls.parenWords[:len(ls.parenWords)-1]
+	}
+	ls.lastRune = c
+	if unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == '$' {
+		if !ls.inWord {
+			ls.lastWord, ls.inWord = "", true
+		}
+		ls.lastWord += string(unicode.ToLower(c))
+	} else {
+		ls.lastWord, ls.inWord = "", false
+	}
 }
 
 // doneStatement finalizes the current statement by filling in its text
 // field with the buffer contents, optionally excluding the last omitEndBytes
-// bytes of the buffer. It then puts this statement onto the result slice,
+// bytes of the buffer. It then passes this statement to the emit callback,
 // and cleans up bookkeeping state in preparation for the next statement.
 func (ls *lineState) doneStatement(omitEndBytes int) {
 	bufLen := ls.buf.Len()
@@ -328,7 +494,9 @@ func (ls *lineState) doneStatement(omitEndBytes int) {
 	}
 	ls.stmt.Text = fmt.Sprintf("%s", ls.buf.Next(bufLen-omitEndBytes))
 	ls.parseStatement()
-	ls.result = append(ls.result, ls.stmt)
+	if ls.emitErr == nil {
+		ls.emitErr = ls.emit(ls.stmt)
+	}
 	ls.stmt = nil
 	if omitEndBytes == 0 {
 		ls.buf.Reset()
@@ -342,6 +510,8 @@ func (ls *lineState) parseStatement() {
 	txt, _ := ls.stmt.SplitTextBody()
 	if !ls.inRelevant || txt == "" {
 		ls.stmt.Type = StatementTypeNoop
+	} else if strings.HasPrefix(txt, "/*!") && strings.Index(txt, "*/") == len(txt)-2 {
+		ls.stmt.Type = StatementTypeCommand
 	} else {
 		sqlStmt := &sqlStatement{}
 		if err := nameParser.ParseString(txt, sqlStmt); err != nil {
@@ -368,6 +538,66 @@ func (ls *lineState) parseStatement() {
 	}
 }
 
+// trailingComment examines the remainder of a line after a statement's
+// delimiter. If the remainder consists solely of whitespace and a single-line
+// comment, the comment is returned without surrounding whitespace. Otherwise
+// an empty string is returned. C-style comments only qualify if they are
+// terminated on the same line and are not version-gated (executable)
+// comments; the tokenizer handles trailing C-style comments spanning multiple
+// lines separately.
+func trailingComment(rest string) string {
+	comment := strings.TrimSpace(rest)
+	if strings.HasPrefix(comment, "#") {
+		return comment
+	} else if comment == "--" || (strings.HasPrefix(comment, "--") && unicode.IsSpace(rune(comment[2]))) {
+		return comment
+	} else if strings.HasPrefix(comment, "/*") && !strings.HasPrefix(comment, "/*!") && strings.Index(comment, "*/") == len(comment)-2 && len(comment) >= 4 {
+		return comment
+	}
+	return ""
+}
+
+// literalPrefixWords are keywords which may be directly followed by a string
+// literal, but not an identifier.
+var literalPrefixWords = map[string]bool{
+	"default": true, "comment": true, "values": true, "value": true, "in": true,
+	"like": true, "regexp": true, "rlike": true, "when": true, "then": true,
+	"else": true, "and": true, "or": true, "xor": true, "not": true, "is": true,
+	"between": true, "separator": true, "escape": true, "by": true,
+}
+
+// literalListWords are keywords which may be followed by a parenthesized list
+// of string literals, but not identifiers.
+var literalListWords = map[string]bool{
+	"enum": true, "set": true, "values": true, "value": true, "in": true, "default": true,
+}
+
+// doubleQuoteIsIdentifier returns true if a double-quote rune, which opens a
+// quoted string at the current position, appears where an identifier is
+// expected rather than a string literal. With the ANSI_QUOTES sql_mode,
+// double-quotes may be used around identifiers; otherwise they are equivalent
+// to single-quotes. This is a heuristic based on the preceding token, so it is
+// not exhaustive.
+func (ls *lineState) doubleQuoteIsIdentifier() bool {
+	switch {
+	case ls.lastRune == 0:
+		return false
+	case ls.lastRune == '.':
+		return true
+	case ls.lastRune == '(' || ls.lastRune == ',':
+		var parenWord string
+		if len(ls.parenWords) > 0 {
+			parenWord = ls.parenWords[len(ls.parenWords)-1]
+		}
+		return !literalListWords[parenWord]
+	case strings.ContainsRune("=<>!+-*/%|&^~:", ls.lastRune):
+		return false
+	}
+	return !literalPrefixWords[ls.lastWord]
+}
+
+var reDelimiterLine = regexp.MustCompile(`(?i)^\s*delimiter\s`)
+
 func stripBackticks(input string) string {
 	if len(input) < 2 || input[0] != '`' || input[len(input)-1] != '`' {
 		return input
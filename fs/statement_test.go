@@ -69,6 +69,39 @@ func TestStatementSplitTextBody(t *testing.T) {
 	}
 }
 
+func TestStatementSplitTextBodyTrailingComment(t *testing.T) {
+	stmt := &Statement{
+		Text:            "CREATE TABLE foo (id int); -- owned by billing\n",
+		TrailingComment: "-- owned by billing",
+		delimiter:       ";",
+	}
+	body, suffix := stmt.SplitTextBody()
+	if body != "CREATE TABLE foo (id int)" || suffix != "; -- owned by billing\n" {
+		t.Errorf("Unexpected return from SplitTextBody: %#v, %#v", body, suffix)
+	}
+}
+
+func TestTrailingComment(t *testing.T) {
+	cases := map[string]string{
+		"\n":                         "",
+		"   \n":                      "",
+		" -- owned by billing\n":     "-- owned by billing",
+		"\t# hash comment":           "# hash comment",
+		" --\n":                      "--",
+		" --nope\n":                  "",
+		" /* inline */ \n":           "/* inline */",
+		" /* starts a block\n":       "",
+		" /* one */ /* two */\n":     "",
+		" /*!40101 SET x=1 */\n":     "",
+		" CREATE TABLE bar (id int)": "",
+	}
+	for input, expected := range cases {
+		if actual := trailingComment(input); actual != expected {
+			t.Errorf("trailingComment on %#v: expected %#v, found %#v", input, expected, actual)
+		}
+	}
+}
+
 func TestStripAnyQuote(t *testing.T) {
 	cases := map[string]string{
 		"":                "",
@@ -103,3 +136,19 @@ func TestCanParse(t *testing.T) {
 		}
 	}
 }
+
+func TestStatementTypeString(t *testing.T) {
+	cases := map[StatementType]string{
+		StatementTypeUnknown: "unknown",
+		StatementTypeNoop:    "noop",
+		StatementTypeCommand: "command",
+		StatementTypeCreate:  "create",
+		StatementTypeAlter:   "alter",
+		StatementType(999):   "unknown",
+	}
+	for input, expected := range cases {
+		if actual := input.String(); actual != expected {
+			t.Errorf("Expected StatementType(%d).String() to return %s, instead found %s", input, expected, actual)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package linter
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/skeema/mybase"
@@ -17,24 +18,99 @@ type Severity string
 const (
 	SeverityError   Severity = "error"
 	SeverityWarning Severity = "warning"
+	SeverityIgnore  Severity = "ignore" // only valid in lint-* options; never stored in Options.ProblemSeverity
 )
 
+// defaultWarnings is the default value of the warnings option.
+const defaultWarnings = "bad-charset,bad-engine,no-pk,no-pk-replication"
+
 // AddCommandOptions adds linting-related mybase options to the supplied
 // mybase.Command.
 func AddCommandOptions(cmd *mybase.Command) {
-	cmd.AddOption(mybase.StringOption("warnings", 0, "bad-charset,bad-engine,no-pk", "Linter problems to display as warnings (non-fatal); see manual for usage"))
+	cmd.AddOption(mybase.StringOption("warnings", 0, defaultWarnings, "Linter problems to display as warnings (non-fatal); see manual for usage"))
 	cmd.AddOption(mybase.StringOption("errors", 0, "", "Linter problems to treat as fatal errors; see manual for usage"))
-	cmd.AddOption(mybase.StringOption("allow-charset", 0, "latin1,utf8mb4", "Whitelist of acceptable character sets"))
-	cmd.AddOption(mybase.StringOption("allow-engine", 0, "innodb", "Whitelist of acceptable storage engines"))
+	for _, name := range listOptionNames {
+		lo := listOptions[name]
+		cmd.AddOption(mybase.StringOption(lo.Name, 0, lo.Default, lo.Description))
+	}
+	for _, name := range allProblemNames() {
+		cmd.AddOption(mybase.StringOption("lint-"+name, 0, "", fmt.Sprintf("Severity of linter problem %s, overriding warnings and errors", name)).Hidden())
+	}
+	cmd.AddOption(mybase.StringOption("index-prefix-threshold", 0, "25", "Percentage of column length below which short-index-prefix is flagged"))
+	cmd.AddOption(mybase.BoolOption("nullable-unique-single", 0, false, "Also flag single-column unique indexes for nullable-unique"))
+	cmd.AddOption(mybase.StringOption("large-table-rows", 0, "1000000", "Estimated row count at which no-secondary-index is flagged"))
+	cmd.AddOption(mybase.StringOption("enum-values-threshold", 0, "20", "Number of ENUM or SET values above which many-enum-values is flagged"))
+	cmd.AddOption(mybase.StringOption("index-count-threshold", 0, "10", "Number of indexes in a table above which many-indexes is flagged"))
+	cmd.AddOption(mybase.StringOption("money-column-pattern", 0, "(?i)price|amount|cost", "Regular expression matching names of monetary columns for money-scale"))
+	cmd.AddOption(mybase.StringOption("auto-inc-threshold", 0, "80", "Percentage of column type's maximum value at which auto-inc-capacity is flagged"))
 }
 
 // Options contains parsed settings controlling linter behavior.
 type Options struct {
-	ProblemSeverity map[string]Severity
-	AllowedCharSets []string
-	AllowedEngines  []string
-	IgnoreSchema    *regexp.Regexp
-	IgnoreTable     *regexp.Regexp
+	ProblemSeverity      map[string]Severity
+	Lists                map[string][]string // list option name => values
+	AutoIncThreshold     int
+	IndexPrefixThreshold int
+	EnumValuesThreshold  int
+	IndexCountThreshold  int
+	NullableUniqueSingle bool
+	LargeTableRows       int
+	MoneyColumnPattern   *regexp.Regexp
+	TableRows            map[string]int64            // estimated row counts of live tables, if available
+	IndexCardinality     map[string]map[string]int64 // estimated cardinality of live indexes by table and index name, if available
+	NextAutoIncrement    map[string]uint64           // next AUTO_INCREMENT values of live tables, if available
+	Collations           map[string][]string         // collations of each lowercased character set name on a live instance, if available
+	Flavor               tengo.Flavor
+	FlavorPatch          int // patch number of Flavor's version, if known from a live instance
+	IgnoreSchema         *regexp.Regexp
+	IgnoreTable          *regexp.Regexp
+}
+
+// ListOption describes a comma-separated list option used by one or more
+// list-based problems, such as allow-charset for bad-charset. Use
+// RegisterListOption to associate a ListOption with a problem.
+type ListOption struct {
+	Name        string // option name, e.g. "allow-charset"
+	Default     string // default value of the option, as a comma-separated string
+	Description string // option description shown in help output
+	Deny        bool   // if true, the list contains disallowed values instead of allowed ones
+	Required    bool   // if true, the list must be non-empty whenever the problem is enabled
+}
+
+var listOptions map[string]ListOption // option name => ListOption
+var listOptionNames []string          // option names, in registration order
+var problemLists map[string]string    // problem name => option name
+
+// RegisterListOption associates the named problem with a list option. The
+// option is added by AddCommandOptions, and its value is then available to the
+// problem's detector via Options.AllowList and Options.IsAllowed. Several
+// problems may share the same list option by registering the same lo.Name; in
+// this case, the first registration's default and description are used. Like
+// RegisterProblem, this must be called prior to AddCommandOptions.
+func RegisterListOption(problem string, lo ListOption) {
+	if _, already := listOptions[lo.Name]; !already {
+		listOptions[lo.Name] = lo
+		listOptionNames = append(listOptionNames, lo.Name)
+	}
+	problemLists[problem] = lo.Name
+}
+
+// AllowList returns the configured values of the list option associated with
+// the named problem, or nil if the problem has no list option. For a Deny list
+// option, the returned values are the disallowed ones.
+func (opts Options) AllowList(problem string) []string {
+	return opts.Lists[problemLists[problem]]
+}
+
+// IsAllowed returns true if value is permitted by the list option associated
+// with the named problem. Comparison is case-insensitive. For a Deny list
+// option, values are permitted if they are not found in the list.
+func (opts Options) IsAllowed(problem, value string) bool {
+	found := isAllowed(value, opts.AllowList(problem))
+	if listOptions[problemLists[problem]].Deny {
+		return !found
+	}
+	return found
 }
 
 // ShouldIgnore returns true if the option configuration indicates the supplied
@@ -48,16 +124,79 @@ func (opts Options) ShouldIgnore(key tengo.ObjectKey) bool {
 	return false
 }
 
+// withoutIgnored returns copies of schema and logicalSchema which omit all
+// tables that ShouldIgnore indicates should be ignored, along with the sorted
+// keys of the omitted objects. If nothing is ignored, schema and logicalSchema
+// are returned as-is.
+func (opts Options) withoutIgnored(schema *tengo.Schema, logicalSchema *fs.LogicalSchema) (*tengo.Schema, *fs.LogicalSchema, []tengo.ObjectKey) {
+	var ignored []tengo.ObjectKey
+	for key := range logicalSchema.Creates {
+		if opts.ShouldIgnore(key) {
+			ignored = append(ignored, key)
+		}
+	}
+	if len(ignored) == 0 {
+		return schema, logicalSchema, nil
+	}
+	sort.Slice(ignored, func(i, j int) bool { return ignored[i].String() < ignored[j].String() })
+
+	filteredLogical := *logicalSchema
+	filteredLogical.Creates = make(map[tengo.ObjectKey]*fs.Statement, len(logicalSchema.Creates))
+	for key, stmt := range logicalSchema.Creates {
+		if !opts.ShouldIgnore(key) {
+			filteredLogical.Creates[key] = stmt
+		}
+	}
+	filtered := *schema
+	filtered.Tables = make([]*tengo.Table, 0, len(schema.Tables))
+	for _, table := range schema.Tables {
+		if !opts.ShouldIgnore(tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: table.Name}) {
+			filtered.Tables = append(filtered.Tables, table)
+		}
+	}
+	return &filtered, &filteredLogical, ignored
+}
+
 // OptionsForDir returns Options based on the configuration in an fs.Dir,
 // effectively converting between mybase options and linter options.
 func OptionsForDir(dir *fs.Dir) (Options, error) {
 	opts := Options{
-		ProblemSeverity: make(map[string]Severity),
-		AllowedCharSets: dir.Config.GetSlice("allow-charset", ',', true),
-		AllowedEngines:  dir.Config.GetSlice("allow-engine", ',', true),
+		ProblemSeverity:      make(map[string]Severity),
+		Lists:                make(map[string][]string),
+		NullableUniqueSingle: dir.Config.GetBool("nullable-unique-single"),
+		Flavor:               tengo.NewFlavor(dir.Config.Get("flavor")),
+	}
+	for _, name := range listOptionNames {
+		if dir.Config.FindOption(name) != nil {
+			opts.Lists[name] = dir.Config.GetSlice(name, ',', true)
+		}
 	}
 
 	var err error
+	opts.AutoIncThreshold, err = dir.Config.GetInt("auto-inc-threshold")
+	if err != nil || opts.AutoIncThreshold < 1 || opts.AutoIncThreshold > 100 {
+		return Options{}, ConfigError("Option auto-inc-threshold must be an integer between 1 and 100")
+	}
+	opts.IndexPrefixThreshold, err = dir.Config.GetInt("index-prefix-threshold")
+	if err != nil || opts.IndexPrefixThreshold < 1 || opts.IndexPrefixThreshold > 100 {
+		return Options{}, ConfigError("Option index-prefix-threshold must be an integer between 1 and 100")
+	}
+	opts.EnumValuesThreshold, err = dir.Config.GetInt("enum-values-threshold")
+	if err != nil || opts.EnumValuesThreshold < 1 {
+		return Options{}, ConfigError("Option enum-values-threshold must be a positive integer")
+	}
+	opts.IndexCountThreshold, err = dir.Config.GetInt("index-count-threshold")
+	if err != nil || opts.IndexCountThreshold < 1 {
+		return Options{}, ConfigError("Option index-count-threshold must be a positive integer")
+	}
+	opts.LargeTableRows, err = dir.Config.GetInt("large-table-rows")
+	if err != nil || opts.LargeTableRows < 1 {
+		return Options{}, ConfigError("Option large-table-rows must be a positive integer")
+	}
+	opts.MoneyColumnPattern, err = dir.Config.GetRegexp("money-column-pattern")
+	if err != nil {
+		return Options{}, ConfigError(err.Error())
+	}
 	opts.IgnoreSchema, err = dir.Config.GetRegexp("ignore-schema")
 	if err != nil {
 		return Options{}, ConfigError(err.Error())
@@ -86,19 +225,41 @@ func OptionsForDir(dir *fs.Dir) (Options, error) {
 		opts.ProblemSeverity[val] = SeverityError
 	}
 
-	// For list-based problems, confirm corresponding list is non-empty
-	problemToList := map[string][]string{
-		"bad-charset": opts.AllowedCharSets,
-		"bad-engine":  opts.AllowedEngines,
+	// Per-problem lint-* options take precedence over warnings and errors. The
+	// full precedence order, from lowest to highest, is therefore:
+	//   1. the default value of the warnings option
+	//   2. warnings and errors set in option files or on the command-line
+	//   3. lint-* options set in option files or on the command-line
+	// Within the option files in layers 2 and 3, mybase already applies the
+	// usual rules: a dir's .skeema overrides its parent dirs' files, and within
+	// any one file, the section for the current environment overrides the
+	// top-level section. Command-line values override all option files.
+	for _, name := range allProblemNames() {
+		if dir.Config.FindOption("lint-"+name) == nil {
+			continue // problem was registered after AddCommandOptions was called
+		}
+		switch val := strings.ToLower(dir.Config.Get("lint-" + name)); Severity(val) {
+		case "":
+			continue
+		case SeverityError, SeverityWarning:
+			opts.ProblemSeverity[name] = Severity(val)
+		case SeverityIgnore:
+			delete(opts.ProblemSeverity, name)
+		default:
+			return Options{}, ConfigError(fmt.Sprintf("Option lint-%s must be one of: error, warning, ignore", name))
+		}
 	}
-	for problem, listOption := range problemToList {
+
+	// For list-based problems with a required list, confirm corresponding list is
+	// non-empty
+	for problem, listOption := range problemLists {
 		severity, ok := opts.ProblemSeverity[problem]
-		if ok && len(listOption) == 0 {
+		if ok && listOptions[listOption].Required && len(opts.Lists[listOption]) == 0 {
 			errStr := fmt.Sprintf(
 				"With option %ss=%s, corresponding option %s must be non-empty",
 				string(severity),
 				problem,
-				strings.Replace(problem, "bad-", "allow-", -1))
+				listOption)
 			return Options{}, ConfigError(errStr)
 		}
 	}
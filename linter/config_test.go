@@ -4,6 +4,9 @@ import (
 	"reflect"
 	"regexp"
 	"testing"
+
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/tengo"
 )
 
 func TestOptionsForDir(t *testing.T) {
@@ -17,16 +20,48 @@ func TestOptionsForDir(t *testing.T) {
 				"bad-charset": SeverityWarning,
 				"bad-engine":  SeverityWarning,
 			},
-			AllowedCharSets: []string{"utf8mb4"},
-			AllowedEngines:  []string{"innodb", "myisam"},
-			IgnoreSchema:    regexp.MustCompile(`^metadata$`),
-			IgnoreTable:     regexp.MustCompile(`^_`),
+			Lists: map[string][]string{
+				"allow-charset":        {"utf8mb4"},
+				"allow-engine":         {"innodb", "myisam"},
+				"allow-legacy-charset": {"latin1"},
+				"allow-collation":      {},
+				"allow-utf8mb3":        {},
+			},
+			AutoIncThreshold:     80,
+			IndexPrefixThreshold: 25,
+			EnumValuesThreshold:  20,
+			IndexCountThreshold:  10,
+			LargeTableRows:       1000000,
+			MoneyColumnPattern:   regexp.MustCompile(`(?i)price|amount|cost`),
+			IgnoreSchema:         regexp.MustCompile(`^metadata$`),
+			IgnoreTable:          regexp.MustCompile(`^_`),
 		}
 		if !reflect.DeepEqual(opts, expected) {
 			t.Errorf("OptionsForDir returned %+v, did not match expectation %+v", opts, expected)
 		}
 	}
 
+	// Confirm each layer of severity precedence: defaults, warnings and errors,
+	// lint-* options in the environment's section, and lint-* options on the
+	// command-line
+	severityCases := []struct {
+		cliArgs  string
+		expected map[string]Severity
+	}{
+		{"--skip-warnings --skip-errors", map[string]Severity{}},
+		{"--errors='' --warnings=no-pk", map[string]Severity{"no-pk": SeverityWarning}},
+		{"development", map[string]Severity{"no-pk": SeverityWarning, "bad-charset": SeverityWarning, "explicit-engine": SeverityError}},
+		{"development --lint-no-pk=ignore --lint-bad-charset=ERROR", map[string]Severity{"bad-charset": SeverityError, "explicit-engine": SeverityError}},
+	}
+	for _, c := range severityCases {
+		dir := getDir(t, "../testdata/linter/validcfg", c.cliArgs)
+		if opts, err := OptionsForDir(dir); err != nil {
+			t.Errorf("Unexpected error from OptionsForDir with CLI %s: %s", c.cliArgs, err)
+		} else if !reflect.DeepEqual(opts.ProblemSeverity, c.expected) {
+			t.Errorf("With CLI %s, expected ProblemSeverity %v, instead found %v", c.cliArgs, c.expected, opts.ProblemSeverity)
+		}
+	}
+
 	// Coverage for error conditions
 	badOptions := []string{
 		"--errors=made-up-problem",
@@ -35,6 +70,19 @@ func TestOptionsForDir(t *testing.T) {
 		"--ignore-schema=+",
 		"--allow-charset=''",
 		"--allow-engine='' --errors=''",
+		"--allow-engine='' --warnings=explicit-engine",
+		"--allow-collation='' --warnings=bad-collation",
+		"--auto-inc-threshold=0",
+		"--auto-inc-threshold=101",
+		"--auto-inc-threshold=lots",
+		"--index-prefix-threshold=0",
+		"--index-prefix-threshold=120",
+		"--enum-values-threshold=0",
+		"--index-count-threshold=0",
+		"--large-table-rows=0",
+		"--money-column-pattern=+",
+		"--money-column-pattern=(",
+		"--lint-no-pk=fatal",
 	}
 	confirmError := func(cliArgs string) {
 		t.Helper()
@@ -56,3 +104,72 @@ func TestOptionsForDir(t *testing.T) {
 		t.Errorf("ConfigError not behaving as expected")
 	}
 }
+
+func TestOptionsIsAllowed(t *testing.T) {
+	opts := Options{
+		Lists: map[string][]string{
+			"allow-engine": {"InnoDB", "myisam"},
+		},
+	}
+	if !opts.IsAllowed("bad-engine", "innodb") || !opts.IsAllowed("explicit-engine", "MyISAM") {
+		t.Error("Expected engines to be allowed, but they were not")
+	}
+	if opts.IsAllowed("bad-engine", "memory") {
+		t.Error("Expected engine memory to not be allowed, but it was")
+	}
+	if actual := opts.AllowList("explicit-engine"); !reflect.DeepEqual(actual, opts.Lists["allow-engine"]) {
+		t.Errorf("Unexpected result from AllowList: %v", actual)
+	}
+	if opts.AllowList("no-pk") != nil || opts.IsAllowed("no-pk", "anything") {
+		t.Error("Expected problem without a list option to have an empty allow list")
+	}
+
+	// Register a new problem with a deny list; confirm values found in the list
+	// are not allowed, and all others are
+	RegisterProblem("new-prob", nil)
+	RegisterListOption("new-prob", ListOption{Name: "deny-thing", Deny: true})
+	defer func() {
+		// Clean up the global state
+		delete(problems, "new-prob")
+		delete(problemLists, "new-prob")
+		delete(listOptions, "deny-thing")
+		listOptionNames = listOptionNames[:len(listOptionNames)-1]
+	}()
+	opts.Lists["deny-thing"] = []string{"foo", "bar"}
+	if opts.IsAllowed("new-prob", "FOO") || !opts.IsAllowed("new-prob", "baz") {
+		t.Error("Deny list option not behaving as expected")
+	}
+}
+
+func TestOptionsWithoutIgnored(t *testing.T) {
+	logicalSchema := &fs.LogicalSchema{Name: "whatever", Creates: make(map[tengo.ObjectKey]*fs.Statement)}
+	schema := &tengo.Schema{Name: "whatever"}
+	for _, name := range []string{"users", "_users_old", "_posts_new", "posts"} {
+		stmt := &fs.Statement{Text: "CREATE TABLE " + name + " (id int)", Type: fs.StatementTypeCreate, ObjectType: tengo.ObjectTypeTable, ObjectName: name}
+		logicalSchema.Creates[stmt.ObjectKey()] = stmt
+		schema.Tables = append(schema.Tables, &tengo.Table{Name: name})
+	}
+
+	// Without ignore-table, the originals are returned
+	var opts Options
+	if s, ls, ignored := opts.withoutIgnored(schema, logicalSchema); s != schema || ls != logicalSchema || ignored != nil {
+		t.Errorf("Expected original schemas to be returned, instead found %v, %v, %v", s, ls, ignored)
+	}
+
+	// Matching tables are removed from copies, leaving originals untouched
+	opts.IgnoreTable = regexp.MustCompile(`^_`)
+	s, ls, ignored := opts.withoutIgnored(schema, logicalSchema)
+	expectedIgnored := []tengo.ObjectKey{
+		{Type: tengo.ObjectTypeTable, Name: "_posts_new"},
+		{Type: tengo.ObjectTypeTable, Name: "_users_old"},
+	}
+	if !reflect.DeepEqual(ignored, expectedIgnored) {
+		t.Errorf("Expected ignored keys %v, instead found %v", expectedIgnored, ignored)
+	}
+	if len(s.Tables) != 2 || s.Tables[0].Name != "users" || s.Tables[1].Name != "posts" || len(ls.Creates) != 2 || ls.Name != "whatever" {
+		t.Errorf("Unexpected filtered schemas: %+v, %+v", s, ls)
+	}
+	if len(schema.Tables) != 4 || len(logicalSchema.Creates) != 4 {
+		t.Error("Original schemas were unexpectedly modified")
+	}
+}
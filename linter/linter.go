@@ -2,9 +2,13 @@
 package linter
 
 import (
+	"database/sql"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/skeema/skeema/fs"
+	"github.com/skeema/skeema/util"
 	"github.com/skeema/skeema/workspace"
 	"github.com/skeema/tengo"
 )
@@ -17,6 +21,35 @@ type Annotation struct {
 	Summary    string
 	Message    string
 	Problem    string
+	Severity   Severity        // SeverityError or SeverityWarning; empty for format notices
+	Fixes      []*Fix          // optional suggested fixes, in order of preference
+	ObjectKey  tengo.ObjectKey // object which the annotation refers to; defaults to the Statement's object
+	ColumnName string          // column which the annotation refers to, if specific to one column
+}
+
+// Fix is a suggested remedy for the problem described by an Annotation. A fix
+// may include DDL to run against a live database, an edit to the annotated
+// statement's text, or both.
+type Fix struct {
+	Description string
+	DDL         string    // statement to execute on the live database, if any
+	Edit        *TextEdit // change to apply to the annotation's statement, if any
+}
+
+// TextEdit describes a replacement of a byte range within a statement's Text.
+type TextEdit struct {
+	Start       int // byte offset of the start of the range, inclusive
+	End         int // byte offset of the end of the range, exclusive
+	Replacement string
+}
+
+// Apply returns text with the edit applied. If the edit's range is invalid for
+// text, text is returned unchanged.
+func (te TextEdit) Apply(text string) string {
+	if te.Start < 0 || te.End < te.Start || te.End > len(text) {
+		return text
+	}
+	return text[:te.Start] + te.Replacement + text[te.End:]
 }
 
 // MessageWithLocation prepends statement location information to a.Message,
@@ -54,6 +87,48 @@ func (r *Result) Merge(other *Result) {
 	r.Exceptions = append(r.Exceptions, other.Exceptions...)
 }
 
+// addStatementErrors adds an error annotation to r for each statement error,
+// other than those for objects ignored by opts.
+func (r *Result) addStatementErrors(statementErrors []*workspace.StatementError, opts Options) {
+	for _, stmtErr := range statementErrors {
+		if opts.ShouldIgnore(stmtErr.ObjectKey()) {
+			continue // already logged by caller
+		}
+		r.Errors = append(r.Errors, &Annotation{
+			Statement: stmtErr.Statement,
+			ObjectKey: stmtErr.ObjectKey(),
+			Summary:   "SQL statement returned an error",
+			Message:   stmtErr.Err.Error(),
+			Severity:  SeverityError,
+		})
+	}
+}
+
+// Exit codes returned by ResultExitCode. These are consistent with the exit
+// codes of `skeema lint`.
+const (
+	ExitCodeClean    = 0 // no errors or warnings
+	ExitCodeWarnings = 1 // at least one warning, but no errors
+	ExitCodeErrors   = 2 // at least one error
+)
+
+// ResultExitCode returns an exit code based on the highest severity among the
+// supplied annotations: ExitCodeErrors if any have SeverityError,
+// ExitCodeWarnings if any have SeverityWarning, or ExitCodeClean otherwise.
+// Annotations without a severity, such as format notices, are not considered.
+func ResultExitCode(annotations []*Annotation) int {
+	code := ExitCodeClean
+	for _, a := range annotations {
+		switch a.Severity {
+		case SeverityError:
+			return ExitCodeErrors
+		case SeverityWarning:
+			code = ExitCodeWarnings
+		}
+	}
+	return code
+}
+
 // BadConfigResult returns a *Result containing a single ConfigError in the
 // Exceptions field. The supplied err will be converted to a ConfigError if it
 // is not already one.
@@ -75,6 +150,41 @@ func LintDir(dir *fs.Dir, wsOpts workspace.Options) *Result {
 	}
 
 	result := &Result{}
+
+	// Problems based on table sizes, index cardinality, or auto-increment values
+	// require information from the live schema, which is only available when a
+	// single schema is mapped to an instance. The filesystem's AUTO_INCREMENT
+	// values cannot be used, since they are omitted unless include-auto-inc is
+	// enabled.
+	_, wantRows := opts.ProblemSeverity["no-secondary-index"]
+	_, wantCardinality := opts.ProblemSeverity["low-cardinality-index"]
+	_, wantAutoInc := opts.ProblemSeverity["auto-inc-capacity"]
+	if (wantRows || wantCardinality || wantAutoInc) && wsOpts.Instance != nil {
+		if names, err := dir.SchemaNames(wsOpts.Instance); err == nil && len(names) == 1 {
+			if wantRows || wantCardinality {
+				if opts.TableRows, err = tableRowCounts(wsOpts.Instance, names[0]); err != nil {
+					result.DebugLogs = append(result.DebugLogs, fmt.Sprintf("Unable to obtain table row counts for %s: %s", names[0], err))
+				}
+			}
+			if wantCardinality {
+				if opts.IndexCardinality, err = indexCardinalities(wsOpts.Instance, names[0]); err != nil {
+					result.DebugLogs = append(result.DebugLogs, fmt.Sprintf("Unable to obtain index cardinality for %s: %s", names[0], err))
+				}
+			}
+			if wantAutoInc {
+				if opts.NextAutoIncrement, err = nextAutoIncrements(wsOpts.Instance, names[0]); err != nil {
+					result.DebugLogs = append(result.DebugLogs, fmt.Sprintf("Unable to obtain AUTO_INCREMENT values for %s: %s", names[0], err))
+				}
+			}
+		}
+	}
+
+	// Mismatched collations are described using the instance's actual list of
+	// collations for each character set, if an instance is available
+	if _, wantCollations := opts.ProblemSeverity["charset-collation-mismatch"]; wantCollations && wsOpts.Instance != nil {
+		opts.Collations = charSetCollations(wsOpts.Instance, dir.LogicalSchemas)
+	}
+
 	for _, logicalSchema := range dir.LogicalSchemas {
 		// ignore-schema is handled relatively simplistically here: skip dir entirely
 		// if any literal schema name matches the pattern, but don't bother
@@ -101,8 +211,10 @@ func LintDir(dir *fs.Dir, wsOpts workspace.Options) *Result {
 		for _, stmt := range dir.IgnoredStatements {
 			result.Warnings = append(result.Warnings, &Annotation{
 				Statement: stmt,
+				ObjectKey: stmt.ObjectKey(),
 				Summary:   "Unable to parse statement",
 				Message:   "Ignoring unsupported or unparseable SQL statement",
+				Severity:  SeverityWarning,
 			})
 		}
 	}
@@ -116,6 +228,20 @@ func LintDir(dir *fs.Dir, wsOpts workspace.Options) *Result {
 func ExecLogicalSchema(logicalSchema *fs.LogicalSchema, wsOpts workspace.Options, opts Options) (*tengo.Schema, *Result) {
 	result := &Result{}
 
+	// A read-only workspace can only check statements for syntax errors, without
+	// creating anything. Since there is no schema to introspect, problem
+	// detection and format notices are skipped.
+	if wsOpts.Type == workspace.TypeReadOnly {
+		statementErrors, err := workspace.ValidateLogicalSchema(logicalSchema, wsOpts)
+		if err != nil {
+			result.Exceptions = append(result.Exceptions, err)
+			return nil, result
+		}
+		result.addStatementErrors(statementErrors, opts)
+		result.DebugLogs = append(result.DebugLogs, "Skipping problem detection since workspace is read-only")
+		return nil, result
+	}
+
 	// Convert the logical schema from the filesystem into a real schema, using a
 	// workspace
 	schema, statementErrors, err := workspace.ExecLogicalSchema(logicalSchema, wsOpts)
@@ -123,25 +249,42 @@ func ExecLogicalSchema(logicalSchema *fs.LogicalSchema, wsOpts workspace.Options
 		result.Exceptions = append(result.Exceptions, err)
 		return nil, result
 	}
-	for _, stmtErr := range statementErrors {
-		if opts.ShouldIgnore(stmtErr.ObjectKey()) {
-			result.DebugLogs = append(result.DebugLogs, fmt.Sprintf("Skipping %s because ignore-table='%s'", stmtErr.ObjectKey(), opts.IgnoreTable))
-			continue
+
+	// Remove ignored tables before linting, so that no problem detection sees
+	// them at all
+	lintSchema, lintLogicalSchema, ignored := opts.withoutIgnored(schema, logicalSchema)
+	for _, key := range ignored {
+		result.DebugLogs = append(result.DebugLogs, fmt.Sprintf("Skipping %s because ignore-table='%s'", key, opts.IgnoreTable))
+	}
+
+	result.addStatementErrors(statementErrors, opts)
+
+	// If the flavor wasn't configured explicitly, use the workspace's flavor.
+	// The patch number is only known from a live instance, and is only used if
+	// the instance's flavor matches the configured one.
+	if wsOpts.Instance != nil {
+		instFlavor := util.InstanceFlavorVersion(wsOpts.Instance)
+		if opts.Flavor == tengo.FlavorUnknown {
+			opts.Flavor = instFlavor.Flavor
 		}
-		result.Errors = append(result.Errors, &Annotation{
-			Statement: stmtErr.Statement,
-			Summary:   "SQL statement returned an error",
-			Message:   stmtErr.Err.Error(),
-		})
+		if opts.Flavor == instFlavor.Flavor {
+			opts.FlavorPatch = instFlavor.Patch
+		}
+	} else if opts.Flavor == tengo.FlavorUnknown {
+		opts.Flavor = wsOpts.Flavor
 	}
 
-	for problemName, severity := range opts.ProblemSeverity {
-		annotations := problems[problemName](schema, logicalSchema, opts)
+	addAnnotations := func(problemName string, annotations []*Annotation) {
+		severity := opts.ProblemSeverity[problemName]
 		for _, a := range annotations {
 			a.Problem = problemName
-			if opts.ShouldIgnore(a.Statement.ObjectKey()) {
-				result.DebugLogs = append(result.DebugLogs, fmt.Sprintf("Skipping %s because ignore-table='%s'", a.Statement.ObjectKey(), opts.IgnoreTable))
-			} else if severity == SeverityWarning {
+			if a.Severity != SeverityError { // detectors may escalate to error
+				a.Severity = severity
+			}
+			if a.ObjectKey == (tengo.ObjectKey{}) {
+				a.ObjectKey = a.Statement.ObjectKey()
+			}
+			if a.Severity == SeverityWarning {
 				result.Warnings = append(result.Warnings, a)
 			} else {
 				result.Errors = append(result.Errors, a)
@@ -149,24 +292,164 @@ func ExecLogicalSchema(logicalSchema *fs.LogicalSchema, wsOpts workspace.Options
 		}
 	}
 
+	// Problems detected by table checkers are all handled together afterwards, in
+	// a single pass over the schema's tables
+	var tableProblems []string
+	for problemName := range opts.ProblemSeverity {
+		if !problemAppliesToFlavor(problemName, opts.Flavor) {
+			result.DebugLogs = append(result.DebugLogs, fmt.Sprintf("Skipping problem %s since it does not apply to flavor %s", problemName, opts.Flavor))
+		} else if _, ok := tableCheckers[problemName]; ok {
+			tableProblems = append(tableProblems, problemName)
+		} else {
+			addAnnotations(problemName, problems[problemName](lintSchema, lintLogicalSchema, opts))
+		}
+	}
+	sort.Strings(tableProblems)
+	tableResults := checkTables(lintSchema, lintLogicalSchema, tableProblems, opts)
+	for _, problemName := range tableProblems {
+		addAnnotations(problemName, tableResults[problemName])
+	}
+
 	// Compare each canonical CREATE in the real schema to each CREATE statement
 	// from the filesystem. In cases where they differ, emit a notice to reformat
 	// the file using the canonical version from the DB.
-	for key, instCreateText := range schema.ObjectDefinitions() {
-		fsStmt := logicalSchema.Creates[key]
+	for key, instCreateText := range lintSchema.ObjectDefinitions() {
+		fsStmt := lintLogicalSchema.Creates[key]
 		fsBody, fsSuffix := fsStmt.SplitTextBody()
 		if instCreateText != fsBody {
-			if opts.ShouldIgnore(key) {
-				result.DebugLogs = append(result.DebugLogs, fmt.Sprintf("Skipping %s because ignore-table='%s'", key, opts.IgnoreTable))
-			} else {
-				result.FormatNotices = append(result.FormatNotices, &Annotation{
-					Statement: fsStmt,
-					Summary:   "SQL statement should be reformatted",
-					Message:   fmt.Sprintf("%s%s", instCreateText, fsSuffix),
-				})
-			}
+			result.FormatNotices = append(result.FormatNotices, &Annotation{
+				Statement: fsStmt,
+				ObjectKey: key,
+				Summary:   "SQL statement should be reformatted",
+				Message:   fmt.Sprintf("%s%s", instCreateText, fsSuffix),
+			})
 		}
 	}
 
 	return schema, result
 }
+
+// tableRowCounts returns a map of table name to estimated row count for all
+// tables in the named schema on inst. The estimates come from
+// information_schema, and may be inaccurate for InnoDB tables.
+func tableRowCounts(inst *tengo.Instance, schemaName string) (map[string]int64, error) {
+	db, err := inst.Connect("information_schema", "")
+	if err != nil {
+		return nil, err
+	}
+	var rawTables []struct {
+		Name string        `db:"table_name"`
+		Rows sql.NullInt64 `db:"table_rows"`
+	}
+	query := `
+		SELECT  table_name AS table_name, table_rows AS table_rows
+		FROM    tables
+		WHERE   table_schema = ? AND table_type = 'BASE TABLE'`
+	if err := db.Select(&rawTables, query, schemaName); err != nil {
+		return nil, err
+	}
+	result := make(map[string]int64, len(rawTables))
+	for _, rawTable := range rawTables {
+		result[rawTable.Name] = rawTable.Rows.Int64
+	}
+	return result, nil
+}
+
+// nextAutoIncrements returns a map of table name to next AUTO_INCREMENT value
+// for all tables in the named schema on inst which have an auto-increment
+// column.
+func nextAutoIncrements(inst *tengo.Instance, schemaName string) (map[string]uint64, error) {
+	db, err := inst.Connect("information_schema", "")
+	if err != nil {
+		return nil, err
+	}
+	var rawTables []struct {
+		Name          string        `db:"table_name"`
+		AutoIncrement sql.NullInt64 `db:"auto_increment"`
+	}
+	query := `
+		SELECT  table_name AS table_name, auto_increment AS auto_increment
+		FROM    tables
+		WHERE   table_schema = ? AND table_type = 'BASE TABLE'
+		AND     auto_increment IS NOT NULL`
+	if err := db.Select(&rawTables, query, schemaName); err != nil {
+		return nil, err
+	}
+	result := make(map[string]uint64, len(rawTables))
+	for _, rawTable := range rawTables {
+		result[rawTable.Name] = uint64(rawTable.AutoIncrement.Int64)
+	}
+	return result, nil
+}
+
+// charSetCollations returns a map of lowercased character set name to the
+// names of its collations on inst, for each character set named in a CREATE
+// TABLE statement in logicalSchemas. Character sets which cannot be looked up
+// on inst are omitted. Since utf8mb3 is named utf8 in information_schema prior
+// to MySQL 8.0.30, and vice versa afterwards, both names are tried.
+func charSetCollations(inst *tengo.Instance, logicalSchemas []*fs.LogicalSchema) map[string][]string {
+	result := make(map[string][]string)
+	seen := make(map[string]bool)
+	for _, logicalSchema := range logicalSchemas {
+		for key, stmt := range logicalSchema.Creates {
+			if key.Type != tengo.ObjectTypeTable {
+				continue
+			}
+			for _, match := range charsetClause.FindAllStringSubmatch(stmt.Text, -1) {
+				charSet := strings.ToLower(match[1])
+				if seen[charSet] {
+					continue
+				}
+				seen[charSet] = true
+				names := []string{charSet}
+				if charSet == "utf8" {
+					names = append(names, "utf8mb3")
+				} else if charSet == "utf8mb3" {
+					names = append(names, "utf8")
+				}
+				for _, name := range names {
+					if collations, err := util.CollationsForCharset(name, inst); err == nil {
+						result[charSet] = collations
+						break
+					}
+				}
+			}
+		}
+	}
+	return result
+}
+
+// indexCardinalities returns a map of table name to index name to estimated
+// cardinality of the index's first column, for all tables in the named schema
+// on inst. The estimates come from information_schema, and may be inaccurate or
+// stale if the tables have not been recently analyzed.
+func indexCardinalities(inst *tengo.Instance, schemaName string) (map[string]map[string]int64, error) {
+	db, err := inst.Connect("information_schema", "")
+	if err != nil {
+		return nil, err
+	}
+	var rawIndexes []struct {
+		TableName   string        `db:"table_name"`
+		IndexName   string        `db:"index_name"`
+		Cardinality sql.NullInt64 `db:"cardinality"`
+	}
+	query := `
+		SELECT  table_name AS table_name, index_name AS index_name,
+		        cardinality AS cardinality
+		FROM    statistics
+		WHERE   table_schema = ? AND seq_in_index = 1`
+	if err := db.Select(&rawIndexes, query, schemaName); err != nil {
+		return nil, err
+	}
+	result := make(map[string]map[string]int64)
+	for _, rawIndex := range rawIndexes {
+		if !rawIndex.Cardinality.Valid {
+			continue
+		}
+		if result[rawIndex.TableName] == nil {
+			result[rawIndex.TableName] = make(map[string]int64)
+		}
+		result[rawIndex.TableName][rawIndex.IndexName] = rawIndex.Cardinality.Int64
+	}
+	return result, nil
+}
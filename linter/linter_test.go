@@ -2,6 +2,8 @@ package linter
 
 import (
 	"fmt"
+	"reflect"
+	"sort"
 	"strings"
 	"testing"
 	"time"
@@ -13,6 +15,47 @@ import (
 	"github.com/skeema/tengo"
 )
 
+func TestTextEditApply(t *testing.T) {
+	text := "hello world"
+	cases := []struct {
+		edit     TextEdit
+		expected string
+	}{
+		{TextEdit{Start: 0, End: 5, Replacement: "goodbye"}, "goodbye world"},
+		{TextEdit{Start: 5, End: 5, Replacement: ","}, "hello, world"},
+		{TextEdit{Start: 5, End: 11}, "hello"},
+		{TextEdit{Start: 6, End: 20, Replacement: "x"}, "hello world"},
+		{TextEdit{Start: 3, End: 2, Replacement: "x"}, "hello world"},
+	}
+	for _, c := range cases {
+		if actual := c.edit.Apply(text); actual != c.expected {
+			t.Errorf("Expected %+v to yield %q, instead found %q", c.edit, c.expected, actual)
+		}
+	}
+}
+
+func TestResultExitCode(t *testing.T) {
+	warning := &Annotation{Problem: "no-pk", Severity: SeverityWarning}
+	err := &Annotation{Problem: "bad-engine", Severity: SeverityError}
+	notice := &Annotation{Summary: "SQL statement should be reformatted"}
+	cases := []struct {
+		annotations []*Annotation
+		expected    int
+	}{
+		{nil, ExitCodeClean},
+		{[]*Annotation{notice}, ExitCodeClean},
+		{[]*Annotation{warning}, ExitCodeWarnings},
+		{[]*Annotation{notice, warning, warning}, ExitCodeWarnings},
+		{[]*Annotation{err}, ExitCodeErrors},
+		{[]*Annotation{warning, err, notice}, ExitCodeErrors},
+	}
+	for n, c := range cases {
+		if actual := ResultExitCode(c.annotations); actual != c.expected {
+			t.Errorf("cases[%d]: Expected exit code %d, instead found %d", n, c.expected, actual)
+		}
+	}
+}
+
 func TestLintDir(t *testing.T) {
 	// This test uses a Dockerized instance; image will be based on first value of
 	// SKEEMA_TEST_IMAGES. Skip test if not set.
@@ -103,6 +146,14 @@ func TestLintDir(t *testing.T) {
 		}
 	}
 
+	// Each annotation should have its resolved severity attached
+	if code := ResultExitCode(result.Warnings); code != ExitCodeWarnings {
+		t.Errorf("Expected warnings to yield exit code %d, instead found %d", ExitCodeWarnings, code)
+	}
+	if code := ResultExitCode(append(result.Warnings, result.Errors...)); code != ExitCodeErrors {
+		t.Errorf("Expected errors to yield exit code %d, instead found %d", ExitCodeErrors, code)
+	}
+
 	// Expect all valid tables to have formatting problems except for `fine`
 	if len(result.FormatNotices) != 6 {
 		t.Errorf("Expected 6 format notices, instead found %d", len(result.FormatNotices))
@@ -118,6 +169,45 @@ func TestLintDir(t *testing.T) {
 	if len(result.DebugLogs) != 1 {
 		t.Errorf("Expected 1 debug log, instead found %d", len(result.DebugLogs))
 	}
+
+	// testdata/linter/problems covers problems not present in validcfg. Tables
+	// which the server rejects should each have a SQL error, in addition to
+	// warnings for problems detected from their statement text.
+	dir = getDir(t, "../testdata/linter/problems")
+	result = LintDir(dir, wsOpts)
+	if len(result.Exceptions) != 0 {
+		t.Fatalf("Expected no fatal exceptions, instead found %d", len(result.Exceptions))
+	}
+	expectedErrors := []string{"mismatch", "notnulldefault", "zerodate"}
+	var actualErrors []string
+	for _, a := range result.Errors {
+		actualErrors = append(actualErrors, a.Statement.ObjectName)
+	}
+	sort.Strings(actualErrors)
+	if !reflect.DeepEqual(actualErrors, expectedErrors) {
+		t.Errorf("Expected SQL errors for tables %v, instead found %v", expectedErrors, actualErrors)
+	}
+	expectedWarnings := map[string][]string{ // problem name => "table:lineOffset"
+		"zero-date":                  {"zerodate:2", "zerodate:3"},
+		"not-null-default":           {"nodefault:2", "notnulldefault:2"},
+		"legacy-charset":             {"legacycols:2"},
+		"utf8mb3":                    {"legacycols:3"},
+		"charset-collation-mismatch": {"mismatch:2"},
+		"explicit-engine":            {"noengine:0"},
+		"nullable-unique":            {"nullunique:5"},
+		"money-scale":                {"orders:2"},
+		"many-enum-values":           {"orders:4"},
+	}
+	actualWarnings := make(map[string][]string)
+	for _, a := range result.Warnings {
+		actualWarnings[a.Problem] = append(actualWarnings[a.Problem], fmt.Sprintf("%s:%d", a.Statement.ObjectName, a.LineOffset))
+	}
+	for problemName := range actualWarnings {
+		sort.Strings(actualWarnings[problemName])
+	}
+	if !reflect.DeepEqual(actualWarnings, expectedWarnings) {
+		t.Errorf("Expected warnings %v, instead found %v", expectedWarnings, actualWarnings)
+	}
 }
 
 func TestLintDirIgnoreSchema(t *testing.T) {
@@ -0,0 +1,202 @@
+package linter
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sort"
+)
+
+// Record is a flattened, machine-readable representation of an Annotation,
+// suitable for serialization.
+type Record struct {
+	File       string   `json:"file,omitempty"`
+	Line       int      `json:"line,omitempty"`   // absolute line number, or 0 if unknown
+	Column     int      `json:"column,omitempty"` // only populated if the annotation refers to the statement's first line
+	ObjectType string   `json:"objectType,omitempty"`
+	ObjectName string   `json:"objectName,omitempty"`
+	ColumnName string   `json:"columnName,omitempty"` // only populated if the annotation refers to a specific column
+	Rule       string   `json:"rule"`
+	Severity   Severity `json:"severity"`
+	Summary    string   `json:"summary"`
+	Message    string   `json:"message"`
+}
+
+// Record converts the annotation into a Record. For annotations which are not
+// associated with a linter problem, such as SQL errors or unparseable
+// statements, a rule identifier is derived from the annotation's severity.
+func (a *Annotation) Record() Record {
+	rec := Record{
+		ObjectType: string(a.ObjectKey.Type),
+		ObjectName: a.ObjectKey.Name,
+		ColumnName: a.ColumnName,
+		Rule:       a.Problem,
+		Severity:   a.Severity,
+		Summary:    a.Summary,
+		Message:    a.Message,
+	}
+	if rec.Rule == "" && a.Severity == SeverityError {
+		rec.Rule = "invalid-sql"
+	} else if rec.Rule == "" {
+		rec.Rule = "unsupported-statement"
+	}
+	if a.Statement != nil {
+		rec.File = a.Statement.File
+		if a.Statement.LineNo > 0 {
+			rec.Line = a.Statement.LineNo + a.LineOffset
+			if a.LineOffset == 0 {
+				rec.Column = a.Statement.CharNo
+			}
+		}
+	}
+	return rec
+}
+
+// Records returns Records for all errors and warnings in the result, sorted by
+// file and line. Format notices are not included, since they do not represent
+// problems.
+func (r *Result) Records() []Record {
+	records := make([]Record, 0, len(r.Errors)+len(r.Warnings))
+	for _, a := range r.Errors {
+		records = append(records, a.Record())
+	}
+	for _, a := range r.Warnings {
+		records = append(records, a.Record())
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].File != records[j].File {
+			return records[i].File < records[j].File
+		}
+		return records[i].Line < records[j].Line
+	})
+	return records
+}
+
+// MarshalJSON returns a JSON array of the result's Records.
+func (r *Result) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.Records())
+}
+
+// sarifLog and its related types represent the subset of the SARIF 2.1.0
+// format used by MarshalSARIF.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// MarshalSARIF returns the result's errors and warnings in SARIF 2.1.0 format,
+// as consumed by code scanning tools such as GitHub's. File paths are
+// converted to forward slashes, but are otherwise used as-is; callers wanting
+// repository-relative paths should run the linter from the repository root
+// using relative paths.
+func (r *Result) MarshalSARIF() ([]byte, error) {
+	run := sarifRun{
+		Tool: sarifTool{
+			Driver: sarifDriver{
+				Name:           "skeema",
+				InformationURI: "https://www.skeema.io",
+				Rules:          []sarifRule{},
+			},
+		},
+		Results: []sarifResult{},
+	}
+	seenRules := make(map[string]bool)
+	for _, rec := range r.Records() {
+		if !seenRules[rec.Rule] {
+			seenRules[rec.Rule] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: rec.Rule, ShortDescription: sarifMessage{Text: rec.Summary}})
+		}
+		result := sarifResult{
+			RuleID:  rec.Rule,
+			Level:   string(rec.Severity),
+			Message: sarifMessage{Text: rec.Message},
+		}
+		var loc sarifLocation
+		if rec.File != "" {
+			loc.PhysicalLocation = &sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(rec.File)},
+			}
+			if rec.Line > 0 {
+				loc.PhysicalLocation.Region = &sarifRegion{StartLine: rec.Line, StartColumn: rec.Column}
+			}
+		}
+		if rec.ColumnName != "" {
+			loc.LogicalLocations = []sarifLogicalLocation{{
+				Name:               rec.ColumnName,
+				FullyQualifiedName: rec.ObjectName + "." + rec.ColumnName,
+				Kind:               "column",
+			}}
+		} else if rec.ObjectName != "" {
+			loc.LogicalLocations = []sarifLogicalLocation{{
+				Name:               rec.ObjectName,
+				FullyQualifiedName: rec.ObjectName,
+				Kind:               rec.ObjectType,
+			}}
+		}
+		if loc.PhysicalLocation != nil || loc.LogicalLocations != nil {
+			result.Locations = []sarifLocation{loc}
+		}
+		run.Results = append(run.Results, result)
+	}
+	return json.Marshal(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
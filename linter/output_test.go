@@ -0,0 +1,119 @@
+package linter
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/tengo"
+)
+
+func testResult() *Result {
+	stmt1 := &fs.Statement{File: "mydb/posts.sql", LineNo: 1, CharNo: 1, Text: "CREATE TABLE posts (\n  id int\n)"}
+	stmt2 := &fs.Statement{File: "mydb/actors.sql", LineNo: 3, CharNo: 5, Text: "CREATE TABLE actors (id int)"}
+	stmt3 := &fs.Statement{Text: "CREATE TABLE nowhere (id int)"}
+	return &Result{
+		Errors: []*Annotation{
+			{Statement: stmt1, LineOffset: 1, ObjectKey: tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: "posts"}, Summary: "No primary key", Message: "Table posts does not define a PRIMARY KEY", Problem: "no-pk", Severity: SeverityError},
+			{Statement: stmt3, Summary: "SQL statement returned an error", Message: "Error 1064: syntax", Severity: SeverityError},
+		},
+		Warnings: []*Annotation{
+			{Statement: stmt2, ObjectKey: tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: "actors"}, Summary: "Storage engine not permitted", Message: "Table actors is using storage engine MyISAM", Problem: "bad-engine", Severity: SeverityWarning},
+		},
+		FormatNotices: []*Annotation{
+			{Statement: stmt2, Summary: "SQL statement should be reformatted", Message: "CREATE TABLE `actors` ..."},
+		},
+	}
+}
+
+func TestResultRecords(t *testing.T) {
+	expected := []Record{
+		{Rule: "invalid-sql", Severity: SeverityError, Summary: "SQL statement returned an error", Message: "Error 1064: syntax"},
+		{File: "mydb/actors.sql", Line: 3, Column: 5, ObjectType: "table", ObjectName: "actors", Rule: "bad-engine", Severity: SeverityWarning, Summary: "Storage engine not permitted", Message: "Table actors is using storage engine MyISAM"},
+		{File: "mydb/posts.sql", Line: 2, ObjectType: "table", ObjectName: "posts", Rule: "no-pk", Severity: SeverityError, Summary: "No primary key", Message: "Table posts does not define a PRIMARY KEY"},
+	}
+	if actual := testResult().Records(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Records returned %+v, did not match expectation %+v", actual, expected)
+	}
+
+	data, err := json.Marshal(testResult())
+	if err != nil {
+		t.Fatalf("Unexpected error from json.Marshal: %v", err)
+	}
+	var decoded []Record
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unexpected error unmarshaling %s: %v", data, err)
+	} else if !reflect.DeepEqual(decoded, expected) {
+		t.Errorf("JSON round-trip returned %+v, did not match expectation %+v", decoded, expected)
+	}
+}
+
+func TestResultMarshalSARIF(t *testing.T) {
+	data, err := testResult().MarshalSARIF()
+	if err != nil {
+		t.Fatalf("Unexpected error from MarshalSARIF: %v", err)
+	}
+	var decoded sarifLog
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unexpected error unmarshaling %s: %v", data, err)
+	}
+	if decoded.Version != "2.1.0" || len(decoded.Runs) != 1 {
+		t.Fatalf("Unexpected SARIF structure: %s", data)
+	}
+	run := decoded.Runs[0]
+	if len(run.Tool.Driver.Rules) != 3 || len(run.Results) != 3 {
+		t.Fatalf("Expected 3 rules and 3 results, instead found %d and %d", len(run.Tool.Driver.Rules), len(run.Results))
+	}
+	if len(run.Results[0].Locations) != 0 {
+		t.Errorf("Expected result without file to have no locations, instead found %+v", run.Results[0].Locations)
+	}
+	expected := sarifResult{
+		RuleID:  "no-pk",
+		Level:   "error",
+		Message: sarifMessage{Text: "Table posts does not define a PRIMARY KEY"},
+		Locations: []sarifLocation{{
+			PhysicalLocation: &sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: "mydb/posts.sql"},
+				Region:           &sarifRegion{StartLine: 2},
+			},
+			LogicalLocations: []sarifLogicalLocation{{Name: "posts", FullyQualifiedName: "posts", Kind: "table"}},
+		}},
+	}
+	if !reflect.DeepEqual(run.Results[2], expected) {
+		t.Errorf("Unexpected SARIF result: %+v", run.Results[2])
+	}
+}
+
+func TestAnnotationRecordColumn(t *testing.T) {
+	stmt := &fs.Statement{File: "mydb/posts.sql", LineNo: 4, CharNo: 1, Text: "CREATE TABLE posts (\n  id int,\n  body text CHARACTER SET latin1\n)"}
+	r := &Result{
+		Warnings: []*Annotation{{
+			Statement:  stmt,
+			LineOffset: 2,
+			ObjectKey:  tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: "posts"},
+			ColumnName: "body",
+			Summary:    "Column uses legacy character set",
+			Message:    "Column body of table posts is using character set latin1",
+			Problem:    "legacy-charset",
+			Severity:   SeverityWarning,
+		}},
+	}
+	expected := Record{File: "mydb/posts.sql", Line: 6, ObjectType: "table", ObjectName: "posts", ColumnName: "body", Rule: "legacy-charset", Severity: SeverityWarning, Summary: "Column uses legacy character set", Message: "Column body of table posts is using character set latin1"}
+	if records := r.Records(); len(records) != 1 || records[0] != expected {
+		t.Errorf("Records returned %+v, did not match expectation %+v", records, expected)
+	}
+
+	data, err := r.MarshalSARIF()
+	if err != nil {
+		t.Fatalf("Unexpected error from MarshalSARIF: %v", err)
+	}
+	var decoded sarifLog
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unexpected error unmarshaling %s: %v", data, err)
+	}
+	expectedLogical := []sarifLogicalLocation{{Name: "body", FullyQualifiedName: "posts.body", Kind: "column"}}
+	if locs := decoded.Runs[0].Results[0].Locations; len(locs) != 1 || !reflect.DeepEqual(locs[0].LogicalLocations, expectedLogical) {
+		t.Errorf("Unexpected SARIF locations: %+v", locs)
+	}
+}
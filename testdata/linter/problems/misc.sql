@@ -0,0 +1,20 @@
+CREATE TABLE noengine (
+	id int unsigned NOT NULL,
+	PRIMARY KEY (id)
+) DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE nullunique (
+	id int unsigned NOT NULL,
+	account_id int unsigned,
+	email varchar(100),
+	PRIMARY KEY (id),
+	UNIQUE KEY account_email (account_id, email)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE orders (
+	id int unsigned NOT NULL,
+	price decimal(10,0),
+	total_amount decimal(10,2),
+	status enum('v01','v02','v03','v04','v05','v06','v07','v08','v09','v10','v11','v12','v13','v14','v15','v16','v17','v18','v19','v20','v21'),
+	PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,13 @@
+CREATE TABLE notnulldefault (
+	id int unsigned NOT NULL,
+	customer_id int unsigned NOT NULL DEFAULT NULL,
+	PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE nodefault (
+	id int unsigned NOT NULL,
+	status varchar(10) NOT NULL,
+	qty int NOT NULL DEFAULT 1,
+	notes text NOT NULL,
+	PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
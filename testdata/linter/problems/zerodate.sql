@@ -0,0 +1,13 @@
+CREATE TABLE zerodate (
+	id int unsigned NOT NULL,
+	created datetime NOT NULL DEFAULT '0000-00-00 00:00:00',
+	day date NOT NULL DEFAULT '2019-03-00',
+	PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE okdates (
+	id int unsigned NOT NULL,
+	since date NOT NULL DEFAULT '1970-01-01',
+	code char(10) NOT NULL DEFAULT '0000-00-00',
+	PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
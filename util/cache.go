@@ -2,6 +2,7 @@ package util
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/skeema/tengo"
@@ -42,3 +43,112 @@ func CloseCachedConnectionPools() {
 		inst.CloseAll()
 	}
 }
+
+// CharacterSet describes a character set available on a database instance.
+type CharacterSet struct {
+	Name             string
+	DefaultCollation string
+	Collations       []string // all collations of the character set, sorted by name
+}
+
+var charSetCache struct {
+	sync.Mutex
+	charSets map[*tengo.Instance]map[string]*CharacterSet // instance => lowercased name => character set
+}
+
+// queryCharacterSet returns information about charSet on inst. It is a
+// variable so that tests can confirm caching behavior without a database.
+var queryCharacterSet = func(inst *tengo.Instance, charSet string) (*CharacterSet, error) {
+	db, err := inst.Connect("information_schema", "")
+	if err != nil {
+		return nil, err
+	}
+	var rawCollations []struct {
+		Name      string `db:"collation_name"`
+		IsDefault string `db:"is_default"`
+	}
+	query := `
+		SELECT   collation_name AS collation_name, is_default AS is_default
+		FROM     collations
+		WHERE    character_set_name = ?
+		ORDER BY collation_name`
+	if err := db.Select(&rawCollations, query, charSet); err != nil {
+		return nil, err
+	} else if len(rawCollations) == 0 {
+		return nil, fmt.Errorf("Character set %s not found on %s", charSet, inst)
+	}
+	result := &CharacterSet{
+		Name:       charSet,
+		Collations: make([]string, len(rawCollations)),
+	}
+	for n, rawCollation := range rawCollations {
+		result.Collations[n] = rawCollation.Name
+		if strings.EqualFold(rawCollation.IsDefault, "Yes") {
+			result.DefaultCollation = rawCollation.Name
+		}
+	}
+	return result, nil
+}
+
+// characterSet returns information about charSet on inst. Results are cached
+// per instance, so the underlying query is only run once for each distinct
+// character set. Errors are not cached. The cache is not locked during the
+// query, so that lookups for other instances are not blocked by it.
+func characterSet(charSet string, inst *tengo.Instance) (*CharacterSet, error) {
+	charSet = strings.ToLower(charSet)
+	charSetCache.Lock()
+	cs, ok := charSetCache.charSets[inst][charSet]
+	charSetCache.Unlock()
+	if ok {
+		return cs, nil
+	}
+	cs, err := queryCharacterSet(inst, charSet)
+	if err != nil {
+		return nil, err
+	}
+
+	charSetCache.Lock()
+	defer charSetCache.Unlock()
+	if charSetCache.charSets == nil {
+		charSetCache.charSets = make(map[*tengo.Instance]map[string]*CharacterSet)
+	}
+	if charSetCache.charSets[inst] == nil {
+		charSetCache.charSets[inst] = make(map[string]*CharacterSet)
+	}
+	charSetCache.charSets[inst][charSet] = cs
+	return cs, nil
+}
+
+// DefaultCollationForCharset returns the default collation of charSet on inst.
+// Results are cached per instance, so the underlying query is only run once
+// for each distinct character set. Errors are not cached.
+func DefaultCollationForCharset(charSet string, inst *tengo.Instance) (string, error) {
+	cs, err := characterSet(charSet, inst)
+	if err != nil {
+		return "", err
+	}
+	return cs.DefaultCollation, nil
+}
+
+// CollationsForCharset returns the names of all collations of charSet on inst,
+// sorted by name. This is useful for validating a user-specified collation
+// before generating DDL. Results are cached in the same manner as
+// DefaultCollationForCharset, sharing the same underlying query.
+func CollationsForCharset(charSet string, inst *tengo.Instance) ([]string, error) {
+	cs, err := characterSet(charSet, inst)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, len(cs.Collations))
+	copy(result, cs.Collations)
+	return result, nil
+}
+
+// ForgetCharacterSets removes any cached character set information for inst.
+// This should be called after changing server variables which affect default
+// collations, such as MySQL 8.0.30's default_collation_for_utf8mb4.
+func ForgetCharacterSets(inst *tengo.Instance) {
+	charSetCache.Lock()
+	defer charSetCache.Unlock()
+	delete(charSetCache.charSets, inst)
+}
@@ -1,6 +1,8 @@
 package util
 
 import (
+	"errors"
+	"reflect"
 	"testing"
 
 	"github.com/skeema/tengo"
@@ -29,3 +31,69 @@ func TestNewInstance(t *testing.T) {
 		t.Error("Expected bad driver to return error, but it did not")
 	}
 }
+
+func TestCharacterSetCache(t *testing.T) {
+	queries := make(map[string]int)
+	origQuery := queryCharacterSet
+	defer func() { queryCharacterSet = origQuery }()
+	queryCharacterSet = func(inst *tengo.Instance, charSet string) (*CharacterSet, error) {
+		queries[inst.String()+" "+charSet]++
+		switch charSet {
+		case "latin1":
+			return &CharacterSet{Name: charSet, DefaultCollation: "latin1_swedish_ci", Collations: []string{"latin1_bin", "latin1_general_ci", "latin1_swedish_ci"}}, nil
+		case "utf8mb4":
+			return &CharacterSet{Name: charSet, DefaultCollation: "utf8mb4_general_ci", Collations: []string{"utf8mb4_bin", "utf8mb4_general_ci"}}, nil
+		default:
+			return nil, errors.New("Character set not found")
+		}
+	}
+	inst1, _ := tengo.NewInstance("mysql", "root:pw@tcp(1.2.3.4:3306)/")
+	inst2, _ := tengo.NewInstance("mysql", "root:pw@tcp(5.6.7.8:3306)/")
+
+	for n := 0; n < 3; n++ {
+		for _, inst := range []*tengo.Instance{inst1, inst2} {
+			if collation, err := DefaultCollationForCharset("latin1", inst); err != nil || collation != "latin1_swedish_ci" {
+				t.Errorf("Unexpected result from DefaultCollationForCharset: %q, %v", collation, err)
+			}
+			if collation, err := DefaultCollationForCharset("UTF8MB4", inst); err != nil || collation != "utf8mb4_general_ci" {
+				t.Errorf("Unexpected result from DefaultCollationForCharset: %q, %v", collation, err)
+			}
+			if collations, err := CollationsForCharset("utf8mb4", inst); err != nil || !reflect.DeepEqual(collations, []string{"utf8mb4_bin", "utf8mb4_general_ci"}) {
+				t.Errorf("Unexpected result from CollationsForCharset: %v, %v", collations, err)
+			}
+		}
+	}
+	if len(queries) != 4 {
+		t.Errorf("Expected 4 distinct queries, instead found %d: %v", len(queries), queries)
+	}
+	for key, count := range queries {
+		if count != 1 {
+			t.Errorf("Expected query %s to run once, instead ran %d times", key, count)
+		}
+	}
+
+	// Errors are not cached
+	for n := 1; n <= 2; n++ {
+		if _, err := DefaultCollationForCharset("nonexistent", inst1); err == nil {
+			t.Error("Expected error for nonexistent character set, but it was nil")
+		}
+		if count := queries[inst1.String()+" nonexistent"]; count != n {
+			t.Errorf("Expected failed query to have run %d times, instead found %d", n, count)
+		}
+	}
+
+	// Modifying a returned slice does not affect the cache
+	collations, _ := CollationsForCharset("latin1", inst1)
+	collations[0] = "modified"
+	if again, _ := CollationsForCharset("latin1", inst1); again[0] != "latin1_bin" {
+		t.Errorf("Expected cached collations to be unaffected by caller modification, instead found %v", again)
+	}
+
+	// Forgetting an instance's cache causes queries to run again
+	ForgetCharacterSets(inst1)
+	DefaultCollationForCharset("latin1", inst1)
+	DefaultCollationForCharset("latin1", inst2)
+	if queries[inst1.String()+" latin1"] != 2 || queries[inst2.String()+" latin1"] != 1 {
+		t.Errorf("Unexpected query counts after ForgetCharacterSets: %v", queries)
+	}
+}
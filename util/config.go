@@ -27,7 +27,9 @@ func AddGlobalOptions(cmd *mybase.Command) {
 	cmd.AddOption(mybase.StringOption("ignore-table", 0, "", "Ignore tables that match regex").Hidden())
 	cmd.AddOption(mybase.StringOption("default-character-set", 0, "", "Schema-level default character set").Hidden())
 	cmd.AddOption(mybase.StringOption("default-collation", 0, "", "Schema-level default collation").Hidden())
+	cmd.AddOption(mybase.StringOption("schema-file-pattern", 0, "*.sql", "Glob pattern for selecting files containing schema definitions").Hidden())
 	cmd.AddOption(mybase.StringOption("flavor", 0, "", "Database server expressed in format vendor:major.minor, for use in vendor/version specific syntax").Hidden())
+	cmd.AddOption(mybase.StringOption("include", 0, "", "Path of another option file to apply beneath this one, relative to this file's directory").Hidden())
 
 	// Visible global options
 	cmd.AddOption(mybase.StringOption("user", 'u', "root", "Username to connect to database host"))
@@ -35,8 +37,16 @@ func AddGlobalOptions(cmd *mybase.Command) {
 	cmd.AddOption(mybase.StringOption("host-wrapper", 'H', "", "External bin to shell out to for host lookup; see manual for template vars"))
 	cmd.AddOption(mybase.StringOption("temp-schema", 't', "_skeema_tmp", "Name of temporary schema for intermediate operations, created and dropped each run unless --reuse-temp-schema"))
 	cmd.AddOption(mybase.StringOption("connect-options", 'o', "", "Comma-separated session options to set upon connecting to each database instance"))
-	cmd.AddOption(mybase.StringOption("workspace", 'w', "TEMP-SCHEMA", `Specifies where to run intermediate operations (valid values: "TEMP-SCHEMA", "DOCKER")`))
+	cmd.AddOption(mybase.StringOption("workspace", 'w', "TEMP-SCHEMA", `Specifies where to run intermediate operations (valid values: "TEMP-SCHEMA", "DOCKER", "READ-ONLY")`))
 	cmd.AddOption(mybase.StringOption("docker-cleanup", 0, "NONE", `With --workspace=docker, specifies how to clean up containers (valid values: "NONE", "STOP", "DESTROY")`))
+	cmd.AddOption(mybase.StringOption("docker-image", 0, "", "With --workspace=docker, specifies the image to use for containers (default based on flavor)"))
+	cmd.AddOption(mybase.StringOption("ssl-mode", 0, "", `Whether and how to use TLS for database connections (valid values: "DISABLED", "REQUIRED", "VERIFY_CA", "VERIFY_IDENTITY"; default based on other ssl options)`))
+	cmd.AddOption(mybase.StringOption("ssl-ca", 0, "", "Path to PEM file of trusted certificate authorities, for verifying database server certificates"))
+	cmd.AddOption(mybase.StringOption("ssl-cert", 0, "", "Path to PEM file of client certificate, for mutual TLS to database servers"))
+	cmd.AddOption(mybase.StringOption("ssl-key", 0, "", "Path to PEM file of client private key, for mutual TLS to database servers"))
+	cmd.AddOption(mybase.StringOption("connect-retries", 0, "0", "Max times to retry connecting to a database server after a transient error"))
+	cmd.AddOption(mybase.StringOption("connect-retry-delay", 0, "500ms", "Initial delay between connection retries, doubling with each retry"))
+	cmd.AddOption(mybase.StringOption("lock-wait", 0, "1", "Max seconds for each attempt to obtain the workspace lock to block"))
 	cmd.AddOption(mybase.BoolOption("reuse-temp-schema", 0, false, "Do not drop temp-schema when done"))
 	cmd.AddOption(mybase.BoolOption("debug", 0, false, "Enable debug logging"))
 }
@@ -87,8 +97,8 @@ func AddGlobalConfigFiles(cfg *mybase.Config) {
 }
 
 // ProcessSpecialGlobalOptions performs special handling of global options with
-// unusual semantics -- handling restricted placement of host and schema;
-// obtaining a password from MYSQL_PWD or STDIN; enable debug logging.
+// unusual semantics -- handling restricted placement of host, schema, and
+// include; obtaining a password from MYSQL_PWD or STDIN; enable debug logging.
 func ProcessSpecialGlobalOptions(cfg *mybase.Config) error {
 	// The host and schema options are special -- most commands only expect
 	// to find them when recursively crawling directory configs. So if these
@@ -102,6 +112,13 @@ func ProcessSpecialGlobalOptions(cfg *mybase.Config) error {
 		}
 	}
 
+	// The include option is only resolved when parsing a directory's .skeema
+	// file, so reject it on the command-line or in a global option file rather
+	// than silently ignoring it.
+	if cfg.Supplied("include") {
+		return fmt.Errorf("Option include cannot be set via %s; it may only be used in a directory's .skeema file", cfg.Source("include"))
+	}
+
 	// Special handling for password option: if not supplied at all, check env
 	// var instead. Or if supplied but with no value (empty string, instead of
 	// its default of "<no password>"), prompt on STDIN like mysql client does.
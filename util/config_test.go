@@ -134,6 +134,30 @@ func TestPasswordOption(t *testing.T) {
 	}
 }
 
+func TestIncludeOptionPlacement(t *testing.T) {
+	cmdSuite := mybase.NewCommandSuite("skeematest", "", "")
+	AddGlobalOptions(cmdSuite)
+	cmdSuite.AddSubCommand(mybase.NewCommand("diff", "", "", nil))
+
+	cfg := mybase.ParseFakeCLI(t, cmdSuite, "skeema diff --include=foo.skeema")
+	if err := ProcessSpecialGlobalOptions(cfg); err == nil {
+		t.Error("Expected ProcessSpecialGlobalOptions to return an error for include on CLI, but it did not")
+	}
+
+	fakeFileSource := mybase.SimpleSource(map[string]string{
+		"include": "foo.skeema",
+	})
+	cfg = mybase.ParseFakeCLI(t, cmdSuite, "skeema diff", fakeFileSource)
+	if err := ProcessSpecialGlobalOptions(cfg); err == nil {
+		t.Error("Expected ProcessSpecialGlobalOptions to return an error for include in global option file, but it did not")
+	}
+
+	cfg = mybase.ParseFakeCLI(t, cmdSuite, "skeema diff")
+	if err := ProcessSpecialGlobalOptions(cfg); err != nil {
+		t.Errorf("Unexpected error from ProcessSpecialGlobalOptions: %s", err)
+	}
+}
+
 func TestSplitConnectOptions(t *testing.T) {
 	assertConnectOpts := func(connectOptions string, expectedPair ...string) {
 		result, err := SplitConnectOptions(connectOptions)
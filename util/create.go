@@ -0,0 +1,175 @@
+package util
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+var (
+	reStatsOptions = regexp.MustCompile(` STATS_(?:PERSISTENT|AUTO_RECALC)=(?:1|DEFAULT)\b`)
+	reRowFormat    = regexp.MustCompile(` ROW_FORMAT=DYNAMIC\b`)
+)
+
+// StripVolatileTableOptions adjusts the supplied CREATE TABLE statement,
+// formatted in the same manner as SHOW CREATE TABLE, to remove table options
+// which just restate a default value, and would therefore cause spurious
+// differences between otherwise-equivalent tables. This includes
+// STATS_PERSISTENT and STATS_AUTO_RECALC with a value of 1 or DEFAULT (since
+// the corresponding global variables are enabled by default in all supported
+// flavors), as well as ROW_FORMAT=DYNAMIC for InnoDB tables in flavors where
+// DYNAMIC is the default row format. Only the table options clause is
+// modified; column and index definitions, as well as any partitioning clause,
+// are left as-is.
+func StripVolatileTableOptions(createStmt string, flavor tengo.Flavor) string {
+	start := strings.LastIndex(createStmt, "\n) ")
+	if start < 0 {
+		return createStmt
+	}
+	end := strings.IndexByte(createStmt[start+1:], '\n')
+	if end < 0 {
+		end = len(createStmt)
+	} else {
+		end += start + 1
+	}
+	options := reStatsOptions.ReplaceAllString(createStmt[start:end], "")
+	if strings.Contains(options, " ENGINE=InnoDB ") && innoDefaultRowFormatDynamic(flavor) {
+		options = reRowFormat.ReplaceAllString(options, "")
+	}
+	return createStmt[0:start] + options + createStmt[end:]
+}
+
+// innoDefaultRowFormatDynamic returns true if the flavor's default InnoDB
+// row format is DYNAMIC.
+func innoDefaultRowFormatDynamic(flavor tengo.Flavor) bool {
+	return FlavorAtLeast(flavor, tengo.VendorMySQL, 5, 7) || FlavorAtLeast(flavor, tengo.VendorMariaDB, 10, 2)
+}
+
+var (
+	reKeyBlockSize = regexp.MustCompile(` KEY_BLOCK_SIZE=\d+\b`)
+	reAnyRowFormat = regexp.MustCompile(` ROW_FORMAT=(\w+)\b`)
+)
+
+// StripNonInnoAttributes adjusts the supplied CREATE TABLE statement to remove
+// any no-op table options that are persisted in SHOW CREATE TABLE, but serve no
+// purpose for InnoDB tables. This function is not safe for use on tables using
+// other storage engines; use StripEngineNoopAttributes instead in that case.
+func StripNonInnoAttributes(createStmt string, flavor tengo.Flavor) string {
+	return StripEngineNoopAttributes(createStmt, "InnoDB", flavor)
+}
+
+// StripEngineNoopAttributes adjusts the supplied CREATE TABLE statement to
+// remove any options which are persisted in SHOW CREATE TABLE, but have no
+// effect for the supplied storage engine:
+//
+//   - InnoDB: index-level KEY_BLOCK_SIZE is always ignored, and table-level
+//     KEY_BLOCK_SIZE is ignored if a ROW_FORMAT other than COMPRESSED is used.
+//   - MEMORY: KEY_BLOCK_SIZE and ROW_FORMAT are ignored at all levels, since
+//     MEMORY tables always use fixed-length rows.
+//   - MyISAM, Aria, and any other engine: the statement is returned as-is,
+//     since KEY_BLOCK_SIZE is meaningful for these engines.
+//
+// The engine name is compared case-insensitively. The flavor is currently
+// unused, but is accepted for consistency with StripVolatileTableOptions.
+func StripEngineNoopAttributes(createStmt, engine string, flavor tengo.Flavor) string {
+	var stripRowFormat, stripAllKeyBlockSize bool
+	switch strings.ToLower(engine) {
+	case "innodb":
+	case "memory":
+		stripRowFormat, stripAllKeyBlockSize = true, true
+	default:
+		return createStmt
+	}
+
+	lines := strings.Split(createStmt, "\n")
+	for n, line := range lines {
+		if strings.HasPrefix(line, ") ") {
+			// Table options line
+			rowFormat := reAnyRowFormat.FindStringSubmatch(line)
+			if stripAllKeyBlockSize || (rowFormat != nil && !strings.EqualFold(rowFormat[1], "COMPRESSED")) {
+				line = reKeyBlockSize.ReplaceAllString(line, "")
+			}
+			if stripRowFormat {
+				line = reAnyRowFormat.ReplaceAllString(line, "")
+			}
+			lines[n] = line
+		} else if strings.HasPrefix(line, "  ") && strings.Contains(line, "KEY ") {
+			// Index definition line
+			lines[n] = reKeyBlockSize.ReplaceAllString(line, "")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+var rePartitionClauseStart = regexp.MustCompile(`\n(?:/\*!\d{5} | )PARTITION BY `)
+
+// ParseCreatePartitioning splits the supplied CREATE TABLE statement, formatted
+// in the same manner as SHOW CREATE TABLE, into a base portion and a
+// partitioning clause. MySQL wraps the partitioning clause in a /*!50100 */
+// version-gated comment (or /*!50500 */ for RANGE COLUMNS and LIST COLUMNS),
+// whereas MariaDB does not. If the table is not partitioned, partitionClause
+// will be an empty string, and base will be the full input. The newline
+// separating the two portions is not included in either return value.
+func ParseCreatePartitioning(createStmt string) (base, partitionClause string) {
+	loc := rePartitionClauseStart.FindStringIndex(createStmt)
+	if loc == nil {
+		return createStmt, ""
+	}
+	return createStmt[0:loc[0]], createStmt[loc[0]+1:]
+}
+
+// CombineCreatePartitioning is the inverse of ParseCreatePartitioning: it
+// rejoins a base CREATE TABLE statement with a partitioning clause, using the
+// same whitespace as SHOW CREATE TABLE. If partitionClause is empty, base is
+// returned as-is.
+func CombineCreatePartitioning(base, partitionClause string) string {
+	if partitionClause == "" {
+		return base
+	}
+	return base + "\n" + partitionClause
+}
+
+// UnescapeCreateTableValue is the inverse of tengo.EscapeValueForCreateTable,
+// converting a string value (such as a column default or comment) from the
+// escaped form used by SHOW CREATE TABLE back into its actual stored value.
+// The input should not include the surrounding single quotes. The input is
+// processed in a single pass, so that an escaped backslash followed by a
+// literal n (`\\n`) correctly becomes a backslash and n, rather than a
+// newline. A backslash followed by any character other than a backslash, 0,
+// n, r, or single quote is left as-is, since some older dump tools did not
+// escape backslashes consistently. A lone single quote (not doubled) is also
+// left as-is.
+func UnescapeCreateTableValue(input string) string {
+	if !strings.ContainsAny(input, `\'`) {
+		return input
+	}
+	var b strings.Builder
+	b.Grow(len(input))
+	for n := 0; n < len(input); n++ {
+		c := input[n]
+		if n+1 < len(input) {
+			next := input[n+1]
+			if c == '\\' {
+				switch next {
+				case '\\', '\'':
+					c = next
+					n++
+				case '0':
+					c = 0
+					n++
+				case 'n':
+					c = '\n'
+					n++
+				case 'r':
+					c = '\r'
+					n++
+				}
+			} else if c == '\'' && next == '\'' {
+				n++
+			}
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
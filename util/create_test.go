@@ -0,0 +1,128 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+func TestStripVolatileTableOptions(t *testing.T) {
+	body := "CREATE TABLE `foo` (\n  `id` int(11) NOT NULL COMMENT 'ROW_FORMAT=DYNAMIC STATS_PERSISTENT=1',\n  PRIMARY KEY (`id`)\n) "
+	cases := []struct {
+		options  string
+		flavor   tengo.Flavor
+		expected string
+	}{
+		{"ENGINE=InnoDB DEFAULT CHARSET=latin1", tengo.FlavorMySQL57, "ENGINE=InnoDB DEFAULT CHARSET=latin1"},
+		{"ENGINE=InnoDB DEFAULT CHARSET=latin1 ROW_FORMAT=DYNAMIC", tengo.FlavorMySQL57, "ENGINE=InnoDB DEFAULT CHARSET=latin1"},
+		{"ENGINE=InnoDB DEFAULT CHARSET=latin1 ROW_FORMAT=DYNAMIC", tengo.FlavorMariaDB102, "ENGINE=InnoDB DEFAULT CHARSET=latin1"},
+		{"ENGINE=InnoDB DEFAULT CHARSET=latin1 ROW_FORMAT=DYNAMIC", tengo.FlavorMySQL56, "ENGINE=InnoDB DEFAULT CHARSET=latin1 ROW_FORMAT=DYNAMIC"},
+		{"ENGINE=InnoDB DEFAULT CHARSET=latin1 ROW_FORMAT=DYNAMIC", tengo.FlavorMariaDB101, "ENGINE=InnoDB DEFAULT CHARSET=latin1 ROW_FORMAT=DYNAMIC"},
+		{"ENGINE=InnoDB DEFAULT CHARSET=latin1 ROW_FORMAT=COMPRESSED", tengo.FlavorMySQL80, "ENGINE=InnoDB DEFAULT CHARSET=latin1 ROW_FORMAT=COMPRESSED"},
+		{"ENGINE=MyISAM DEFAULT CHARSET=latin1 ROW_FORMAT=DYNAMIC", tengo.FlavorMySQL80, "ENGINE=MyISAM DEFAULT CHARSET=latin1 ROW_FORMAT=DYNAMIC"},
+		{"ENGINE=InnoDB DEFAULT CHARSET=latin1 STATS_PERSISTENT=1 STATS_AUTO_RECALC=DEFAULT", tengo.FlavorMySQL56, "ENGINE=InnoDB DEFAULT CHARSET=latin1"},
+		{"ENGINE=InnoDB DEFAULT CHARSET=latin1 STATS_PERSISTENT=0 STATS_AUTO_RECALC=0", tengo.FlavorMySQL56, "ENGINE=InnoDB DEFAULT CHARSET=latin1 STATS_PERSISTENT=0 STATS_AUTO_RECALC=0"},
+		{"ENGINE=InnoDB AUTO_INCREMENT=5 DEFAULT CHARSET=utf8mb4 ROW_FORMAT=DYNAMIC STATS_PERSISTENT=DEFAULT COMMENT='hi'", tengo.FlavorMySQL80, "ENGINE=InnoDB AUTO_INCREMENT=5 DEFAULT CHARSET=utf8mb4 COMMENT='hi'"},
+		{"ENGINE=InnoDB DEFAULT CHARSET=latin1 ROW_FORMAT=DYNAMIC\n/*!50100 PARTITION BY HASH (`id`)\nPARTITIONS 4 */", tengo.FlavorMySQL57, "ENGINE=InnoDB DEFAULT CHARSET=latin1\n/*!50100 PARTITION BY HASH (`id`)\nPARTITIONS 4 */"},
+	}
+	for n, c := range cases {
+		if actual := StripVolatileTableOptions(body+c.options, c.flavor); actual != body+c.expected {
+			t.Errorf("cases[%d]: Expected %q, instead found %q", n, body+c.expected, actual)
+		}
+	}
+	if actual := StripVolatileTableOptions("not a create", tengo.FlavorMySQL80); actual != "not a create" {
+		t.Errorf("Unexpected modification of non-CREATE input: %q", actual)
+	}
+}
+
+func TestStripEngineNoopAttributes(t *testing.T) {
+	body := "CREATE TABLE `foo` (\n  `id` int(11) NOT NULL,\n  `name` varchar(30) DEFAULT NULL,\n  PRIMARY KEY (`id`),\n  KEY `name` (`name`) KEY_BLOCK_SIZE=8\n) "
+	strippedBody := "CREATE TABLE `foo` (\n  `id` int(11) NOT NULL,\n  `name` varchar(30) DEFAULT NULL,\n  PRIMARY KEY (`id`),\n  KEY `name` (`name`)\n) "
+	cases := []struct {
+		engine   string
+		options  string
+		expected string
+	}{
+		{"InnoDB", "ENGINE=InnoDB DEFAULT CHARSET=latin1", strippedBody + "ENGINE=InnoDB DEFAULT CHARSET=latin1"},
+		{"InnoDB", "ENGINE=InnoDB DEFAULT CHARSET=latin1 KEY_BLOCK_SIZE=8", strippedBody + "ENGINE=InnoDB DEFAULT CHARSET=latin1 KEY_BLOCK_SIZE=8"},
+		{"InnoDB", "ENGINE=InnoDB DEFAULT CHARSET=latin1 ROW_FORMAT=COMPRESSED KEY_BLOCK_SIZE=8", strippedBody + "ENGINE=InnoDB DEFAULT CHARSET=latin1 ROW_FORMAT=COMPRESSED KEY_BLOCK_SIZE=8"},
+		{"innodb", "ENGINE=InnoDB DEFAULT CHARSET=latin1 ROW_FORMAT=DYNAMIC KEY_BLOCK_SIZE=8", strippedBody + "ENGINE=InnoDB DEFAULT CHARSET=latin1 ROW_FORMAT=DYNAMIC"},
+		{"MyISAM", "ENGINE=MyISAM DEFAULT CHARSET=latin1 ROW_FORMAT=DYNAMIC KEY_BLOCK_SIZE=8", body + "ENGINE=MyISAM DEFAULT CHARSET=latin1 ROW_FORMAT=DYNAMIC KEY_BLOCK_SIZE=8"},
+		{"Aria", "ENGINE=Aria DEFAULT CHARSET=latin1 KEY_BLOCK_SIZE=8", body + "ENGINE=Aria DEFAULT CHARSET=latin1 KEY_BLOCK_SIZE=8"},
+		{"MEMORY", "ENGINE=MEMORY DEFAULT CHARSET=latin1 ROW_FORMAT=DYNAMIC KEY_BLOCK_SIZE=8", strippedBody + "ENGINE=MEMORY DEFAULT CHARSET=latin1"},
+	}
+	for n, c := range cases {
+		if actual := StripEngineNoopAttributes(body+c.options, c.engine, tengo.FlavorMySQL57); actual != c.expected {
+			t.Errorf("cases[%d]: Expected %q, instead found %q", n, c.expected, actual)
+		}
+	}
+
+	// StripNonInnoAttributes should behave identically to the InnoDB case
+	input := body + "ENGINE=InnoDB DEFAULT CHARSET=latin1 ROW_FORMAT=DYNAMIC KEY_BLOCK_SIZE=8"
+	if expected, actual := StripEngineNoopAttributes(input, "InnoDB", tengo.FlavorMySQL57), StripNonInnoAttributes(input, tengo.FlavorMySQL57); actual != expected {
+		t.Errorf("Expected StripNonInnoAttributes to return %q, instead found %q", expected, actual)
+	}
+}
+
+func TestParseCombineCreatePartitioning(t *testing.T) {
+	base := "CREATE TABLE `orders` (\n  `id` int(11) NOT NULL,\n  `region` varchar(10) NOT NULL,\n  `created` date NOT NULL,\n  PRIMARY KEY (`id`,`region`,`created`)\n) ENGINE=InnoDB DEFAULT CHARSET=latin1"
+	clauses := []string{
+		"/*!50100 PARTITION BY RANGE (year(`created`))\n(PARTITION p2019 VALUES LESS THAN (2020) ENGINE = InnoDB,\n PARTITION pmax VALUES LESS THAN MAXVALUE ENGINE = InnoDB) */",
+		"/*!50500 PARTITION BY RANGE  COLUMNS(created)\n(PARTITION p0 VALUES LESS THAN ('2020-01-01') ENGINE = InnoDB) */",
+		"/*!50100 PARTITION BY LIST (`id`)\n(PARTITION odd VALUES IN (1,3,5) ENGINE = InnoDB,\n PARTITION even VALUES IN (2,4,6) ENGINE = InnoDB) */",
+		"/*!50100 PARTITION BY HASH (`id`)\nPARTITIONS 4 */",
+		"/*!50100 PARTITION BY RANGE (`id`)\nSUBPARTITION BY HASH (`id`)\nSUBPARTITIONS 2\n(PARTITION p0 VALUES LESS THAN (100) ENGINE = InnoDB) */",
+		" PARTITION BY KEY (`region`)\nPARTITIONS 3",
+		" PARTITION BY LIST  COLUMNS(`region`)\n(PARTITION pEast VALUES IN ('east') ENGINE = InnoDB)",
+	}
+	for n, clause := range clauses {
+		createStmt := base + "\n" + clause
+		actualBase, actualClause := ParseCreatePartitioning(createStmt)
+		if actualBase != base || actualClause != clause {
+			t.Errorf("clauses[%d]: Unexpected result from ParseCreatePartitioning: %q, %q", n, actualBase, actualClause)
+		}
+		if roundTrip := CombineCreatePartitioning(actualBase, actualClause); roundTrip != createStmt {
+			t.Errorf("clauses[%d]: Round-trip through CombineCreatePartitioning returned %q, expected %q", n, roundTrip, createStmt)
+		}
+	}
+
+	// Unpartitioned table
+	if actualBase, actualClause := ParseCreatePartitioning(base); actualBase != base || actualClause != "" {
+		t.Errorf("Unexpected result from ParseCreatePartitioning on unpartitioned table: %q, %q", actualBase, actualClause)
+	}
+	if roundTrip := CombineCreatePartitioning(base, ""); roundTrip != base {
+		t.Errorf("Unexpected result from CombineCreatePartitioning on unpartitioned table: %q", roundTrip)
+	}
+}
+
+func TestUnescapeCreateTableValue(t *testing.T) {
+	cases := []struct {
+		input, expected string
+	}{
+		{"", ""},
+		{"hello world", "hello world"},
+		{"it''s", "it's"},
+		{`it\'s`, "it's"},
+		{`line1\nline2\r\n`, "line1\nline2\r\n"},
+		{`nul\0byte`, "nul\000byte"},
+		{`C:\\new`, `C:\new`},
+		{`\\\\n`, `\\n`},
+		{`\d+`, `\d+`},
+		{`trailing\`, `trailing\`},
+		{"lone ' quote", "lone ' quote"},
+		{"''''", "''"},
+	}
+	for _, c := range cases {
+		if actual := UnescapeCreateTableValue(c.input); actual != c.expected {
+			t.Errorf("Expected UnescapeCreateTableValue(%q) to return %q, instead found %q", c.input, c.expected, actual)
+		}
+	}
+
+	// Confirm round-trip of tengo.EscapeValueForCreateTable
+	values := []string{"", "plain", "it's", "back\\slash", "\\n is not a newline", "new\nline", "cr\rlf", "nul\000", `'\'\\''`}
+	for _, value := range values {
+		if actual := UnescapeCreateTableValue(tengo.EscapeValueForCreateTable(value)); actual != value {
+			t.Errorf("Round-trip of %q returned %q", value, actual)
+		}
+	}
+}
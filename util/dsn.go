@@ -0,0 +1,106 @@
+package util
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// DSNBuilder assembles a DSN in the format used by go-sql-driver/mysql. Its
+// setter methods return the receiver, so that calls may be chained. The zero
+// value is not usable; create a DSNBuilder using NewDSNBuilder.
+type DSNBuilder struct {
+	user        string
+	password    string
+	hasPassword bool
+	host        string
+	port        int
+	socket      string
+	schema      string
+	params      url.Values
+}
+
+// NewDSNBuilder returns a new DSNBuilder with no fields set. Unless Socket is
+// called, the resulting DSN uses TCP.
+func NewDSNBuilder() *DSNBuilder {
+	return &DSNBuilder{
+		params: make(url.Values),
+	}
+}
+
+// User sets the username.
+func (b *DSNBuilder) User(user string) *DSNBuilder {
+	b.user = user
+	return b
+}
+
+// Password sets the password. Note that an empty string is still included in
+// the DSN; the password is only omitted if this method is never called.
+func (b *DSNBuilder) Password(password string) *DSNBuilder {
+	b.password = password
+	b.hasPassword = true
+	return b
+}
+
+// Host sets the hostname or IP address to connect to using TCP. An ipv6
+// address must be wrapped in brackets.
+func (b *DSNBuilder) Host(host string) *DSNBuilder {
+	b.host = host
+	b.socket = ""
+	return b
+}
+
+// Port sets the TCP port. If never called, or called with 0, the port is
+// omitted from the DSN, and the driver uses its default of 3306.
+func (b *DSNBuilder) Port(port int) *DSNBuilder {
+	b.port = port
+	return b
+}
+
+// Socket sets the path to a Unix domain socket to connect to. This overrides
+// any previous call to Host.
+func (b *DSNBuilder) Socket(path string) *DSNBuilder {
+	b.socket = path
+	b.host = ""
+	return b
+}
+
+// Schema sets the default database (schema) name.
+func (b *DSNBuilder) Schema(name string) *DSNBuilder {
+	b.schema = name
+	return b
+}
+
+// Param sets a driver param or session variable. If the same name was already
+// set, its value is replaced.
+func (b *DSNBuilder) Param(name, value string) *DSNBuilder {
+	b.params.Set(name, value)
+	return b
+}
+
+// Params calls Param for each name in values. If a name has multiple values,
+// the last one is used.
+func (b *DSNBuilder) Params(values url.Values) *DSNBuilder {
+	for name, vals := range values {
+		if len(vals) > 0 {
+			b.params.Set(name, vals[len(vals)-1])
+		}
+	}
+	return b
+}
+
+// Build returns the DSN string. Params are always sorted by name.
+func (b *DSNBuilder) Build() string {
+	userAndPass := b.user
+	if b.hasPassword {
+		userAndPass = fmt.Sprintf("%s:%s", b.user, b.password)
+	}
+	var address string
+	if b.socket != "" {
+		address = fmt.Sprintf("unix(%s)", b.socket)
+	} else if b.port > 0 {
+		address = fmt.Sprintf("tcp(%s:%d)", b.host, b.port)
+	} else {
+		address = fmt.Sprintf("tcp(%s)", b.host)
+	}
+	return fmt.Sprintf("%s@%s/%s?%s", userAndPass, address, b.schema, b.params.Encode())
+}
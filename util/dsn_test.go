@@ -0,0 +1,39 @@
+package util
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestDSNBuilder(t *testing.T) {
+	cases := []struct {
+		builder  *DSNBuilder
+		expected string
+	}{
+		{NewDSNBuilder(), "@tcp()/?"},
+		{NewDSNBuilder().User("root").Host("1.2.3.4").Port(3306), "root@tcp(1.2.3.4:3306)/?"},
+		{NewDSNBuilder().User("root").Password("").Host("db"), "root:@tcp(db)/?"},
+		{NewDSNBuilder().User("bob").Password("s3cret").Host("[::1]").Port(3307).Schema("product"), "bob:s3cret@tcp([::1]:3307)/product?"},
+		{NewDSNBuilder().User("root").Socket("/tmp/mysql.sock").Param("timeout", "5s"), "root@unix(/tmp/mysql.sock)/?timeout=5s"},
+		{NewDSNBuilder().Socket("/tmp/mysql.sock").Host("db").Port(3306), "@tcp(db:3306)/?"},
+		{NewDSNBuilder().Host("db").Socket("/tmp/mysql.sock"), "@unix(/tmp/mysql.sock)/?"},
+		{NewDSNBuilder().Host("db").Param("b", "2").Param("a", "1").Param("b", "3"), "@tcp(db)/?a=1&b=3"},
+		{NewDSNBuilder().Host("db").Param("sql_mode", "'STRICT_ALL_TABLES'"), "@tcp(db)/?sql_mode=%27STRICT_ALL_TABLES%27"},
+	}
+	for n, c := range cases {
+		if actual := c.builder.Build(); actual != c.expected {
+			t.Errorf("cases[%d]: Expected DSN %q, instead found %q", n, c.expected, actual)
+		}
+	}
+
+	// Params should use the last value for each name, and override earlier calls
+	// to Param
+	values, err := url.ParseQuery("foo=1&bar=2&foo=3")
+	if err != nil {
+		t.Fatalf("Unexpected error from url.ParseQuery: %s", err)
+	}
+	b := NewDSNBuilder().Host("db").Param("bar", "old").Param("baz", "4").Params(values)
+	if actual, expected := b.Build(), "@tcp(db)/?bar=2&baz=4&foo=3"; actual != expected {
+		t.Errorf("Expected DSN %q, instead found %q", expected, actual)
+	}
+}
@@ -0,0 +1,252 @@
+package util
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/skeema/tengo"
+)
+
+// FlavorVersion pairs a tengo.Flavor with the patch number of a server's
+// version, since tengo.Flavor only tracks major.minor. When the patch number is
+// not known, such as for a flavor obtained from configuration, Patch is 0.
+type FlavorVersion struct {
+	tengo.Flavor
+	Patch int
+}
+
+func (fv FlavorVersion) String() string {
+	return fmt.Sprintf("%s.%d", fv.Flavor, fv.Patch)
+}
+
+// version returns fv's major, minor, and patch numbers as a triple, as used by
+// compareVersions.
+func (fv FlavorVersion) version() [3]int {
+	return [3]int{fv.Major, fv.Minor, fv.Patch}
+}
+
+// CompareFlavors returns a negative number if a is older than b, a positive
+// number if a is newer than b, or 0 if they have the same version, including
+// patch number. Flavors of different vendors are not comparable by version;
+// they are ordered by vendor instead, which is only meaningful for sorting.
+// Percona Server is considered a different vendor than MySQL here; use
+// FlavorAtLeast for version gates.
+func CompareFlavors(a, b FlavorVersion) int {
+	if a.Vendor != b.Vendor {
+		return int(a.Vendor) - int(b.Vendor)
+	}
+	return compareVersions(a.version(), b.version())
+}
+
+// FlavorAtLeast returns true if flavor is from vendor, and has a version equal
+// to or newer than major.minor. Unlike tengo.Flavor.VendorMinVersion, Percona
+// Server satisfies requirements for VendorMySQL, since it tracks the MySQL
+// release of the same version. This permits feature gates to be expressed with
+// a single call per vendor family. For features introduced in a specific point
+// release, use FlavorVersionAtLeast instead.
+func FlavorAtLeast(flavor tengo.Flavor, vendor tengo.Vendor, major, minor int) bool {
+	return FlavorVersionAtLeast(FlavorVersion{Flavor: flavor}, vendor, major, minor, 0)
+}
+
+// FlavorVersionAtLeast behaves like FlavorAtLeast, but also compares the patch
+// number of fv. This is useful for features introduced in a specific point
+// release, such as MySQL 8.0.13's expression defaults.
+func FlavorVersionAtLeast(fv FlavorVersion, vendor tengo.Vendor, major, minor, patch int) bool {
+	if fv.Vendor != vendor && !(vendor == tengo.VendorMySQL && fv.Vendor == tengo.VendorPercona) {
+		return false
+	}
+	return compareVersions(fv.version(), [3]int{major, minor, patch}) >= 0
+}
+
+// InstanceAtLeast behaves like FlavorVersionAtLeast, using the flavor and
+// patch number of inst. If inst's version cannot be determined, false is
+// returned.
+func InstanceAtLeast(inst *tengo.Instance, vendor tengo.Vendor, major, minor, patch int) bool {
+	return FlavorVersionAtLeast(InstanceFlavorVersion(inst), vendor, major, minor, patch)
+}
+
+// compareVersions returns a negative number, 0, or a positive number if a is
+// older than, the same as, or newer than b, respectively. Each version is a
+// major, minor, patch triple, as returned by tengo.ParseVersion.
+func compareVersions(a, b [3]int) int {
+	for n := range a {
+		if a[n] != b[n] {
+			return a[n] - b[n]
+		}
+	}
+	return 0
+}
+
+// Fork identifies a database server which reports a MySQL-compatible version,
+// but differs from MySQL in behavior. Since tengo.Vendor only enumerates the
+// vendors known to package tengo, forks are tracked separately from Flavor.
+type Fork int
+
+// Constants enumerating forks. ForkNone indicates the server is not a
+// recognized fork, i.e. its flavor fully describes it.
+const (
+	ForkNone   Fork = iota
+	ForkAurora      // Amazon Aurora MySQL
+	ForkTiDB        // PingCAP TiDB
+)
+
+func (f Fork) String() string {
+	switch f {
+	case ForkAurora:
+		return "aurora"
+	case ForkTiDB:
+		return "tidb"
+	default:
+		return ""
+	}
+}
+
+// auroraBaseVersion maps Aurora MySQL's major.minor to the MySQL patch release
+// it is based upon, since Aurora's @@version omits the MySQL patch number.
+var auroraBaseVersion = map[[2]int]int{
+	{5, 6}: 10,
+	{5, 7}: 12,
+	{8, 0}: 23,
+}
+
+var (
+	reAuroraVersion  = regexp.MustCompile(`^(\d+)\.(\d+)\.mysql_aurora\.`)
+	reMariaDBVersion = regexp.MustCompile(`^5\.5\.5-(\d+\.\d+\.\d+)`)
+)
+
+// parseVersionInfo returns the flavor, version, and fork based on a server's
+// @@version and @@version_comment values. For forks, the returned flavor and
+// version are those of the MySQL release the fork is compatible with.
+func parseVersionInfo(versionString, versionComment string) (tengo.Flavor, [3]int, Fork) {
+	lowerVersion := strings.ToLower(versionString)
+	lowerComment := strings.ToLower(versionComment)
+	var version [3]int
+	fork := ForkNone
+	vendor := tengo.ParseVendor(versionComment)
+	if vendor == tengo.VendorUnknown {
+		vendor = tengo.ParseVendor(versionString)
+	}
+
+	if matches := reAuroraVersion.FindStringSubmatch(lowerVersion); matches != nil {
+		// Aurora reports e.g. "5.7.mysql_aurora.2.10.2"
+		version = tengo.ParseVersion(matches[1] + "." + matches[2] + ".0")
+		version[2] = auroraBaseVersion[[2]int{version[0], version[1]}]
+		vendor, fork = tengo.VendorMySQL, ForkAurora
+	} else if strings.Contains(lowerVersion, "-tidb-") || strings.Contains(lowerComment, "tidb") {
+		// TiDB reports e.g. "5.7.25-TiDB-v6.5.0", where the prefix is the MySQL
+		// version it is compatible with
+		version = tengo.ParseVersion(versionString)
+		vendor, fork = tengo.VendorMySQL, ForkTiDB
+	} else if matches := reMariaDBVersion.FindStringSubmatch(versionString); matches != nil && vendor == tengo.VendorMariaDB {
+		// Some MariaDB servers and proxies prefix the version with "5.5.5-" for
+		// compatibility with old replication clients
+		version = tengo.ParseVersion(matches[1])
+	} else {
+		version = tengo.ParseVersion(versionString)
+	}
+	return tengo.Flavor{Vendor: vendor, Major: version[0], Minor: version[1]}, version, fork
+}
+
+// ParseFlavor returns the flavor of a database server based on its @@version
+// and @@version_comment values. Unlike tengo's own detection, this handles
+// forks with nonstandard version strings, such as Amazon Aurora and TiDB; for
+// these, the returned flavor is that of the MySQL release they are compatible
+// with. Use ParseFork to determine whether the server is a fork.
+func ParseFlavor(versionString, versionComment string) tengo.Flavor {
+	flavor, _, _ := parseVersionInfo(versionString, versionComment)
+	return flavor
+}
+
+// ParseFork returns the fork of a database server based on its @@version and
+// @@version_comment values, or ForkNone if it is not a recognized fork.
+func ParseFork(versionString, versionComment string) Fork {
+	_, _, fork := parseVersionInfo(versionString, versionComment)
+	return fork
+}
+
+// serverInfo holds the result of parseVersionInfo for an instance.
+type serverInfo struct {
+	flavor  tengo.Flavor
+	version [3]int
+	fork    Fork
+}
+
+var serverInfoCache struct {
+	sync.Mutex
+	servers map[*tengo.Instance]serverInfo
+}
+
+// queryVersionInfo returns the @@version and @@version_comment of inst. It is
+// a variable so that tests can supply version strings without a database.
+var queryVersionInfo = func(inst *tengo.Instance) (versionString, versionComment string, err error) {
+	db, err := inst.Connect("", "")
+	if err != nil {
+		return "", "", err
+	}
+	err = db.QueryRow("SELECT @@global.version, @@global.version_comment").Scan(&versionString, &versionComment)
+	return versionString, versionComment, err
+}
+
+// instanceServerInfo returns the flavor, version, and fork of inst, querying
+// it upon first use. If the query fails, tengo's own detection is used
+// instead, and the result is not cached, so that a transient error does not
+// persist for the life of the process. The cache is not locked during the
+// query, so that lookups for other instances are not blocked by it.
+func instanceServerInfo(inst *tengo.Instance) serverInfo {
+	serverInfoCache.Lock()
+	info, ok := serverInfoCache.servers[inst]
+	serverInfoCache.Unlock()
+	if ok {
+		return info
+	}
+
+	versionString, versionComment, err := queryVersionInfo(inst)
+	if err != nil {
+		major, minor, patch := inst.Version()
+		return serverInfo{flavor: inst.Flavor(), version: [3]int{major, minor, patch}}
+	}
+	info.flavor, info.version, info.fork = parseVersionInfo(versionString, versionComment)
+
+	serverInfoCache.Lock()
+	defer serverInfoCache.Unlock()
+	if serverInfoCache.servers == nil {
+		serverInfoCache.servers = make(map[*tengo.Instance]serverInfo)
+	}
+	// If another goroutine concurrently looked up the same instance, keep its
+	// result so that all callers see a consistent value
+	if existing, ok := serverInfoCache.servers[inst]; ok {
+		return existing
+	}
+	serverInfoCache.servers[inst] = info
+	return info
+}
+
+// ForgetServerInfo removes any cached flavor, version, and fork information
+// for inst. This should be called once inst is no longer in use, or after an
+// in-place upgrade of the server.
+func ForgetServerInfo(inst *tengo.Instance) {
+	serverInfoCache.Lock()
+	defer serverInfoCache.Unlock()
+	delete(serverInfoCache.servers, inst)
+}
+
+// InstanceFlavor behaves like inst.Flavor(), but uses ParseFlavor to correctly
+// identify forks which tengo misdetects. Results are cached per instance.
+func InstanceFlavor(inst *tengo.Instance) tengo.Flavor {
+	return instanceServerInfo(inst).flavor
+}
+
+// InstanceFlavorVersion behaves like InstanceFlavor, but also includes the
+// patch number of inst's version. Results are cached per instance.
+func InstanceFlavorVersion(inst *tengo.Instance) FlavorVersion {
+	info := instanceServerInfo(inst)
+	return FlavorVersion{Flavor: info.flavor, Patch: info.version[2]}
+}
+
+// InstanceFork returns the fork of inst, or ForkNone if it is not a recognized
+// fork. Results are cached per instance.
+func InstanceFork(inst *tengo.Instance) Fork {
+	return instanceServerInfo(inst).fork
+}
@@ -0,0 +1,181 @@
+package util
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+func TestCompareFlavors(t *testing.T) {
+	cases := []struct {
+		a, b     FlavorVersion
+		expected int // only the sign is checked
+	}{
+		{FlavorVersion{Flavor: tengo.FlavorMySQL57}, FlavorVersion{Flavor: tengo.FlavorMySQL57}, 0},
+		{FlavorVersion{Flavor: tengo.FlavorMySQL56, Patch: 40}, FlavorVersion{Flavor: tengo.FlavorMySQL57}, -1},
+		{FlavorVersion{Flavor: tengo.FlavorMySQL80}, FlavorVersion{Flavor: tengo.FlavorMySQL57, Patch: 30}, 1},
+		{FlavorVersion{Flavor: tengo.FlavorMySQL80, Patch: 13}, FlavorVersion{Flavor: tengo.FlavorMySQL80, Patch: 12}, 1},
+		{FlavorVersion{Flavor: tengo.FlavorMySQL80, Patch: 13}, FlavorVersion{Flavor: tengo.FlavorMySQL80, Patch: 13}, 0},
+		{FlavorVersion{Flavor: tengo.Flavor{Vendor: tengo.VendorMariaDB, Major: 10, Minor: 10}}, FlavorVersion{Flavor: tengo.FlavorMariaDB103}, 1},
+		{FlavorVersion{Flavor: tengo.FlavorMySQL80}, FlavorVersion{Flavor: tengo.FlavorPercona57}, -1},
+		{FlavorVersion{Flavor: tengo.FlavorUnknown}, FlavorVersion{Flavor: tengo.FlavorMySQL55}, -1},
+	}
+	sign := func(n int) int {
+		if n < 0 {
+			return -1
+		} else if n > 0 {
+			return 1
+		}
+		return 0
+	}
+	for _, c := range cases {
+		if actual := sign(CompareFlavors(c.a, c.b)); actual != c.expected {
+			t.Errorf("Expected CompareFlavors(%s, %s) to have sign %d, instead found %d", c.a, c.b, c.expected, actual)
+		}
+		if reverse := sign(CompareFlavors(c.b, c.a)); reverse != -c.expected {
+			t.Errorf("Expected CompareFlavors(%s, %s) to have sign %d, instead found %d", c.b, c.a, -c.expected, reverse)
+		}
+	}
+}
+
+func TestFlavorAtLeast(t *testing.T) {
+	cases := []struct {
+		flavor       tengo.Flavor
+		vendor       tengo.Vendor
+		major, minor int
+		expected     bool
+	}{
+		{tengo.FlavorMySQL80, tengo.VendorMySQL, 8, 0, true},
+		{tengo.FlavorMySQL80, tengo.VendorMySQL, 5, 7, true},
+		{tengo.FlavorMySQL57, tengo.VendorMySQL, 8, 0, false},
+		{tengo.FlavorPercona80, tengo.VendorMySQL, 8, 0, true},
+		{tengo.FlavorMySQL80, tengo.VendorPercona, 8, 0, false},
+		{tengo.FlavorMariaDB103, tengo.VendorMySQL, 5, 5, false},
+		{tengo.FlavorMariaDB103, tengo.VendorMariaDB, 10, 2, true},
+		{tengo.FlavorMariaDB102, tengo.VendorMariaDB, 10, 3, false},
+		{tengo.FlavorUnknown, tengo.VendorMySQL, 0, 0, false},
+	}
+	for _, c := range cases {
+		if actual := FlavorAtLeast(c.flavor, c.vendor, c.major, c.minor); actual != c.expected {
+			t.Errorf("Expected FlavorAtLeast(%s, %s, %d, %d) to return %t, instead found %t", c.flavor, c.vendor, c.major, c.minor, c.expected, actual)
+		}
+	}
+}
+
+func TestFlavorVersionAtLeast(t *testing.T) {
+	cases := []struct {
+		fv                  FlavorVersion
+		vendor              tengo.Vendor
+		major, minor, patch int
+		expected            bool
+	}{
+		{FlavorVersion{Flavor: tengo.FlavorMySQL80, Patch: 13}, tengo.VendorMySQL, 8, 0, 13, true},
+		{FlavorVersion{Flavor: tengo.FlavorMySQL80, Patch: 30}, tengo.VendorMySQL, 8, 0, 13, true},
+		{FlavorVersion{Flavor: tengo.FlavorMySQL80, Patch: 12}, tengo.VendorMySQL, 8, 0, 13, false},
+		{FlavorVersion{Flavor: tengo.FlavorMySQL80}, tengo.VendorMySQL, 8, 0, 13, false},
+		{FlavorVersion{Flavor: tengo.FlavorPercona80, Patch: 20}, tengo.VendorMySQL, 8, 0, 13, true},
+		{FlavorVersion{Flavor: tengo.FlavorMySQL57, Patch: 40}, tengo.VendorMySQL, 8, 0, 0, false},
+		{FlavorVersion{Flavor: tengo.FlavorMariaDB103, Patch: 1}, tengo.VendorMySQL, 8, 0, 13, false},
+	}
+	for _, c := range cases {
+		if actual := FlavorVersionAtLeast(c.fv, c.vendor, c.major, c.minor, c.patch); actual != c.expected {
+			t.Errorf("Expected FlavorVersionAtLeast(%s, %s, %d, %d, %d) to return %t, instead found %t", c.fv, c.vendor, c.major, c.minor, c.patch, c.expected, actual)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	if compareVersions([3]int{8, 0, 13}, [3]int{8, 0, 13}) != 0 {
+		t.Error("Expected equal versions to compare as 0")
+	}
+	if compareVersions([3]int{8, 0, 12}, [3]int{8, 0, 13}) >= 0 {
+		t.Error("Expected 8.0.12 to be older than 8.0.13")
+	}
+	if compareVersions([3]int{10, 6, 0}, [3]int{10, 5, 17}) <= 0 {
+		t.Error("Expected 10.6.0 to be newer than 10.5.17")
+	}
+}
+
+func TestParseFlavor(t *testing.T) {
+	cases := []struct {
+		version, comment string
+		expected         tengo.Flavor
+		fork             Fork
+	}{
+		{"8.0.22", "MySQL Community Server - GPL", tengo.FlavorMySQL80, ForkNone},
+		{"5.7.31-34", "Percona Server (GPL), Release 34, Revision 2e68637", tengo.FlavorPercona57, ForkNone},
+		{"10.3.8-MariaDB-log", "mariadb.org binary distribution", tengo.FlavorMariaDB103, ForkNone},
+		{"5.5.5-10.3.8-MariaDB", "mariadb.org binary distribution", tengo.FlavorMariaDB103, ForkNone},
+		{"5.7.mysql_aurora.2.10.2", "MySQL Community Server (GPL)", tengo.FlavorMySQL57, ForkAurora},
+		{"8.0.mysql_aurora.3.02.0", "Source distribution", tengo.FlavorMySQL80, ForkAurora},
+		{"5.7.25-TiDB-v6.5.0", "TiDB Server (Apache License 2.0) Community Edition, MySQL 5.7 compatible", tengo.FlavorMySQL57, ForkTiDB},
+		{"garbage", "", tengo.FlavorUnknown, ForkNone},
+	}
+	for _, c := range cases {
+		if actual := ParseFlavor(c.version, c.comment); actual != c.expected {
+			t.Errorf("Expected ParseFlavor(%q, %q) to return %s, instead found %s", c.version, c.comment, c.expected, actual)
+		}
+		if actual := ParseFork(c.version, c.comment); actual != c.fork {
+			t.Errorf("Expected ParseFork(%q, %q) to return %q, instead found %q", c.version, c.comment, c.fork, actual)
+		}
+	}
+}
+
+func TestInstanceFlavor(t *testing.T) {
+	origQuery := queryVersionInfo
+	defer func() { queryVersionInfo = origQuery }()
+	var queries int
+	queryVersionInfo = func(inst *tengo.Instance) (string, string, error) {
+		queries++
+		return "8.0.mysql_aurora.3.02.0", "Source distribution", nil
+	}
+
+	inst, _ := tengo.NewInstance("mysql", "root:pw@tcp(1.2.3.4:3306)/")
+	if flavor := InstanceFlavor(inst); flavor != tengo.FlavorMySQL80 {
+		t.Errorf("Expected InstanceFlavor to return %s, instead found %s", tengo.FlavorMySQL80, flavor)
+	}
+	if fork := InstanceFork(inst); fork != ForkAurora {
+		t.Errorf("Expected InstanceFork to return %q, instead found %q", ForkAurora, fork)
+	}
+	if !InstanceAtLeast(inst, tengo.VendorMySQL, 8, 0, 13) || InstanceAtLeast(inst, tengo.VendorMySQL, 8, 0, 30) {
+		t.Error("Expected Aurora 3 to be treated as MySQL 8.0.23")
+	}
+	if fv := InstanceFlavorVersion(inst); fv.String() != "mysql:8.0.23" {
+		t.Errorf("Expected InstanceFlavorVersion to return mysql:8.0.23, instead found %s", fv)
+	}
+	if queries != 1 {
+		t.Errorf("Expected version to be queried once, instead found %d queries", queries)
+	}
+
+	// Forgetting the instance causes the query to run again
+	ForgetServerInfo(inst)
+	InstanceFlavor(inst)
+	if queries != 2 {
+		t.Errorf("Expected version to be queried again after ForgetServerInfo, instead found %d queries", queries)
+	}
+
+	// Failures should fall back to tengo's detection, but not be cached
+	queryVersionInfo = func(inst *tengo.Instance) (string, string, error) {
+		queries++
+		return "", "", errors.New("connection refused")
+	}
+	queries = 0
+	inst, _ = tengo.NewInstance("mysql", "root:pw@tcp(127.0.0.1:1)/")
+	for n := 0; n < 2; n++ {
+		if flavor := InstanceFlavor(inst); flavor != tengo.FlavorUnknown {
+			t.Errorf("Expected InstanceFlavor to return %s, instead found %s", tengo.FlavorUnknown, flavor)
+		}
+	}
+	if queries != 2 {
+		t.Errorf("Expected failed version query to be attempted each time, instead found %d attempts", queries)
+	}
+
+	// Once the query succeeds, its result should be used
+	queryVersionInfo = func(inst *tengo.Instance) (string, string, error) {
+		return "5.7.30-tidb-v4.0.0", "TiDB Server", nil
+	}
+	if fork := InstanceFork(inst); fork != ForkTiDB {
+		t.Errorf("Expected InstanceFork to return %q after query succeeded, instead found %q", ForkTiDB, fork)
+	}
+}
@@ -0,0 +1,29 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+// SplitHostOptionalPort wraps tengo.SplitHostOptionalPort, additionally
+// rejecting host addresses with malformed brackets. An ipv6 address must be
+// wrapped in a single pair of brackets, with the opening bracket at the start
+// of hostaddr; the closing bracket may only be followed by a colon and port.
+// Zone identifiers of link-local ipv6 addresses, such as "[fe80::1%eth0]", are
+// preserved inside the brackets on output.
+func SplitHostOptionalPort(hostaddr string) (string, int, error) {
+	openCount, closeCount := strings.Count(hostaddr, "["), strings.Count(hostaddr, "]")
+	if openCount > 1 || closeCount > 1 || openCount != closeCount {
+		return "", 0, fmt.Errorf("Unbalanced brackets in host address %s", hostaddr)
+	} else if openCount == 1 {
+		closePos := strings.IndexByte(hostaddr, ']')
+		if hostaddr[0] != '[' || closePos < 2 {
+			return "", 0, fmt.Errorf("Malformed brackets in host address %s", hostaddr)
+		} else if closePos < len(hostaddr)-1 && hostaddr[closePos+1] != ':' {
+			return "", 0, fmt.Errorf("Unexpected characters after closing bracket in host address %s", hostaddr)
+		}
+	}
+	return tengo.SplitHostOptionalPort(hostaddr)
+}
@@ -0,0 +1,56 @@
+package util
+
+import (
+	"testing"
+)
+
+func TestSplitHostOptionalPort(t *testing.T) {
+	assertSplit := func(addr, expectHost string, expectPort int, expectErr bool) {
+		t.Helper()
+		host, port, err := SplitHostOptionalPort(addr)
+		if host != expectHost {
+			t.Errorf("Expected SplitHostOptionalPort(%q) to return host of %q, instead found %q", addr, expectHost, host)
+		}
+		if port != expectPort {
+			t.Errorf("Expected SplitHostOptionalPort(%q) to return port of %d, instead found %d", addr, expectPort, port)
+		}
+		if expectErr && err == nil {
+			t.Errorf("Expected SplitHostOptionalPort(%q) to return an error, but it did not", addr)
+		} else if !expectErr && err != nil {
+			t.Errorf("Expected SplitHostOptionalPort(%q) to not return an error, but it returned: %s", addr, err)
+		}
+	}
+
+	// Hostnames and ipv4
+	assertSplit("", "", 0, true)
+	assertSplit("foo", "foo", 0, false)
+	assertSplit("1.2.3.4", "1.2.3.4", 0, false)
+	assertSplit("some.host:1234", "some.host", 1234, false)
+	assertSplit("some.host:text", "", 0, true)
+	assertSplit("some.host:1.2", "", 0, true)
+	assertSplit("some.host:0", "", 0, true)
+	assertSplit("some.host:-5", "", 0, true)
+
+	// ipv6, with and without port
+	assertSplit("::1", "", 0, true)
+	assertSplit("[::1]", "[::1]", 0, false)
+	assertSplit("[::1]:3306", "[::1]", 3306, false)
+	assertSplit("[2001:db8::ff00:42:8329]:3307", "[2001:db8::ff00:42:8329]", 3307, false)
+
+	// Link-local ipv6 with zone identifier
+	assertSplit("[fe80::1%eth0]", "[fe80::1%eth0]", 0, false)
+	assertSplit("[fe80::1%eth0]:3306", "[fe80::1%eth0]", 3306, false)
+	assertSplit("[fe80::abcd:1%en0]:1234", "[fe80::abcd:1%en0]", 1234, false)
+
+	// Malformed brackets
+	assertSplit("[::1", "", 0, true)
+	assertSplit("::1]", "", 0, true)
+	assertSplit("[::1]]", "", 0, true)
+	assertSplit("[[::1]", "", 0, true)
+	assertSplit("[fe80::1%eth0:3306", "", 0, true)
+	assertSplit("fe80::1%eth0]:3306", "", 0, true)
+	assertSplit("]::1[", "", 0, true)
+	assertSplit("[]", "", 0, true)
+	assertSplit("[::1]x", "", 0, true)
+	assertSplit("x[::1]", "", 0, true)
+}
@@ -0,0 +1,762 @@
+package util
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+// IndexPart represents a single column of an index, along with the length of
+// its prefix, if only a prefix of the column's value is indexed.
+type IndexPart struct {
+	Column       *tengo.Column
+	PrefixLength uint16 // 0 if the entire column value is indexed
+	Descending   bool   // true if sorted in descending order (MySQL 8.0+); see TableIndexParts
+}
+
+// SameTarget returns true if part and other index the same column in the same
+// sort order, regardless of prefix length. Column names are compared
+// case-insensitively. This is useful for determining whether an index could be
+// changed by adjusting a prefix length, rather than being dropped and
+// recreated.
+func (part IndexPart) SameTarget(other IndexPart) bool {
+	if part.Column == nil || other.Column == nil {
+		return part.Column == other.Column && part.Descending == other.Descending
+	}
+	return strings.EqualFold(part.Column.Name, other.Column.Name) && part.Descending == other.Descending
+}
+
+// IndexParts returns the parts of idx, in order.
+func IndexParts(idx *tengo.Index) []IndexPart {
+	parts := make([]IndexPart, len(idx.Columns))
+	for n, col := range idx.Columns {
+		parts[n].Column = col
+		if n < len(idx.SubParts) {
+			parts[n].PrefixLength = idx.SubParts[n]
+		}
+	}
+	return parts
+}
+
+// TableIndexParts behaves like IndexParts, but also determines which parts of
+// idx are descending, by examining the index's definition in the table's
+// CREATE TABLE statement. This is necessary since tengo.Index does not track
+// sort order. If the definition cannot be located, all parts are treated as
+// ascending.
+func TableIndexParts(table *tengo.Table, idx *tengo.Index) []IndexPart {
+	parts := IndexParts(idx)
+	descending := indexDescendingParts(table.CreateStatement, idx)
+	for n := range parts {
+		parts[n].Descending = n < len(descending) && descending[n]
+	}
+	return parts
+}
+
+// indexDescendingParts returns, for each part of idx's definition within
+// createStatement, whether the part is followed by DESC. Returns nil if the
+// definition cannot be found.
+func indexDescendingParts(createStatement string, idx *tengo.Index) []bool {
+	var re *regexp.Regexp
+	if idx.PrimaryKey {
+		re = regexp.MustCompile(`(?im)^\s*PRIMARY KEY\s*\(`)
+	} else {
+		re = regexp.MustCompile(fmt.Sprintf("(?im)^\\s*(?:UNIQUE |FULLTEXT |SPATIAL )?(?:KEY|INDEX)\\s+`?%s`?\\s*\\(", regexp.QuoteMeta(idx.Name)))
+	}
+	loc := re.FindStringIndex(createStatement)
+	if loc == nil {
+		return nil
+	}
+
+	// Split the parenthesized part list on top-level commas, since functional
+	// parts and prefix lengths contain nested parentheses
+	var result []bool
+	depth, start := 0, loc[1]
+	for pos := loc[1]; pos < len(createStatement); pos++ {
+		c := createStatement[pos]
+		if c == '(' {
+			depth++
+			continue
+		} else if c == ')' && depth > 0 {
+			depth--
+			continue
+		} else if depth > 0 || (c != ',' && c != ')') {
+			continue
+		}
+		fields := strings.Fields(createStatement[start:pos])
+		result = append(result, len(fields) > 1 && strings.EqualFold(fields[len(fields)-1], "DESC"))
+		if c == ')' {
+			return result
+		}
+		start = pos + 1
+	}
+	return nil
+}
+
+// EffectiveIndexParts returns the parts of idx, including any implicit ones.
+// In InnoDB, the leaf nodes of a non-unique secondary index also store the
+// table's primary key columns, so these are appended to the result, skipping
+// any that already appear in full (non-prefix) form in idx. For any other
+// engine or index type, the result is the same as TableIndexParts(table, idx).
+// Implicit parts have the same sort order as in the primary key.
+func EffectiveIndexParts(table *tengo.Table, idx *tengo.Index) []IndexPart {
+	parts := TableIndexParts(table, idx)
+	if idx.PrimaryKey || idx.Unique || table.PrimaryKey == nil || !strings.EqualFold(table.Engine, "InnoDB") {
+		return parts
+	}
+	present := make(map[string]bool, len(parts))
+	for _, part := range parts {
+		if part.PrefixLength == 0 {
+			present[part.Column.Name] = true
+		}
+	}
+	for _, pkPart := range TableIndexParts(table, table.PrimaryKey) {
+		if !present[pkPart.Column.Name] {
+			parts = append(parts, IndexPart{Column: pkPart.Column, Descending: pkPart.Descending})
+		}
+	}
+	return parts
+}
+
+// IndexCovers returns true if every named column appears in full among the
+// parts of idx, meaning that queries referencing only these columns could be
+// satisfied entirely by reading the index. Parts that only index a prefix of a
+// column cannot cover that column. Column names are compared
+// case-insensitively, and the order of the names is irrelevant.
+func IndexCovers(idx *tengo.Index, columns []string) bool {
+	parts := IndexParts(idx)
+	for _, name := range columns {
+		var found bool
+		for _, part := range parts {
+			if part.PrefixLength == 0 && strings.EqualFold(part.Column.Name, name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateIndex returns an error if idx, which must be an index of table,
+// could not be created with flavor. This checks that each part's prefix
+// length is legal for the type of its column: prefixes may only be used on
+// string and binary types, may not exceed the column's declared length, and
+// are mandatory for BLOB and TEXT types. It also checks that flavor supports
+// the features of idx, per IndexSupportedBy, and that the key length does not
+// exceed the limit of the table's storage engine and row format, per
+// EstimatedKeyLength. The key length check is skipped if the length of any
+// column type cannot be determined.
+func ValidateIndex(table *tengo.Table, idx *tengo.Index, flavor tengo.Flavor) error {
+	if err := validateIndexPrefixes(idx); err != nil {
+		return err
+	}
+	if err := IndexSupportedBy(table, idx, flavor); err != nil {
+		return err
+	}
+	for _, part := range IndexParts(idx) {
+		if _, _, err := keyPartLength(part, table); err != nil {
+			return nil
+		}
+	}
+	_, err := EstimatedKeyLength(table, idx, flavor)
+	return err
+}
+
+// validateIndexPrefixes returns an error if any part of idx has an illegal
+// prefix length for the type of its column.
+func validateIndexPrefixes(idx *tengo.Index) error {
+	for _, part := range IndexParts(idx) {
+		colType := strings.ToLower(part.Column.TypeInDB)
+		baseType := colType
+		if pos := strings.IndexAny(colType, "( "); pos > -1 {
+			baseType = colType[0:pos]
+		}
+		switch baseType {
+		case "char", "varchar", "binary", "varbinary":
+			if part.PrefixLength > 0 {
+				// Extract the declared length from a type such as "varchar(100)"
+				start, end := strings.IndexByte(colType, '('), strings.IndexByte(colType, ')')
+				if start > -1 && end > start {
+					if length, err := strconv.ParseUint(colType[start+1:end], 10, 16); err == nil && uint64(part.PrefixLength) > length {
+						return fmt.Errorf("Index %s: prefix length %d on column %s exceeds its length of %d", tengo.EscapeIdentifier(idx.Name), part.PrefixLength, tengo.EscapeIdentifier(part.Column.Name), length)
+					}
+				}
+			}
+		case "tinytext", "text", "mediumtext", "longtext", "tinyblob", "blob", "mediumblob", "longblob":
+			if part.PrefixLength == 0 {
+				return fmt.Errorf("Index %s: column %s of type %s requires a prefix length", tengo.EscapeIdentifier(idx.Name), tengo.EscapeIdentifier(part.Column.Name), baseType)
+			}
+		default:
+			if part.PrefixLength > 0 {
+				return fmt.Errorf("Index %s: column %s of type %s cannot have a prefix length", tengo.EscapeIdentifier(idx.Name), tengo.EscapeIdentifier(part.Column.Name), baseType)
+			}
+		}
+	}
+	return nil
+}
+
+// IndexSupportedBy returns an error naming the first feature of idx, which must
+// be an index of table, that flavor does not support. Currently the only such
+// feature detected is descending index parts, which are ignored by flavors
+// prior to MySQL 8.0 and MariaDB 10.8; they are determined from the table's
+// CREATE TABLE statement via TableIndexParts. Other flavor-gated index
+// features, such as invisible indexes, expression parts, and VECTOR indexes,
+// are not represented in tengo.Index and so cannot be checked. If flavor is
+// unknown, nil is returned.
+func IndexSupportedBy(table *tengo.Table, idx *tengo.Index, flavor tengo.Flavor) error {
+	if flavor == tengo.FlavorUnknown || FlavorAtLeast(flavor, tengo.VendorMySQL, 8, 0) || FlavorAtLeast(flavor, tengo.VendorMariaDB, 10, 8) {
+		return nil
+	}
+	for _, part := range TableIndexParts(table, idx) {
+		if part.Descending {
+			return fmt.Errorf("Index %s: descending part on column %s is not supported by %s", tengo.EscapeIdentifier(idx.Name), tengo.EscapeIdentifier(part.Column.Name), flavor)
+		}
+	}
+	return nil
+}
+
+// charSetMaxLength maps character set names to the maximum number of bytes
+// per character. Character sets not listed here are assumed to use up to 4
+// bytes per character, which is the largest of any character set.
+var charSetMaxLength = map[string]int{
+	"armscii8": 1, "ascii": 1, "big5": 2, "binary": 1, "cp1250": 1, "cp1251": 1,
+	"cp1256": 1, "cp1257": 1, "cp850": 1, "cp852": 1, "cp866": 1, "cp932": 2,
+	"dec8": 1, "eucjpms": 3, "euckr": 2, "gb18030": 4, "gb2312": 2, "gbk": 2,
+	"geostd8": 1, "greek": 1, "hebrew": 1, "hp8": 1, "keybcs2": 1, "koi8r": 1,
+	"koi8u": 1, "latin1": 1, "latin2": 1, "latin5": 1, "latin7": 1, "macce": 1,
+	"macroman": 1, "sjis": 2, "swe7": 1, "tis620": 1, "ucs2": 2, "ujis": 3,
+	"utf16": 4, "utf16le": 4, "utf32": 4, "utf8": 3, "utf8mb3": 3, "utf8mb4": 4,
+}
+
+// Maximum index key lengths, in bytes. InnoDB's limit depends on the row
+// format: the smaller limit applies to each column of the key with the
+// REDUNDANT or COMPACT row formats.
+const (
+	innoMaxKeyLength       = 3072
+	innoMaxColumnKeyLength = 767
+	myISAMMaxKeyLength     = 1000
+)
+
+// EstimatedKeyLength returns the estimated number of bytes used by each key of
+// idx, which must be an index of table. This is the sum of each part's data
+// length, plus 1 byte for each nullable column and 2 bytes for each part of a
+// variable-length type. String lengths are based on the maximum bytes per
+// character of the column's character set, and the part's prefix length, if
+// any.
+//
+// An error is returned if the key's data length (excluding the per-part
+// overhead, as in the server's own check) exceeds the maximum key length of
+// the table's storage engine, meaning the index cannot be created. For InnoDB
+// the limit is 3072 bytes, or 767 bytes per column if the table uses the
+// REDUNDANT or COMPACT row format; flavor determines the default row format
+// if the table does not specify one. An error is also returned if the length
+// of a column type cannot be determined.
+func EstimatedKeyLength(table *tengo.Table, idx *tengo.Index, flavor tengo.Flavor) (int, error) {
+	var dataLength, overhead int
+	maxKeyLength, maxColumnKeyLength := maxKeyLengths(table, flavor)
+	for _, part := range IndexParts(idx) {
+		partLength, variable, err := keyPartLength(part, table)
+		if err != nil {
+			return 0, fmt.Errorf("Index %s: %s", tengo.EscapeIdentifier(idx.Name), err)
+		}
+		if maxColumnKeyLength > 0 && partLength > maxColumnKeyLength {
+			return 0, fmt.Errorf("Index %s: column %s uses %d bytes, which exceeds the maximum of %d bytes per column for row format %s", tengo.EscapeIdentifier(idx.Name), tengo.EscapeIdentifier(part.Column.Name), partLength, maxColumnKeyLength, innoRowFormat(table, flavor))
+		}
+		dataLength += partLength
+		if variable {
+			overhead += 2
+		}
+		if part.Column.Nullable {
+			overhead++
+		}
+	}
+	if maxKeyLength > 0 && dataLength > maxKeyLength {
+		return 0, fmt.Errorf("Index %s uses %d bytes, which exceeds the maximum key length of %d bytes for storage engine %s", tengo.EscapeIdentifier(idx.Name), dataLength, maxKeyLength, table.Engine)
+	}
+	return dataLength + overhead, nil
+}
+
+// maxKeyLengths returns the maximum total key length and the maximum length
+// of each key part for table, or 0 if there is no applicable limit.
+func maxKeyLengths(table *tengo.Table, flavor tengo.Flavor) (total, perColumn int) {
+	switch strings.ToLower(table.Engine) {
+	case "innodb":
+		if format := innoRowFormat(table, flavor); format == "REDUNDANT" || format == "COMPACT" {
+			return innoMaxKeyLength, innoMaxColumnKeyLength
+		}
+		return innoMaxKeyLength, 0
+	case "myisam":
+		return myISAMMaxKeyLength, 0
+	}
+	return 0, 0
+}
+
+// innoRowFormat returns the InnoDB row format of table, falling back to the
+// default of flavor if none was specified: DYNAMIC in MySQL 5.7+ and MariaDB
+// 10.2+, or COMPACT in older versions.
+func innoRowFormat(table *tengo.Table, flavor tengo.Flavor) string {
+	if format := strings.ToUpper(table.RowFormatClause()); format != "" && format != "DEFAULT" {
+		return format
+	}
+	if FlavorAtLeast(flavor, tengo.VendorMySQL, 5, 7) || FlavorAtLeast(flavor, tengo.VendorMariaDB, 10, 2) {
+		return "DYNAMIC"
+	}
+	return "COMPACT"
+}
+
+// keyPartLength returns the number of bytes of data stored in an index key for
+// part, and whether the part's type is variable-length.
+func keyPartLength(part IndexPart, table *tengo.Table) (length int, variable bool, err error) {
+	colType := strings.ToLower(part.Column.TypeInDB)
+	baseType, args := colType, ""
+	if start, end := strings.IndexByte(colType, '('), strings.IndexByte(colType, ')'); start > -1 && end > start {
+		baseType, args = colType[:start], colType[start+1:end]
+	} else if pos := strings.IndexByte(colType, ' '); pos > -1 {
+		baseType = colType[:pos]
+	}
+	intArg := func(n int) int {
+		fields := strings.Split(args, ",")
+		if n >= len(fields) {
+			return 0
+		}
+		val, _ := strconv.Atoi(strings.TrimSpace(fields[n]))
+		return val
+	}
+	fractionalBytes := (intArg(0) + 1) / 2
+
+	switch baseType {
+	case "tinyint", "year":
+		return 1, false, nil
+	case "smallint":
+		return 2, false, nil
+	case "mediumint", "date":
+		return 3, false, nil
+	case "int", "integer", "float":
+		return 4, false, nil
+	case "bigint", "double", "real":
+		return 8, false, nil
+	case "decimal", "numeric":
+		return decimalLength(intArg(0), intArg(1)), false, nil
+	case "time":
+		return 3 + fractionalBytes, false, nil
+	case "datetime":
+		return 5 + fractionalBytes, false, nil
+	case "timestamp":
+		return 4 + fractionalBytes, false, nil
+	case "bit":
+		return (intArg(0) + 7) / 8, false, nil
+	case "enum":
+		if strings.Count(args, ",") < 255 {
+			return 1, false, nil
+		}
+		return 2, false, nil
+	case "set":
+		members := strings.Count(args, ",") + 1
+		if bytes := (members + 7) / 8; bytes <= 4 {
+			return bytes, false, nil
+		}
+		return 8, false, nil
+	case "char", "varchar", "binary", "varbinary":
+		chars := int(part.PrefixLength)
+		if chars == 0 {
+			chars = intArg(0)
+		}
+		return chars * bytesPerChar(baseType, part.Column, table), strings.HasPrefix(baseType, "var"), nil
+	case "tinytext", "text", "mediumtext", "longtext", "tinyblob", "blob", "mediumblob", "longblob":
+		return int(part.PrefixLength) * bytesPerChar(baseType, part.Column, table), true, nil
+	}
+	return 0, false, fmt.Errorf("unable to determine key length of column %s of type %s", tengo.EscapeIdentifier(part.Column.Name), part.Column.TypeInDB)
+}
+
+// bytesPerChar returns the maximum bytes per character of col, which has the
+// supplied base type. Binary types always use 1 byte per character.
+func bytesPerChar(baseType string, col *tengo.Column, table *tengo.Table) int {
+	if strings.Contains(baseType, "binary") || strings.Contains(baseType, "blob") {
+		return 1
+	}
+	charSet := col.CharSet
+	if charSet == "" {
+		charSet = table.CharSet
+	}
+	if maxLength, ok := charSetMaxLength[strings.ToLower(charSet)]; ok {
+		return maxLength
+	}
+	return 4
+}
+
+// decimalLength returns the number of bytes used to store a DECIMAL with the
+// supplied precision and scale. Each group of 9 digits on either side of the
+// decimal point uses 4 bytes, and any remaining digits use a proportional
+// number of bytes.
+func decimalLength(precision, scale int) int {
+	if precision == 0 {
+		precision = 10
+	}
+	leftover := [9]int{0, 1, 1, 2, 2, 3, 3, 4, 4}
+	length := func(digits int) int {
+		return (digits/9)*4 + leftover[digits%9]
+	}
+	return length(precision-scale) + length(scale)
+}
+
+// IndexCompareOptions controls optional leniency in IndexEqualsWithOptions.
+type IndexCompareOptions struct {
+	IgnoreCommentCase       bool // compare index comments case-insensitively
+	IgnoreCommentWhitespace bool // ignore leading/trailing whitespace in comments, and treat internal runs of whitespace as a single space
+}
+
+// IndexEqualsWithOptions returns true if two indexes are identical, aside
+// from any differences permitted by opts. With a zero value for opts, this
+// behaves identically to tengo.Index.Equals.
+func IndexEqualsWithOptions(idx, other *tengo.Index, opts IndexCompareOptions) bool {
+	if idx == nil || other == nil {
+		return idx == other
+	}
+	normalize := func(comment string) string {
+		if opts.IgnoreCommentCase {
+			comment = strings.ToLower(comment)
+		}
+		if opts.IgnoreCommentWhitespace {
+			comment = strings.Join(strings.Fields(comment), " ")
+		}
+		return comment
+	}
+	idxCopy, otherCopy := *idx, *other
+	idxCopy.Comment, otherCopy.Comment = normalize(idx.Comment), normalize(other.Comment)
+	return idxCopy.Equals(&otherCopy)
+}
+
+// IndexEquivalent returns true if two indexes have the same type and parts,
+// regardless of their names or comments. Equivalent indexes are functionally
+// identical to the query optimizer and for enforcing constraints. This is the
+// same as comparing the results of IndexCanonicalKey, so the sort order of
+// parts is not considered; use TableIndexCanonicalKey when the indexes' tables
+// are available.
+func IndexEquivalent(idx, other *tengo.Index) bool {
+	if idx == nil || other == nil {
+		return idx == other
+	}
+	return IndexCanonicalKey(idx) == IndexCanonicalKey(other)
+}
+
+// IndexCanonicalKey returns a string describing the type and parts of idx,
+// but not its name or comment, for example "UNIQUE(`a`,`b`(10))". Two indexes
+// have the same key if and only if they are equivalent per IndexEquivalent, so
+// the key may be used to bucket indexes by equivalence, rather than comparing
+// every pair. Column names are lowercased, since they are case-insensitive.
+// Since tengo.Index does not track sort order, an ascending and a descending
+// part on the same column yield the same key; TableIndexCanonicalKey
+// distinguishes them.
+func IndexCanonicalKey(idx *tengo.Index) string {
+	return canonicalKey(idx, IndexParts(idx))
+}
+
+// TableIndexCanonicalKey behaves like IndexCanonicalKey, but also includes the
+// sort order of each part, as determined by TableIndexParts: descending parts
+// are followed by " DESC", for example "KEY(`a` DESC,`b`)". idx must be an
+// index of table.
+func TableIndexCanonicalKey(table *tengo.Table, idx *tengo.Index) string {
+	return canonicalKey(idx, TableIndexParts(table, idx))
+}
+
+// canonicalKey implements IndexCanonicalKey and TableIndexCanonicalKey, using
+// the supplied parts of idx.
+func canonicalKey(idx *tengo.Index, parts []IndexPart) string {
+	var b strings.Builder
+	if idx.PrimaryKey {
+		b.WriteString("PRIMARY(")
+	} else if idx.Unique {
+		b.WriteString("UNIQUE(")
+	} else {
+		b.WriteString("KEY(")
+	}
+	for n, part := range parts {
+		if n > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(tengo.EscapeIdentifier(strings.ToLower(part.Column.Name)))
+		if part.PrefixLength > 0 {
+			fmt.Fprintf(&b, "(%d)", part.PrefixLength)
+		}
+		if part.Descending {
+			b.WriteString(" DESC")
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// DiffIndexes compares two sets of indexes, such as the secondary indexes of
+// two versions of a table; from must be indexes of fromTable, and to must be
+// indexes of toTable. Indexes are matched by name: a matched pair which is not
+// identical (per tengo.Index.Equals, as well as the sort order of each part) is
+// returned in modified, as a {from, to} pair. Of the remaining indexes, any
+// index in to which has the same TableIndexCanonicalKey as an unmatched index
+// in from is treated as a rename, and is also returned in modified, so that it
+// may be handled with a single RENAME INDEX rather than a drop and re-add. All
+// other indexes are returned in added or dropped. Results are ordered
+// deterministically: added and modified follow the order of to, and dropped
+// follows the order of from.
+func DiffIndexes(fromTable, toTable *tengo.Table, from, to []*tengo.Index) (added, dropped []*tengo.Index, modified [][2]*tengo.Index) {
+	fromByName := make(map[string]*tengo.Index, len(from))
+	for _, idx := range from {
+		fromByName[idx.Name] = idx
+	}
+	matched := make(map[*tengo.Index]bool, len(from))
+	var unmatchedTo []*tengo.Index
+	for _, idx := range to {
+		if fromIdx, ok := fromByName[idx.Name]; ok {
+			matched[fromIdx] = true
+			if !fromIdx.Equals(idx) || TableIndexCanonicalKey(fromTable, fromIdx) != TableIndexCanonicalKey(toTable, idx) {
+				modified = append(modified, [2]*tengo.Index{fromIdx, idx})
+			}
+		} else {
+			unmatchedTo = append(unmatchedTo, idx)
+		}
+	}
+
+	// Detect renames among the unmatched indexes, bucketing the unmatched indexes
+	// of from by canonical key. Each index in from may only be used as the source
+	// of one rename; within a bucket, indexes are used in the order of from.
+	candidates := make(map[string][]*tengo.Index)
+	for _, fromIdx := range from {
+		if !matched[fromIdx] {
+			key := TableIndexCanonicalKey(fromTable, fromIdx)
+			candidates[key] = append(candidates[key], fromIdx)
+		}
+	}
+	for _, idx := range unmatchedTo {
+		key := TableIndexCanonicalKey(toTable, idx)
+		if bucket := candidates[key]; len(bucket) > 0 {
+			matched[bucket[0]] = true
+			candidates[key] = bucket[1:]
+			modified = append(modified, [2]*tengo.Index{bucket[0], idx})
+		} else {
+			added = append(added, idx)
+		}
+	}
+	for _, idx := range from {
+		if !matched[idx] {
+			dropped = append(dropped, idx)
+		}
+	}
+
+	// Keep modified in the order of to, since renames were appended last
+	toPos := make(map[*tengo.Index]int, len(to))
+	for n, idx := range to {
+		toPos[idx] = n
+	}
+	sort.SliceStable(modified, func(i, j int) bool {
+		return toPos[modified[i][1]] < toPos[modified[j][1]]
+	})
+	return added, dropped, modified
+}
+
+// IndexConstraintImpliedBy returns true if idx is a unique index whose
+// uniqueness constraint is already guaranteed by other, meaning that other is
+// a unique index (or primary key) whose parts are a leading prefix of idx's
+// parts. For example, a unique index on (a) implies uniqueness of any
+// combination (a,b). A part of other that only indexes a prefix of a column
+// still implies uniqueness of any longer prefix, or the entire column, in idx.
+// Column names are compared case-insensitively. This differs from redundancy
+// in the usual sense: idx may still be useful for queries, but it is pointless
+// for enforcing uniqueness.
+func IndexConstraintImpliedBy(idx, other *tengo.Index) bool {
+	if idx == other || !idx.Unique || !other.Unique {
+		return false
+	}
+	parts, otherParts := IndexParts(idx), IndexParts(other)
+	if len(otherParts) == 0 || len(otherParts) > len(parts) {
+		return false
+	}
+	for n, otherPart := range otherParts {
+		part := parts[n]
+		if !part.SameTarget(otherPart) {
+			return false
+		}
+		if otherPart.PrefixLength == 0 && part.PrefixLength != 0 {
+			return false
+		}
+		if part.PrefixLength != 0 && part.PrefixLength < otherPart.PrefixLength {
+			return false
+		}
+	}
+	return true
+}
+
+// AlterIndexVisibilityClause returns a clause for use in an ALTER TABLE
+// statement, which changes whether idx is visible to the query optimizer. This
+// is useful for making an index invisible prior to dropping it, in order to
+// safely confirm it is not needed. MySQL and Percona Server 8.0+ use the
+// syntax ALTER INDEX ... [IN]VISIBLE, while MariaDB 10.6+ uses ALTER INDEX ...
+// [NOT] IGNORED. An empty string is returned if the flavor does not support
+// this feature, or if idx is a primary key, which cannot be made invisible.
+func AlterIndexVisibilityClause(idx *tengo.Index, invisible bool, flavor tengo.Flavor) string {
+	if idx.PrimaryKey {
+		return ""
+	}
+	name := tengo.EscapeIdentifier(idx.Name)
+	if FlavorAtLeast(flavor, tengo.VendorMySQL, 8, 0) {
+		if invisible {
+			return fmt.Sprintf("ALTER INDEX %s INVISIBLE", name)
+		}
+		return fmt.Sprintf("ALTER INDEX %s VISIBLE", name)
+	} else if FlavorAtLeast(flavor, tengo.VendorMariaDB, 10, 6) {
+		if invisible {
+			return fmt.Sprintf("ALTER INDEX %s IGNORED", name)
+		}
+		return fmt.Sprintf("ALTER INDEX %s NOT IGNORED", name)
+	}
+	return ""
+}
+
+// IndexAddClause returns a clause for use in an ALTER TABLE statement, which
+// adds idx to a table, for example "ADD KEY `idx_a` (`a`)". If invisible is
+// true, the index is added in a state where it is ignored by the query
+// optimizer, using the syntax of flavor; this permits safely confirming the
+// impact of a new index before exposing it to queries. If flavor does not
+// support invisible indexes, or idx is a primary key, invisible is ignored.
+func IndexAddClause(idx *tengo.Index, flavor tengo.Flavor, invisible bool) string {
+	clause := "ADD " + idx.Definition(flavor)
+	if invisible && !idx.PrimaryKey {
+		if FlavorAtLeast(flavor, tengo.VendorMySQL, 8, 0) {
+			clause += " INVISIBLE"
+		} else if FlavorAtLeast(flavor, tengo.VendorMariaDB, 10, 6) {
+			clause += " IGNORED"
+		}
+	}
+	return clause
+}
+
+// IndexDropClause returns a clause for use in an ALTER TABLE statement, which
+// drops idx from a table: either "DROP PRIMARY KEY", or "DROP KEY" followed by
+// the escaped index name.
+func IndexDropClause(idx *tengo.Index) string {
+	if idx.PrimaryKey {
+		return "DROP PRIMARY KEY"
+	}
+	return "DROP KEY " + tengo.EscapeIdentifier(idx.Name)
+}
+
+// IndexRenameClause returns a clause for use in an ALTER TABLE statement,
+// which renames index from to the name of index to, for example as detected
+// by DiffIndexes. An empty string is returned if flavor does not support
+// RENAME INDEX (MySQL and Percona Server 5.7+, MariaDB 10.5+), or if either
+// index is a primary key, which cannot be renamed; in this case, the caller
+// should drop from and add to instead.
+func IndexRenameClause(from, to *tengo.Index, flavor tengo.Flavor) string {
+	if from.PrimaryKey || to.PrimaryKey {
+		return ""
+	}
+	if !FlavorAtLeast(flavor, tengo.VendorMySQL, 5, 7) && !FlavorAtLeast(flavor, tengo.VendorMariaDB, 10, 5) {
+		return ""
+	}
+	return fmt.Sprintf("RENAME KEY %s TO %s", tengo.EscapeIdentifier(from.Name), tengo.EscapeIdentifier(to.Name))
+}
+
+// DiffIndexParts returns human-readable descriptions of how the parts of index
+// to differ from those of index from, for example "column `a` added at position
+// 2" or "prefix length on `b` changed 10→20". Positions are 1-based. Reordering
+// is described minimally: columns belonging to the longest sequence that keeps
+// its relative order are not reported as moved. The result is empty if the two
+// indexes have the same parts, even if other attributes (such as name or
+// uniqueness) differ.
+func DiffIndexParts(from, to *tengo.Index) []string {
+	fromParts, toParts := IndexParts(from), IndexParts(to)
+	fromPos := make(map[string]int, len(fromParts))
+	for n, part := range fromParts {
+		fromPos[part.Column.Name] = n
+	}
+	toPos := make(map[string]int, len(toParts))
+	for n, part := range toParts {
+		toPos[part.Column.Name] = n
+	}
+
+	var diffs []string
+	for n, part := range fromParts {
+		if _, stillPresent := toPos[part.Column.Name]; !stillPresent {
+			diffs = append(diffs, fmt.Sprintf("column %s removed from position %d", tengo.EscapeIdentifier(part.Column.Name), n+1))
+		}
+	}
+
+	// For columns present in both, find which ones keep their relative ordering;
+	// any others are reported as moved.
+	var common []int // for each column in both (in to's order), its position in from
+	for _, part := range toParts {
+		if n, ok := fromPos[part.Column.Name]; ok {
+			common = append(common, n)
+		}
+	}
+	stable := make(map[int]bool, len(common))
+	for _, n := range longestIncreasingSubsequence(common) {
+		stable[n] = true
+	}
+
+	for n, part := range toParts {
+		name := tengo.EscapeIdentifier(part.Column.Name)
+		oldPos, ok := fromPos[part.Column.Name]
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("column %s added at position %d", name, n+1))
+			continue
+		}
+		if !stable[oldPos] {
+			diffs = append(diffs, fmt.Sprintf("column %s moved from position %d to %d", name, oldPos+1, n+1))
+		}
+		if oldPrefix := fromParts[oldPos].PrefixLength; oldPrefix != part.PrefixLength {
+			diffs = append(diffs, fmt.Sprintf("prefix length on %s changed %s→%s", name, prefixLengthString(oldPrefix), prefixLengthString(part.PrefixLength)))
+		}
+	}
+	return diffs
+}
+
+func prefixLengthString(length uint16) string {
+	if length == 0 {
+		return "none"
+	}
+	return strconv.Itoa(int(length))
+}
+
+// longestIncreasingSubsequence returns one of the longest strictly-increasing
+// subsequences of input, using patience sorting.
+func longestIncreasingSubsequence(input []int) []int {
+	if len(input) == 0 {
+		return nil
+	}
+	// tails[k] is the index into input of the smallest tail value of any
+	// increasing subsequence of length k+1; prev links each element to its
+	// predecessor in such a subsequence.
+	tails := make([]int, 0, len(input))
+	prev := make([]int, len(input))
+	for n, val := range input {
+		lo, hi := 0, len(tails)
+		for lo < hi {
+			mid := (lo + hi) / 2
+			if input[tails[mid]] < val {
+				lo = mid + 1
+			} else {
+				hi = mid
+			}
+		}
+		if lo > 0 {
+			prev[n] = tails[lo-1]
+		} else {
+			prev[n] = -1
+		}
+		if lo == len(tails) {
+			tails = append(tails, n)
+		} else {
+			tails[lo] = n
+		}
+	}
+	result := make([]int, len(tails))
+	for n, k := tails[len(tails)-1], len(tails)-1; k >= 0; n, k = prev[n], k-1 {
+		result[k] = input[n]
+	}
+	return result
+}
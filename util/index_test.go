@@ -0,0 +1,744 @@
+package util
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+// indexTestTable returns a table with columns id, a, b, c, and name; a
+// composite primary key on (id, a); and no secondary indexes.
+func indexTestTable() *tengo.Table {
+	cols := []*tengo.Column{
+		{Name: "id", TypeInDB: "int(10) unsigned"},
+		{Name: "a", TypeInDB: "int(11)"},
+		{Name: "b", TypeInDB: "int(11)", Nullable: true},
+		{Name: "c", TypeInDB: "int(11)", Nullable: true},
+		{Name: "name", TypeInDB: "varchar(100)", Nullable: true, CharSet: "utf8mb4", Collation: "utf8mb4_general_ci"},
+	}
+	return &tengo.Table{
+		Name:    "widgets",
+		Engine:  "InnoDB",
+		Columns: cols,
+		PrimaryKey: &tengo.Index{
+			Name:       "PRIMARY",
+			Columns:    []*tengo.Column{cols[0], cols[1]},
+			SubParts:   []uint16{0, 0},
+			PrimaryKey: true,
+			Unique:     true,
+		},
+	}
+}
+
+// indexOn returns a non-unique index on the named columns of table. Each name
+// may be followed by a prefix length, e.g. indexOn(table, "name", 10, "b").
+func indexOn(table *tengo.Table, args ...interface{}) *tengo.Index {
+	idx := &tengo.Index{Name: "idx"}
+	for _, arg := range args {
+		switch arg := arg.(type) {
+		case string:
+			for _, col := range table.Columns {
+				if col.Name == arg {
+					idx.Columns = append(idx.Columns, col)
+					idx.SubParts = append(idx.SubParts, 0)
+				}
+			}
+		case int:
+			idx.SubParts[len(idx.SubParts)-1] = uint16(arg)
+		}
+	}
+	return idx
+}
+
+func partNames(parts []IndexPart) []string {
+	names := make([]string, len(parts))
+	for n, part := range parts {
+		names[n] = part.Column.Name
+	}
+	return names
+}
+
+func TestIndexParts(t *testing.T) {
+	table := indexTestTable()
+	idx := indexOn(table, "name", 10, "b")
+	parts := IndexParts(idx)
+	expected := []IndexPart{
+		{Column: table.Columns[4], PrefixLength: 10},
+		{Column: table.Columns[2]},
+	}
+	if !reflect.DeepEqual(parts, expected) {
+		t.Errorf("Unexpected result from IndexParts: %+v", parts)
+	}
+}
+
+func TestIndexPartSameTarget(t *testing.T) {
+	table := indexTestTable()
+	name, b := table.Columns[4], table.Columns[2]
+	upperName := *name
+	upperName.Name = "NAME"
+	cases := []struct {
+		part, other IndexPart
+		expected    bool
+	}{
+		{IndexPart{Column: name}, IndexPart{Column: name}, true},
+		{IndexPart{Column: name, PrefixLength: 10}, IndexPart{Column: name, PrefixLength: 20}, true},
+		{IndexPart{Column: name}, IndexPart{Column: &upperName, PrefixLength: 5}, true},
+		{IndexPart{Column: name}, IndexPart{Column: b}, false},
+		{IndexPart{Column: name, Descending: true}, IndexPart{Column: name}, false},
+		{IndexPart{Column: name}, IndexPart{}, false},
+	}
+	for n, c := range cases {
+		if actual := c.part.SameTarget(c.other); actual != c.expected {
+			t.Errorf("cases[%d]: Expected SameTarget to return %t, instead found %t", n, c.expected, actual)
+		}
+		if reverse := c.other.SameTarget(c.part); reverse != c.expected {
+			t.Errorf("cases[%d]: Expected SameTarget to be symmetric, but reverse returned %t", n, reverse)
+		}
+	}
+}
+
+func TestEffectiveIndexParts(t *testing.T) {
+	table := indexTestTable()
+	cases := []struct {
+		idx      *tengo.Index
+		expected []string
+	}{
+		{indexOn(table, "b"), []string{"b", "id", "a"}},
+		{indexOn(table, "a", "b"), []string{"a", "b", "id"}},
+		{indexOn(table, "b", "id", "a"), []string{"b", "id", "a"}},
+		{indexOn(table, "name", 10), []string{"name", "id", "a"}},
+		{table.PrimaryKey, []string{"id", "a"}},
+	}
+	for _, c := range cases {
+		if actual := partNames(EffectiveIndexParts(table, c.idx)); !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("Expected EffectiveIndexParts to return %v, instead found %v", c.expected, actual)
+		}
+	}
+
+	// Unique indexes, non-InnoDB tables, and tables without a primary key do not
+	// get any implicit parts
+	unique := indexOn(table, "b")
+	unique.Unique = true
+	if actual := partNames(EffectiveIndexParts(table, unique)); !reflect.DeepEqual(actual, []string{"b"}) {
+		t.Errorf("Unexpected result from EffectiveIndexParts on unique index: %v", actual)
+	}
+	idx := indexOn(table, "b")
+	table.Engine = "MyISAM"
+	if actual := partNames(EffectiveIndexParts(table, idx)); !reflect.DeepEqual(actual, []string{"b"}) {
+		t.Errorf("Unexpected result from EffectiveIndexParts on MyISAM table: %v", actual)
+	}
+	table.Engine = "InnoDB"
+	table.PrimaryKey = nil
+	if actual := partNames(EffectiveIndexParts(table, idx)); !reflect.DeepEqual(actual, []string{"b"}) {
+		t.Errorf("Unexpected result from EffectiveIndexParts on table without PK: %v", actual)
+	}
+}
+
+func TestTableIndexParts(t *testing.T) {
+	table := indexTestTable()
+	table.CreateStatement = "CREATE TABLE `widgets` (\n" +
+		"  `id` int(10) unsigned NOT NULL,\n" +
+		"  `a` int(11) NOT NULL,\n" +
+		"  `b` int(11) DEFAULT NULL,\n" +
+		"  `c` int(11) DEFAULT NULL,\n" +
+		"  `name` varchar(100) DEFAULT NULL,\n" +
+		"  PRIMARY KEY (`id`,`a` DESC),\n" +
+		"  KEY `idx_b_name` (`b` DESC,`name`(10)),\n" +
+		"  UNIQUE KEY `idx_name_c` (`name`(20) DESC,`c`)\n" +
+		") ENGINE=InnoDB"
+	descending := func(parts []IndexPart) []bool {
+		result := make([]bool, len(parts))
+		for n, part := range parts {
+			result[n] = part.Descending
+		}
+		return result
+	}
+	bName := indexOn(table, "b", "name", 10)
+	bName.Name = "idx_b_name"
+	nameC := indexOn(table, "name", 20, "c")
+	nameC.Name = "idx_name_c"
+	nameC.Unique = true
+	missing := indexOn(table, "c")
+	missing.Name = "idx_missing"
+	cases := []struct {
+		idx      *tengo.Index
+		expected []bool
+	}{
+		{table.PrimaryKey, []bool{false, true}},
+		{bName, []bool{true, false}},
+		{nameC, []bool{true, false}},
+		{missing, []bool{false}},
+	}
+	for _, c := range cases {
+		if actual := descending(TableIndexParts(table, c.idx)); !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("Expected TableIndexParts for %s to have descending %v, instead found %v", c.idx.Name, c.expected, actual)
+		}
+	}
+
+	// Implicit primary key parts retain their sort order
+	if actual, expected := descending(EffectiveIndexParts(table, bName)), []bool{true, false, false, true}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected EffectiveIndexParts to have descending %v, instead found %v", expected, actual)
+	}
+}
+
+func TestIndexCovers(t *testing.T) {
+	table := indexTestTable()
+	idx := indexOn(table, "a", "name", 10, "b")
+	cases := map[string]bool{
+		"":        true,
+		"a":       true,
+		"b,a":     true,
+		"A,B":     true,
+		"a,c":     false,
+		"name":    false,
+		"a,name":  false,
+		"id":      false,
+		"b,b,a,a": true,
+	}
+	for input, expected := range cases {
+		var columns []string
+		if input != "" {
+			columns = strings.Split(input, ",")
+		}
+		if actual := IndexCovers(idx, columns); actual != expected {
+			t.Errorf("Expected IndexCovers(%q) to return %t, instead found %t", input, expected, actual)
+		}
+	}
+}
+
+func TestValidateIndex(t *testing.T) {
+	table := indexTestTable()
+	table.Columns = append(table.Columns,
+		&tengo.Column{Name: "body", TypeInDB: "mediumtext", CharSet: "utf8mb4", Collation: "utf8mb4_general_ci"},
+		&tengo.Column{Name: "hash", TypeInDB: "binary(16)"},
+		&tengo.Column{Name: "geo", TypeInDB: "point"},
+	)
+	table.CreateStatement = "CREATE TABLE `widgets` (\n" +
+		"  `id` int(10) unsigned NOT NULL,\n" +
+		"  `a` int(11) NOT NULL,\n" +
+		"  PRIMARY KEY (`id`,`a`),\n" +
+		"  KEY `idx_desc` (`b` DESC)\n" +
+		") ENGINE=InnoDB"
+	desc := indexOn(table, "b")
+	desc.Name = "idx_desc"
+	cases := []struct {
+		idx       *tengo.Index
+		expectErr bool
+	}{
+		{indexOn(table, "a", "b"), false},
+		{indexOn(table, "name"), false},
+		{indexOn(table, "name", 10), false},
+		{indexOn(table, "name", 100), false},
+		{indexOn(table, "name", 101), true},
+		{indexOn(table, "hash", 8, "a"), false},
+		{indexOn(table, "hash", 17), true},
+		{indexOn(table, "body", 255), false},
+		{indexOn(table, "body"), true},
+		{indexOn(table, "a", "b", 5), true},
+		{table.PrimaryKey, false},
+		{indexOn(table, "body", 1000), true}, // 4000 bytes exceeds InnoDB's 3072 byte limit
+		{indexOn(table, "geo"), false},       // key length of type cannot be determined
+		{desc, true},
+	}
+	for n, c := range cases {
+		if err := ValidateIndex(table, c.idx, tengo.FlavorMySQL57); c.expectErr && err == nil {
+			t.Errorf("cases[%d]: Expected error from ValidateIndex, but err was nil", n)
+		} else if !c.expectErr && err != nil {
+			t.Errorf("cases[%d]: Unexpected error from ValidateIndex: %s", n, err)
+		}
+	}
+
+	// Descending parts are supported by MySQL 8.0
+	if err := ValidateIndex(table, desc, tengo.FlavorMySQL80); err != nil {
+		t.Errorf("Unexpected error from ValidateIndex with MySQL 8.0: %s", err)
+	}
+}
+
+func TestEstimatedKeyLength(t *testing.T) {
+	table := indexTestTable()
+	table.CharSet = "latin1"
+	table.Columns = append(table.Columns,
+		&tengo.Column{Name: "body", TypeInDB: "text", CharSet: "utf8mb4"},
+		&tengo.Column{Name: "created", TypeInDB: "datetime(6)"},
+		&tengo.Column{Name: "price", TypeInDB: "decimal(12,2)", Nullable: true},
+		&tengo.Column{Name: "code", TypeInDB: "char(10)"},
+		&tengo.Column{Name: "hash", TypeInDB: "varbinary(255)"},
+		&tengo.Column{Name: "big", TypeInDB: "varchar(1000)", CharSet: "utf8mb4"},
+		&tengo.Column{Name: "doc", TypeInDB: "json"},
+	)
+	cases := []struct {
+		idx      *tengo.Index
+		expected int
+	}{
+		{table.PrimaryKey, 8},                                // int + int
+		{indexOn(table, "b"), 5},                             // int + null byte
+		{indexOn(table, "name"), 403},                        // 100 utf8mb4 chars + 2 length bytes + null byte
+		{indexOn(table, "name", 10, "a"), 47},                // 10 utf8mb4 chars + 3 overhead, int
+		{indexOn(table, "body", 20), 82},                     // 20 utf8mb4 chars + 2 length bytes
+		{indexOn(table, "created", "price"), 8 + 6 + 1},      // datetime(6), decimal(12,2) + null byte
+		{indexOn(table, "code", "hash"), 10 + 255 + 2},       // latin1 from table default, binary
+		{indexOn(table, "big", 768), 768*4 + 2},              // exactly at the InnoDB limit
+		{indexOn(table, "id", "a", "b", "c"), 4 + 4 + 5 + 5}, // mix of nullable and not
+	}
+	for n, c := range cases {
+		if actual, err := EstimatedKeyLength(table, c.idx, tengo.FlavorMySQL80); err != nil {
+			t.Errorf("cases[%d]: Unexpected error: %v", n, err)
+		} else if actual != c.expected {
+			t.Errorf("cases[%d]: Expected key length %d, instead found %d", n, c.expected, actual)
+		}
+	}
+
+	// Exceeding the InnoDB limit in total, or per column with COMPACT row format
+	tooLong := indexOn(table, "big")
+	if _, err := EstimatedKeyLength(table, tooLong, tengo.FlavorMySQL80); err == nil {
+		t.Error("Expected error for index exceeding 3072 bytes, but err was nil")
+	}
+	prefixed := indexOn(table, "big", 200)
+	if _, err := EstimatedKeyLength(table, prefixed, tengo.FlavorMySQL80); err != nil {
+		t.Errorf("Unexpected error with DYNAMIC row format: %v", err)
+	}
+	if _, err := EstimatedKeyLength(table, prefixed, tengo.FlavorMySQL56); err == nil {
+		t.Error("Expected error for column exceeding 767 bytes with default row format of MySQL 5.6, but err was nil")
+	}
+	table.CreateOptions = "ROW_FORMAT=COMPACT"
+	if _, err := EstimatedKeyLength(table, prefixed, tengo.FlavorMySQL80); err == nil {
+		t.Error("Expected error for column exceeding 767 bytes with ROW_FORMAT=COMPACT, but err was nil")
+	}
+	table.CreateOptions = ""
+
+	// MyISAM has a lower limit, and other engines have none
+	table.Engine = "MyISAM"
+	if _, err := EstimatedKeyLength(table, prefixed, tengo.FlavorMySQL80); err != nil {
+		t.Errorf("Unexpected error for 800-byte MyISAM index: %v", err)
+	} else if _, err := EstimatedKeyLength(table, indexOn(table, "big", 300), tengo.FlavorMySQL80); err == nil {
+		t.Error("Expected error for 1200-byte MyISAM index, but err was nil")
+	}
+	table.Engine = "MEMORY"
+	if _, err := EstimatedKeyLength(table, tooLong, tengo.FlavorMySQL80); err != nil {
+		t.Errorf("Unexpected error for MEMORY table: %v", err)
+	}
+
+	// Unknown types cannot be estimated
+	if _, err := EstimatedKeyLength(table, indexOn(table, "doc"), tengo.FlavorMySQL80); err == nil {
+		t.Error("Expected error for json column, but err was nil")
+	}
+}
+
+func TestDecimalLength(t *testing.T) {
+	cases := map[[2]int]int{
+		{10, 0}:  5,
+		{12, 2}:  6,
+		{18, 9}:  8,
+		{20, 10}: 10,
+		{65, 30}: 30,
+	}
+	for args, expected := range cases {
+		if actual := decimalLength(args[0], args[1]); actual != expected {
+			t.Errorf("Expected decimal(%d,%d) to use %d bytes, instead found %d", args[0], args[1], expected, actual)
+		}
+	}
+}
+
+func TestIndexEqualsWithOptions(t *testing.T) {
+	table := indexTestTable()
+	withComment := func(comment string) *tengo.Index {
+		idx := indexOn(table, "a", "b")
+		idx.Comment = comment
+		return idx
+	}
+	cases := []struct {
+		a, b                      string
+		strict, ignoreCase, ignWS bool
+	}{
+		{"hello", "hello", true, true, true},
+		{"hello", "Hello", false, true, false},
+		{"hello  world", " hello world\n", false, false, true},
+		{"Hello  World", "hello world", false, false, false},
+		{"hello", "goodbye", false, false, false},
+	}
+	for _, c := range cases {
+		a, b := withComment(c.a), withComment(c.b)
+		if actual := IndexEqualsWithOptions(a, b, IndexCompareOptions{}); actual != c.strict || actual != a.Equals(b) {
+			t.Errorf("Unexpected strict comparison result for %q vs %q: %t", c.a, c.b, actual)
+		}
+		if actual := IndexEqualsWithOptions(a, b, IndexCompareOptions{IgnoreCommentCase: true}); actual != c.ignoreCase {
+			t.Errorf("Unexpected case-insensitive comparison result for %q vs %q: %t", c.a, c.b, actual)
+		}
+		if actual := IndexEqualsWithOptions(a, b, IndexCompareOptions{IgnoreCommentWhitespace: true}); actual != c.ignWS {
+			t.Errorf("Unexpected whitespace-insensitive comparison result for %q vs %q: %t", c.a, c.b, actual)
+		}
+	}
+	both := IndexCompareOptions{IgnoreCommentCase: true, IgnoreCommentWhitespace: true}
+	if !IndexEqualsWithOptions(withComment("Hello  World"), withComment("hello world"), both) {
+		t.Error("Expected comments to be equal when ignoring both case and whitespace")
+	}
+
+	// Options should not affect comparison of anything besides comments
+	a, b := withComment("x"), withComment("X")
+	b.Name = "other"
+	if IndexEqualsWithOptions(a, b, both) {
+		t.Error("Expected indexes with different names to be unequal")
+	}
+	if !IndexEqualsWithOptions(nil, nil, both) || IndexEqualsWithOptions(a, nil, both) {
+		t.Error("Unexpected handling of nil indexes")
+	}
+	if a.Comment != "x" || b.Comment != "X" {
+		t.Error("IndexEqualsWithOptions unexpectedly modified its inputs")
+	}
+}
+
+func TestIndexEquivalent(t *testing.T) {
+	table := indexTestTable()
+	idx := indexOn(table, "a", "name", 10)
+	other := indexOn(table, "a", "name", 10)
+	other.Name, other.Comment = "renamed", "hello"
+	if !IndexEquivalent(idx, other) {
+		t.Error("Expected indexes differing only by name and comment to be equivalent")
+	}
+	other.Unique = true
+	if IndexEquivalent(idx, other) {
+		t.Error("Expected indexes differing by uniqueness to not be equivalent")
+	}
+	if IndexEquivalent(idx, indexOn(table, "a", "name", 20)) || IndexEquivalent(idx, indexOn(table, "a")) {
+		t.Error("Expected indexes differing by parts to not be equivalent")
+	}
+	if IndexEquivalent(idx, nil) || !IndexEquivalent(nil, nil) {
+		t.Error("Unexpected result comparing with nil")
+	}
+}
+
+func TestIndexSupportedBy(t *testing.T) {
+	table := indexTestTable()
+	table.CreateStatement = "CREATE TABLE `widgets` (\n" +
+		"  `id` int(10) unsigned NOT NULL,\n" +
+		"  `a` int(11) NOT NULL,\n" +
+		"  PRIMARY KEY (`id`,`a`),\n" +
+		"  KEY `idx_desc` (`b`,`c` DESC),\n" +
+		"  KEY `idx_asc` (`b`,`c`)\n" +
+		") ENGINE=InnoDB"
+	desc := indexOn(table, "b", "c")
+	desc.Name = "idx_desc"
+	asc := indexOn(table, "b", "c")
+	asc.Name = "idx_asc"
+	cases := []struct {
+		flavor    tengo.Flavor
+		supported bool
+	}{
+		{tengo.FlavorMySQL57, false},
+		{tengo.FlavorPercona57, false},
+		{tengo.NewFlavor("mariadb:10.6"), false},
+		{tengo.FlavorMySQL80, true},
+		{tengo.FlavorPercona80, true},
+		{tengo.NewFlavor("mariadb:10.8"), true},
+		{tengo.FlavorUnknown, true},
+	}
+	for _, c := range cases {
+		if err := IndexSupportedBy(table, desc, c.flavor); (err == nil) != c.supported {
+			t.Errorf("Unexpected result from IndexSupportedBy for flavor %s: %v", c.flavor, err)
+		}
+		if err := IndexSupportedBy(table, asc, c.flavor); err != nil {
+			t.Errorf("Unexpected error from IndexSupportedBy for ascending index with flavor %s: %v", c.flavor, err)
+		}
+	}
+}
+
+func TestIndexCanonicalKey(t *testing.T) {
+	table := indexTestTable()
+	cases := []struct {
+		idx      *tengo.Index
+		expected string
+	}{
+		{table.PrimaryKey, "PRIMARY(`id`,`a`)"},
+		{indexOn(table, "b", "name", 10), "KEY(`b`,`name`(10))"},
+	}
+	for _, c := range cases {
+		if actual := IndexCanonicalKey(c.idx); actual != c.expected {
+			t.Errorf("Expected IndexCanonicalKey to return %q, instead found %q", c.expected, actual)
+		}
+	}
+
+	// Name, comment, and column name case do not affect the key; type, parts,
+	// and prefix lengths do
+	idx := indexOn(table, "b", "name", 10)
+	other := indexOn(table, "b", "name", 10)
+	other.Name, other.Comment = "other", "hello"
+	upper := *table.Columns[2]
+	upper.Name = "B"
+	other.Columns[0] = &upper
+	if IndexCanonicalKey(idx) != IndexCanonicalKey(other) {
+		t.Errorf("Expected equivalent indexes to have same key, instead found %q vs %q", IndexCanonicalKey(idx), IndexCanonicalKey(other))
+	}
+	different := []*tengo.Index{
+		indexOn(table, "b", "name", 20),
+		indexOn(table, "b", "name"),
+		indexOn(table, "name", 10, "b"),
+		indexOn(table, "b"),
+	}
+	unique := indexOn(table, "b", "name", 10)
+	unique.Unique = true
+	different = append(different, unique)
+	for _, d := range different {
+		if IndexCanonicalKey(idx) == IndexCanonicalKey(d) {
+			t.Errorf("Expected different keys, but both were %q", IndexCanonicalKey(d))
+		}
+	}
+}
+
+func TestDiffIndexes(t *testing.T) {
+	table := indexTestTable()
+	named := func(name string, args ...interface{}) *tengo.Index {
+		idx := indexOn(table, args...)
+		idx.Name = name
+		return idx
+	}
+	names := func(indexes []*tengo.Index) []string {
+		var result []string
+		for _, idx := range indexes {
+			result = append(result, idx.Name)
+		}
+		return result
+	}
+	pairNames := func(pairs [][2]*tengo.Index) []string {
+		var result []string
+		for _, pair := range pairs {
+			result = append(result, pair[0].Name+">"+pair[1].Name)
+		}
+		return result
+	}
+
+	from := []*tengo.Index{
+		named("idx_a", "a"),
+		named("idx_b", "b"),
+		named("idx_c", "c"),
+		named("old_name", "name", 10),
+		named("idx_ab", "a", "b"),
+	}
+	commented := named("idx_c", "c")
+	commented.Comment = "changed"
+	to := []*tengo.Index{
+		named("idx_new", "c", "b"),
+		named("new_name", "name", 10),
+		commented,
+		named("idx_a", "a"),
+		named("idx_b", "b", "a"),
+	}
+	added, dropped, modified := DiffIndexes(table, table, from, to)
+	if actual, expected := names(added), []string{"idx_new"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected added %v, instead found %v", expected, actual)
+	}
+	if actual, expected := names(dropped), []string{"idx_ab"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected dropped %v, instead found %v", expected, actual)
+	}
+	if actual, expected := pairNames(modified), []string{"old_name>new_name", "idx_c>idx_c", "idx_b>idx_b"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected modified %v, instead found %v", expected, actual)
+	}
+
+	// Identical inputs should yield no differences
+	if added, dropped, modified := DiffIndexes(table, table, from, from); len(added)+len(dropped)+len(modified) > 0 {
+		t.Errorf("Expected no differences, instead found added=%v dropped=%v modified=%v", names(added), names(dropped), pairNames(modified))
+	}
+
+	// Each index may only be the source of one rename
+	from = []*tengo.Index{named("x", "a")}
+	to = []*tengo.Index{named("y", "a"), named("z", "a")}
+	added, dropped, modified = DiffIndexes(table, table, from, to)
+	if len(dropped) != 0 || !reflect.DeepEqual(names(added), []string{"z"}) || !reflect.DeepEqual(pairNames(modified), []string{"x>y"}) {
+		t.Errorf("Unexpected result with duplicate rename candidates: added=%v dropped=%v modified=%v", names(added), names(dropped), pairNames(modified))
+	}
+
+	// Changing the sort order of a part is not a rename, and is a modification
+	// of an index with the same name
+	descTable := indexTestTable()
+	descTable.CreateStatement = "CREATE TABLE `widgets` (\n" +
+		"  `id` int(10) unsigned NOT NULL,\n" +
+		"  `a` int(11) NOT NULL,\n" +
+		"  PRIMARY KEY (`id`,`a`),\n" +
+		"  KEY `y` (`a` DESC),\n" +
+		"  KEY `x2` (`b` DESC)\n" +
+		") ENGINE=InnoDB"
+	from = []*tengo.Index{named("x", "a"), named("x2", "b")}
+	to = []*tengo.Index{named("y", "a"), named("x2", "b")}
+	added, dropped, modified = DiffIndexes(table, descTable, from, to)
+	if !reflect.DeepEqual(names(added), []string{"y"}) || !reflect.DeepEqual(names(dropped), []string{"x"}) || !reflect.DeepEqual(pairNames(modified), []string{"x2>x2"}) {
+		t.Errorf("Unexpected result with changed sort order: added=%v dropped=%v modified=%v", names(added), names(dropped), pairNames(modified))
+	}
+}
+
+func TestTableIndexCanonicalKey(t *testing.T) {
+	table := indexTestTable()
+	table.CreateStatement = "CREATE TABLE `widgets` (\n" +
+		"  `id` int(10) unsigned NOT NULL,\n" +
+		"  `a` int(11) NOT NULL,\n" +
+		"  PRIMARY KEY (`id`,`a` DESC),\n" +
+		"  KEY `idx` (`b` DESC,`name`(10))\n" +
+		") ENGINE=InnoDB"
+	idx := indexOn(table, "b", "name", 10)
+	if actual, expected := TableIndexCanonicalKey(table, table.PrimaryKey), "PRIMARY(`id`,`a` DESC)"; actual != expected {
+		t.Errorf("Expected TableIndexCanonicalKey to return %q, instead found %q", expected, actual)
+	}
+	if actual, expected := TableIndexCanonicalKey(table, idx), "KEY(`b` DESC,`name`(10))"; actual != expected {
+		t.Errorf("Expected TableIndexCanonicalKey to return %q, instead found %q", expected, actual)
+	}
+
+	// IndexCanonicalKey cannot determine sort order
+	if actual, expected := IndexCanonicalKey(idx), "KEY(`b`,`name`(10))"; actual != expected {
+		t.Errorf("Expected IndexCanonicalKey to return %q, instead found %q", expected, actual)
+	}
+}
+
+func TestIndexConstraintImpliedBy(t *testing.T) {
+	table := indexTestTable()
+	uniqueOn := func(args ...interface{}) *tengo.Index {
+		idx := indexOn(table, args...)
+		idx.Unique = true
+		return idx
+	}
+	cases := []struct {
+		idx, other *tengo.Index
+		expected   bool
+	}{
+		{uniqueOn("a", "b"), uniqueOn("a"), true},
+		{uniqueOn("a", "b"), uniqueOn("a", "b"), true},
+		{uniqueOn("a"), uniqueOn("a", "b"), false},
+		{uniqueOn("b", "a"), uniqueOn("a"), false},
+		{indexOn(table, "a", "b"), uniqueOn("a"), false},
+		{uniqueOn("a", "b"), indexOn(table, "a"), false},
+		{uniqueOn("id", "a", "b"), table.PrimaryKey, true},
+		{uniqueOn("name", "b"), uniqueOn("name", 10), true},
+		{uniqueOn("name", 20, "b"), uniqueOn("name", 10), true},
+		{uniqueOn("name", 5, "b"), uniqueOn("name", 10), false},
+		{uniqueOn("name", 10, "b"), uniqueOn("name"), false},
+	}
+	for n, c := range cases {
+		if actual := IndexConstraintImpliedBy(c.idx, c.other); actual != c.expected {
+			t.Errorf("cases[%d]: Expected %t, instead found %t", n, c.expected, actual)
+		}
+	}
+	idx := uniqueOn("a")
+	if IndexConstraintImpliedBy(idx, idx) {
+		t.Error("Expected an index to not be considered implied by itself")
+	}
+
+	// Column names are case-insensitive
+	upper := *table.Columns[1]
+	upper.Name = "A"
+	mixedCase := uniqueOn("a", "b")
+	mixedCase.Columns[0] = &upper
+	if !IndexConstraintImpliedBy(mixedCase, uniqueOn("a")) {
+		t.Error("Expected column names to be compared case-insensitively")
+	}
+}
+
+func TestAlterIndexVisibilityClause(t *testing.T) {
+	table := indexTestTable()
+	idx := indexOn(table, "b")
+	cases := []struct {
+		flavor    tengo.Flavor
+		invisible bool
+		expected  string
+	}{
+		{tengo.FlavorMySQL80, true, "ALTER INDEX `idx` INVISIBLE"},
+		{tengo.FlavorMySQL80, false, "ALTER INDEX `idx` VISIBLE"},
+		{tengo.FlavorPercona80, true, "ALTER INDEX `idx` INVISIBLE"},
+		{tengo.NewFlavor("mariadb:10.6"), true, "ALTER INDEX `idx` IGNORED"},
+		{tengo.NewFlavor("mariadb:10.11"), false, "ALTER INDEX `idx` NOT IGNORED"},
+		{tengo.FlavorMySQL57, true, ""},
+		{tengo.FlavorMariaDB103, true, ""},
+		{tengo.FlavorUnknown, true, ""},
+	}
+	for _, c := range cases {
+		if actual := AlterIndexVisibilityClause(idx, c.invisible, c.flavor); actual != c.expected {
+			t.Errorf("Expected AlterIndexVisibilityClause(%t) for %s to return %q, instead found %q", c.invisible, c.flavor, c.expected, actual)
+		}
+	}
+	if actual := AlterIndexVisibilityClause(table.PrimaryKey, true, tengo.FlavorMySQL80); actual != "" {
+		t.Errorf("Expected empty clause for primary key, instead found %q", actual)
+	}
+}
+
+func TestIndexAddDropClauses(t *testing.T) {
+	table := indexTestTable()
+	idx := indexOn(table, "a", "name", 10)
+	idx.Name = "idx_a_name"
+	idx.Unique = true
+	mariaDB106 := tengo.Flavor{Vendor: tengo.VendorMariaDB, Major: 10, Minor: 6}
+	cases := []struct {
+		flavor    tengo.Flavor
+		invisible bool
+		expected  string
+	}{
+		{tengo.FlavorMySQL57, false, "ADD UNIQUE KEY `idx_a_name` (`a`,`name`(10))"},
+		{tengo.FlavorMySQL57, true, "ADD UNIQUE KEY `idx_a_name` (`a`,`name`(10))"},
+		{tengo.FlavorPercona80, true, "ADD UNIQUE KEY `idx_a_name` (`a`,`name`(10)) INVISIBLE"},
+		{mariaDB106, true, "ADD UNIQUE KEY `idx_a_name` (`a`,`name`(10)) IGNORED"},
+	}
+	for _, c := range cases {
+		if actual := IndexAddClause(idx, c.flavor, c.invisible); actual != c.expected {
+			t.Errorf("Expected IndexAddClause with flavor %s, invisible=%t to return %q, instead found %q", c.flavor, c.invisible, c.expected, actual)
+		}
+	}
+	if actual, expected := IndexAddClause(table.PrimaryKey, tengo.FlavorMySQL80, true), "ADD PRIMARY KEY (`id`,`a`)"; actual != expected {
+		t.Errorf("Expected %q, instead found %q", expected, actual)
+	}
+
+	if actual, expected := IndexDropClause(idx), "DROP KEY `idx_a_name`"; actual != expected {
+		t.Errorf("Expected %q, instead found %q", expected, actual)
+	}
+	if actual, expected := IndexDropClause(table.PrimaryKey), "DROP PRIMARY KEY"; actual != expected {
+		t.Errorf("Expected %q, instead found %q", expected, actual)
+	}
+
+	renamed := *idx
+	renamed.Name = "new_name"
+	if actual, expected := IndexRenameClause(idx, &renamed, tengo.FlavorMySQL57), "RENAME KEY `idx_a_name` TO `new_name`"; actual != expected {
+		t.Errorf("Expected %q, instead found %q", expected, actual)
+	}
+	if actual := IndexRenameClause(idx, &renamed, tengo.FlavorMySQL56); actual != "" {
+		t.Errorf("Expected no rename clause for %s, instead found %q", tengo.FlavorMySQL56, actual)
+	}
+	if actual := IndexRenameClause(idx, &renamed, tengo.FlavorMariaDB103); actual != "" {
+		t.Errorf("Expected no rename clause for %s, instead found %q", tengo.FlavorMariaDB103, actual)
+	}
+}
+
+func TestDiffIndexParts(t *testing.T) {
+	table := indexTestTable()
+	cases := []struct {
+		from, to *tengo.Index
+		expected []string
+	}{
+		{indexOn(table, "a", "b"), indexOn(table, "a", "b"), nil},
+		{indexOn(table, "a"), indexOn(table, "a", "b"), []string{"column `b` added at position 2"}},
+		{indexOn(table, "a", "b", "c"), indexOn(table, "a", "c"), []string{"column `b` removed from position 2"}},
+		{indexOn(table, "a", "b", "c"), indexOn(table, "b", "c", "a"), []string{"column `a` moved from position 1 to 3"}},
+		{indexOn(table, "name", 10), indexOn(table, "name", 20), []string{"prefix length on `name` changed 10→20"}},
+		{indexOn(table, "name", 10, "a"), indexOn(table, "a", "name"), []string{"column `a` moved from position 2 to 1", "prefix length on `name` changed 10→none"}},
+		{indexOn(table, "a", "b", "c"), indexOn(table, "c", "a", "b"), []string{"column `c` moved from position 3 to 1"}},
+		{indexOn(table, "a", "b"), indexOn(table, "c", "a", "id", "b", 4), []string{"column `c` added at position 1", "column `id` added at position 3", "prefix length on `b` changed none→4"}},
+	}
+	for n, c := range cases {
+		if actual := DiffIndexParts(c.from, c.to); !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("cases[%d]: Expected %v, instead found %v", n, c.expected, actual)
+		}
+	}
+}
+
+func TestLongestIncreasingSubsequence(t *testing.T) {
+	cases := []struct {
+		input, expected []int
+	}{
+		{nil, nil},
+		{[]int{4}, []int{4}},
+		{[]int{0, 1, 2, 3}, []int{0, 1, 2, 3}},
+		{[]int{3, 2, 1, 0}, []int{0}},
+		{[]int{1, 2, 0}, []int{1, 2}},
+		{[]int{2, 0, 3, 1, 4}, []int{0, 1, 4}},
+	}
+	for _, c := range cases {
+		if actual := longestIncreasingSubsequence(c.input); !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("Expected longestIncreasingSubsequence(%v) to return %v, instead found %v", c.input, c.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,381 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// PartitioningClause represents the partitioning clause of a CREATE TABLE
+// statement, as formatted by SHOW CREATE TABLE.
+type PartitioningClause struct {
+	Method        string      // "RANGE", "LIST", "HASH", or "KEY"
+	Linear        bool        // true for LINEAR HASH or LINEAR KEY
+	Expression    string      // partitioning expression; empty if Columns is used instead
+	Columns       []string    // column list for RANGE COLUMNS, LIST COLUMNS, or KEY
+	Count         int         // value of PARTITIONS clause, or 0 if omitted
+	SubMethod     string      // "HASH" or "KEY" if subpartitioned, otherwise empty
+	SubLinear     bool        // true for LINEAR HASH or LINEAR KEY subpartitioning
+	SubExpression string      // subpartitioning expression; empty if SubColumns is used instead
+	SubColumns    []string    // column list for subpartitioning by KEY
+	SubCount      int         // value of SUBPARTITIONS clause, or 0 if omitted
+	Partitions    []Partition // explicitly-defined partitions, if any
+}
+
+// Partition represents a single explicitly-defined partition.
+type Partition struct {
+	Name          string
+	Values        string   // "MAXVALUE", or the contents of the VALUES parentheses; empty for HASH or KEY
+	Comment       string   // unescaped partition comment, if any
+	Subpartitions []string // names of explicitly-defined subpartitions, if any
+}
+
+// ParsePartitioning parses the partitioning clause of the supplied CREATE TABLE
+// statement, formatted in the same manner as SHOW CREATE TABLE. Version-gated
+// comment wrappers are handled automatically. If the table is not partitioned,
+// a nil PartitioningClause and nil error are returned.
+func ParsePartitioning(createStmt string) (*PartitioningClause, error) {
+	_, clause := ParseCreatePartitioning(createStmt)
+	if clause == "" {
+		return nil, nil
+	}
+	clause = strings.TrimSpace(clause)
+	if strings.HasPrefix(clause, "/*!") && strings.HasSuffix(clause, "*/") {
+		clause = strings.TrimLeft(clause[3:len(clause)-2], "0123456789")
+	}
+
+	p := &partitionParser{input: clause}
+	if !p.keyword("PARTITION") || !p.keyword("BY") {
+		return nil, p.errorf("expected PARTITION BY")
+	}
+	result := &PartitioningClause{}
+	var err error
+	if result.Method, result.Linear, result.Expression, result.Columns, err = p.method(); err != nil {
+		return nil, err
+	}
+	if p.keyword("PARTITIONS") {
+		if result.Count, err = p.number(); err != nil {
+			return nil, err
+		}
+	}
+	if p.keyword("SUBPARTITION") {
+		if !p.keyword("BY") {
+			return nil, p.errorf("expected BY")
+		}
+		if result.SubMethod, result.SubLinear, result.SubExpression, result.SubColumns, err = p.method(); err != nil {
+			return nil, err
+		}
+		if p.keyword("SUBPARTITIONS") {
+			if result.SubCount, err = p.number(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if p.peek() == '(' {
+		defs, err := p.parens()
+		if err != nil {
+			return nil, err
+		}
+		for _, def := range splitTopLevel(defs) {
+			part, err := parsePartitionDefinition(def)
+			if err != nil {
+				return nil, err
+			}
+			result.Partitions = append(result.Partitions, part)
+		}
+	}
+	if p.skipSpace(); p.pos < len(p.input) {
+		return nil, p.errorf("unexpected trailing content")
+	}
+	return result, nil
+}
+
+func parsePartitionDefinition(def string) (part Partition, err error) {
+	p := &partitionParser{input: def}
+	if !p.keyword("PARTITION") {
+		return part, p.errorf("expected PARTITION")
+	}
+	part.Name = p.identifier()
+	if p.keyword("VALUES") {
+		if p.keyword("LESS") {
+			if !p.keyword("THAN") {
+				return part, p.errorf("expected THAN")
+			}
+			if p.keyword("MAXVALUE") {
+				part.Values = "MAXVALUE"
+			} else if part.Values, err = p.parens(); err != nil {
+				return part, err
+			}
+		} else if p.keyword("IN") {
+			if part.Values, err = p.parens(); err != nil {
+				return part, err
+			}
+		} else {
+			return part, p.errorf("expected LESS THAN or IN")
+		}
+	}
+	for p.skipSpace(); p.pos < len(p.input); p.skipSpace() {
+		if p.peek() == '(' {
+			subdefs, err := p.parens()
+			if err != nil {
+				return part, err
+			}
+			for _, subdef := range splitTopLevel(subdefs) {
+				sp := &partitionParser{input: subdef}
+				if !sp.keyword("SUBPARTITION") {
+					return part, sp.errorf("expected SUBPARTITION")
+				}
+				part.Subpartitions = append(part.Subpartitions, sp.identifier())
+			}
+		} else if p.keyword("COMMENT") {
+			p.equals()
+			if part.Comment, err = p.stringLiteral(); err != nil {
+				return part, err
+			}
+		} else {
+			// Skip other options, such as ENGINE = InnoDB or DATA DIRECTORY = '...'
+			if p.peek() == '\'' {
+				if _, err = p.stringLiteral(); err != nil {
+					return part, err
+				}
+			} else if p.word() == "" {
+				p.pos++
+			}
+		}
+	}
+	return part, nil
+}
+
+// partitionParser is a simple cursor over a partitioning clause.
+type partitionParser struct {
+	input string
+	pos   int
+}
+
+func (p *partitionParser) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("Unable to parse partitioning clause at byte offset %d: %s", p.pos, fmt.Sprintf(format, a...))
+}
+
+func (p *partitionParser) skipSpace() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *partitionParser) peek() byte {
+	p.skipSpace()
+	if p.pos >= len(p.input) {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || b == '$' || (b >= '0' && b <= '9') || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+// word consumes and returns the next run of identifier characters, or an empty
+// string if the next character is not one.
+func (p *partitionParser) word() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.input) && isWordByte(p.input[p.pos]) {
+		p.pos++
+	}
+	return p.input[start:p.pos]
+}
+
+// keyword consumes the next word and returns true if it case-insensitively
+// matches kw. Otherwise, nothing is consumed and false is returned.
+func (p *partitionParser) keyword(kw string) bool {
+	start := p.pos
+	if strings.EqualFold(p.word(), kw) {
+		return true
+	}
+	p.pos = start
+	return false
+}
+
+// identifier consumes an identifier, which may optionally be backtick-quoted.
+func (p *partitionParser) identifier() string {
+	if p.peek() != '`' {
+		return p.word()
+	}
+	var b strings.Builder
+	for p.pos++; p.pos < len(p.input); p.pos++ {
+		if p.input[p.pos] == '`' {
+			if p.pos+1 < len(p.input) && p.input[p.pos+1] == '`' {
+				p.pos++
+			} else {
+				p.pos++
+				break
+			}
+		}
+		b.WriteByte(p.input[p.pos])
+	}
+	return b.String()
+}
+
+func (p *partitionParser) equals() {
+	if p.peek() == '=' {
+		p.pos++
+	}
+}
+
+func (p *partitionParser) number() (int, error) {
+	p.equals()
+	n, err := strconv.Atoi(p.word())
+	if err != nil {
+		return 0, p.errorf("expected number")
+	}
+	return n, nil
+}
+
+// stringLiteral consumes a single-quoted string, returning its unescaped value.
+func (p *partitionParser) stringLiteral() (string, error) {
+	if p.peek() != '\'' {
+		return "", p.errorf("expected string literal")
+	}
+	var b strings.Builder
+	for p.pos++; p.pos < len(p.input); p.pos++ {
+		c := p.input[p.pos]
+		if c == '\\' && p.pos+1 < len(p.input) {
+			p.pos++
+			c = p.input[p.pos]
+		} else if c == '\'' {
+			if p.pos+1 < len(p.input) && p.input[p.pos+1] == '\'' {
+				p.pos++
+			} else {
+				p.pos++
+				return b.String(), nil
+			}
+		}
+		b.WriteByte(c)
+	}
+	return "", p.errorf("unterminated string literal")
+}
+
+// parens consumes a balanced parenthesized expression, returning its contents
+// without the outer parentheses.
+func (p *partitionParser) parens() (string, error) {
+	if p.peek() != '(' {
+		return "", p.errorf("expected (")
+	}
+	start := p.pos + 1
+	end := matchingParen(p.input, p.pos)
+	if end < 0 {
+		return "", p.errorf("unbalanced parentheses")
+	}
+	p.pos = end + 1
+	return p.input[start:end], nil
+}
+
+// method consumes a partitioning method and its expression or column list.
+func (p *partitionParser) method() (method string, linear bool, expr string, columns []string, err error) {
+	linear = p.keyword("LINEAR")
+	method = strings.ToUpper(p.word())
+	switch method {
+	case "RANGE", "LIST":
+		if p.keyword("COLUMNS") {
+			columns, err = p.columnList()
+			return
+		}
+		expr, err = p.parens()
+	case "HASH":
+		expr, err = p.parens()
+	case "KEY":
+		if p.keyword("ALGORITHM") {
+			if _, err = p.number(); err != nil {
+				return
+			}
+		}
+		columns, err = p.columnList()
+	default:
+		err = p.errorf("unknown partitioning method %q", method)
+	}
+	return
+}
+
+func (p *partitionParser) columnList() ([]string, error) {
+	contents, err := p.parens()
+	if err != nil {
+		return nil, err
+	}
+	var columns []string
+	for _, col := range splitTopLevel(contents) {
+		cp := &partitionParser{input: col}
+		if name := cp.identifier(); name != "" {
+			columns = append(columns, name)
+		}
+	}
+	return columns, nil
+}
+
+// matchingParen returns the position of the parenthesis which closes the one
+// at position open, or -1 if there is none. Quoted strings and identifiers are
+// handled properly.
+func matchingParen(s string, open int) int {
+	var depth int
+	var quote byte
+	for n := open; n < len(s); n++ {
+		c := s[n]
+		if quote != 0 {
+			if c == '\\' && quote == '\'' {
+				n++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"', '`':
+			quote = c
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return n
+			}
+		}
+	}
+	return -1
+}
+
+// splitTopLevel splits s on commas which are not inside parentheses or quotes,
+// trimming whitespace from each resulting element.
+func splitTopLevel(s string) []string {
+	var result []string
+	var depth int
+	var quote byte
+	start := 0
+	for n := 0; n < len(s); n++ {
+		c := s[n]
+		if quote != 0 {
+			if c == '\\' && quote == '\'' {
+				n++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"', '`':
+			quote = c
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				result = append(result, strings.TrimSpace(s[start:n]))
+				start = n + 1
+			}
+		}
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		result = append(result, last)
+	}
+	return result
+}
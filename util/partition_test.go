@@ -0,0 +1,98 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePartitioning(t *testing.T) {
+	base := "CREATE TABLE `orders` (\n  `id` int(11) NOT NULL,\n  `region` varchar(10) NOT NULL,\n  `created` date NOT NULL,\n  PRIMARY KEY (`id`,`region`,`created`)\n) ENGINE=InnoDB DEFAULT CHARSET=latin1"
+	cases := []struct {
+		clause   string
+		expected PartitioningClause
+	}{
+		{
+			"/*!50100 PARTITION BY RANGE (year(`created`))\n(PARTITION p2019 VALUES LESS THAN (2020) ENGINE = InnoDB,\n PARTITION pmax VALUES LESS THAN MAXVALUE COMMENT = 'it''s the \\'max\\'' ENGINE = InnoDB) */",
+			PartitioningClause{
+				Method:     "RANGE",
+				Expression: "year(`created`)",
+				Partitions: []Partition{
+					{Name: "p2019", Values: "2020"},
+					{Name: "pmax", Values: "MAXVALUE", Comment: "it's the 'max'"},
+				},
+			},
+		},
+		{
+			"/*!50500 PARTITION BY RANGE  COLUMNS(created,`region`)\n(PARTITION p0 VALUES LESS THAN ('2020-01-01','m') ENGINE = InnoDB) */",
+			PartitioningClause{
+				Method:     "RANGE",
+				Columns:    []string{"created", "region"},
+				Partitions: []Partition{{Name: "p0", Values: "'2020-01-01','m'"}},
+			},
+		},
+		{
+			"/*!50100 PARTITION BY LIST (`id`)\n(PARTITION odd VALUES IN (1,3,5) ENGINE = InnoDB,\n PARTITION even VALUES IN (2,4,6) ENGINE = InnoDB) */",
+			PartitioningClause{
+				Method:     "LIST",
+				Expression: "`id`",
+				Partitions: []Partition{{Name: "odd", Values: "1,3,5"}, {Name: "even", Values: "2,4,6"}},
+			},
+		},
+		{
+			"/*!50100 PARTITION BY HASH (`id`)\nPARTITIONS 4 */",
+			PartitioningClause{Method: "HASH", Expression: "`id`", Count: 4},
+		},
+		{
+			"/*!50100 PARTITION BY LINEAR KEY ALGORITHM = 2 (region,id)\nPARTITIONS 3 */",
+			PartitioningClause{Method: "KEY", Linear: true, Columns: []string{"region", "id"}, Count: 3},
+		},
+		{
+			"/*!50100 PARTITION BY RANGE (`id`)\nSUBPARTITION BY HASH (`id`)\nSUBPARTITIONS 2\n(PARTITION p0 VALUES LESS THAN (100) ENGINE = InnoDB,\n PARTITION p1 VALUES LESS THAN MAXVALUE ENGINE = InnoDB) */",
+			PartitioningClause{
+				Method:        "RANGE",
+				Expression:    "`id`",
+				SubMethod:     "HASH",
+				SubExpression: "`id`",
+				SubCount:      2,
+				Partitions:    []Partition{{Name: "p0", Values: "100"}, {Name: "p1", Values: "MAXVALUE"}},
+			},
+		},
+		{
+			" PARTITION BY LIST  COLUMNS(`region`)\nSUBPARTITION BY KEY (`id`)\n(PARTITION pEast VALUES IN ('east','north, east') COMMENT = 'East' (SUBPARTITION s0 ENGINE = InnoDB,\n  SUBPARTITION `s 1` ENGINE = InnoDB))",
+			PartitioningClause{
+				Method:     "LIST",
+				Columns:    []string{"region"},
+				SubMethod:  "KEY",
+				SubColumns: []string{"id"},
+				Partitions: []Partition{{Name: "pEast", Values: "'east','north, east'", Comment: "East", Subpartitions: []string{"s0", "s 1"}}},
+			},
+		},
+	}
+	for n, c := range cases {
+		actual, err := ParsePartitioning(base + "\n" + c.clause)
+		if err != nil {
+			t.Errorf("cases[%d]: Unexpected error: %v", n, err)
+		} else if !reflect.DeepEqual(*actual, c.expected) {
+			t.Errorf("cases[%d]: Expected %+v, instead found %+v", n, c.expected, *actual)
+		}
+	}
+
+	// Unpartitioned table
+	if actual, err := ParsePartitioning(base); actual != nil || err != nil {
+		t.Errorf("Expected nil result and nil error for unpartitioned table, instead found %+v, %v", actual, err)
+	}
+
+	// Malformed clauses
+	badClauses := []string{
+		"/*!50100 PARTITION BY SPLIT (`id`) */",
+		"/*!50100 PARTITION BY HASH (`id`\nPARTITIONS 4 */",
+		"/*!50100 PARTITION BY HASH (`id`)\nPARTITIONS four */",
+		"/*!50100 PARTITION BY RANGE (`id`)\n(PARTITION p0 VALUES FOO (100)) */",
+		"/*!50100 PARTITION BY LIST (`id`)\n(PARTITION p0 VALUES IN (1) COMMENT = 'oops) */",
+	}
+	for n, clause := range badClauses {
+		if actual, err := ParsePartitioning(base + "\n" + clause); err == nil {
+			t.Errorf("badClauses[%d]: Expected error, instead found %+v", n, actual)
+		}
+	}
+}
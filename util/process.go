@@ -0,0 +1,146 @@
+package util
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+// ProcessInfo describes a connection to a database server, as shown in the
+// server's process list.
+type ProcessInfo struct {
+	ID      int64
+	User    string
+	Host    string
+	DB      string
+	Command string
+	Time    int64 // seconds in the current state
+	State   string
+	Info    string // currently running statement, if any
+	Waiting bool   // true if waiting to acquire a lock, rather than holding it
+}
+
+func (p ProcessInfo) String() string {
+	verb := "held by"
+	if p.Waiting {
+		verb = "waited on by"
+	}
+	desc := fmt.Sprintf("%s connection %d (%s@%s, %s for %ds", verb, p.ID, p.User, p.Host, p.Command, p.Time)
+	if p.State != "" {
+		desc += ", state " + p.State
+	}
+	return desc + ")"
+}
+
+// ErrLockWaitersUnavailable is returned by LockHolders, along with partial
+// results, if connections waiting on a lock could not be determined. This
+// typically occurs if performance_schema is disabled, or its metadata lock
+// instrumentation is not enabled.
+var ErrLockWaitersUnavailable = errors.New("Unable to determine connections waiting on lock, since performance_schema metadata lock instrumentation is unavailable")
+
+// LockHolders returns information about the connection holding the named lock
+// on inst, obtained via GET_LOCK(), followed by any connections waiting to
+// acquire it. The holding connection is determined using IS_USED_LOCK(), which
+// is available in all flavors. Waiting connections can only be found using
+// performance_schema.metadata_locks; if this is unavailable, the holder is
+// still returned, along with ErrLockWaitersUnavailable. If the lock is not
+// held, an empty slice is returned.
+func LockHolders(inst *tengo.Instance, lockName string) ([]ProcessInfo, error) {
+	db, err := inst.Connect("", "")
+	if err != nil {
+		return nil, err
+	}
+	var holderID sql.NullInt64
+	if err := db.QueryRow("SELECT IS_USED_LOCK(?)", lockName).Scan(&holderID); err != nil {
+		return nil, err
+	}
+	var result []ProcessInfo
+	if holderID.Valid {
+		holders, err := processList(inst, []int64{holderID.Int64}, false)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, holders...)
+	}
+
+	var waiterIDs []int64
+	query := `
+		SELECT t.processlist_id
+		FROM   performance_schema.metadata_locks ml
+		JOIN   performance_schema.threads t ON t.thread_id = ml.owner_thread_id
+		WHERE  ml.object_type = 'USER LEVEL LOCK' AND ml.object_name = ?
+		       AND ml.lock_status = 'PENDING'`
+	if err := db.Select(&waiterIDs, query, lockName); err != nil {
+		return result, ErrLockWaitersUnavailable
+	}
+	waiters, err := processList(inst, waiterIDs, true)
+	if err != nil {
+		return result, ErrLockWaitersUnavailable
+	}
+	return append(result, waiters...), nil
+}
+
+// processList returns process list information for the supplied connection
+// IDs on inst. Connections which no longer exist are omitted.
+func processList(inst *tengo.Instance, ids []int64, waiting bool) ([]ProcessInfo, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	db, err := inst.Connect("information_schema", "")
+	if err != nil {
+		return nil, err
+	}
+	var raw []struct {
+		ID      int64          `db:"id"`
+		User    string         `db:"user"`
+		Host    string         `db:"host"`
+		DB      sql.NullString `db:"db"`
+		Command string         `db:"command"`
+		Time    int64          `db:"time"`
+		State   sql.NullString `db:"state"`
+		Info    sql.NullString `db:"info"`
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	args := make([]interface{}, len(ids))
+	for n, id := range ids {
+		args[n] = id
+	}
+	query := fmt.Sprintf(`
+		SELECT   id AS id, user AS user, host AS host, db AS db, command AS command,
+		         time AS time, state AS state, info AS info
+		FROM     processlist
+		WHERE    id IN (%s)
+		ORDER BY id`, placeholders)
+	if err := db.Select(&raw, query, args...); err != nil {
+		return nil, err
+	}
+	result := make([]ProcessInfo, len(raw))
+	for n, r := range raw {
+		result[n] = ProcessInfo{
+			ID:      r.ID,
+			User:    r.User,
+			Host:    r.Host,
+			DB:      r.DB.String,
+			Command: r.Command,
+			Time:    r.Time,
+			State:   r.State.String,
+			Info:    r.Info.String,
+			Waiting: waiting,
+		}
+	}
+	return result, nil
+}
+
+// DescribeLockHolders returns a human-readable summary of the result of
+// LockHolders, suitable for inclusion in an error message. An empty string is
+// returned if no information is available.
+func DescribeLockHolders(processes []ProcessInfo) string {
+	descs := make([]string, len(processes))
+	for n, p := range processes {
+		descs[n] = p.String()
+	}
+	return strings.Join(descs, "; ")
+}
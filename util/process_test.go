@@ -0,0 +1,19 @@
+package util
+
+import (
+	"testing"
+)
+
+func TestDescribeLockHolders(t *testing.T) {
+	if desc := DescribeLockHolders(nil); desc != "" {
+		t.Errorf("Expected empty description for no processes, instead found %q", desc)
+	}
+	processes := []ProcessInfo{
+		{ID: 12, User: "deploy", Host: "10.0.0.5:51234", Command: "Sleep", Time: 340},
+		{ID: 15, User: "ci", Host: "10.0.0.9:40100", Command: "Query", Time: 3, State: "User lock", Info: "SELECT GET_LOCK('skeema.tmp', 30)", Waiting: true},
+	}
+	expected := "held by connection 12 (deploy@10.0.0.5:51234, Sleep for 340s); waited on by connection 15 (ci@10.0.0.9:40100, Query for 3s, state User lock)"
+	if desc := DescribeLockHolders(processes); desc != expected {
+		t.Errorf("Unexpected description:\n  expected %q\n  found    %q", expected, desc)
+	}
+}
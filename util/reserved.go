@@ -0,0 +1,122 @@
+package util
+
+import (
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+// commonReservedWords contains uppercase words which are reserved in all
+// supported flavors.
+var commonReservedWords = makeWordSet(`
+	ACCESSIBLE ADD ALL ALTER ANALYZE AND AS ASC ASENSITIVE BEFORE BETWEEN BIGINT
+	BINARY BLOB BOTH BY CALL CASCADE CASE CHANGE CHAR CHARACTER CHECK COLLATE
+	COLUMN CONDITION CONSTRAINT CONTINUE CONVERT CREATE CROSS CURRENT_DATE
+	CURRENT_TIME CURRENT_TIMESTAMP CURRENT_USER CURSOR DATABASE DATABASES
+	DAY_HOUR DAY_MICROSECOND DAY_MINUTE DAY_SECOND DEC DECIMAL DECLARE DEFAULT
+	DELAYED DELETE DESC DESCRIBE DETERMINISTIC DISTINCT DISTINCTROW DIV DOUBLE
+	DROP DUAL EACH ELSE ELSEIF ENCLOSED ESCAPED EXISTS EXIT EXPLAIN FALSE FETCH
+	FLOAT FLOAT4 FLOAT8 FOR FORCE FOREIGN FROM FULLTEXT GRANT GROUP HAVING
+	HIGH_PRIORITY HOUR_MICROSECOND HOUR_MINUTE HOUR_SECOND IF IGNORE IN INDEX
+	INFILE INNER INOUT INSENSITIVE INSERT INT INT1 INT2 INT3 INT4 INT8 INTEGER
+	INTERVAL INTO IS ITERATE JOIN KEY KEYS KILL LEADING LEAVE LEFT LIKE LIMIT
+	LINEAR LINES LOAD LOCALTIME LOCALTIMESTAMP LOCK LONG LONGBLOB LONGTEXT LOOP
+	LOW_PRIORITY MATCH MAXVALUE MEDIUMBLOB MEDIUMINT MEDIUMTEXT MIDDLEINT
+	MINUTE_MICROSECOND MINUTE_SECOND MOD MODIFIES NATURAL NOT NO_WRITE_TO_BINLOG
+	NULL NUMERIC ON OPTIMIZE OPTION OPTIONALLY OR ORDER OUT OUTER OUTFILE
+	PRECISION PRIMARY PROCEDURE PURGE RANGE READ READS READ_WRITE REAL REFERENCES
+	REGEXP RELEASE RENAME REPEAT REPLACE REQUIRE RESIGNAL RESTRICT RETURN REVOKE
+	RIGHT RLIKE SCHEMA SCHEMAS SECOND_MICROSECOND SELECT SENSITIVE SEPARATOR SET
+	SHOW SIGNAL SMALLINT SPATIAL SPECIFIC SQL SQLEXCEPTION SQLSTATE SQLWARNING
+	SQL_BIG_RESULT SQL_CALC_FOUND_ROWS SQL_SMALL_RESULT SSL STARTING
+	STRAIGHT_JOIN TABLE TERMINATED THEN TINYBLOB TINYINT TINYTEXT TO TRAILING
+	TRIGGER TRUE UNDO UNION UNIQUE UNLOCK UNSIGNED UPDATE USAGE USE USING
+	UTC_DATE UTC_TIME UTC_TIMESTAMP VALUES VARBINARY VARCHAR VARCHARACTER VARYING
+	WHEN WHERE WHILE WITH WRITE XOR YEAR_MONTH ZEROFILL
+`)
+
+func makeWordSet(words string) map[string]bool {
+	result := make(map[string]bool)
+	for _, word := range strings.Fields(words) {
+		result[word] = true
+	}
+	return result
+}
+
+func mysqlMinVersion(major, minor int) func(tengo.Flavor) bool {
+	return func(fl tengo.Flavor) bool {
+		return FlavorAtLeast(fl, tengo.VendorMySQL, major, minor)
+	}
+}
+
+func mariaMinVersion(major, minor int) func(tengo.Flavor) bool {
+	return func(fl tengo.Flavor) bool {
+		return FlavorAtLeast(fl, tengo.VendorMariaDB, major, minor)
+	}
+}
+
+// versionedReservedWords maps uppercase words, which are only reserved in some
+// flavors, to a function indicating whether a given flavor reserves the word.
+var versionedReservedWords = map[string]func(tengo.Flavor) bool{
+	// Added in MySQL 5.6; PARTITION is also reserved in MariaDB
+	"GET":             mysqlMinVersion(5, 6),
+	"IO_AFTER_GTIDS":  mysqlMinVersion(5, 6),
+	"IO_BEFORE_GTIDS": mysqlMinVersion(5, 6),
+	"MASTER_BIND":     mysqlMinVersion(5, 6),
+	"PARTITION":       func(fl tengo.Flavor) bool { return mysqlMinVersion(5, 6)(fl) || fl.Vendor == tengo.VendorMariaDB },
+
+	// Added in MySQL 5.7
+	"GENERATED":       mysqlMinVersion(5, 7),
+	"OPTIMIZER_COSTS": mysqlMinVersion(5, 7),
+	"STORED":          mysqlMinVersion(5, 7),
+	"VIRTUAL":         mysqlMinVersion(5, 7),
+
+	// Added in MySQL 8.0; some are also reserved in newer MariaDB
+	"CUME_DIST":    mysqlMinVersion(8, 0),
+	"DENSE_RANK":   mysqlMinVersion(8, 0),
+	"EMPTY":        mysqlMinVersion(8, 0),
+	"EXCEPT":       func(fl tengo.Flavor) bool { return mysqlMinVersion(8, 0)(fl) || mariaMinVersion(10, 3)(fl) },
+	"FIRST_VALUE":  mysqlMinVersion(8, 0),
+	"FUNCTION":     mysqlMinVersion(8, 0),
+	"GROUPING":     mysqlMinVersion(8, 0),
+	"GROUPS":       mysqlMinVersion(8, 0),
+	"JSON_TABLE":   mysqlMinVersion(8, 0),
+	"LAG":          mysqlMinVersion(8, 0),
+	"LAST_VALUE":   mysqlMinVersion(8, 0),
+	"LATERAL":      mysqlMinVersion(8, 0),
+	"LEAD":         mysqlMinVersion(8, 0),
+	"NTH_VALUE":    mysqlMinVersion(8, 0),
+	"NTILE":        mysqlMinVersion(8, 0),
+	"OF":           mysqlMinVersion(8, 0),
+	"OVER":         func(fl tengo.Flavor) bool { return mysqlMinVersion(8, 0)(fl) || mariaMinVersion(10, 2)(fl) },
+	"PERCENT_RANK": mysqlMinVersion(8, 0),
+	"RANK":         mysqlMinVersion(8, 0),
+	"RECURSIVE":    func(fl tengo.Flavor) bool { return mysqlMinVersion(8, 0)(fl) || mariaMinVersion(10, 2)(fl) },
+	"ROW":          mysqlMinVersion(8, 0),
+	"ROWS":         func(fl tengo.Flavor) bool { return mysqlMinVersion(8, 0)(fl) || mariaMinVersion(10, 2)(fl) },
+	"ROW_NUMBER":   mysqlMinVersion(8, 0),
+	"SYSTEM":       mysqlMinVersion(8, 0),
+	"WINDOW":       mysqlMinVersion(8, 0),
+
+	// MariaDB-specific
+	"DELETE_DOMAIN_ID": mariaMinVersion(10, 0),
+	"INTERSECT":        mariaMinVersion(10, 3),
+	"RETURNING":        mariaMinVersion(10, 0),
+}
+
+// IsReservedWord returns true if word is a reserved word in flavor, meaning it
+// cannot be used as an unquoted identifier. The word is matched
+// case-insensitively. If the flavor's vendor is unknown, this returns true if
+// the word is reserved in any supported flavor, since an identifier using it
+// may break on some servers.
+func IsReservedWord(word string, flavor tengo.Flavor) bool {
+	word = strings.ToUpper(word)
+	if commonReservedWords[word] {
+		return true
+	}
+	reserved, ok := versionedReservedWords[word]
+	if !ok {
+		return false
+	}
+	return flavor.Vendor == tengo.VendorUnknown || reserved(flavor)
+}
@@ -0,0 +1,40 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+func TestIsReservedWord(t *testing.T) {
+	cases := []struct {
+		word     string
+		flavor   tengo.Flavor
+		expected bool
+	}{
+		{"select", tengo.FlavorMySQL55, true},
+		{"Order", tengo.FlavorMariaDB101, true},
+		{"name", tengo.FlavorMySQL80, false},
+		{"status", tengo.FlavorUnknown, false},
+		{"rank", tengo.FlavorMySQL57, false},
+		{"rank", tengo.FlavorMySQL80, true},
+		{"RANK", tengo.FlavorPercona80, true},
+		{"groups", tengo.FlavorMariaDB103, false},
+		{"groups", tengo.FlavorUnknown, true},
+		{"virtual", tengo.FlavorMySQL56, false},
+		{"virtual", tengo.FlavorPercona57, true},
+		{"partition", tengo.FlavorMySQL55, false},
+		{"partition", tengo.FlavorMariaDB101, true},
+		{"rows", tengo.FlavorMariaDB101, false},
+		{"rows", tengo.FlavorMariaDB102, true},
+		{"except", tengo.FlavorMariaDB102, false},
+		{"except", tengo.FlavorMariaDB103, true},
+		{"returning", tengo.FlavorMySQL80, false},
+		{"returning", tengo.FlavorMariaDB101, true},
+	}
+	for _, c := range cases {
+		if actual := IsReservedWord(c.word, c.flavor); actual != c.expected {
+			t.Errorf("Expected IsReservedWord(%q, %s) to return %t, instead found %t", c.word, c.flavor, c.expected, actual)
+		}
+	}
+}
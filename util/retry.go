@@ -0,0 +1,129 @@
+package util
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	log "github.com/sirupsen/logrus"
+	"github.com/skeema/mybase"
+	"github.com/skeema/tengo"
+)
+
+// maxRetryDelay caps the delay between connection attempts, regardless of how
+// many attempts have been made.
+const maxRetryDelay = 30 * time.Second
+
+// RetryPolicy controls how ConnectWithRetry handles transient connection
+// errors. The zero value makes a single attempt, without retrying.
+type RetryPolicy struct {
+	Retries      int           // max additional attempts after the first
+	InitialDelay time.Duration // delay before the first retry; doubles with each subsequent retry
+	MaxDelay     time.Duration // if 0, maxRetryDelay is used
+}
+
+// RetryPolicyForConfig returns a RetryPolicy based on the "connect-retries"
+// and "connect-retry-delay" options in cfg, which must have been defined via
+// AddGlobalOptions.
+func RetryPolicyForConfig(cfg *mybase.Config) (RetryPolicy, error) {
+	retries, err := cfg.GetInt("connect-retries")
+	if err != nil {
+		return RetryPolicy{}, err
+	} else if retries < 0 {
+		return RetryPolicy{}, fmt.Errorf("Option connect-retries must not be negative; found %d", retries)
+	}
+	delay, err := time.ParseDuration(cfg.Get("connect-retry-delay"))
+	if err != nil {
+		return RetryPolicy{}, fmt.Errorf("Option connect-retry-delay must be a duration such as \"500ms\" or \"2s\"; found %q", cfg.Get("connect-retry-delay"))
+	} else if delay <= 0 {
+		return RetryPolicy{}, fmt.Errorf("Option connect-retry-delay must be positive; found %s", delay)
+	}
+	return RetryPolicy{Retries: retries, InitialDelay: delay}, nil
+}
+
+// jitterRand is the source of randomness for Delay. It is seeded per process,
+// unlike the global math/rand source, so that concurrent processes do not all
+// compute the same delays. Its mutex must be held while using it.
+var jitterRand = struct {
+	sync.Mutex
+	*rand.Rand
+}{Rand: rand.New(rand.NewSource(time.Now().UnixNano() ^ int64(os.Getpid())))}
+
+// Delay returns the amount of time to wait before the supplied retry number,
+// starting at 1. The delay grows exponentially, up to the policy's max delay,
+// and includes up to 50% random jitter so that concurrent processes do not
+// retry in lockstep.
+func (policy RetryPolicy) Delay(retry int) time.Duration {
+	maxDelay := policy.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = maxRetryDelay
+	}
+	delay := policy.InitialDelay
+	for n := 1; n < retry && delay < maxDelay; n++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	jitterRand.Lock()
+	defer jitterRand.Unlock()
+	return delay/2 + time.Duration(jitterRand.Int63n(int64(delay/2)+1))
+}
+
+// retrySleep is used by ConnectWithRetry to wait between attempts. It may be
+// overridden in tests.
+var retrySleep = time.Sleep
+
+// ConnectWithRetry behaves like inst.Connect, but retries according to policy
+// if a transient error occurs, as determined by IsTransientConnectError. Other
+// errors, such as access denied, are returned immediately.
+func ConnectWithRetry(inst *tengo.Instance, defaultSchema, params string, policy RetryPolicy) (*sqlx.DB, error) {
+	db, err := inst.Connect(defaultSchema, params)
+	for retry := 1; err != nil && retry <= policy.Retries && IsTransientConnectError(err); retry++ {
+		delay := policy.Delay(retry)
+		log.Debugf("%s: Connection attempt failed (%s); retry %d of %d in %s", inst, err, retry, policy.Retries, delay)
+		retrySleep(delay)
+		db, err = inst.Connect(defaultSchema, params)
+	}
+	return db, err
+}
+
+// IsTransientConnectError returns true if err, obtained from attempting to
+// connect to a database server, may succeed upon retry. This includes network
+// errors, as well as server errors indicating the server is overloaded or
+// shutting down. Authentication errors and other server errors are considered
+// fatal.
+func IsTransientConnectError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case 1040, // ER_CON_COUNT_ERROR: too many connections
+			1053, // ER_SERVER_SHUTDOWN: shutdown in progress
+			1203: // ER_TOO_MANY_USER_CONNECTIONS
+			return true
+		}
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
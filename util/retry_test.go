@@ -0,0 +1,99 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/skeema/mybase"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{Retries: 10, InitialDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	cases := []struct {
+		retry int
+		max   time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{10, time.Second},
+	}
+	for _, c := range cases {
+		for n := 0; n < 20; n++ {
+			if delay := policy.Delay(c.retry); delay < c.max/2 || delay > c.max {
+				t.Errorf("Expected Delay(%d) to be between %s and %s; instead found %s", c.retry, c.max/2, c.max, delay)
+			}
+		}
+	}
+
+	if delay := (RetryPolicy{}).Delay(1); delay != 0 {
+		t.Errorf("Expected zero-value policy to have no delay; instead found %s", delay)
+	}
+	policy.MaxDelay = 0
+	if delay := policy.Delay(100); delay > maxRetryDelay {
+		t.Errorf("Expected delay to be capped at %s; instead found %s", maxRetryDelay, delay)
+	}
+}
+
+func TestIsTransientConnectError(t *testing.T) {
+	transient := []error{
+		&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED},
+		fmt.Errorf("wrapped: %w", syscall.ECONNRESET),
+		mysql.ErrInvalidConn,
+		io.EOF,
+		&mysql.MySQLError{Number: 1040, Message: "Too many connections"},
+		&mysql.MySQLError{Number: 1053, Message: "Server shutdown in progress"},
+	}
+	for _, err := range transient {
+		if !IsTransientConnectError(err) {
+			t.Errorf("Expected error %v to be considered transient, but it was not", err)
+		}
+	}
+	fatal := []error{
+		nil,
+		errors.New("some other problem"),
+		&mysql.MySQLError{Number: 1045, Message: "Access denied for user"},
+		&mysql.MySQLError{Number: 1044, Message: "Access denied for user to database"},
+		&mysql.MySQLError{Number: 1049, Message: "Unknown database"},
+	}
+	for _, err := range fatal {
+		if IsTransientConnectError(err) {
+			t.Errorf("Expected error %v to be considered fatal, but it was not", err)
+		}
+	}
+}
+
+func TestRetryPolicyForConfig(t *testing.T) {
+	cmdSuite := mybase.NewCommandSuite("skeematest", "", "")
+	AddGlobalOptions(cmdSuite)
+	cmdSuite.AddSubCommand(mybase.NewCommand("diff", "", "", nil))
+
+	cfg := mybase.ParseFakeCLI(t, cmdSuite, "skeema diff")
+	if policy, err := RetryPolicyForConfig(cfg); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if expected := (RetryPolicy{Retries: 0, InitialDelay: 500 * time.Millisecond}); policy != expected {
+		t.Errorf("Expected default policy %+v; instead found %+v", expected, policy)
+	}
+
+	cfg = mybase.ParseFakeCLI(t, cmdSuite, "skeema diff --connect-retries=3 --connect-retry-delay=2s")
+	if policy, err := RetryPolicyForConfig(cfg); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if expected := (RetryPolicy{Retries: 3, InitialDelay: 2 * time.Second}); policy != expected {
+		t.Errorf("Expected policy %+v; instead found %+v", expected, policy)
+	}
+
+	for _, args := range []string{"--connect-retries=-1", "--connect-retries=lots", "--connect-retry-delay=5", "--connect-retry-delay=0s"} {
+		cfg = mybase.ParseFakeCLI(t, cmdSuite, "skeema diff "+args)
+		if _, err := RetryPolicyForConfig(cfg); err == nil {
+			t.Errorf("Expected error from %s, but err was nil", args)
+		}
+	}
+}
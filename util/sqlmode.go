@@ -0,0 +1,105 @@
+package util
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+// FilterSQLMode splits orig, a comma-separated sql_mode value, and removes
+// any modes that are keys of remove with a true value. The keys of remove must
+// be uppercase, but modes in orig are matched case-insensitively and without
+// regard to surrounding whitespace. Kept modes retain their original casing,
+// and are rejoined with commas. Empty modes are discarded.
+func FilterSQLMode(orig string, remove map[string]bool) string {
+	var kept []string
+	for _, mode := range strings.Split(orig, ",") {
+		mode = strings.TrimSpace(mode)
+		if mode != "" && !remove[strings.ToUpper(mode)] {
+			kept = append(kept, mode)
+		}
+	}
+	return strings.Join(kept, ",")
+}
+
+// FilterSQLModeNormalized behaves like FilterSQLMode, but the returned modes
+// are uppercased, de-duplicated, and sorted. This is useful for comparing
+// sql_mode values from different sources, which may vary in formatting.
+func FilterSQLModeNormalized(orig string, remove map[string]bool) string {
+	seen := make(map[string]bool)
+	var kept []string
+	for _, mode := range strings.Split(FilterSQLMode(orig, remove), ",") {
+		mode = strings.ToUpper(mode)
+		if mode != "" && !seen[mode] {
+			seen[mode] = true
+			kept = append(kept, mode)
+		}
+	}
+	sort.Strings(kept)
+	return strings.Join(kept, ",")
+}
+
+func isMySQLOrPercona(flavor tengo.Flavor) bool {
+	return flavor.Vendor == tengo.VendorMySQL || flavor.Vendor == tengo.VendorPercona
+}
+
+// nonPortableSQLModes maps uppercase sql_mode values, which are not available
+// in all flavors, to a function indicating whether a given flavor supports the
+// mode. Modes not present in this map are available in all flavors.
+var nonPortableSQLModes = map[string]func(tengo.Flavor) bool{
+	// Removed in MySQL 8.0, but still present in MariaDB
+	"NO_AUTO_CREATE_USER": func(fl tengo.Flavor) bool { return !isMySQLOrPercona(fl) || fl.Major < 8 },
+	"DB2":                 func(fl tengo.Flavor) bool { return !isMySQLOrPercona(fl) || fl.Major < 8 },
+	"MAXDB":               func(fl tengo.Flavor) bool { return !isMySQLOrPercona(fl) || fl.Major < 8 },
+	"MSSQL":               func(fl tengo.Flavor) bool { return !isMySQLOrPercona(fl) || fl.Major < 8 },
+	"MYSQL323":            func(fl tengo.Flavor) bool { return !isMySQLOrPercona(fl) || fl.Major < 8 },
+	"MYSQL40":             func(fl tengo.Flavor) bool { return !isMySQLOrPercona(fl) || fl.Major < 8 },
+	"ORACLE":              func(fl tengo.Flavor) bool { return !isMySQLOrPercona(fl) || fl.Major < 8 },
+	"POSTGRESQL":          func(fl tengo.Flavor) bool { return !isMySQLOrPercona(fl) || fl.Major < 8 },
+	"NO_FIELD_OPTIONS":    func(fl tengo.Flavor) bool { return !isMySQLOrPercona(fl) || fl.Major < 8 },
+	"NO_KEY_OPTIONS":      func(fl tengo.Flavor) bool { return !isMySQLOrPercona(fl) || fl.Major < 8 },
+	"NO_TABLE_OPTIONS":    func(fl tengo.Flavor) bool { return !isMySQLOrPercona(fl) || fl.Major < 8 },
+
+	// Added in MySQL 8.0
+	"TIME_TRUNCATE_FRACTIONAL": func(fl tengo.Flavor) bool { return isMySQLOrPercona(fl) && fl.Major >= 8 },
+
+	// MariaDB-specific
+	"IGNORE_BAD_TABLE_OPTIONS": func(fl tengo.Flavor) bool { return fl.Vendor == tengo.VendorMariaDB },
+	"EMPTY_STRING_IS_NULL":     func(fl tengo.Flavor) bool { return fl.VendorMinVersion(tengo.VendorMariaDB, 10, 3) },
+	"SIMULTANEOUS_ASSIGNMENT":  func(fl tengo.Flavor) bool { return fl.VendorMinVersion(tengo.VendorMariaDB, 10, 3) },
+	"TIME_ROUND_FRACTIONAL":    func(fl tengo.Flavor) bool { return fl.VendorMinVersion(tengo.VendorMariaDB, 10, 4) },
+}
+
+// FlavorSupportsSQLMode returns true if the supplied sql_mode value can be
+// used with flavor. The mode is matched case-insensitively. If the flavor's
+// vendor is unknown, this always returns true, since there is no basis for
+// rejecting the mode.
+func FlavorSupportsSQLMode(flavor tengo.Flavor, mode string) bool {
+	if flavor.Vendor == tengo.VendorUnknown {
+		return true
+	}
+	if supported, ok := nonPortableSQLModes[strings.ToUpper(strings.TrimSpace(mode))]; ok {
+		return supported(flavor)
+	}
+	return true
+}
+
+// PortableSQLModes splits modes, a comma-separated sql_mode value, into the
+// modes that may be kept when moving from flavor from to flavor to, and the
+// modes which must be dropped since to does not support them. Modes which from
+// does not support are also dropped, since they cannot have been in effect.
+// Modes retain their original casing and order; empty modes are discarded.
+func PortableSQLModes(modes string, from, to tengo.Flavor) (kept, dropped []string) {
+	for _, mode := range strings.Split(modes, ",") {
+		mode = strings.TrimSpace(mode)
+		if mode == "" {
+			continue
+		} else if FlavorSupportsSQLMode(from, mode) && FlavorSupportsSQLMode(to, mode) {
+			kept = append(kept, mode)
+		} else {
+			dropped = append(dropped, mode)
+		}
+	}
+	return kept, dropped
+}
@@ -0,0 +1,91 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+func TestFilterSQLMode(t *testing.T) {
+	remove := map[string]bool{
+		"STRICT_TRANS_TABLES": true,
+		"STRICT_ALL_TABLES":   true,
+		"NO_AUTO_CREATE_USER": true,
+	}
+	cases := []struct {
+		orig, expected, expectedNormalized string
+	}{
+		{"", "", ""},
+		{"STRICT_TRANS_TABLES", "", ""},
+		{"ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE", "ONLY_FULL_GROUP_BY,NO_ZERO_IN_DATE", "NO_ZERO_IN_DATE,ONLY_FULL_GROUP_BY"},
+		{"strict_trans_tables,no_engine_substitution", "no_engine_substitution", "NO_ENGINE_SUBSTITUTION"},
+		{" Strict_All_Tables , Error_For_Division_By_Zero ,NO_ENGINE_SUBSTITUTION", "Error_For_Division_By_Zero,NO_ENGINE_SUBSTITUTION", "ERROR_FOR_DIVISION_BY_ZERO,NO_ENGINE_SUBSTITUTION"},
+		{"NO_ZERO_DATE,,no_zero_date, NO_ZERO_DATE", "NO_ZERO_DATE,no_zero_date,NO_ZERO_DATE", "NO_ZERO_DATE"},
+		// MySQL 5.7 default
+		{"ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION",
+			"ONLY_FULL_GROUP_BY,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_ENGINE_SUBSTITUTION",
+			"ERROR_FOR_DIVISION_BY_ZERO,NO_ENGINE_SUBSTITUTION,NO_ZERO_DATE,NO_ZERO_IN_DATE,ONLY_FULL_GROUP_BY"},
+		// MariaDB 10.3 default
+		{"STRICT_TRANS_TABLES,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION",
+			"ERROR_FOR_DIVISION_BY_ZERO,NO_ENGINE_SUBSTITUTION",
+			"ERROR_FOR_DIVISION_BY_ZERO,NO_ENGINE_SUBSTITUTION"},
+	}
+	for _, c := range cases {
+		if actual := FilterSQLMode(c.orig, remove); actual != c.expected {
+			t.Errorf("Expected FilterSQLMode(%q) to return %q, instead found %q", c.orig, c.expected, actual)
+		}
+		if actual := FilterSQLModeNormalized(c.orig, remove); actual != c.expectedNormalized {
+			t.Errorf("Expected FilterSQLModeNormalized(%q) to return %q, instead found %q", c.orig, c.expectedNormalized, actual)
+		}
+	}
+}
+
+func TestFlavorSupportsSQLMode(t *testing.T) {
+	cases := []struct {
+		flavor   tengo.Flavor
+		mode     string
+		expected bool
+	}{
+		{tengo.FlavorMySQL57, "STRICT_TRANS_TABLES", true},
+		{tengo.FlavorMySQL80, "strict_trans_tables", true},
+		{tengo.FlavorMySQL57, "NO_AUTO_CREATE_USER", true},
+		{tengo.FlavorMySQL80, "no_auto_create_user", false},
+		{tengo.FlavorPercona80, "NO_AUTO_CREATE_USER", false},
+		{tengo.FlavorMariaDB103, "NO_AUTO_CREATE_USER", true},
+		{tengo.FlavorMySQL57, "TIME_TRUNCATE_FRACTIONAL", false},
+		{tengo.FlavorMySQL80, "TIME_TRUNCATE_FRACTIONAL", true},
+		{tengo.FlavorMariaDB103, "TIME_TRUNCATE_FRACTIONAL", false},
+		{tengo.FlavorMariaDB102, "EMPTY_STRING_IS_NULL", false},
+		{tengo.FlavorMariaDB103, "EMPTY_STRING_IS_NULL", true},
+		{tengo.FlavorMySQL80, "EMPTY_STRING_IS_NULL", false},
+		{tengo.FlavorUnknown, "EMPTY_STRING_IS_NULL", true},
+	}
+	for _, c := range cases {
+		if actual := FlavorSupportsSQLMode(c.flavor, c.mode); actual != c.expected {
+			t.Errorf("Expected FlavorSupportsSQLMode(%s, %q) to return %t, instead found %t", c.flavor, c.mode, c.expected, actual)
+		}
+	}
+}
+
+func TestPortableSQLModes(t *testing.T) {
+	mysql57Default := "ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION"
+	cases := []struct {
+		modes           string
+		from, to        tengo.Flavor
+		expectedKept    []string
+		expectedDropped []string
+	}{
+		{"", tengo.FlavorMySQL57, tengo.FlavorMySQL80, nil, nil},
+		{mysql57Default, tengo.FlavorMySQL57, tengo.FlavorMySQL57, []string{"ONLY_FULL_GROUP_BY", "STRICT_TRANS_TABLES", "NO_ZERO_IN_DATE", "NO_ZERO_DATE", "ERROR_FOR_DIVISION_BY_ZERO", "NO_AUTO_CREATE_USER", "NO_ENGINE_SUBSTITUTION"}, nil},
+		{mysql57Default, tengo.FlavorMySQL57, tengo.FlavorMySQL80, []string{"ONLY_FULL_GROUP_BY", "STRICT_TRANS_TABLES", "NO_ZERO_IN_DATE", "NO_ZERO_DATE", "ERROR_FOR_DIVISION_BY_ZERO", "NO_ENGINE_SUBSTITUTION"}, []string{"NO_AUTO_CREATE_USER"}},
+		{"strict_all_tables, time_truncate_fractional", tengo.FlavorMySQL80, tengo.FlavorMariaDB103, []string{"strict_all_tables"}, []string{"time_truncate_fractional"}},
+		{"EMPTY_STRING_IS_NULL,ANSI", tengo.FlavorMariaDB102, tengo.FlavorMariaDB103, []string{"ANSI"}, []string{"EMPTY_STRING_IS_NULL"}},
+	}
+	for _, c := range cases {
+		kept, dropped := PortableSQLModes(c.modes, c.from, c.to)
+		if !reflect.DeepEqual(kept, c.expectedKept) || !reflect.DeepEqual(dropped, c.expectedDropped) {
+			t.Errorf("Unexpected result from PortableSQLModes(%q, %s, %s): kept=%v dropped=%v", c.modes, c.from, c.to, kept, dropped)
+		}
+	}
+}
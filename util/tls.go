@@ -0,0 +1,177 @@
+package util
+
+import (
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/skeema/mybase"
+)
+
+// TLSOptions describes how to use TLS when connecting to a database server,
+// using the same semantics as the ssl-* options of the standard MySQL client.
+type TLSOptions struct {
+	Mode string // one of "disabled", "required", "verify_ca", "verify_identity", or "" to infer from other fields
+	CA   string // path to PEM file of trusted certificate authorities
+	Cert string // path to PEM file of client certificate
+	Key  string // path to PEM file of client private key
+}
+
+// TLSOptionsForConfig returns TLSOptions based on the "ssl-mode", "ssl-ca",
+// "ssl-cert", and "ssl-key" options in cfg, which must have been defined via
+// AddGlobalOptions. An error is returned if the options are inconsistent, but
+// the files are not examined; see TLSOptions.DSNParam.
+func TLSOptionsForConfig(cfg *mybase.Config) (TLSOptions, error) {
+	opts := TLSOptions{
+		Mode: strings.ToLower(strings.Replace(cfg.Get("ssl-mode"), "-", "_", -1)),
+		CA:   cfg.Get("ssl-ca"),
+		Cert: cfg.Get("ssl-cert"),
+		Key:  cfg.Get("ssl-key"),
+	}
+	switch opts.Mode {
+	case "", "disabled", "required", "verify_ca", "verify_identity":
+	default:
+		return TLSOptions{}, fmt.Errorf("Option ssl-mode has invalid value %q; valid values are \"DISABLED\", \"REQUIRED\", \"VERIFY_CA\", \"VERIFY_IDENTITY\"", cfg.Get("ssl-mode"))
+	}
+	if (opts.Cert == "") != (opts.Key == "") {
+		return TLSOptions{}, errors.New("Options ssl-cert and ssl-key must be supplied together")
+	}
+	if opts.Mode == "verify_ca" && opts.CA == "" {
+		return TLSOptions{}, errors.New("Option ssl-mode=VERIFY_CA requires ssl-ca to also be supplied")
+	}
+	return opts, nil
+}
+
+// effectiveMode returns the TLS mode, taking into account other fields. As
+// with the standard MySQL client, supplying a CA without a mode implies
+// verify_ca, as does supplying a CA with mode required; supplying only a
+// client certificate implies required.
+func (opts TLSOptions) effectiveMode() string {
+	if opts.CA != "" && (opts.Mode == "" || opts.Mode == "required") {
+		return "verify_ca"
+	} else if opts.Mode == "" && opts.Cert != "" {
+		return "required"
+	}
+	return opts.Mode
+}
+
+// DSNParam returns the value to use for the go-sql-driver/mysql "tls" DSN
+// param. If a custom TLS configuration is needed, it is built and registered
+// with the driver under a name derived from opts, so that repeated calls with
+// the same options reuse the same name. An empty string is returned if no TLS
+// options were supplied at all. An error is returned if any file cannot be
+// read, or if the client certificate and key do not form a valid pair.
+func (opts TLSOptions) DSNParam() (string, error) {
+	mode := opts.effectiveMode()
+	switch {
+	case mode == "":
+		return "", nil
+	case mode == "disabled":
+		return "false", nil
+	case mode == "required" && opts.Cert == "":
+		return "skip-verify", nil
+	case mode == "verify_identity" && opts.CA == "" && opts.Cert == "":
+		return "true", nil
+	}
+
+	config, err := opts.tlsConfig(mode)
+	if err != nil {
+		return "", err
+	}
+	hash := sha1.Sum([]byte(strings.Join([]string{mode, opts.CA, opts.Cert, opts.Key}, "\x00")))
+	name := "skeema-" + hex.EncodeToString(hash[:8])
+	if err := mysql.RegisterTLSConfig(name, config); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// tlsConfig builds a *tls.Config for the supplied effective mode, which must
+// be one of "required", "verify_ca", or "verify_identity".
+func (opts TLSOptions) tlsConfig(mode string) (*tls.Config, error) {
+	config := &tls.Config{}
+	if opts.Cert != "" {
+		if err := checkFileExists("ssl-cert", opts.Cert); err != nil {
+			return nil, err
+		} else if err := checkFileExists("ssl-key", opts.Key); err != nil {
+			return nil, err
+		}
+		cert, err := tls.LoadX509KeyPair(opts.Cert, opts.Key)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to load client certificate from ssl-cert %s and ssl-key %s: %s", opts.Cert, opts.Key, err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if opts.CA != "" && mode != "required" {
+		if err := checkFileExists("ssl-ca", opts.CA); err != nil {
+			return nil, err
+		}
+		pem, err := os.ReadFile(opts.CA)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read ssl-ca %s: %s", opts.CA, err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("Unable to read ssl-ca %s: no PEM-encoded certificates found", opts.CA)
+		}
+	}
+
+	switch mode {
+	case "required":
+		config.InsecureSkipVerify = true
+	case "verify_ca":
+		// Verify the server's certificate chain, but not its hostname. The standard
+		// library has no direct support for this, so automatic verification is
+		// disabled in favor of a custom verifier.
+		config.InsecureSkipVerify = true
+		roots := config.RootCAs
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifyCertChain(rawCerts, roots)
+		}
+	}
+	// For verify_identity, the driver sets ServerName to the host being connected
+	// to, since InsecureSkipVerify is false.
+	return config, nil
+}
+
+// verifyCertChain verifies that rawCerts, as supplied by a server during the
+// TLS handshake, form a valid chain to one of roots.
+func verifyCertChain(rawCerts [][]byte, roots *x509.CertPool) error {
+	if len(rawCerts) == 0 {
+		return errors.New("Server did not supply a TLS certificate")
+	}
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for n, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certs[n] = cert
+	}
+	verifyOpts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range certs[1:] {
+		verifyOpts.Intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(verifyOpts)
+	return err
+}
+
+// checkFileExists returns a descriptive error if the file at path, supplied
+// via the named option, does not exist or is a directory.
+func checkFileExists(optionName, path string) error {
+	if fi, err := os.Stat(path); err != nil {
+		return fmt.Errorf("Option %s refers to %s, which cannot be read: %s", optionName, path, err)
+	} else if fi.IsDir() {
+		return fmt.Errorf("Option %s refers to %s, which is a directory", optionName, path)
+	}
+	return nil
+}
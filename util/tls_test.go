@@ -0,0 +1,155 @@
+package util
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skeema/mybase"
+)
+
+// writeTestCert writes a self-signed certificate and its private key to PEM
+// files in dir, returning their paths.
+func writeTestCert(t *testing.T, dir string) (certPath, keyPath string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Unable to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "skeema test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Unable to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Unable to marshal key: %v", err)
+	}
+	certPath = filepath.Join(dir, "cert.pem")
+	keyPath = filepath.Join(dir, "key.pem")
+	os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	return certPath, keyPath
+}
+
+func TestTLSOptionsForConfig(t *testing.T) {
+	cmdSuite := mybase.NewCommandSuite("skeematest", "", "")
+	AddGlobalOptions(cmdSuite)
+	cmdSuite.AddSubCommand(mybase.NewCommand("diff", "", "", nil))
+
+	cfg := mybase.ParseFakeCLI(t, cmdSuite, "skeema diff --ssl-mode=verify-identity --ssl-ca=/tmp/ca.pem")
+	if opts, err := TLSOptionsForConfig(cfg); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if expected := (TLSOptions{Mode: "verify_identity", CA: "/tmp/ca.pem"}); opts != expected {
+		t.Errorf("Expected %+v, instead found %+v", expected, opts)
+	}
+
+	badArgs := []string{
+		"--ssl-mode=sometimes",
+		"--ssl-cert=/tmp/cert.pem",
+		"--ssl-key=/tmp/key.pem",
+		"--ssl-mode=VERIFY_CA",
+	}
+	for _, args := range badArgs {
+		cfg = mybase.ParseFakeCLI(t, cmdSuite, "skeema diff "+args)
+		if _, err := TLSOptionsForConfig(cfg); err == nil {
+			t.Errorf("Expected error from %s, but err was nil", args)
+		}
+	}
+}
+
+func TestTLSOptionsDSNParam(t *testing.T) {
+	dir, err := os.MkdirTemp("", "skeematls")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	certPath, keyPath := writeTestCert(t, dir)
+
+	simpleCases := map[TLSOptions]string{
+		{}:                          "",
+		{Mode: "disabled"}:          "false",
+		{Mode: "required"}:          "skip-verify",
+		{Mode: "verify_identity"}:   "true",
+		{Mode: "disabled", CA: "x"}: "false",
+	}
+	for opts, expected := range simpleCases {
+		if actual, err := opts.DSNParam(); err != nil || actual != expected {
+			t.Errorf("Expected %+v to return %q, nil; instead found %q, %v", opts, expected, actual, err)
+		}
+	}
+
+	// Custom configs should be registered under a consistent name
+	mutual := TLSOptions{CA: certPath, Cert: certPath, Key: keyPath}
+	name, err := mutual.DSNParam()
+	if err != nil || !strings.HasPrefix(name, "skeema-") {
+		t.Fatalf("Unexpected return from DSNParam: %q, %v", name, err)
+	}
+	if again, err := mutual.DSNParam(); err != nil || again != name {
+		t.Errorf("Expected repeated call to return %q, nil; instead found %q, %v", name, again, err)
+	}
+	if other, err := (TLSOptions{Mode: "verify_identity", CA: certPath}).DSNParam(); err != nil || other == name {
+		t.Errorf("Expected different options to return different name; found %q, %v", other, err)
+	}
+
+	badCases := []TLSOptions{
+		{CA: filepath.Join(dir, "missing.pem")},
+		{CA: dir},
+		{CA: keyPath},
+		{Mode: "required", Cert: certPath, Key: filepath.Join(dir, "missing.pem")},
+		{Mode: "required", Cert: keyPath, Key: certPath},
+	}
+	for _, opts := range badCases {
+		if name, err := opts.DSNParam(); err == nil {
+			t.Errorf("Expected %+v to return an error, but it returned %q", opts, name)
+		}
+	}
+}
+
+func TestVerifyCertChain(t *testing.T) {
+	dir, err := os.MkdirTemp("", "skeematls")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	certPath, _ := writeTestCert(t, dir)
+	otherDir := filepath.Join(dir, "other")
+	os.Mkdir(otherDir, 0700)
+	otherPath, _ := writeTestCert(t, otherDir)
+
+	readDER := func(path string) []byte {
+		contents, _ := os.ReadFile(path)
+		block, _ := pem.Decode(contents)
+		return block.Bytes
+	}
+	roots := x509.NewCertPool()
+	cert, _ := x509.ParseCertificate(readDER(certPath))
+	roots.AddCert(cert)
+
+	if err := verifyCertChain([][]byte{readDER(certPath)}, roots); err != nil {
+		t.Errorf("Expected certificate to verify against itself, but found error %v", err)
+	}
+	if err := verifyCertChain([][]byte{readDER(otherPath)}, roots); err == nil {
+		t.Error("Expected certificate from different CA to fail verification, but it did not")
+	}
+	if err := verifyCertChain(nil, roots); err == nil {
+		t.Error("Expected empty chain to fail verification, but it did not")
+	}
+}
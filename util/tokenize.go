@@ -0,0 +1,65 @@
+package util
+
+import (
+	"strings"
+	"unicode"
+)
+
+// TokenizeString splits input on whitespace, aside from any whitespace inside
+// of a single-quoted, double-quoted, or backtick-quoted section. Quoted
+// sections may appear anywhere within a token, for example COMMENT='a b' is a
+// single token. Inside of single or double quotes, a backslash escapes the
+// following character; inside of any type of quote, a doubled quote character
+// represents a literal quote character. Quotes and escapes are retained as-is
+// in the resulting tokens. An unterminated quote extends to the end of input.
+func TokenizeString(input string) []string {
+	var tokens []string
+	var quote rune
+	var escaped bool
+	start := -1
+	for n, r := range input {
+		if quote != 0 {
+			if escaped {
+				escaped = false
+			} else if r == '\\' && quote != '`' {
+				escaped = true
+			} else if r == quote {
+				// A doubled quote character is a literal; the following rune will
+				// reopen the quote.
+				quote = 0
+			}
+			continue
+		}
+		if unicode.IsSpace(r) {
+			if start > -1 {
+				tokens = append(tokens, input[start:n])
+				start = -1
+			}
+			continue
+		}
+		if start == -1 {
+			start = n
+		}
+		if r == '\'' || r == '"' || r == '`' {
+			quote = r
+		}
+	}
+	if start > -1 {
+		tokens = append(tokens, input[start:])
+	}
+	return tokens
+}
+
+// Detokenize is the inverse of TokenizeString, joining tokens with a single
+// space. Since TokenizeString does not split inside of quoted sections, the
+// result of Detokenize(TokenizeString(input)) differs from input only in its
+// whitespace outside of quotes. Empty tokens are omitted.
+func Detokenize(tokens []string) string {
+	nonEmpty := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		if token != "" {
+			nonEmpty = append(nonEmpty, token)
+		}
+	}
+	return strings.Join(nonEmpty, " ")
+}
@@ -0,0 +1,77 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenizeString(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected []string
+	}{
+		{"", nil},
+		{"   ", nil},
+		{"ENGINE=InnoDB DEFAULT CHARSET=latin1", []string{"ENGINE=InnoDB", "DEFAULT", "CHARSET=latin1"}},
+		{"  KEY_BLOCK_SIZE=8\n\tCOMMENT='hello world'  ", []string{"KEY_BLOCK_SIZE=8", "COMMENT='hello world'"}},
+		{`COMMENT='it''s a \'test\'' ENGINE=InnoDB`, []string{`COMMENT='it''s a \'test\''`, "ENGINE=InnoDB"}},
+		{`COMMENT="say \"hi there\"" x`, []string{`COMMENT="say \"hi there\""`, "x"}},
+		{"`odd `` name` `other\\` x", []string{"`odd `` name`", "`other\\`", "x"}},
+		{`a='b "c' "d 'e" f`, []string{`a='b "c'`, `"d 'e"`, "f"}},
+		{`COMMENT='trailing backslash\\' KEY_BLOCK_SIZE=8`, []string{`COMMENT='trailing backslash\\'`, "KEY_BLOCK_SIZE=8"}},
+		{"COMMENT='unterminated quote here", []string{"COMMENT='unterminated quote here"}},
+		{"COMMENT='ünïcödé välüe' x", []string{"COMMENT='ünïcödé välüe'", "x"}},
+	}
+	for _, c := range cases {
+		if actual := TokenizeString(c.input); !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("Expected TokenizeString(%q) to return %q, instead found %q", c.input, c.expected, actual)
+		}
+	}
+}
+
+func TestDetokenize(t *testing.T) {
+	input := "ENGINE=InnoDB  DEFAULT CHARSET=latin1 KEY_BLOCK_SIZE=8\nCOMMENT='a  b'"
+	tokens := TokenizeString(input)
+	for n := range tokens {
+		if tokens[n] == "KEY_BLOCK_SIZE=8" {
+			tokens[n] = "KEY_BLOCK_SIZE=4"
+		}
+	}
+	expected := "ENGINE=InnoDB DEFAULT CHARSET=latin1 KEY_BLOCK_SIZE=4 COMMENT='a  b'"
+	if actual := Detokenize(tokens); actual != expected {
+		t.Errorf("Expected %q, instead found %q", expected, actual)
+	}
+	if actual := Detokenize([]string{"", "a", "", "b"}); actual != "a b" {
+		t.Errorf("Expected empty tokens to be omitted, instead found %q", actual)
+	}
+}
+
+func TestTokenizeDetokenizeRoundTrip(t *testing.T) {
+	inputs := []string{
+		"",
+		"   ",
+		"ENGINE=InnoDB DEFAULT CHARSET=latin1",
+		"\tENGINE=InnoDB\n\n DEFAULT   CHARSET=latin1\r\n",
+		`COMMENT='it''s a \'test\'' ENGINE=InnoDB`,
+		`COMMENT="say ""hi"" \"there\"" x`,
+		"`odd `` name` \"x y\"",
+		"`back\\tick`  `a``b`",
+		"COMMENT='unterminated",
+		"a 'unterminated  with\tspaces",
+		"x \"unterminated\\\"",
+		`a\ b '\\' "\\"`,
+		`COMMENT='trailing backslash\\'   KEY_BLOCK_SIZE=8`,
+		`a='b "c' "d 'e"` + "\t`f 'g`",
+		"COMMENT='ünïcödé  välüe'\u00a0x",
+	}
+	for _, input := range inputs {
+		tokens := TokenizeString(input)
+		joined := Detokenize(tokens)
+		if retokenized := TokenizeString(joined); !reflect.DeepEqual(retokenized, tokens) {
+			t.Errorf("Tokenizing %q returned %q, but re-tokenizing %q returned %q", input, tokens, joined, retokenized)
+		}
+		if Detokenize(TokenizeString(joined)) != joined {
+			t.Errorf("Detokenize output %q is not stable", joined)
+		}
+	}
+}
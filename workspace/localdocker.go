@@ -49,9 +49,12 @@ func NewLocalDocker(opts Options) (ld *LocalDocker, err error) {
 		schemaName:    opts.SchemaName,
 		cleanupAction: opts.CleanupAction,
 	}
-	image := opts.Flavor.String()
+	image := opts.Image
+	if image == "" {
+		image = opts.Flavor.String()
+	}
 	if opts.ContainerName == "" {
-		opts.ContainerName = fmt.Sprintf("skeema-%s", strings.Replace(image, ":", "-", -1))
+		opts.ContainerName = containerNameForImage(image)
 	}
 	if cstore.containers[opts.ContainerName] == nil {
 		log.Infof("Using container %s (image=%s) for workspace operations", opts.ContainerName, image)
@@ -65,9 +68,12 @@ func NewLocalDocker(opts Options) (ld *LocalDocker, err error) {
 	if err != nil {
 		return nil, err
 	}
+	if ld.schemaName, err = expandSchemaName(ld.d.Instance, ld.schemaName); err != nil {
+		return nil, err
+	}
 
 	lockName := fmt.Sprintf("skeema.%s", ld.schemaName)
-	if ld.releaseLock, err = getLock(ld.d.Instance, lockName, opts.LockWaitTimeout); err != nil {
+	if ld.releaseLock, err = getLock(ld.d.Instance, lockName, opts.LockWaitTimeout, opts.LockAttemptTimeout, opts.ConnectRetry); err != nil {
 		return nil, fmt.Errorf("Unable to obtain lock on %s: %s", ld.d.Instance, err)
 	}
 	// If this function errors, don't continue to hold the lock
@@ -132,6 +138,14 @@ func (ld *LocalDocker) Cleanup() error {
 	return nil
 }
 
+// containerNameForImage returns the name to use for a container based on the
+// supplied image, such that each distinct image uses a separate container,
+// which may be reused across runs.
+func containerNameForImage(image string) string {
+	replacer := strings.NewReplacer(":", "-", "/", "-", "@", "-")
+	return fmt.Sprintf("skeema-%s", replacer.Replace(image))
+}
+
 // shutdown handles shutdown logic for a specific LocalDocker instance. A single
 // string arg may optionally be supplied as a container name prefix: if the
 // container name does not begin with the prefix, no shutdown occurs.
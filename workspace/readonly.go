@@ -0,0 +1,117 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/tengo"
+)
+
+// ReadOnly is a Workspace that never creates any objects. It is intended for
+// environments where the database user lacks privileges to create a temporary
+// schema. Statements may only be checked for syntax errors, using
+// ValidateStatement; any Workspace operation which would require creating
+// objects returns a ReadOnlyError.
+type ReadOnly struct {
+	inst *tengo.Instance
+}
+
+// ReadOnlyError is returned by ReadOnly workspace methods which are not
+// available, since they would require creating objects.
+type ReadOnlyError string
+
+// Error satisfies the builtin error interface.
+func (roe ReadOnlyError) Error() string {
+	return fmt.Sprintf("%s is unavailable with a read-only workspace: executing DDL and introspecting the resulting objects require a workspace with CREATE privileges; only syntax validation is supported", string(roe))
+}
+
+// NewReadOnly returns a read-only workspace for the supplied instance. No
+// schema is created.
+func NewReadOnly(opts Options) (*ReadOnly, error) {
+	if opts.Instance == nil {
+		return nil, errors.New("No instance defined in options")
+	}
+	return &ReadOnly{inst: opts.Instance}, nil
+}
+
+// ConnectionPool always returns a ReadOnlyError, since any use of the pool
+// would involve executing statements in a workspace schema.
+func (ro *ReadOnly) ConnectionPool(params string) (*sqlx.DB, error) {
+	return nil, ReadOnlyError("Connecting to the workspace schema")
+}
+
+// IntrospectSchema always returns a ReadOnlyError, since no objects exist to
+// introspect.
+func (ro *ReadOnly) IntrospectSchema() (*tengo.Schema, error) {
+	return nil, ReadOnlyError("Schema introspection")
+}
+
+// Cleanup is a no-op, since a read-only workspace never creates anything.
+func (ro *ReadOnly) Cleanup() error {
+	return nil
+}
+
+// ValidateStatement checks statement for syntax errors by preparing it on the
+// server, without executing it. Statements which the server does not permit
+// in prepared statements (such as CREATE PROCEDURE) cannot be validated this
+// way, and are assumed to be valid. A nil return value therefore does not
+// guarantee that the statement would execute successfully.
+func (ro *ReadOnly) ValidateStatement(statement *fs.Statement) *StatementError {
+	db, err := ro.inst.Connect("", "")
+	if err != nil {
+		return &StatementError{
+			Statement: statement,
+			Err:       fmt.Errorf("Cannot connect to %s: %s", ro.inst, err),
+		}
+	}
+	stmt, err := db.Prepare(statement.Body())
+	if err == nil {
+		stmt.Close()
+		return nil
+	}
+	if tengo.IsSyntaxError(err) {
+		return &StatementError{
+			Statement: statement,
+			Err:       fmt.Errorf("SQL syntax error: %s", err),
+		}
+	}
+	// Other errors aren't meaningful here: either the statement type cannot be
+	// prepared, or the statement refers to objects which don't exist since
+	// nothing is created in read-only mode.
+	return nil
+}
+
+// ValidateLogicalSchema checks each statement in logicalSchema for syntax
+// errors using a read-only workspace, without creating any objects. This is
+// the counterpart of ExecLogicalSchema for opts.Type TypeReadOnly, since that
+// workspace type cannot execute DDL. Syntax errors are returned in the first
+// return value; see ReadOnly.ValidateStatement regarding which statements can
+// be validated. The second return value represents fatal errors only.
+func ValidateLogicalSchema(logicalSchema *fs.LogicalSchema, opts Options) (statementErrors []*StatementError, fatalErr error) {
+	ro, err := NewReadOnly(opts)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := ro.inst.Connect("", ""); err != nil {
+		return nil, fmt.Errorf("Cannot connect to %s: %s", ro.inst, err)
+	}
+	keys := make([]tengo.ObjectKey, 0, len(logicalSchema.Creates))
+	for key := range logicalSchema.Creates {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	for _, key := range keys {
+		if stmtErr := ro.ValidateStatement(logicalSchema.Creates[key]); stmtErr != nil {
+			statementErrors = append(statementErrors, stmtErr)
+		}
+	}
+	for _, statement := range logicalSchema.Alters {
+		if stmtErr := ro.ValidateStatement(statement); stmtErr != nil {
+			statementErrors = append(statementErrors, stmtErr)
+		}
+	}
+	return statementErrors, nil
+}
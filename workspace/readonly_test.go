@@ -0,0 +1,84 @@
+package workspace
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/tengo"
+)
+
+func TestReadOnlyErrors(t *testing.T) {
+	if _, err := New(Options{Type: TypeReadOnly}); err == nil {
+		t.Error("Expected error from NewReadOnly without an instance, but err was nil")
+	}
+	ro := &ReadOnly{}
+	if _, err := ro.ConnectionPool(""); err == nil {
+		t.Error("Expected ConnectionPool to return an error, but err was nil")
+	} else if _, ok := err.(ReadOnlyError); !ok || !strings.Contains(err.Error(), "read-only") {
+		t.Errorf("Unexpected error from ConnectionPool: %v", err)
+	}
+	if _, err := ro.IntrospectSchema(); err == nil {
+		t.Error("Expected IntrospectSchema to return an error, but err was nil")
+	} else if _, ok := err.(ReadOnlyError); !ok {
+		t.Errorf("Unexpected error type from IntrospectSchema: %T", err)
+	}
+	if err := ro.Cleanup(); err != nil {
+		t.Errorf("Unexpected error from Cleanup: %v", err)
+	}
+
+	// ExecLogicalSchema cannot execute DDL with a read-only workspace, and
+	// ValidateLogicalSchema requires an instance
+	logicalSchema := &fs.LogicalSchema{}
+	if _, _, err := ExecLogicalSchema(logicalSchema, Options{Type: TypeReadOnly}); err == nil {
+		t.Error("Expected ExecLogicalSchema to return an error, but err was nil")
+	} else if _, ok := err.(ReadOnlyError); !ok {
+		t.Errorf("Unexpected error type from ExecLogicalSchema: %T", err)
+	}
+	if _, err := ValidateLogicalSchema(logicalSchema, Options{Type: TypeReadOnly}); err == nil {
+		t.Error("Expected ValidateLogicalSchema without an instance to return an error, but err was nil")
+	}
+}
+
+func (s WorkspaceIntegrationSuite) TestReadOnly(t *testing.T) {
+	ws, err := New(Options{Type: TypeReadOnly, Instance: s.d.Instance})
+	if err != nil {
+		t.Fatalf("Unexpected error from New: %s", err)
+	}
+	ro := ws.(*ReadOnly)
+	cases := map[string]bool{
+		"CREATE TABLE valid (id int unsigned NOT NULL PRIMARY KEY)":                            true,
+		"CREATE TABLE nonexistent_db.valid (id int)":                                           true,
+		"CREATE TABLE invalid (id int unsigned NOT NULL PRIMARY KEY":                           false,
+		"CREATE TABLE invalid (id int unsinged)":                                               false,
+		"CREATE PROCEDURE cannot_prepare() BEGIN SELECT 1; SELECT 2; END":                      true,
+		"CREATE TABLE `another valid` (`name` varchar(30) CHARACTER SET utf8mb4 DEFAULT NULL)": true,
+	}
+	for body, expectValid := range cases {
+		statement := &fs.Statement{Text: body, Type: fs.StatementTypeCreate}
+		stmtErr := ro.ValidateStatement(statement)
+		if expectValid && stmtErr != nil {
+			t.Errorf("Unexpected error validating %q: %s", body, stmtErr)
+		} else if !expectValid && stmtErr == nil {
+			t.Errorf("Expected error validating %q, but err was nil", body)
+		}
+	}
+
+	// ValidateLogicalSchema should only report the statements with syntax errors
+	logicalSchema := &fs.LogicalSchema{Creates: make(map[tengo.ObjectKey]*fs.Statement)}
+	for body := range cases {
+		statement := &fs.Statement{Text: body, Type: fs.StatementTypeCreate, ObjectType: tengo.ObjectTypeTable, ObjectName: body}
+		logicalSchema.Creates[statement.ObjectKey()] = statement
+	}
+	statementErrors, err := ValidateLogicalSchema(logicalSchema, Options{Type: TypeReadOnly, Instance: s.d.Instance})
+	if err != nil {
+		t.Fatalf("Unexpected error from ValidateLogicalSchema: %s", err)
+	} else if len(statementErrors) != 2 {
+		t.Errorf("Expected 2 statement errors from ValidateLogicalSchema, instead found %d", len(statementErrors))
+	}
+
+	// Confirm nothing was actually created
+	if has, err := s.d.HasSchema("nonexistent_db"); has || err != nil {
+		t.Errorf("Unexpected result from HasSchema: has=%t err=%v", has, err)
+	}
+}
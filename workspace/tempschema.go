@@ -1,39 +1,87 @@
 package workspace
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/skeema/mybase"
 	"github.com/skeema/tengo"
 )
 
 // TempSchema is a Workspace that exists as a schema that is created on another
 // database instance. The schema is cleaned up when done interacting with the
-// workspace.
+// workspace. Creation of the schema and dropping of its tables occur on the
+// same connection which holds the workspace's lock, ensuring that these
+// operations share a session with the lock even when connecting through a
+// proxy.
 type TempSchema struct {
-	schemaName  string
-	keepSchema  bool
-	inst        *tengo.Instance
-	releaseLock releaseFunc
+	schemaName   string
+	keepSchema   bool
+	inst         *tengo.Instance
+	lockConn     *lockConn
+	releaseLock  releaseFunc
+	observer     Observer
+	forceCleanup bool
+}
+
+// NonEmptyTable describes a table which unexpectedly has rows.
+type NonEmptyTable struct {
+	Name     string
+	RowCount int64
+}
+
+// NonEmptyTablesError is returned when a temporary schema's tables cannot be
+// dropped, because one or more of them have rows.
+type NonEmptyTablesError struct {
+	Schema string
+	Tables []NonEmptyTable
+}
+
+// Error satisfies the builtin error interface.
+func (nete *NonEmptyTablesError) Error() string {
+	descriptions := make([]string, len(nete.Tables))
+	for n, table := range nete.Tables {
+		descriptions[n] = fmt.Sprintf("%s (%d rows)", tengo.EscapeIdentifier(table.Name), table.RowCount)
+	}
+	return fmt.Sprintf("Tables in schema %s have rows: %s", tengo.EscapeIdentifier(nete.Schema), strings.Join(descriptions, ", "))
 }
 
 // NewTempSchema creates a temporary schema on the supplied instance and returns
 // it.
 func NewTempSchema(opts Options) (ts *TempSchema, err error) {
+	return NewTempSchemaContext(context.Background(), opts)
+}
+
+// NewTempSchemaContext behaves like NewTempSchema, but gives up waiting for the
+// temporary schema's lock once ctx is cancelled. This permits a user to abort
+// (e.g. via Ctrl-C) while another process is holding the lock. The context is
+// also used for dropping any pre-existing tables and creating the schema.
+func NewTempSchemaContext(ctx context.Context, opts Options) (ts *TempSchema, err error) {
 	if opts.Instance == nil {
 		return nil, errors.New("No instance defined in options")
 	}
+	schemaName, err := expandSchemaName(opts.Instance, opts.SchemaName)
+	if err != nil {
+		return nil, err
+	}
 	ts = &TempSchema{
-		schemaName: opts.SchemaName,
-		keepSchema: opts.CleanupAction == CleanupActionNone,
-		inst:       opts.Instance,
+		schemaName:   schemaName,
+		keepSchema:   opts.CleanupAction == CleanupActionNone,
+		inst:         opts.Instance,
+		observer:     opts.Observer,
+		forceCleanup: opts.ForceCleanup,
 	}
 
 	lockName := fmt.Sprintf("skeema.%s", ts.schemaName)
-	if ts.releaseLock, err = getLock(ts.inst, lockName, opts.LockWaitTimeout); err != nil {
-		return nil, fmt.Errorf("Unable to lock temporary schema on %s: %s", ts.inst, err)
+	timer := startPhase(ts.observer)
+	if ts.lockConn, ts.releaseLock, err = getLockConn(ctx, ts.inst, lockName, opts.LockWaitTimeout, opts.LockAttemptTimeout, opts.ConnectRetry); err != nil {
+		return nil, fmt.Errorf("Unable to lock temporary schema on %s: %w", ts.inst, err)
 	}
+	timer.end(PhaseLockAcquire)
 	// If NewTempSchema errors, don't continue to hold the lock
 	defer func() {
 		if err != nil {
@@ -47,18 +95,35 @@ func NewTempSchema(opts Options) (ts *TempSchema, err error) {
 	} else if has {
 		// Attempt to drop any tables already present in tempSchema, but fail if
 		// any of them actually have 1 or more rows
-		if err := ts.inst.DropTablesInSchema(ts.schemaName, true); err != nil {
+		timer := startPhase(ts.observer)
+		if err := ts.dropTables(ctx, false); err != nil {
 			return ts, fmt.Errorf("Cannot drop existing temp schema tables on %s: %s", ts.inst, err)
 		}
+		timer.end(PhaseReuse)
 	} else {
-		_, err = ts.inst.CreateSchema(ts.schemaName, opts.DefaultCharacterSet, opts.DefaultCollation)
+		timer := startPhase(ts.observer)
+		schema := &tengo.Schema{
+			Name:      ts.schemaName,
+			CharSet:   opts.DefaultCharacterSet,
+			Collation: opts.DefaultCollation,
+		}
+		ts.lockConn.Lock()
+		_, err = ts.lockConn.ExecContext(ctx, schema.CreateStatement())
+		ts.lockConn.Unlock()
 		if err != nil {
 			return ts, fmt.Errorf("Cannot create temporary schema on %s: %s", ts.inst, err)
 		}
+		timer.end(PhaseCreate)
 	}
 	return ts, nil
 }
 
+// SchemaName returns the name of the temporary schema. If the temp-schema
+// option contained any placeholders, this returns the expanded name.
+func (ts *TempSchema) SchemaName() string {
+	return ts.schemaName
+}
+
 // ConnectionPool returns a connection pool (*sqlx.DB) to the temporary
 // workspace schema, using the supplied connection params (which may be blank).
 func (ts *TempSchema) ConnectionPool(params string) (*sqlx.DB, error) {
@@ -72,9 +137,17 @@ func (ts *TempSchema) IntrospectSchema() (*tengo.Schema, error) {
 
 // Cleanup either drops the temporary schema (if not using reuse-temp-schema)
 // or just drops all tables in the schema (if using reuse-temp-schema). If any
-// tables have any rows in the temp schema, the cleanup aborts and an error is
-// returned.
+// tables have any rows in the temp schema, the cleanup aborts and returns an
+// error wrapping a *NonEmptyTablesError, which lists all such tables, unless
+// Options.ForceCleanup was set.
 func (ts *TempSchema) Cleanup() error {
+	return ts.CleanupContext(context.Background())
+}
+
+// CleanupContext behaves like Cleanup, but aborts once ctx is cancelled. The
+// workspace's lock is released regardless, but the temporary schema may be
+// left behind, for removal by a subsequent run.
+func (ts *TempSchema) CleanupContext(ctx context.Context) error {
 	if ts.releaseLock == nil {
 		return errors.New("Cleanup() called multiple times on same TempSchema")
 	}
@@ -83,12 +156,152 @@ func (ts *TempSchema) Cleanup() error {
 		ts.releaseLock = nil
 	}()
 
-	if ts.keepSchema {
-		if err := ts.inst.DropTablesInSchema(ts.schemaName, true); err != nil {
-			return fmt.Errorf("Cannot drop tables in temporary schema on %s: %s", ts.inst, err)
+	timer := startPhase(ts.observer)
+	if err := ts.dropTables(ctx, ts.forceCleanup); err != nil {
+		return fmt.Errorf("Cannot drop tables in temporary schema on %s: %w", ts.inst, err)
+	}
+	if !ts.keepSchema {
+		// The schema has no tables at this point. tengo.Instance.DropSchema is used
+		// for the schema itself, since it also closes any connection pools that
+		// were using the schema. It does not accept a context, so check for
+		// cancellation beforehand.
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("Cannot drop temporary schema on %s: %w", ts.inst, err)
+		}
+		if err := ts.inst.DropSchema(ts.schemaName, true); err != nil {
+			return fmt.Errorf("Cannot drop temporary schema on %s: %s", ts.inst, err)
 		}
-	} else if err := ts.inst.DropSchema(ts.schemaName, true); err != nil {
-		return fmt.Errorf("Cannot drop temporary schema on %s: %s", ts.inst, err)
 	}
+	timer.end(PhaseDrop)
 	return nil
 }
+
+// dropTables drops all tables in the temporary schema, using the connection
+// holding the workspace lock. If any tables have rows and force is false, a
+// *NonEmptyTablesError listing all such tables is returned, and no tables are
+// dropped. The supplied context applies to all queries. Since the lock's
+// connection belongs to a shared pool, its foreign_key_checks setting is
+// restored afterwards if it had to be changed.
+func (ts *TempSchema) dropTables(ctx context.Context, force bool) (err error) {
+	// Obtain table names via a normal connection pool, since this is read-only;
+	// only the DDL needs to share a session with the lock
+	db, err := ts.inst.Connect("", "")
+	if err != nil {
+		return err
+	}
+	var names []string
+	query := `
+		SELECT table_name
+		FROM   information_schema.tables
+		WHERE  table_schema = ?
+		AND    table_type = 'BASE TABLE'`
+	if err := db.SelectContext(ctx, &names, query, ts.schemaName); err != nil {
+		return err
+	} else if len(names) == 0 {
+		return nil
+	}
+
+	ts.lockConn.Lock()
+	defer ts.lockConn.Unlock()
+	// With force, tables are dropped regardless of their contents, so there's no
+	// need to examine them
+	if !force {
+		var nonEmpty []NonEmptyTable
+		for _, name := range names {
+			var result int
+			tableName := fmt.Sprintf("%s.%s", tengo.EscapeIdentifier(ts.schemaName), tengo.EscapeIdentifier(name))
+			if err := ts.lockConn.QueryRowContext(ctx, "SELECT 1 FROM "+tableName+" LIMIT 1").Scan(&result); err == sql.ErrNoRows {
+				continue
+			} else if err != nil {
+				return err
+			}
+			// Only count rows in tables already known to be non-empty, since this may
+			// be slow for a large table
+			table := NonEmptyTable{Name: name}
+			if err := ts.lockConn.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+tableName).Scan(&table.RowCount); err != nil {
+				return err
+			}
+			nonEmpty = append(nonEmpty, table)
+		}
+		if len(nonEmpty) > 0 {
+			return &NonEmptyTablesError{Schema: ts.schemaName, Tables: nonEmpty}
+		}
+	}
+	var fkChecks int
+	if err := ts.lockConn.QueryRowContext(ctx, "SELECT @@foreign_key_checks").Scan(&fkChecks); err != nil {
+		return err
+	}
+	if fkChecks != 0 {
+		if _, err := ts.lockConn.ExecContext(ctx, "SET foreign_key_checks=0"); err != nil {
+			return err
+		}
+		defer func() {
+			// Restore even if ctx was cancelled, so that the session isn't returned
+			// to the pool in an altered state
+			if _, restoreErr := ts.lockConn.ExecContext(context.Background(), "SET foreign_key_checks=1"); restoreErr != nil && err == nil {
+				err = restoreErr
+			}
+		}()
+	}
+	for _, name := range names {
+		if _, err := ts.lockConn.ExecContext(ctx, fmt.Sprintf("DROP TABLE %s.%s", tengo.EscapeIdentifier(ts.schemaName), tengo.EscapeIdentifier(name))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CleanupOrphanedWorkspaces drops temporary schemas left behind on instance by
+// a previous run which exited without calling Cleanup, for example due to a
+// crash. A schema is considered orphaned if its name matches the temp-schema
+// option in config (including any names generated from its placeholders), and
+// its lock can be obtained immediately, meaning that no
+// other process is currently using it. The number of schemas dropped is
+// returned. Schemas containing any tables with rows are never dropped; an
+// error is returned in this case.
+func CleanupOrphanedWorkspaces(instance *tengo.Instance, config *mybase.Config) (int, error) {
+	names, err := instance.SchemaNames()
+	if err != nil {
+		return 0, err
+	}
+	db, err := instance.Connect("", "")
+	if err != nil {
+		return 0, err
+	}
+	re := schemaNameRegexp(config.Get("temp-schema"))
+	var dropped int
+	for _, name := range names {
+		if !re.MatchString(name) {
+			continue
+		}
+		if ok, err := dropIfUnlocked(db, instance, name); err != nil {
+			return dropped, err
+		} else if ok {
+			dropped++
+		}
+	}
+	return dropped, nil
+}
+
+// dropIfUnlocked drops the temporary schema with the supplied name if its lock
+// is not currently held. It returns true if the schema was dropped.
+func dropIfUnlocked(db *sqlx.DB, instance *tengo.Instance, schemaName string) (bool, error) {
+	// Use a single connection, since named locks are held per-session
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	lockName := fmt.Sprintf("skeema.%s", schemaName)
+	var getLockResult sql.NullInt64
+	if err := conn.QueryRowContext(context.Background(), "SELECT GET_LOCK(?, 0)", lockName).Scan(&getLockResult); err != nil {
+		return false, err
+	} else if getLockResult.Int64 != 1 {
+		return false, nil
+	}
+	defer conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName)
+	if err := instance.DropSchema(schemaName, true); err != nil {
+		return false, fmt.Errorf("Cannot drop orphaned temporary schema %s on %s: %s", schemaName, instance, err)
+	}
+	return true, nil
+}
@@ -1,8 +1,13 @@
 package workspace
 
 import (
+	"context"
+	"errors"
+	"reflect"
 	"testing"
 	"time"
+
+	"github.com/skeema/skeema/util"
 )
 
 func (s WorkspaceIntegrationSuite) TestTempSchema(t *testing.T) {
@@ -100,3 +105,287 @@ func (s WorkspaceIntegrationSuite) TestTempSchema(t *testing.T) {
 		t.Fatal("Expected non-nil error from NewTempSchema, but return was nil")
 	}
 }
+
+func TestNonEmptyTablesError(t *testing.T) {
+	err := &NonEmptyTablesError{
+		Schema: "_skeema_tmp",
+		Tables: []NonEmptyTable{{Name: "foo", RowCount: 3}, {Name: "bar", RowCount: 1}},
+	}
+	expected := "Tables in schema `_skeema_tmp` have rows: `foo` (3 rows), `bar` (1 rows)"
+	if actual := err.Error(); actual != expected {
+		t.Errorf("Expected %q, instead found %q", expected, actual)
+	}
+}
+
+func (s WorkspaceIntegrationSuite) TestTempSchemaNonEmptyCleanup(t *testing.T) {
+	opts := Options{
+		Type:            TypeTempSchema,
+		CleanupAction:   CleanupActionDrop,
+		Instance:        s.d.Instance,
+		SchemaName:      "_skeema_tmp",
+		LockWaitTimeout: 100 * time.Millisecond,
+	}
+	ts, err := NewTempSchema(opts)
+	if err != nil {
+		t.Fatalf("Unexpected error from NewTempSchema: %s", err)
+	}
+	if _, err := s.d.SourceSQL("../testdata/tempschema1.sql"); err != nil {
+		t.Fatalf("Unexpected SourceSQL error: %s", err)
+	}
+	db, _ := s.d.Connect("_skeema_tmp", "")
+	for _, query := range []string{"CREATE TABLE foo (id int)", "CREATE TABLE baz (id int)", "INSERT INTO foo (id) VALUES (1)"} {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatalf("Unexpected error in test setup: %s", err)
+		}
+	}
+
+	// Cleanup should report all non-empty tables, not just the first one
+	err = ts.Cleanup()
+	var neErr *NonEmptyTablesError
+	if !errors.As(err, &neErr) {
+		t.Fatalf("Expected error wrapping *NonEmptyTablesError, instead found %v", err)
+	}
+	expected := []NonEmptyTable{{Name: "bar", RowCount: 3}, {Name: "foo", RowCount: 1}}
+	if neErr.Schema != "_skeema_tmp" || len(neErr.Tables) != 2 {
+		t.Errorf("Unexpected NonEmptyTablesError: %+v", neErr)
+	} else {
+		for _, table := range expected {
+			var found bool
+			for _, actual := range neErr.Tables {
+				found = found || actual == table
+			}
+			if !found {
+				t.Errorf("Expected %+v in NonEmptyTablesError, but it was not found: %+v", table, neErr.Tables)
+			}
+		}
+	}
+
+	// With ForceCleanup, tables should be dropped despite having rows
+	opts.ForceCleanup = true
+	if ts, err = NewTempSchema(opts); err == nil {
+		t.Fatal("Expected NewTempSchema to fail with pre-existing non-empty tables even with ForceCleanup")
+	}
+	if _, err := db.Exec("DELETE FROM bar"); err != nil {
+		t.Fatalf("Unexpected error in test setup: %s", err)
+	}
+	if _, err := db.Exec("DELETE FROM foo"); err != nil {
+		t.Fatalf("Unexpected error in test setup: %s", err)
+	}
+	if ts, err = NewTempSchema(opts); err != nil {
+		t.Fatalf("Unexpected error from NewTempSchema: %s", err)
+	}
+	if _, err := s.d.SourceSQL("../testdata/tempschema1.sql"); err != nil {
+		t.Fatalf("Unexpected SourceSQL error: %s", err)
+	}
+	if err := ts.Cleanup(); err != nil {
+		t.Errorf("Unexpected error from Cleanup with ForceCleanup: %s", err)
+	}
+	if has, err := s.d.HasSchema("_skeema_tmp"); has || err != nil {
+		t.Errorf("Expected schema to be dropped; has=%t err=%v", has, err)
+	}
+}
+
+func (s WorkspaceIntegrationSuite) TestTempSchemaContext(t *testing.T) {
+	opts := Options{
+		Type:                TypeTempSchema,
+		CleanupAction:       CleanupActionDrop,
+		Instance:            s.d.Instance,
+		SchemaName:          "_skeema_tmp",
+		DefaultCharacterSet: "latin1",
+		DefaultCollation:    "latin1_swedish_ci",
+		LockWaitTimeout:     time.Minute,
+	}
+	ts, err := NewTempSchemaContext(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Unexpected error from NewTempSchemaContext: %s", err)
+	}
+	defer ts.Cleanup()
+
+	// With the lock already held, a cancelled context should cause
+	// NewTempSchemaContext to give up long before LockWaitTimeout
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := NewTempSchemaContext(ctx, opts); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected error wrapping context.DeadlineExceeded, instead found %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("NewTempSchemaContext took too long to give up after context cancellation: %s", elapsed)
+	}
+
+	// A cancelled context should cause CleanupContext to fail, but the lock
+	// should still be released, permitting a subsequent run to reuse the schema
+	cancelled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	if err := ts.CleanupContext(cancelled); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected error wrapping context.Canceled, instead found %v", err)
+	}
+	if ts, err = NewTempSchemaContext(context.Background(), opts); err != nil {
+		t.Fatalf("Unexpected error from NewTempSchemaContext after cancelled cleanup: %s", err)
+	}
+	if err := ts.Cleanup(); err != nil {
+		t.Errorf("Unexpected error from Cleanup: %s", err)
+	}
+}
+
+func (s WorkspaceIntegrationSuite) TestCleanupOrphanedWorkspaces(t *testing.T) {
+	dir := s.getParsedDir(t, "../testdata/golden/init/mydb/product", "--temp-schema=_skeema_orphan")
+	opts := Options{
+		Type:            TypeTempSchema,
+		CleanupAction:   CleanupActionNone,
+		Instance:        s.d.Instance,
+		SchemaName:      "_skeema_orphan",
+		LockWaitTimeout: 100 * time.Millisecond,
+	}
+	ts, err := NewTempSchema(opts)
+	if err != nil {
+		t.Fatalf("Unexpected error from NewTempSchema: %s", err)
+	}
+
+	// While the lock is held, the schema should not be dropped
+	if count, err := CleanupOrphanedWorkspaces(s.d.Instance, dir.Config); count != 0 || err != nil {
+		t.Errorf("Unexpected return from CleanupOrphanedWorkspaces: %d, %v", count, err)
+	}
+
+	// Once the lock is released, the schema is orphaned since CleanupActionNone
+	// leaves it in place
+	if err := ts.Cleanup(); err != nil {
+		t.Fatalf("Unexpected error from Cleanup: %s", err)
+	}
+	if count, err := CleanupOrphanedWorkspaces(s.d.Instance, dir.Config); count != 1 || err != nil {
+		t.Errorf("Unexpected return from CleanupOrphanedWorkspaces: %d, %v", count, err)
+	}
+	if has, err := s.d.HasSchema("_skeema_orphan"); has || err != nil {
+		t.Errorf("Expected schema to be dropped; has=%t err=%v", has, err)
+	}
+	if count, err := CleanupOrphanedWorkspaces(s.d.Instance, dir.Config); count != 0 || err != nil {
+		t.Errorf("Unexpected return from CleanupOrphanedWorkspaces: %d, %v", count, err)
+	}
+}
+
+func (s WorkspaceIntegrationSuite) TestTempSchemaNameTemplate(t *testing.T) {
+	opts := Options{
+		Type:            TypeTempSchema,
+		CleanupAction:   CleanupActionDrop,
+		Instance:        s.d.Instance,
+		SchemaName:      "_skeema_tmp_{pid}_{rand}",
+		LockWaitTimeout: 100 * time.Millisecond,
+	}
+	ts1, err := NewTempSchema(opts)
+	if err != nil {
+		t.Fatalf("Unexpected error from NewTempSchema: %s", err)
+	}
+	ts2, err := NewTempSchema(opts)
+	if err != nil {
+		t.Fatalf("Unexpected error from concurrent NewTempSchema: %s", err)
+	}
+	if ts1.SchemaName() == ts2.SchemaName() || !schemaNameRegexp(opts.SchemaName).MatchString(ts1.SchemaName()) {
+		t.Errorf("Unexpected schema names %s and %s", ts1.SchemaName(), ts2.SchemaName())
+	}
+	for _, ts := range []*TempSchema{ts1, ts2} {
+		if err := ts.Cleanup(); err != nil {
+			t.Errorf("Unexpected error from Cleanup: %s", err)
+		}
+		if has, err := s.d.HasSchema(ts.SchemaName()); has || err != nil {
+			t.Errorf("Expected schema %s to be dropped; has=%t err=%v", ts.SchemaName(), has, err)
+		}
+	}
+}
+
+func (s WorkspaceIntegrationSuite) TestTempSchemaLockConn(t *testing.T) {
+	opts := Options{
+		Type:            TypeTempSchema,
+		CleanupAction:   CleanupActionDrop,
+		Instance:        s.d.Instance,
+		SchemaName:      "_skeema_tmp",
+		LockWaitTimeout: 100 * time.Millisecond,
+	}
+	ts, err := NewTempSchema(opts)
+	if err != nil {
+		t.Fatalf("Unexpected error from NewTempSchema: %s", err)
+	}
+	defer ts.Cleanup()
+
+	// The connection used for workspace DDL should be the one holding the lock
+	var holdsLock int
+	ts.lockConn.Lock()
+	err = ts.lockConn.QueryRowContext(context.Background(), "SELECT IS_USED_LOCK('skeema._skeema_tmp') = CONNECTION_ID()").Scan(&holdsLock)
+	ts.lockConn.Unlock()
+	if err != nil || holdsLock != 1 {
+		t.Errorf("Expected lockConn to hold lock; result=%d err=%v", holdsLock, err)
+	}
+}
+
+func (s WorkspaceIntegrationSuite) TestTempSchemaObserver(t *testing.T) {
+	observer := &recordingObserver{}
+	opts := Options{
+		Type:            TypeTempSchema,
+		CleanupAction:   CleanupActionNone,
+		Instance:        s.d.Instance,
+		SchemaName:      "_skeema_tmp",
+		LockWaitTimeout: 100 * time.Millisecond,
+		Observer:        observer,
+	}
+	for n := 0; n < 2; n++ {
+		ts, err := NewTempSchema(opts)
+		if err != nil {
+			t.Fatalf("Unexpected error from NewTempSchema: %s", err)
+		}
+		if err := ts.Cleanup(); err != nil {
+			t.Fatalf("Unexpected error from Cleanup: %s", err)
+		}
+	}
+	expected := []string{PhaseLockAcquire, PhaseCreate, PhaseDrop, PhaseLockAcquire, PhaseReuse, PhaseDrop}
+	if !reflect.DeepEqual(observer.phases, expected) {
+		t.Errorf("Expected phases %v, instead found %v", expected, observer.phases)
+	}
+}
+
+func (s WorkspaceIntegrationSuite) TestGetLockConnCancel(t *testing.T) {
+	lockName := "skeema._skeema_cancel"
+	release, err := getLock(s.d.Instance, lockName, time.Second, 0, util.RetryPolicy{})
+	if err != nil {
+		t.Fatalf("Unexpected error from getLock: %s", err)
+	}
+
+	// With the lock already held, a cancelled attempt should fail
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if _, _, err := getLockConn(ctx, s.d.Instance, lockName, time.Minute, time.Second, util.RetryPolicy{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected error wrapping context.DeadlineExceeded, instead found %v", err)
+	}
+	release()
+	db, err := s.d.Connect("", "")
+	if err != nil {
+		t.Fatalf("Unable to connect: %s", err)
+	}
+	assertLockFreed := func() {
+		t.Helper()
+		var isFree int
+		for n := 0; n < 50; n++ {
+			if err := db.QueryRow("SELECT IS_FREE_LOCK(?)", lockName).Scan(&isFree); err != nil {
+				t.Fatalf("Unexpected error from IS_FREE_LOCK: %s", err)
+			} else if isFree == 1 {
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		t.Errorf("Expected lock %s to be released, but it is still held", lockName)
+	}
+	assertLockFreed()
+
+	// Simulate a GET_LOCK which succeeded on the server after the client gave
+	// up on it: discarding the connection must not leave the lock held by a
+	// pooled session
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Unable to obtain connection: %s", err)
+	}
+	lc := &lockConn{Conn: conn}
+	var getLockResult int
+	if err := lc.QueryRowContext(context.Background(), "SELECT GET_LOCK(?, 1)", lockName).Scan(&getLockResult); err != nil || getLockResult != 1 {
+		t.Fatalf("Unexpected result from GET_LOCK: %d, %v", getLockResult, err)
+	}
+	lc.discard()
+	assertLockFreed()
+}
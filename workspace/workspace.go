@@ -7,8 +7,14 @@ package workspace
 
 import (
 	"context"
-	"errors"
+	"crypto/rand"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
 	"fmt"
+	"os"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -16,6 +22,7 @@ import (
 	"github.com/jmoiron/sqlx"
 	log "github.com/sirupsen/logrus"
 	"github.com/skeema/skeema/fs"
+	"github.com/skeema/skeema/util"
 	"github.com/skeema/tengo"
 )
 
@@ -44,6 +51,7 @@ const (
 	TypeTempSchema  Type = iota // A temporary schema on a real pre-supplied Instance
 	TypeLocalDocker             // A schema on an ephemeral Docker container on localhost
 	TypePrefab                  // A pre-supplied Workspace, possibly from another package
+	TypeReadOnly                // Syntax validation only on a real pre-supplied Instance, without creating anything
 )
 
 // CleanupAction represents how to clean up a workspace.
@@ -73,9 +81,10 @@ const (
 type Options struct {
 	Type                Type
 	CleanupAction       CleanupAction
-	Instance            *tengo.Instance // only TypeTempSchema
+	Instance            *tengo.Instance // only TypeTempSchema or TypeReadOnly
 	Flavor              tengo.Flavor    // only TypeLocalDocker
 	ContainerName       string          // only TypeLocalDocker
+	Image               string          // only TypeLocalDocker; if blank, based on Flavor
 	SchemaName          string
 	DefaultCharacterSet string
 	DefaultCollation    string
@@ -83,6 +92,46 @@ type Options struct {
 	RootPassword        string    // only TypeLocalDocker
 	PrefabWorkspace     Workspace // only TypePrefab
 	LockWaitTimeout     time.Duration
+	LockAttemptTimeout  time.Duration    // if 0, defaultLockAttemptTimeout is used
+	ConnectRetry        util.RetryPolicy // used when connecting to obtain the workspace lock
+	Observer            Observer         // only TypeTempSchema; may be nil
+	ForceCleanup        bool             // only TypeTempSchema; drop tables in Cleanup() even if they have rows
+}
+
+// Observer receives timing information about phases of workspace setup and
+// cleanup, for example to record metrics.
+type Observer interface {
+	// OnPhase is called after each phase completes, with one of the Phase
+	// constants as name.
+	OnPhase(name string, d time.Duration)
+}
+
+// Constants enumerating the phases reported to an Observer.
+const (
+	PhaseLockAcquire = "lock-acquire" // obtaining the workspace lock
+	PhaseCreate      = "create"       // creating the workspace schema
+	PhaseReuse       = "reuse"        // dropping tables in a pre-existing workspace schema
+	PhaseDrop        = "drop"         // dropping tables and/or the schema upon cleanup
+)
+
+// phaseTimer measures the duration of a phase for an Observer. If the
+// observer is nil, no time measurement occurs.
+type phaseTimer struct {
+	observer Observer
+	start    time.Time
+}
+
+func startPhase(observer Observer) phaseTimer {
+	if observer == nil {
+		return phaseTimer{}
+	}
+	return phaseTimer{observer: observer, start: time.Now()}
+}
+
+func (pt phaseTimer) end(name string) {
+	if pt.observer != nil {
+		pt.observer.OnPhase(name, time.Since(pt.start))
+	}
 }
 
 // New returns a pointer to a ready-to-use Workspace, using the configuration
@@ -95,6 +144,8 @@ func New(opts Options) (Workspace, error) {
 		return NewLocalDocker(opts)
 	case TypePrefab:
 		return opts.PrefabWorkspace, nil
+	case TypeReadOnly:
+		return NewReadOnly(opts)
 	}
 	return nil, fmt.Errorf("Unsupported workspace type %v", opts.Type)
 }
@@ -103,25 +154,42 @@ func New(opts Options) (Workspace, error) {
 // A non-nil instance should be supplied, unless the caller already knows the
 // workspace won't be temp-schema based.
 // This method relies on option definitions from util.AddGlobalOptions(),
-// including "workspace", "temp-schema", "flavor", "docker-cleanup", and
+// including "workspace", "temp-schema", "flavor", "docker-cleanup",
+// "docker-image", "lock-wait", "connect-retries", "connect-retry-delay", and
 // "reuse-temp-schema".
 func OptionsForDir(dir *fs.Dir, instance *tengo.Instance) (Options, error) {
-	requestedType, err := dir.Config.GetEnum("workspace", "temp-schema", "docker")
+	requestedType, err := dir.Config.GetEnum("workspace", "temp-schema", "docker", "read-only")
+	if err != nil {
+		return Options{}, err
+	}
+	lockWait, err := dir.Config.GetInt("lock-wait")
+	if err != nil {
+		return Options{}, err
+	} else if lockWait < 1 {
+		return Options{}, fmt.Errorf("Option lock-wait must be at least 1; found %d", lockWait)
+	}
+	connectRetry, err := util.RetryPolicyForConfig(dir.Config)
 	if err != nil {
 		return Options{}, err
 	}
 	opts := Options{
-		CleanupAction:   CleanupActionNone,
-		SchemaName:      dir.Config.Get("temp-schema"),
-		LockWaitTimeout: 30 * time.Second,
+		CleanupAction:      CleanupActionNone,
+		SchemaName:         dir.Config.Get("temp-schema"),
+		LockWaitTimeout:    30 * time.Second,
+		LockAttemptTimeout: time.Duration(lockWait) * time.Second,
+		ConnectRetry:       connectRetry,
 	}
 	if requestedType == "docker" {
 		opts.Type = TypeLocalDocker
 		opts.Flavor = tengo.NewFlavor(dir.Config.Get("flavor"))
 		if opts.Flavor == tengo.FlavorUnknown && instance != nil {
-			opts.Flavor = instance.Flavor()
+			opts.Flavor = util.InstanceFlavor(instance)
+		}
+		opts.Image = dir.Config.Get("docker-image")
+		if opts.Image == "" {
+			opts.Image = opts.Flavor.String()
 		}
-		opts.ContainerName = fmt.Sprintf("skeema-%s", strings.Replace(opts.Flavor.String(), ":", "-", -1))
+		opts.ContainerName = containerNameForImage(opts.Image)
 		if cleanup, err := dir.Config.GetEnum("docker-cleanup", "none", "stop", "destroy"); err != nil {
 			return Options{}, err
 		} else if cleanup == "stop" {
@@ -132,11 +200,18 @@ func OptionsForDir(dir *fs.Dir, instance *tengo.Instance) (Options, error) {
 		if opts.DefaultConnParams, err = dir.InstanceDefaultParams(); err != nil {
 			return Options{}, err
 		}
+	} else if requestedType == "read-only" {
+		opts.Type = TypeReadOnly
+		opts.Instance = instance
 	} else {
 		opts.Type = TypeTempSchema
 		opts.Instance = instance
 		if !dir.Config.GetBool("reuse-temp-schema") {
 			opts.CleanupAction = CleanupActionDrop
+		} else if hasSchemaNamePlaceholder(opts.SchemaName) {
+			// Each run would leave behind a differently-named schema, which nothing
+			// would ever clean up
+			return Options{}, ConfigError("Option reuse-temp-schema cannot be used with a temp-schema value containing {pid} or {rand}")
 		}
 		// Note: no support for opts.DefaultConnParams for temp-schema because the
 		// supplied instance already has default params
@@ -144,6 +219,75 @@ func OptionsForDir(dir *fs.Dir, instance *tengo.Instance) (Options, error) {
 	return opts, nil
 }
 
+// ConfigError represents a configuration problem detected by OptionsForDir.
+type ConfigError string
+
+// Error satisfies the builtin error interface.
+func (ce ConfigError) Error() string {
+	return string(ce)
+}
+
+// hasSchemaNamePlaceholder returns true if template contains any placeholder
+// which expandSchemaName replaces with a value that varies between processes.
+func hasSchemaNamePlaceholder(template string) bool {
+	return strings.Contains(template, "{pid}") || strings.Contains(template, "{rand}")
+}
+
+// maxSchemaNameAttempts is the number of random schema names tried by
+// expandSchemaName before giving up, if all names collide with existing
+// schemas.
+const maxSchemaNameAttempts = 10
+
+// expandSchemaName returns a workspace schema name based on template, which
+// may contain placeholders: {pid} is replaced with the current process ID, and
+// {rand} is replaced with 8 random hex digits. This permits concurrent
+// processes to use distinct schema names on the same instance. If the template
+// contains {rand} and the resulting name collides with an existing schema on
+// instance, another random value is tried. An error is returned if the expanded
+// name exceeds the maximum identifier length of 64 characters.
+func expandSchemaName(instance *tengo.Instance, template string) (string, error) {
+	if !strings.Contains(template, "{") {
+		return template, nil
+	}
+	hasRand := strings.Contains(template, "{rand}")
+	for attempt := 1; ; attempt++ {
+		// Use crypto/rand rather than math/rand, since the latter produces the same
+		// sequence in every process unless seeded
+		var randBytes [4]byte
+		if hasRand {
+			if _, err := rand.Read(randBytes[:]); err != nil {
+				return "", err
+			}
+		}
+		name := strings.NewReplacer(
+			"{pid}", strconv.Itoa(os.Getpid()),
+			"{rand}", hex.EncodeToString(randBytes[:]),
+		).Replace(template)
+		if len(name) > 64 {
+			return "", fmt.Errorf("Workspace schema name %s exceeds maximum length of 64 characters", name)
+		}
+		if !hasRand {
+			return name, nil
+		}
+		if has, err := instance.HasSchema(name); err != nil {
+			return "", err
+		} else if !has {
+			return name, nil
+		} else if attempt >= maxSchemaNameAttempts {
+			return "", fmt.Errorf("Unable to generate unique workspace schema name from %s after %d attempts", template, attempt)
+		}
+	}
+}
+
+// schemaNameRegexp returns a regular expression matching any schema name that
+// could be produced by calling expandSchemaName on template.
+func schemaNameRegexp(template string) *regexp.Regexp {
+	re := regexp.QuoteMeta(template)
+	re = strings.Replace(re, regexp.QuoteMeta("{pid}"), `\d+`, -1)
+	re = strings.Replace(re, regexp.QuoteMeta("{rand}"), `[0-9a-f]{8}`, -1)
+	return regexp.MustCompile("^" + re + "$")
+}
+
 // ShutdownFunc is a function that manages final cleanup of a Workspace upon
 // completion of a request or process. It may optionally use args, passed
 // through by Shutdown(), to determine whether or not a Workspace needs to be
@@ -210,6 +354,10 @@ func (se *StatementError) String() string {
 // created) are non-fatal, and are returned in the second return value. The
 // third return value represents fatal errors only.
 func ExecLogicalSchema(logicalSchema *fs.LogicalSchema, opts Options) (schema *tengo.Schema, statementErrors []*StatementError, fatalErr error) {
+	if opts.Type == TypeReadOnly {
+		fatalErr = ReadOnlyError("Executing DDL in a workspace")
+		return
+	}
 	if logicalSchema.CharSet != "" {
 		opts.DefaultCharacterSet = logicalSchema.CharSet
 	}
@@ -300,15 +448,65 @@ func execStatement(db *sqlx.DB, statement *fs.Statement) (stmtErr *StatementErro
 // releaseFunc is a function to release a lock obtained by getLock
 type releaseFunc func()
 
-func getLock(instance *tengo.Instance, lockName string, maxWait time.Duration) (releaseFunc, error) {
-	db, err := instance.Connect("", "")
+// defaultLockAttemptTimeout is the GET_LOCK timeout used by getLock if the
+// supplied attemptTimeout is 0.
+const defaultLockAttemptTimeout = time.Second
+
+// lockRetryInterval is the minimum time between lock acquisition attempts.
+// This prevents rapid retries if an attempt fails without blocking, for example
+// due to a query error.
+var lockRetryInterval = 250 * time.Millisecond
+
+// getLock obtains a named lock on instance, waiting up to maxWait. Each
+// attempt blocks for at most attemptTimeout (rounded down to a whole number of
+// seconds, minimum 1), after which another attempt is made. Using a short
+// attemptTimeout avoids potential issues with query killers, spurious slow
+// query logging, etc. Connecting to instance is retried according to retry.
+func getLock(instance *tengo.Instance, lockName string, maxWait, attemptTimeout time.Duration, retry util.RetryPolicy) (releaseFunc, error) {
+	return getLockContext(context.Background(), instance, lockName, maxWait, attemptTimeout, retry)
+}
+
+// getLockContext behaves like getLock, but stops retrying once ctx is
+// cancelled, returning an error wrapping ctx.Err().
+func getLockContext(ctx context.Context, instance *tengo.Instance, lockName string, maxWait, attemptTimeout time.Duration, retry util.RetryPolicy) (releaseFunc, error) {
+	_, release, err := getLockConn(ctx, instance, lockName, maxWait, attemptTimeout, retry)
+	return release, err
+}
+
+// lockConn is a connection holding a named lock. The connection is also used
+// periodically by a keepalive goroutine, so its mutex must be held while using
+// it.
+type lockConn struct {
+	*sql.Conn
+	sync.Mutex
+}
+
+// discard closes the connection without returning it to the connection pool,
+// ending its session. This ensures the session cannot retain the named lock,
+// for example if GET_LOCK succeeded on the server after the client gave up on
+// it due to an error or context cancellation.
+func (lc *lockConn) discard() {
+	lc.Raw(func(driverConn interface{}) error {
+		return driver.ErrBadConn
+	})
+	lc.Close()
+}
+
+// getLockConn behaves like getLockContext, but also returns the connection
+// holding the lock. Since named locks are scoped to a session, callers may use
+// this connection to guarantee that other statements run in the same session
+// as the lock. The connection is closed once the lock is released, so it must
+// not be used after calling the releaseFunc.
+func getLockConn(ctx context.Context, instance *tengo.Instance, lockName string, maxWait, attemptTimeout time.Duration, retry util.RetryPolicy) (*lockConn, releaseFunc, error) {
+	db, err := util.ConnectWithRetry(instance, "", "", retry)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	lockConn, err := db.Conn(context.Background())
+	conn, err := db.Conn(ctx)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	lockConn := &lockConn{Conn: conn}
 
 	done := make(chan struct{})
 	release := func() {
@@ -320,13 +518,17 @@ func getLock(instance *tengo.Instance, lockName string, maxWait time.Duration) (
 		for {
 			select {
 			case <-done:
+				lockConn.Lock()
 				err := lockConn.QueryRowContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName).Scan(&result)
+				lockConn.Unlock()
 				if err != nil || result != 1 {
 					log.Warnf("%s: Failed to release lock, or lock released early due to connection being dropped: %s [%d]", instance, err, result)
 				}
 				return
 			case <-time.After(750 * time.Millisecond):
+				lockConn.Lock()
 				err := lockConn.QueryRowContext(context.Background(), "SELECT 1").Scan(&result)
+				lockConn.Unlock()
 				if err != nil {
 					log.Warnf("%s: Lock released early due to connection being dropped: %s", instance, err)
 					return
@@ -335,18 +537,65 @@ func getLock(instance *tengo.Instance, lockName string, maxWait time.Duration) (
 		}
 	}
 
-	var getLockResult int
-	start := time.Now()
-	for time.Since(start) < maxWait {
-		// Only using a timeout of 1 sec on each query to avoid potential issues with
-		// query killers, spurious slow query logging, etc
-		err := lockConn.QueryRowContext(context.Background(), "SELECT GET_LOCK(?, 1)", lockName).Scan(&getLockResult)
-		if err == nil && getLockResult == 1 {
-			// Launch a goroutine to keep the connection active, and release the lock
-			// once the ReleaseFunc is called
-			go connMaintainer()
-			return release, nil
+	tryLock := func(ctx context.Context, timeoutSecs int) bool {
+		var getLockResult int
+		err := lockConn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, timeoutSecs).Scan(&getLockResult)
+		return err == nil && getLockResult == 1
+	}
+	if acquireLock(ctx, tryLock, maxWait, attemptTimeout) {
+		// Launch a goroutine to keep the connection active, and release the lock
+		// once the ReleaseFunc is called
+		go connMaintainer()
+		return lockConn, release, nil
+	}
+	lockConn.discard()
+	if ctx.Err() != nil {
+		return nil, nil, fmt.Errorf("Gave up waiting for lock: %w", ctx.Err())
+	}
+	return nil, nil, lockTimeoutError(instance, lockName)
+}
+
+// lockTimeoutError returns an error for failing to acquire the named lock on
+// instance, describing the connections holding or waiting on the lock if this
+// information is available.
+func lockTimeoutError(instance *tengo.Instance, lockName string) error {
+	holders, err := util.LockHolders(instance, lockName)
+	if desc := util.DescribeLockHolders(holders); desc != "" {
+		return fmt.Errorf("Unable to acquire lock %s: %s", lockName, desc)
+	} else if err != nil && err != util.ErrLockWaitersUnavailable {
+		log.Debugf("%s: Unable to determine holder of lock %s: %s", instance, lockName, err)
+	}
+	return fmt.Errorf("Unable to acquire lock %s", lockName)
+}
+
+// acquireLock repeatedly calls tryLock until it returns true, maxWait elapses,
+// or ctx is cancelled. tryLock is supplied the number of seconds it may block
+// for, based on attemptTimeout and the remaining portion of maxWait. Attempts
+// are made no more frequently than lockRetryInterval.
+func acquireLock(ctx context.Context, tryLock func(context.Context, int) bool, maxWait, attemptTimeout time.Duration) bool {
+	if attemptTimeout <= 0 {
+		attemptTimeout = defaultLockAttemptTimeout
+	}
+	deadline := time.Now().Add(maxWait)
+	for remaining := maxWait; remaining > 0 && ctx.Err() == nil; remaining = time.Until(deadline) {
+		timeout := attemptTimeout
+		if timeout > remaining {
+			timeout = remaining
+		}
+		timeoutSecs := int(timeout / time.Second)
+		if timeoutSecs < 1 {
+			timeoutSecs = 1
+		}
+		attemptStart := time.Now()
+		if tryLock(ctx, timeoutSecs) {
+			return true
+		}
+		if wait := lockRetryInterval - time.Since(attemptStart); wait > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(wait):
+			}
 		}
 	}
-	return nil, errors.New("Unable to acquire lock")
+	return false
 }
@@ -1,8 +1,11 @@
 package workspace
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -21,6 +24,169 @@ func TestMain(m *testing.M) {
 	os.Exit(m.Run())
 }
 
+func TestAcquireLock(t *testing.T) {
+	origInterval := lockRetryInterval
+	lockRetryInterval = 50 * time.Millisecond
+	defer func() {
+		lockRetryInterval = origInterval
+	}()
+
+	// Simulate a contended lock where each attempt fails immediately: the number
+	// of attempts should be bounded by maxWait / lockRetryInterval
+	var attempts int
+	var maxTimeoutSecs int
+	contended := func(ctx context.Context, timeoutSecs int) bool {
+		attempts++
+		if timeoutSecs > maxTimeoutSecs {
+			maxTimeoutSecs = timeoutSecs
+		}
+		return false
+	}
+	if acquireLock(context.Background(), contended, 500*time.Millisecond, 5*time.Second) {
+		t.Error("Expected acquireLock to fail with contended lock, but it succeeded")
+	}
+	if attempts < 2 || attempts > 11 {
+		t.Errorf("Expected between 2 and 11 attempts, instead found %d", attempts)
+	}
+	if maxTimeoutSecs != 1 {
+		t.Errorf("Expected attempt timeout to be limited by remaining wait time, instead found %d", maxTimeoutSecs)
+	}
+
+	// Default attempt timeout should be used if none supplied, and a longer
+	// attempt timeout should be used when less than the remaining wait time
+	attempts, maxTimeoutSecs = 0, 0
+	acquireLock(context.Background(), contended, 100*time.Millisecond, 0)
+	if attempts < 1 || maxTimeoutSecs != 1 {
+		t.Errorf("Unexpected attempts=%d maxTimeoutSecs=%d with default attempt timeout", attempts, maxTimeoutSecs)
+	}
+	var timeoutSecs int
+	acquireLock(context.Background(), func(ctx context.Context, secs int) bool {
+		timeoutSecs = secs
+		return true
+	}, time.Minute, 5*time.Second)
+	if timeoutSecs != 5 {
+		t.Errorf("Expected attempt timeout of 5 seconds, instead found %d", timeoutSecs)
+	}
+
+	// Lock becomes available after a few attempts
+	attempts = 0
+	eventually := func(ctx context.Context, timeoutSecs int) bool {
+		attempts++
+		return attempts >= 3
+	}
+	if !acquireLock(context.Background(), eventually, time.Minute, time.Second) || attempts != 3 {
+		t.Errorf("Expected acquireLock to succeed on third attempt; attempts=%d", attempts)
+	}
+
+	// Cancelled context should stop retrying
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	attempts = 0
+	if acquireLock(ctx, contended, time.Minute, time.Second) || attempts != 0 {
+		t.Errorf("Expected acquireLock to fail without attempts after cancellation; attempts=%d", attempts)
+	}
+}
+
+func TestExpandSchemaName(t *testing.T) {
+	pid := strconv.Itoa(os.Getpid())
+	cases := map[string]string{
+		"_skeema_tmp":           "_skeema_tmp",
+		"_skeema_tmp_{pid}":     "_skeema_tmp_" + pid,
+		"{pid}_x_{pid}":         pid + "_x_" + pid,
+		"_skeema_{unknown}":     "_skeema_{unknown}",
+		strings.Repeat("a", 64): strings.Repeat("a", 64),
+	}
+	for template, expected := range cases {
+		// No instance needed since none of these templates contain {rand}
+		if actual, err := expandSchemaName(nil, template); err != nil || actual != expected {
+			t.Errorf("Expected expandSchemaName(%q) to return %q, instead found %q, %v", template, expected, actual, err)
+		}
+		if re := schemaNameRegexp(template); !re.MatchString(expected) {
+			t.Errorf("Expected schemaNameRegexp(%q) to match %q, but it did not", template, expected)
+		}
+	}
+	if actual, err := expandSchemaName(nil, strings.Repeat("a", 64)+"{pid}"); err == nil {
+		t.Errorf("Expected error from overly-long name, instead found %q", actual)
+	}
+
+	re := schemaNameRegexp("_skeema_tmp_{pid}_{rand}")
+	matches := map[string]bool{
+		"_skeema_tmp_123_0123abcd":  true,
+		"_skeema_tmp_1_ffffffff":    true,
+		"_skeema_tmp_123_0123abc":   false,
+		"_skeema_tmp__0123abcd":     false,
+		"_skeema_tmp_123_0123abcdx": false,
+		"x_skeema_tmp_1_ffffffff":   false,
+		"_skeema_tmp":               false,
+	}
+	for name, expected := range matches {
+		if actual := re.MatchString(name); actual != expected {
+			t.Errorf("Expected match of %q to return %t, instead found %t", name, expected, actual)
+		}
+	}
+}
+
+func TestOptionsForDirSchemaNamePlaceholders(t *testing.T) {
+	cmd := mybase.NewCommand("workspacetest", "", "", nil)
+	util.AddGlobalOptions(cmd)
+	cmd.AddArg("environment", "production", false)
+	getOpts := func(cliFlags string) (Options, error) {
+		t.Helper()
+		cfg := mybase.ParseFakeCLI(t, cmd, "workspacetest --host=127.0.0.1 "+cliFlags)
+		dir, err := fs.ParseDir("../testdata/golden/init/mydb/product", cfg)
+		if err != nil {
+			t.Fatalf("Unexpectedly cannot parse working dir: %s", err)
+		}
+		return OptionsForDir(dir, nil)
+	}
+
+	for _, template := range []string{"_skeema_tmp_{pid}", "_skeema_tmp_{rand}", "{pid}_{rand}"} {
+		if _, err := getOpts("--temp-schema=" + template + " --reuse-temp-schema"); err == nil {
+			t.Errorf("Expected error from OptionsForDir with temp-schema=%s and reuse-temp-schema, but err was nil", template)
+		} else if _, ok := err.(ConfigError); !ok {
+			t.Errorf("Expected error to be a ConfigError, but instead type is %T", err)
+		}
+		if opts, err := getOpts("--temp-schema=" + template); err != nil || opts.CleanupAction != CleanupActionDrop {
+			t.Errorf("Unexpected return from OptionsForDir with temp-schema=%s: %+v, %v", template, opts, err)
+		}
+	}
+	if opts, err := getOpts("--temp-schema=_skeema_{unknown} --reuse-temp-schema"); err != nil || opts.CleanupAction != CleanupActionNone {
+		t.Errorf("Unexpected return from OptionsForDir: %+v, %v", opts, err)
+	}
+
+	// Placeholders are permitted with reuse-temp-schema when not using a
+	// temp-schema workspace, since the option has no effect there
+	if _, err := getOpts("--workspace=read-only --temp-schema=_skeema_tmp_{pid} --reuse-temp-schema"); err != nil {
+		t.Errorf("Unexpected error from OptionsForDir with read-only workspace: %v", err)
+	}
+}
+
+// recordingObserver is an Observer which records phase names.
+type recordingObserver struct {
+	phases []string
+}
+
+func (ro *recordingObserver) OnPhase(name string, d time.Duration) {
+	ro.phases = append(ro.phases, name)
+}
+
+func TestPhaseTimer(t *testing.T) {
+	// Nil observer: nothing should happen, and start time should not be recorded
+	timer := startPhase(nil)
+	if !timer.start.IsZero() {
+		t.Error("Expected phaseTimer with nil observer to not record start time")
+	}
+	timer.end(PhaseCreate)
+
+	observer := &recordingObserver{}
+	timer = startPhase(observer)
+	timer.end(PhaseLockAcquire)
+	startPhase(observer).end(PhaseDrop)
+	if expected := []string{PhaseLockAcquire, PhaseDrop}; !reflect.DeepEqual(observer.phases, expected) {
+		t.Errorf("Expected phases %v, instead found %v", expected, observer.phases)
+	}
+}
+
 func TestIntegration(t *testing.T) {
 	images := tengo.SplitEnv("SKEEMA_TEST_IMAGES")
 	if len(images) == 0 {
@@ -152,6 +318,9 @@ func (s WorkspaceIntegrationSuite) TestOptionsForDir(t *testing.T) {
 	assertOptsError("--workspace=invalid")
 	assertOptsError("--workspace=docker --docker-cleanup=invalid")
 	assertOptsError("--workspace=docker --connect-options='autocommit=0'")
+	assertOptsError("--lock-wait=0")
+	assertOptsError("--lock-wait=abc")
+	assertOptsError("--temp-schema=_skeema_tmp_{pid} --reuse-temp-schema")
 
 	// Test default configuration, which should use temp-schema with drop cleanup
 	if opts := getOpts(""); opts.Type != TypeTempSchema || opts.CleanupAction != CleanupActionDrop {
@@ -180,7 +349,18 @@ func (s WorkspaceIntegrationSuite) TestOptionsForDir(t *testing.T) {
 	}
 
 	// Test docker with specific flavor
-	if opts = getOpts("--workspace=docker --flavor=mysql:5.5"); opts.Flavor.String() != "mysql:5.5" {
+	if opts = getOpts("--workspace=docker --flavor=mysql:5.5"); opts.Flavor.String() != "mysql:5.5" || opts.Image != "mysql:5.5" || opts.ContainerName != "skeema-mysql-5.5" {
+		t.Errorf("Unexpected return from OptionsForDir: %+v", opts)
+	}
+
+	// Test docker with specific image
+	opts = getOpts("--workspace=docker --flavor=mysql:5.7 --docker-image=example.com/mysql:5.7.30")
+	if opts.Flavor.String() != "mysql:5.7" || opts.Image != "example.com/mysql:5.7.30" || opts.ContainerName != "skeema-example.com-mysql-5.7.30" {
+		t.Errorf("Unexpected return from OptionsForDir: %+v", opts)
+	}
+
+	// Test read-only, which uses the supplied instance
+	if opts = getOpts("--workspace=read-only"); opts.Type != TypeReadOnly || opts.Instance != s.d.Instance {
 		t.Errorf("Unexpected return from OptionsForDir: %+v", opts)
 	}
 }